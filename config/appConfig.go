@@ -8,9 +8,12 @@ import (
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"io/ioutil"
 	"log"
 	"my-todo-app/domain"
 	"os"
+	"strings"
+	"time"
 )
 
 var (
@@ -18,6 +21,22 @@ var (
 	AppLogger          *zap.Logger
 	SqlDriver          string
 	DataSourceName     string
+	SqlAutoMigrate     bool
+	DeleteParentPolicy string
+	MaxBatchSize       int
+	TaskWorkflow       domain.Workflow
+	PublicRoutes       []string
+	DefaultRole        string
+	JwtKeys            domain.JwtKeys
+	TenantHeader       string
+	TenantBaseDomain   string
+	IdempotencyTtl     time.Duration
+	TrashRetention     time.Duration
+	TrashPurgeInterval time.Duration
+	AttachmentStore    string
+	AttachmentLocation string
+	AttachmentMaxSize  int64
+	AttachmentTypes    []string
 	fiberLogFormat     string
 	fiberLogTimeFormat string
 	corsAllowOrigins   string
@@ -40,6 +59,22 @@ func init() {
 		Port = viper.GetString(domain.AppServerPort)
 		SqlDriver = viper.GetString(domain.SqlDriver)
 		DataSourceName = viper.GetString(domain.SqlDatabaseName)
+		SqlAutoMigrate = viper.GetBool(domain.SqlAutoMigrate)
+		DeleteParentPolicy = viper.GetString(domain.DeleteParentPolicy)
+		MaxBatchSize = viper.GetInt(domain.MaxBatchSize)
+		TaskWorkflow = getWorkflow()
+		PublicRoutes = viper.GetStringSlice(domain.AuthPublicRoutes)
+		DefaultRole = getDefaultRole()
+		JwtKeys = getJwtKeys()
+		TenantHeader = viper.GetString(domain.TenantHeader)
+		TenantBaseDomain = strings.ToLower(strings.Trim(viper.GetString(domain.TenantBaseDomain), "."))
+		IdempotencyTtl = viper.GetDuration(domain.IdempotencyTtl)
+		TrashRetention = viper.GetDuration(domain.TrashRetention)
+		TrashPurgeInterval = viper.GetDuration(domain.TrashPurgeInterval)
+		AttachmentStore = viper.GetString(domain.AttachmentStore)
+		AttachmentLocation = viper.GetString(domain.AttachmentLocation)
+		AttachmentMaxSize = viper.GetInt64(domain.AttachmentMaxSize)
+		AttachmentTypes = viper.GetStringSlice(domain.AttachmentTypes)
 		fiberLogFormat = viper.GetString(domain.FiberLogFormat)
 		fiberLogTimeFormat = viper.GetString(domain.FiberLogTimeFormat)
 		corsAllowOrigins = viper.GetString(domain.CorsAllowedOrigin)
@@ -51,6 +86,52 @@ func init() {
 	}
 }
 
+func getWorkflow() domain.Workflow {
+	workflow := domain.Workflow{
+		Statuses:    viper.GetStringSlice(domain.TaskStatuses),
+		Transitions: viper.GetStringMapStringSlice(domain.TaskTransitions),
+		Actions:     viper.GetStringMapString(domain.TaskActions),
+		Completed:   domain.NormalizeStatus(viper.GetString(domain.TaskCompletedStatus)),
+	}
+	if err := workflow.Validate(); err != nil {
+		log.Panic(fmt.Sprintf("Invalid task workflow in config, program will exit now. Error: %s", err.Error()))
+	}
+	return workflow
+}
+
+func getDefaultRole() string {
+	role := viper.GetString(domain.AuthDefaultRole)
+	if !domain.IsRole(role) {
+		log.Panic(fmt.Sprintf("Invalid default role in config, program will exit now. Got: %q", role))
+	}
+	return role
+}
+
+// getJwtKeys collects the keys verifying bearer tokens, from config and from
+// the JWKS file it points to.
+func getJwtKeys() domain.JwtKeys {
+	keys := domain.NewJwtKeys(viper.GetString(domain.JwtIssuer), viper.GetString(domain.JwtAudience))
+	keys.TenantClaim = viper.GetString(domain.JwtTenantClaim)
+	if secret := viper.GetString(domain.JwtHmacSecret); secret != "" {
+		keys.Hmac[""] = []byte(secret)
+	}
+
+	var err error
+	if pem := viper.GetString(domain.JwtRsaPublicKey); pem != "" {
+		err = keys.AddRsaPem("", []byte(pem))
+	}
+	if file := viper.GetString(domain.JwtJwksFile); err == nil && file != "" {
+		var data []byte
+		if data, err = ioutil.ReadFile(file); err == nil {
+			err = keys.AddJwks(data)
+		}
+	}
+	if err != nil {
+		log.Panic(fmt.Sprintf("Invalid JWT keys in config, program will exit now. Error: %s", err.Error()))
+	}
+	return keys
+}
+
 func getLogger(filepath string) *zap.Logger {
 	file := getFile(filepath)
 	return zap.New(
@@ -77,6 +158,8 @@ func GetCors() fiber.Handler {
 		cors.Config{
 			AllowHeaders: corsAllowHeaders,
 			AllowOrigins: corsAllowOrigins,
+			ExposeHeaders: strings.Join([]string{fiber.HeaderETag, "Idempotent-Replayed", fiber.HeaderContentRange,
+				fiber.HeaderAcceptRanges, fiber.HeaderContentDisposition}, ", "),
 		})
 }
 
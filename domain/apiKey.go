@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+const (
+	// ScopeReadOnly keys may only read, ScopeReadWrite keys may also change tasks.
+	ScopeReadOnly  = "read-only"
+	ScopeReadWrite = "read-write"
+
+	apiKeyPrefix = "todo_"
+	// apiKeyDisplayLength is how much of a key is kept in clear to recognize it by.
+	apiKeyDisplayLength = 12
+)
+
+// ApiKey lets scripts act as the user owning it. Only a hash of the key is
+// stored, the key itself is shown once on creation.
+type ApiKey struct {
+	Id         int64  `json:"id"`
+	UserId     int64  `json:"-"`
+	TenantId   string `json:"-"`
+	Name       string `json:"name"`
+	Prefix     string `json:"prefix"`
+	KeyHash    string `json:"-"`
+	Scope      string `json:"scope"`
+	AddedOn    int64  `json:"added_on"`
+	LastUsedOn int64  `json:"last_used_on"`
+	// Key is only set on the response creating the key
+	Key string `json:"key,omitempty"`
+}
+
+// NewApiKey generates a random key for userId.
+func NewApiKey(userId int64, name string, scope string, addedOn int64) (ApiKey, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return ApiKey{}, err
+	}
+
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	return ApiKey{
+		UserId:  userId,
+		Name:    name,
+		Prefix:  key[:apiKeyDisplayLength],
+		KeyHash: HashApiKey(key),
+		Scope:   scope,
+		AddedOn: addedOn,
+		Key:     key,
+	}, nil
+}
+
+// HashApiKey hashes a key for storage and lookup. Keys are long and random,
+// so a fast hash is enough.
+func HashApiKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+// IsScope reports whether scope can be given to an API key.
+func IsScope(scope string) bool {
+	return scope == ScopeReadOnly || scope == ScopeReadWrite
+}
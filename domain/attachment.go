@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"mime"
+	"strings"
+)
+
+// Attachment describes a file uploaded to a task. The file itself is kept in
+// a blob store under StorageKey.
+type Attachment struct {
+	Id          int64  `json:"id"`
+	TaskId      int64  `json:"task_id"`
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	UploaderId  int64  `json:"uploader_id"`
+	Uploader    string `json:"uploader"`
+	AddedOn     int64  `json:"added_on"`
+	// StorageKey and TenantId never leave the server
+	StorageKey string `json:"-"`
+	TenantId   string `json:"-"`
+}
+
+func (a *Attachment) GetId() int64 {
+	return a.Id
+}
+
+func (a *Attachment) GetTaskId() int64 {
+	return a.TaskId
+}
+
+func (a *Attachment) GetName() string {
+	return a.Name
+}
+
+func (a *Attachment) GetContentType() string {
+	return a.ContentType
+}
+
+func (a *Attachment) GetSize() int64 {
+	return a.Size
+}
+
+func (a *Attachment) GetAddedOn() int64 {
+	return a.AddedOn
+}
+
+func (a *Attachment) GetStorageKey() string {
+	return a.StorageKey
+}
+
+// IsAllowedType reports whether the media type of contentType, parameters
+// aside, is one of allowed, where "image/*" allows every image type.
+func IsAllowedType(contentType string, allowed []string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, pattern := range allowed {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == mediaType || strings.HasSuffix(pattern, "/*") && strings.HasPrefix(mediaType, pattern[:len(pattern)-1]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package domain
+
+import "encoding/json"
+
+// Operations of a batch, each applied like the single task route it is named after.
+const (
+	BatchCreate = "create"
+	BatchUpdate = "update"
+	BatchDelete = "delete"
+)
+
+// BatchRequest is the body of POST /tasks/batch. An atomic batch stores all
+// of its operations or none of them, otherwise each operation stands alone.
+type BatchRequest struct {
+	Atomic     bool             `json:"atomic"`
+	Operations []BatchOperation `json:"operations"`
+}
+
+// BatchOperation creates Task, or updates or deletes the task with Id. Like
+// the If-Match header of the single routes, IfMatch holds the ETag an update
+// or delete is based on.
+type BatchOperation struct {
+	Op      string          `json:"op"`
+	Id      int64           `json:"id"`
+	IfMatch string          `json:"if_match"`
+	Task    json.RawMessage `json:"task"`
+}
+
+// BatchResult tells how one operation went, with the status code and the
+// task the single route would have answered.
+type BatchResult struct {
+	Op     string `json:"op"`
+	Id     int64  `json:"id,omitempty"`
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+	ETag   string `json:"etag,omitempty"`
+	Task   *Task  `json:"task,omitempty"`
+}
+
+// BatchResponse holds a result for every operation of a batch, in order.
+type BatchResponse struct {
+	Atomic  bool          `json:"atomic"`
+	Results []BatchResult `json:"results"`
+}
+
+// TaskWrite is a checked operation of a batch, ready to be stored: Task is
+// created, or it replaces the task with its id, or the task with its id is
+// deleted. Updates and deletes only apply at the version of Task, any
+// version when 0, and deletes only take subtasks along with Cascade. Next is the next occurrence of a recurring task an update
+// completes, created along with it. Revision is recorded for every task the
+// write changes, the next occurrence recording its creation.
+type TaskWrite struct {
+	Op       string
+	Task     Task
+	Next     *Task
+	Cascade  bool
+	Revision TaskRevision
+}
+
+// TaskWriteResult is what storing a TaskWrite did: the id of the task and of
+// its next occurrence, whether it was applied and the error that stopped it,
+// if any.
+type TaskWriteResult struct {
+	Id      int64
+	NextId  int64
+	Applied bool
+	Err     error
+}
@@ -0,0 +1,39 @@
+package domain
+
+// Comment is a message left on a task by one of the users who can see it.
+// Only its author edits or deletes it.
+type Comment struct {
+	Id        int64  `json:"id"`
+	TaskId    int64  `json:"task_id"`
+	AuthorId  int64  `json:"author_id"`
+	Author    string `json:"author"`
+	Body      string `json:"body"`
+	AddedOn   int64  `json:"added_on"`
+	UpdatedOn int64  `json:"updated_on"`
+	// TenantId is always taken from the caller and never leaves the server
+	TenantId string `json:"-"`
+}
+
+func (c *Comment) GetId() int64 {
+	return c.Id
+}
+
+func (c *Comment) GetTaskId() int64 {
+	return c.TaskId
+}
+
+func (c *Comment) GetAuthorId() int64 {
+	return c.AuthorId
+}
+
+func (c *Comment) GetBody() string {
+	return c.Body
+}
+
+func (c *Comment) GetAddedOn() int64 {
+	return c.AddedOn
+}
+
+func (c *Comment) GetUpdatedOn() int64 {
+	return c.UpdatedOn
+}
@@ -10,8 +10,44 @@ const (
 	CorsAllowedHeaders   = "app.cors.allowHeaders"
 	SqlDriver            = "sql.driver"
 	SqlDatabaseName      = "sql.database.name"
+	SqlAutoMigrate       = "sql.autoMigrate"
+	DeleteParentPolicy   = "app.tasks.deleteParentPolicy"
+	MaxBatchSize         = "app.tasks.maxBatchSize"
+	TaskStatuses         = "app.tasks.statuses"
+	TaskTransitions      = "app.tasks.transitions"
+	TaskActions          = "app.tasks.actions"
+	TaskCompletedStatus  = "app.tasks.completedStatus"
+	AuthPublicRoutes     = "app.auth.publicRoutes"
+	AuthDefaultRole      = "app.auth.defaultRole"
+	JwtHmacSecret        = "app.auth.jwt.hmacSecret"
+	JwtRsaPublicKey      = "app.auth.jwt.rsaPublicKey"
+	JwtJwksFile          = "app.auth.jwt.jwksFile"
+	JwtIssuer            = "app.auth.jwt.issuer"
+	JwtAudience          = "app.auth.jwt.audience"
+	JwtTenantClaim       = "app.auth.jwt.tenantClaim"
+	TenantHeader         = "app.tenancy.header"
+	TenantBaseDomain     = "app.tenancy.baseDomain"
+	IdempotencyTtl       = "app.idempotency.ttl"
+	TrashRetention       = "app.trash.retention"
+	TrashPurgeInterval   = "app.trash.purgeInterval"
+	AttachmentStore      = "app.attachments.store"
+	AttachmentLocation   = "app.attachments.location"
+	AttachmentMaxSize    = "app.attachments.maxSize"
+	AttachmentTypes      = "app.attachments.allowedTypes"
 )
 
+const (
+	// DefaultPriority is given to tasks created without a priority, P0 is the most urgent.
+	DefaultPriority = "P2"
+
+	// DeleteParentCascade deletes every subtask together with its parent,
+	// DeleteParentReject refuses to delete a task that still has subtasks.
+	DeleteParentCascade = "cascade"
+	DeleteParentReject  = "reject"
+)
+
+var Priorities = []string{"P0", "P1", "P2", "P3"}
+
 var SupportedSearchParams = map[string]string{
 	"page":        "0",
 	"perPage":     "10",
@@ -21,4 +57,11 @@ var SupportedSearchParams = map[string]string{
 	"addedOnTo":   "9999999999999",
 	"id":          "",
 	"status":      "",
+	"tag":         "",
+	"tagMatch":    "any",
+	"parentId":    "",
+	"listId":      "",
+	"priority":    "",
+	"blocked":     "",
+	"sort":        "",
 }
@@ -0,0 +1,23 @@
+package domain
+
+// Dependency records that the task with TaskId is blocked by the task with
+// BlockedById, which has to be done before the blocked task can be.
+type Dependency struct {
+	TaskId      int64 `json:"task_id"`
+	BlockedById int64 `json:"blocked_by"`
+	AddedOn     int64 `json:"added_on"`
+	// TenantId is always taken from the caller and never leaves the server
+	TenantId string `json:"-"`
+}
+
+func (d *Dependency) GetTaskId() int64 {
+	return d.TaskId
+}
+
+func (d *Dependency) GetBlockedById() int64 {
+	return d.BlockedById
+}
+
+func (d *Dependency) GetAddedOn() int64 {
+	return d.AddedOn
+}
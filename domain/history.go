@@ -0,0 +1,56 @@
+package domain
+
+// Actions recorded in the history of a task.
+const (
+	HistoryCreate     = "create"
+	HistoryUpdate     = "update"
+	HistoryTransition = "transition"
+	HistoryDelete     = "delete"
+	HistoryRestore    = "restore"
+	HistoryRevert     = "revert"
+)
+
+// TaskRevision records one change of a task: who made it, when, and how its
+// fields changed. Version is the version of the task the change resulted in,
+// and Task the task as it was then, which a revert goes back to.
+type TaskRevision struct {
+	TaskId    int64         `json:"task_id"`
+	TenantId  string        `json:"-"`
+	Version   int64         `json:"version"`
+	Action    string        `json:"action"`
+	ActorId   int64         `json:"actor_id"`
+	Actor     string        `json:"actor"`
+	ChangedOn int64         `json:"changed_on"`
+	Changes   []FieldChange `json:"changes"`
+	// RevertedTo is the version a revert went back to
+	RevertedTo int64 `json:"reverted_to,omitempty"`
+	Task       Task  `json:"-"`
+}
+
+// FieldChange is the value of one of the TaskFields before and after a change.
+type FieldChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// DiffTasks lists how every changed field of TaskFields went from before to
+// after. Creating a task is a diff from the empty Task.
+func DiffTasks(before Task, after Task) []FieldChange {
+	changes := []FieldChange{}
+	for _, field := range ChangedFields(before, after) {
+		changes = append(changes, FieldChange{Field: field, Before: getValue(before, field), After: getValue(after, field)})
+	}
+	return changes
+}
+
+// getValue is the value of field as the json of a task shows it.
+func getValue(task Task, field string) interface{} {
+	if field == "tags" {
+		if task.GetTags() == nil {
+			return []string{}
+		}
+		return task.GetTags()
+	}
+	return getField(task, field)
+}
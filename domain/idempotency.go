@@ -0,0 +1,34 @@
+package domain
+
+// MaxIdempotencyKeyLength is the longest Idempotency-Key header accepted.
+const MaxIdempotencyKeyLength = 255
+
+// IdempotencyRecord is the response to a request sent with an
+// Idempotency-Key, replayed to the retries of that request until ExpiresOn.
+// A StatusCode of 0 means the first request is still being handled.
+type IdempotencyRecord struct {
+	Key         string
+	UserId      int64
+	TenantId    string
+	RequestHash string
+	StatusCode  int
+	ContentType string
+	ETag        string
+	Body        string
+	AddedOn     int64
+	ExpiresOn   int64
+}
+
+// IsIdempotencyKey reports whether key is 1 to MaxIdempotencyKeyLength
+// printable ASCII characters.
+func IsIdempotencyKey(key string) bool {
+	if len(key) == 0 || len(key) > MaxIdempotencyKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < ' ' || key[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
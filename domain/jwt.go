@@ -0,0 +1,142 @@
+package domain
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt"
+	"math/big"
+	"time"
+)
+
+// JwtKeys verifies bearer tokens. HMAC keys check HS256 signatures and RSA
+// keys RS256 ones, both looked up by the kid header of a token. Keys taken
+// straight from config have an empty kid and verify tokens without one.
+type JwtKeys struct {
+	Hmac     map[string][]byte
+	Rsa      map[string]*rsa.PublicKey
+	Issuer   string
+	Audience string
+	// TenantClaim names the claim holding the tenant of a token, empty to ignore tenants
+	TenantClaim string
+}
+
+// NewJwtKeys returns a key set without keys, verifying tokens from issuer
+// for audience; either may be empty to skip that check.
+func NewJwtKeys(issuer string, audience string) JwtKeys {
+	return JwtKeys{
+		Hmac:     map[string][]byte{},
+		Rsa:      map[string]*rsa.PublicKey{},
+		Issuer:   issuer,
+		Audience: audience,
+	}
+}
+
+// IsEmpty reports whether k can not verify any token.
+func (k JwtKeys) IsEmpty() bool {
+	return len(k.Hmac) == 0 && len(k.Rsa) == 0
+}
+
+// AddRsaPem adds a PEM encoded RSA public key under kid.
+func (k JwtKeys) AddRsaPem(kid string, pem []byte) error {
+	key, err := jwt.ParseRSAPublicKeyFromPEM(pem)
+	if err == nil {
+		k.Rsa[kid] = key
+	}
+	return err
+}
+
+// AddJwks adds the RSA and symmetric signing keys of a JSON Web Key Set
+// (RFC 7517). Keys of other types, or meant for encryption, are skipped.
+func (k JwtKeys) AddJwks(data []byte) error {
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			K   string `json:"k"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return err
+	}
+
+	for _, key := range set.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		switch key.Kty {
+		case "RSA":
+			n, err := base64.RawURLEncoding.DecodeString(key.N)
+			if err != nil {
+				return fmt.Errorf("invalid modulus of key %q: %s", key.Kid, err)
+			}
+			e, err := base64.RawURLEncoding.DecodeString(key.E)
+			if err != nil || len(e) == 0 || len(e) > 4 {
+				return fmt.Errorf("invalid exponent of key %q", key.Kid)
+			}
+			k.Rsa[key.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "oct":
+			secret, err := base64.RawURLEncoding.DecodeString(key.K)
+			if err != nil || len(secret) == 0 {
+				return fmt.Errorf("invalid secret of key %q", key.Kid)
+			}
+			k.Hmac[key.Kid] = secret
+		}
+	}
+	return nil
+}
+
+// Verify checks the signature of token along with its exp, nbf, iss and aud
+// claims, and returns its subject and tenant. Tokens must expire, the tenant
+// is empty when the token does not name one.
+func (k JwtKeys) Verify(token string) (string, string, error) {
+	parser := jwt.Parser{ValidMethods: []string{jwt.SigningMethodHS256.Alg(), jwt.SigningMethodRS256.Alg()}}
+	parsed, err := parser.Parse(token, k.getKey)
+	if err != nil {
+		return "", "", err
+	}
+
+	claims, _ := parsed.Claims.(jwt.MapClaims)
+	switch {
+	case !claims.VerifyExpiresAt(time.Now().Unix(), true):
+		return "", "", errors.New("token does not expire")
+	case k.Issuer != "" && !claims.VerifyIssuer(k.Issuer, true):
+		return "", "", fmt.Errorf("token is not issued by %s", k.Issuer)
+	case k.Audience != "" && !claims.VerifyAudience(k.Audience, true):
+		return "", "", fmt.Errorf("token is not meant for %s", k.Audience)
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return "", "", errors.New("token has no subject")
+	}
+
+	var tenant string
+	if k.TenantClaim != "" && claims[k.TenantClaim] != nil {
+		tenant, _ = claims[k.TenantClaim].(string)
+		if !IsTenantId(tenant) {
+			return "", "", fmt.Errorf("token has an invalid %s claim", k.TenantClaim)
+		}
+	}
+	return subject, tenant, nil
+}
+
+func (k JwtKeys) getKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	switch token.Method {
+	case jwt.SigningMethodHS256:
+		if key, found := k.Hmac[kid]; found {
+			return key, nil
+		}
+	case jwt.SigningMethodRS256:
+		if key, found := k.Rsa[kid]; found {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("no %s key with kid %q", token.Method.Alg(), kid)
+}
@@ -0,0 +1,33 @@
+package domain
+
+// List groups tasks under a name, such as "Sprint 12" or "Home". Its owner
+// shares it by adding members, who then see and edit every task in it.
+type List struct {
+	Id      int64  `json:"id"`
+	Name    string `json:"name"`
+	OwnerId int64  `json:"owner_id"`
+	AddedOn int64  `json:"added_on"`
+	// TenantId is always taken from the caller and never leaves the server
+	TenantId string `json:"-"`
+	// Members is only filled in when a single list is read
+	Members []ListMember `json:"members,omitempty"`
+}
+
+// ListMember is a user a list is shared with; the owner is a member too.
+type ListMember struct {
+	UserId   int64  `json:"user_id"`
+	Username string `json:"username"`
+	AddedOn  int64  `json:"added_on"`
+}
+
+func (l *List) GetId() int64 {
+	return l.Id
+}
+
+func (l *List) GetName() string {
+	return l.Name
+}
+
+func (l *List) GetOwnerId() int64 {
+	return l.OwnerId
+}
@@ -0,0 +1,113 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+)
+
+// TaskFields are the json names of the stored fields of a task, the ones a
+// patch can change.
+var TaskFields = []string{"title", "description", "added_on", "due_by", "status", "tags", "parent_id", "recurrence",
+	"priority", "list_id"}
+
+// MergePatch applies the RFC 7396 JSON merge patch patch to the JSON document
+// target: members of patch replace the ones of target, null members remove
+// them, objects are merged recursively and anything else replaces target.
+func MergePatch(target []byte, patch []byte) ([]byte, error) {
+	targetValue, err := decodeJson(target)
+	if err != nil {
+		return nil, err
+	}
+	patchValue, err := decodeJson(patch)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(mergeValue(targetValue, patchValue))
+}
+
+// IsMergePatchObject reports whether patch is a JSON object, the only kind of
+// merge patch that changes a task field by field.
+func IsMergePatchObject(patch []byte) bool {
+	value, err := decodeJson(patch)
+	_, isObject := value.(map[string]interface{})
+	return err == nil && isObject
+}
+
+// ChangedFields lists the TaskFields that differ between before and after.
+// Tags are compared as sets of names, nil and empty alike.
+func ChangedFields(before Task, after Task) []string {
+	var fields []string
+	for _, field := range TaskFields {
+		if !reflect.DeepEqual(getField(before, field), getField(after, field)) {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+func getField(task Task, field string) interface{} {
+	switch field {
+	case "title":
+		return task.GetTitle()
+	case "description":
+		return task.GetDescription()
+	case "added_on":
+		return task.GetAddedOn()
+	case "due_by":
+		return task.GetDueBy()
+	case "status":
+		return task.GetStatus()
+	case "tags":
+		tags := map[string]bool{}
+		for _, tag := range task.GetTags() {
+			tags[tag] = true
+		}
+		return tags
+	case "parent_id":
+		return task.GetParentId()
+	case "recurrence":
+		return task.GetRecurrence()
+	case "priority":
+		return task.GetPriority()
+	case "list_id":
+		return task.GetListId()
+	}
+	return nil
+}
+
+func mergeValue(target interface{}, patch interface{}) interface{} {
+	patchObject, isObject := patch.(map[string]interface{})
+	if !isObject {
+		return patch
+	}
+
+	targetObject, isObject := target.(map[string]interface{})
+	if !isObject {
+		targetObject = map[string]interface{}{}
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+		} else {
+			targetObject[key] = mergeValue(targetObject[key], value)
+		}
+	}
+	return targetObject
+}
+
+// decodeJson keeps numbers as written, so that ids and epoch milliseconds
+// survive the round trip unchanged.
+func decodeJson(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, errors.New("unexpected data after JSON value")
+	}
+	return value, nil
+}
@@ -0,0 +1,231 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRecurrencePeriods bounds the search for occurrences, so rules that can
+// never match, like BYMONTHDAY=31 every 12 months from February, terminate.
+const maxRecurrencePeriods = 1000
+
+var (
+	frequencies = map[string]bool{"DAILY": true, "WEEKLY": true, "MONTHLY": true, "YEARLY": true}
+	weekdays    = map[string]time.Weekday{
+		"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+		"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+	}
+)
+
+// Recurrence is the subset of RFC 5545 recurrence rules supported for tasks:
+// FREQ (DAILY, WEEKLY, MONTHLY or YEARLY), INTERVAL, COUNT, UNTIL, BYDAY for
+// weekly rules and BYMONTHDAY for monthly ones. Occurrences are computed in UTC.
+type Recurrence struct {
+	Frequency  string
+	Interval   int
+	Count      int       // occurrences left including the current one, 0 means unbounded
+	Until      time.Time // last instant an occurrence may fall on, zero means unbounded
+	ByDay      []time.Weekday
+	ByMonthDay int // 0 keeps the day of the current occurrence, negative counts from the end of the month
+}
+
+// ParseRecurrence reads a rule like "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH",
+// with or without the "RRULE:" prefix.
+func ParseRecurrence(rule string) (Recurrence, error) {
+	recurrence := Recurrence{Interval: 1}
+	rule = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(rule)), "RRULE:")
+
+	for _, part := range strings.Split(rule, ";") {
+		pair := strings.SplitN(part, "=", 2)
+		if len(pair) != 2 {
+			return recurrence, fmt.Errorf("malformed recurrence rule part %q", part)
+		}
+
+		var err error
+		key, value := pair[0], pair[1]
+		switch key {
+		case "FREQ":
+			if !frequencies[value] {
+				err = fmt.Errorf("unsupported FREQ %q", value)
+			}
+			recurrence.Frequency = value
+		case "INTERVAL":
+			recurrence.Interval, err = parsePositive(key, value)
+		case "COUNT":
+			recurrence.Count, err = parsePositive(key, value)
+		case "UNTIL":
+			recurrence.Until, err = parseUntil(value)
+		case "BYDAY":
+			recurrence.ByDay, err = parseWeekdays(value)
+		case "BYMONTHDAY":
+			recurrence.ByMonthDay, err = strconv.Atoi(value)
+			if err != nil || recurrence.ByMonthDay == 0 || recurrence.ByMonthDay < -31 || recurrence.ByMonthDay > 31 {
+				err = fmt.Errorf("invalid BYMONTHDAY %q", value)
+			}
+		default:
+			err = fmt.Errorf("unsupported recurrence rule part %s", key)
+		}
+		if err != nil {
+			return recurrence, err
+		}
+	}
+
+	switch {
+	case recurrence.Frequency == "":
+		return recurrence, fmt.Errorf("recurrence rule needs a FREQ")
+	case recurrence.Count > 0 && !recurrence.Until.IsZero():
+		return recurrence, fmt.Errorf("recurrence rule may have COUNT or UNTIL, not both")
+	case len(recurrence.ByDay) > 0 && recurrence.Frequency != "WEEKLY":
+		return recurrence, fmt.Errorf("BYDAY is only supported for WEEKLY rules")
+	case recurrence.ByMonthDay != 0 && recurrence.Frequency != "MONTHLY":
+		return recurrence, fmt.Errorf("BYMONTHDAY is only supported for MONTHLY rules")
+	}
+	return recurrence, nil
+}
+
+// String formats the rule in a canonical order, so equal rules compare equal.
+func (r Recurrence) String() string {
+	parts := []string{"FREQ=" + r.Frequency}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, day := range r.ByDay {
+			days = append(days, strings.ToUpper(day.String()[:2]))
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.ByMonthDay != 0 {
+		parts = append(parts, "BYMONTHDAY="+strconv.Itoa(r.ByMonthDay))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.Format("20060102T150405Z"))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Following returns the rule carried by the occurrence after the current one.
+func (r Recurrence) Following() Recurrence {
+	if r.Count > 0 {
+		r.Count--
+	}
+	return r
+}
+
+// Occurrences returns up to n occurrences after start, which is taken to be
+// the current occurrence and counts towards COUNT.
+func (r Recurrence) Occurrences(start time.Time, n int) []time.Time {
+	start = start.UTC()
+	if r.Count > 0 && r.Count-1 < n {
+		n = r.Count - 1
+	}
+
+	occurrences := []time.Time{}
+	for period := 0; len(occurrences) < n && period < maxRecurrencePeriods; period++ {
+		for _, candidate := range r.candidates(start, period) {
+			if !candidate.After(start) {
+				continue
+			}
+			if !r.Until.IsZero() && candidate.After(r.Until) {
+				return occurrences
+			}
+			occurrences = append(occurrences, candidate)
+			if len(occurrences) == n {
+				break
+			}
+		}
+	}
+	return occurrences
+}
+
+// candidates lists the dates the rule produces in the given period after
+// start, in order. Periods are days, weeks, months or years, depending on FREQ.
+func (r Recurrence) candidates(start time.Time, period int) []time.Time {
+	step := period * r.Interval
+	switch r.Frequency {
+	case "DAILY":
+		return []time.Time{start.AddDate(0, 0, step)}
+	case "WEEKLY":
+		if len(r.ByDay) == 0 {
+			return []time.Time{start.AddDate(0, 0, 7*step)}
+		}
+		monday := start.AddDate(0, 0, 7*step-daysSinceMonday(start.Weekday()))
+		dates := make([]time.Time, 0, len(r.ByDay))
+		for _, day := range r.ByDay {
+			dates = append(dates, monday.AddDate(0, 0, daysSinceMonday(day)))
+		}
+		return dates
+	case "MONTHLY":
+		month := time.Date(start.Year(), start.Month()+time.Month(step), 1,
+			start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), time.UTC)
+		last := month.AddDate(0, 1, -1).Day()
+		day := start.Day()
+		if r.ByMonthDay > 0 {
+			day = r.ByMonthDay
+		} else if r.ByMonthDay < 0 {
+			day = last + r.ByMonthDay + 1
+		}
+		if day < 1 || day > last {
+			return nil
+		}
+		return []time.Time{month.AddDate(0, 0, day-1)}
+	default:
+		date := time.Date(start.Year()+step, start.Month(), start.Day(),
+			start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), time.UTC)
+		// February 29th only exists in leap years
+		if date.Month() != start.Month() {
+			return nil
+		}
+		return []time.Time{date}
+	}
+}
+
+func daysSinceMonday(day time.Weekday) int {
+	return (int(day) + 6) % 7
+}
+
+func parsePositive(key string, value string) (int, error) {
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 1 {
+		return 0, fmt.Errorf("invalid %s %q", key, value)
+	}
+	return number, nil
+}
+
+// parseUntil accepts a UTC date-time, or a date that includes the whole day.
+func parseUntil(value string) (time.Time, error) {
+	if until, err := time.Parse("20060102T150405Z", value); err == nil {
+		return until, nil
+	}
+	until, err := time.Parse("20060102", value)
+	if err != nil {
+		return until, fmt.Errorf("invalid UNTIL %q, expected YYYYMMDD or YYYYMMDDTHHMMSSZ", value)
+	}
+	return until.Add(24*time.Hour - time.Second), nil
+}
+
+func parseWeekdays(value string) ([]time.Weekday, error) {
+	seen := map[time.Weekday]bool{}
+	var days []time.Weekday
+	for _, name := range strings.Split(value, ",") {
+		day, found := weekdays[name]
+		if !found {
+			return nil, fmt.Errorf("invalid BYDAY %q", name)
+		}
+		if !seen[day] {
+			seen[day] = true
+			days = append(days, day)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool {
+		return daysSinceMonday(days[i]) < daysSinceMonday(days[j])
+	})
+	return days, nil
+}
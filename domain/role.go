@@ -0,0 +1,38 @@
+package domain
+
+// Roles decide what a user may do with tasks, from least to most privileged.
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+// Permissions are checked in front of the handlers they guard.
+const (
+	PermissionReadTasks   = "tasks:read"
+	PermissionWriteTasks  = "tasks:write"
+	PermissionDeleteTasks = "tasks:delete"
+	PermissionManageUsers = "users:manage"
+)
+
+var rolePermissions = map[string][]string{
+	RoleViewer: {PermissionReadTasks},
+	RoleEditor: {PermissionReadTasks, PermissionWriteTasks, PermissionDeleteTasks},
+	RoleAdmin:  {PermissionReadTasks, PermissionWriteTasks, PermissionDeleteTasks, PermissionManageUsers},
+}
+
+// IsRole reports whether role is one of the known roles.
+func IsRole(role string) bool {
+	_, found := rolePermissions[role]
+	return found
+}
+
+// HasPermission reports whether role grants permission; unknown roles grant nothing.
+func HasPermission(role string, permission string) bool {
+	for _, granted := range rolePermissions[role] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SortableColumns whitelists the task columns accepted in sort parameters.
+var SortableColumns = map[string]bool{
+	"id":       true,
+	"title":    true,
+	"addedOn":  true,
+	"dueBy":    true,
+	"status":   true,
+	"priority": true,
+}
+
+// SortKey orders tasks by one column; ties fall through to the next key.
+type SortKey struct {
+	Column     string
+	Descending bool
+}
+
+// ParseSort reads a comma separated list of columns like "priority,-dueBy",
+// where a leading "-" sorts that column in descending order.
+func ParseSort(value string) ([]SortKey, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var keys []SortKey
+	for _, column := range strings.Split(value, ",") {
+		key := SortKey{Column: strings.TrimPrefix(column, "-"), Descending: strings.HasPrefix(column, "-")}
+		if !SortableColumns[key.Column] {
+			return nil, fmt.Errorf("unsupported sort column %q", key.Column)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
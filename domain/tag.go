@@ -0,0 +1,7 @@
+package domain
+
+// Tag is a label together with the number of tasks carrying it.
+type Tag struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
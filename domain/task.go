@@ -1,12 +1,38 @@
 package domain
 
 type Task struct {
-	Id          int64  `json:"id"`
-	AddedOn     int64  `json:"added_on"`
-	DueBy       int64  `json:"due_by"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Status      string `json:"status"`
+	Id          int64    `json:"id"`
+	AddedOn     int64    `json:"added_on"`
+	DueBy       int64    `json:"due_by"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Status      string   `json:"status"`
+	Tags        []string `json:"tags,omitempty"`
+	ParentId    int64    `json:"parent_id,omitempty"`
+	Recurrence  string   `json:"recurrence,omitempty"`
+	Priority    string   `json:"priority,omitempty"`
+	// OwnerId is always taken from the caller, never from the request body
+	OwnerId int64 `json:"owner_id,omitempty"`
+	ListId  int64 `json:"list_id,omitempty"`
+	// TenantId is always taken from the caller and never leaves the server
+	TenantId string `json:"-"`
+	// Version counts the changes to a task, it is sent as the ETag header
+	Version int64 `json:"-"`
+	// DeletedOn is when the task was moved to the trash, 0 while it is not
+	DeletedOn int64 `json:"deleted_on,omitempty"`
+	// Subtasks and Children are computed on read and never stored
+	Subtasks *SubtaskRollup `json:"subtasks,omitempty"`
+	Children []Task         `json:"children,omitempty"`
+	// NextOccurrenceId is only set on the response completing a recurring task
+	NextOccurrenceId int64 `json:"next_occurrence_id,omitempty"`
+	// Attachments are only filled in when a single task is read
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+// SubtaskRollup counts the direct children of a task, and how many of them are done.
+type SubtaskRollup struct {
+	Total int64 `json:"total"`
+	Done  int64 `json:"done"`
 }
 
 func (t *Task) SetId(id int64) {
@@ -33,6 +59,58 @@ func (t *Task) SetStatus(status string) {
 	t.Status = status
 }
 
+func (t *Task) SetTags(tags []string) {
+	t.Tags = tags
+}
+
+func (t *Task) SetParentId(parentId int64) {
+	t.ParentId = parentId
+}
+
+func (t *Task) SetRecurrence(recurrence string) {
+	t.Recurrence = recurrence
+}
+
+func (t *Task) SetPriority(priority string) {
+	t.Priority = priority
+}
+
+func (t *Task) SetOwnerId(ownerId int64) {
+	t.OwnerId = ownerId
+}
+
+func (t *Task) SetListId(listId int64) {
+	t.ListId = listId
+}
+
+func (t *Task) SetTenantId(tenantId string) {
+	t.TenantId = tenantId
+}
+
+func (t *Task) SetVersion(version int64) {
+	t.Version = version
+}
+
+func (t *Task) SetDeletedOn(deletedOn int64) {
+	t.DeletedOn = deletedOn
+}
+
+func (t *Task) SetNextOccurrenceId(nextOccurrenceId int64) {
+	t.NextOccurrenceId = nextOccurrenceId
+}
+
+func (t *Task) SetSubtasks(subtasks *SubtaskRollup) {
+	t.Subtasks = subtasks
+}
+
+func (t *Task) SetChildren(children []Task) {
+	t.Children = children
+}
+
+func (t *Task) SetAttachments(attachments []Attachment) {
+	t.Attachments = attachments
+}
+
 func (t *Task) GetId() int64 {
 	return t.Id
 }
@@ -56,3 +134,55 @@ func (t *Task) GetDescription() string {
 func (t *Task) GetStatus() string {
 	return t.Status
 }
+
+func (t *Task) GetTags() []string {
+	return t.Tags
+}
+
+func (t *Task) GetParentId() int64 {
+	return t.ParentId
+}
+
+func (t *Task) GetRecurrence() string {
+	return t.Recurrence
+}
+
+func (t *Task) GetPriority() string {
+	return t.Priority
+}
+
+func (t *Task) GetOwnerId() int64 {
+	return t.OwnerId
+}
+
+func (t *Task) GetListId() int64 {
+	return t.ListId
+}
+
+func (t *Task) GetTenantId() string {
+	return t.TenantId
+}
+
+func (t *Task) GetVersion() int64 {
+	return t.Version
+}
+
+func (t *Task) GetDeletedOn() int64 {
+	return t.DeletedOn
+}
+
+func (t *Task) GetNextOccurrenceId() int64 {
+	return t.NextOccurrenceId
+}
+
+func (t *Task) GetSubtasks() *SubtaskRollup {
+	return t.Subtasks
+}
+
+func (t *Task) GetChildren() []Task {
+	return t.Children
+}
+
+func (t *Task) GetAttachments() []Attachment {
+	return t.Attachments
+}
@@ -0,0 +1,14 @@
+package domain
+
+import "regexp"
+
+// DefaultTenant holds everything stored before tenants existed.
+const DefaultTenant = "default"
+
+// tenantIdPattern keeps tenant ids usable as a subdomain.
+var tenantIdPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// IsTenantId reports whether id can name a tenant.
+func IsTenantId(id string) bool {
+	return tenantIdPattern.MatchString(id)
+}
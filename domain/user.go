@@ -0,0 +1,89 @@
+package domain
+
+import (
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+// User is an account that owns tasks. The password hash never leaves the server.
+type User struct {
+	Id           int64  `json:"id"`
+	Username     string `json:"username"`
+	PasswordHash string `json:"-"`
+	Role         string `json:"role"`
+	TenantId     string `json:"tenant_id"`
+	AddedOn      int64  `json:"added_on"`
+}
+
+// Credentials is the body of a registration request.
+type Credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Identity is the authenticated caller of a request. Repositories only
+// show it the tasks it owns, and never anything outside its tenant.
+type Identity struct {
+	UserId   int64
+	Username string
+	Role     string
+	TenantId string
+	// Scope is the scope of the API key used, empty when users sign in themselves
+	Scope string
+}
+
+// IsApiKey reports whether the caller authenticated with an API key.
+func (i Identity) IsApiKey() bool {
+	return i.Scope != ""
+}
+
+// Can reports whether the role of the caller grants permission.
+func (i Identity) Can(permission string) bool {
+	return HasPermission(i.Role, permission)
+}
+
+// CanWrite reports whether the caller may change data.
+func (i Identity) CanWrite() bool {
+	return i.Scope != ScopeReadOnly
+}
+
+// NewUser creates an editor in the default tenant with a normalized username and a bcrypt hash of password.
+func NewUser(username string, password string, addedOn int64) (User, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return User{}, err
+	}
+	return User{Username: NormalizeUsername(username), PasswordHash: string(hash), Role: RoleEditor, TenantId: DefaultTenant,
+		AddedOn: addedOn}, nil
+}
+
+// NormalizeUsername makes usernames case insensitive.
+func NormalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// CheckPassword reports whether password matches the stored hash of u.
+func (u *User) CheckPassword(password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) == nil
+}
+
+func (u *User) GetId() int64 {
+	return u.Id
+}
+
+func (u *User) GetUsername() string {
+	return u.Username
+}
+
+func (u *User) GetRole() string {
+	return u.Role
+}
+
+func (u *User) GetTenantId() string {
+	return u.TenantId
+}
+
+// Identity returns the identity u acts as once authenticated.
+func (u *User) Identity() Identity {
+	return Identity{UserId: u.Id, Username: u.Username, Role: u.Role, TenantId: u.TenantId}
+}
@@ -0,0 +1,75 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Workflow is the task status state machine configured in config.yml.
+// Statuses are lowercase, and the first one is given to new tasks that
+// leave their status out.
+type Workflow struct {
+	Statuses    []string
+	Transitions map[string][]string // allowed target statuses, keyed by current status
+	Actions     map[string]string   // target status of each transition action, keyed by action name
+	Completed   string              // status of the tasks that are done
+}
+
+// Validate checks that every transition and action refers to a configured status.
+func (w Workflow) Validate() error {
+	if len(w.Statuses) == 0 {
+		return fmt.Errorf("no task statuses configured")
+	}
+	if !w.IsStatus(w.Completed) {
+		return fmt.Errorf("completed status %q is not a configured status", w.Completed)
+	}
+	for from, targets := range w.Transitions {
+		for _, status := range append([]string{from}, targets...) {
+			if !w.IsStatus(status) {
+				return fmt.Errorf("transition from %q refers to unknown status %q", from, status)
+			}
+		}
+	}
+	for action, status := range w.Actions {
+		if !w.IsStatus(status) {
+			return fmt.Errorf("action %q refers to unknown status %q", action, status)
+		}
+	}
+	return nil
+}
+
+func (w Workflow) InitialStatus() string {
+	return w.Statuses[0]
+}
+
+func (w Workflow) IsStatus(status string) bool {
+	for _, known := range w.Statuses {
+		if known == status {
+			return true
+		}
+	}
+	return false
+}
+
+// CanTransition reports whether a task may move from one status to another.
+// Staying in the same status is always allowed, and tasks stored before the
+// workflow existed may leave their unknown status for any configured one.
+func (w Workflow) CanTransition(from string, to string) bool {
+	if !w.IsStatus(to) {
+		return false
+	}
+	if from == to || !w.IsStatus(from) {
+		return true
+	}
+	for _, target := range w.Transitions[from] {
+		if target == to {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeStatus folds case and surrounding spaces, so "Done " matches "done".
+func NormalizeStatus(status string) string {
+	return strings.ToLower(strings.TrimSpace(status))
+}
@@ -1,38 +1,115 @@
 package main
 
 import (
+	"flag"
 	"github.com/gofiber/fiber/v2"
 	"log"
 	"my-todo-app/config"
+	"my-todo-app/domain"
+	"my-todo-app/repository"
 	"my-todo-app/services"
 )
 
 func main() {
+	demo := flag.Bool("demo", false, "serve seeded in-memory tasks instead of the configured database")
+	flag.Parse()
+
+	switch flag.Arg(0) {
+	case "migrate":
+		runMigrateCommand(flag.Args()[1:])
+		return
+	case "role":
+		runRoleCommand(flag.Args()[1:])
+		return
+	}
+
+	backend, err := getRepository(*demo)
+	if err != nil {
+		log.Panic("Error connecting to storage backend with error: ", err)
+	}
+	services.SetRepository(backend)
+	blobStore, err := repository.NewBlobStore(config.AttachmentStore, config.AttachmentLocation)
+	if err != nil {
+		log.Panic("Error opening attachment store with error: ", err)
+	}
+	services.SetBlobStore(blobStore)
+	stopPurge := services.ScheduleTrashPurge()
+	defer stopPurge()
+
 	app := fiber.New()
+	app.Server().HeaderReceived = services.AttachmentBodyLimit
 
 	defer func() { _ = app.Shutdown() }()
 
 	configureApp(app)
 	registerRoutes(app)
 
-	err := app.Listen(config.Port)
+	err = app.Listen(config.Port)
 	if err != nil {
 		log.Panic("Error starting server with error: ", err)
 	}
 }
 
+func getRepository(demo bool) (repository.Repository, error) {
+	if demo {
+		return repository.NewDemoRepository(), nil
+	}
+	return repository.NewRepository(config.SqlDriver, config.DataSourceName)
+}
+
 func configureApp(app *fiber.App) {
 	app.Use(
 		config.GetFiberLogger(),
 		config.GetCors(),
+		services.AuthMiddleware,
 	)
 }
 
 func registerRoutes(app *fiber.App) {
-	app.Get("/task/:id", services.GetTaskByIdHandler)
-	app.Get("/tasks", services.GetAllTasksHandler)
-	app.Get("/tasks/search", services.SearchHandler)
-	app.Post("/task", services.CreateTaskHandler)
-	app.Put("/task/:id", services.UpdateTaskByIdHandler)
-	app.Delete("/task/:id", services.DeleteTaskByIdHandler)
+	read := services.RequirePermission(domain.PermissionReadTasks)
+	write := services.RequirePermission(domain.PermissionWriteTasks)
+	remove := services.RequirePermission(domain.PermissionDeleteTasks)
+	manageUsers := services.RequirePermission(domain.PermissionManageUsers)
+
+	app.Get("/task/:id", read, services.GetTaskByIdHandler)
+	app.Get("/task/:id/subtasks", read, services.GetSubtasksHandler)
+	app.Post("/task/:id/transition/:action", write, services.TransitionTaskHandler)
+	app.Get("/task/:id/occurrences", read, services.GetOccurrencesHandler)
+	app.Get("/task/:id/history", read, services.GetTaskHistoryHandler)
+	app.Post("/task/:id/history/:version/revert", write, services.RevertTaskHandler)
+	app.Get("/task/:id/comments", read, services.GetCommentsHandler)
+	app.Post("/task/:id/comments", write, services.CreateCommentHandler)
+	app.Put("/task/:id/comments/:commentId", write, services.UpdateCommentHandler)
+	app.Delete("/task/:id/comments/:commentId", write, services.DeleteCommentHandler)
+	app.Get("/task/:id/attachments", read, services.GetAttachmentsHandler)
+	app.Post("/task/:id/attachments", write, services.UploadAttachmentHandler)
+	app.Get("/task/:id/attachments/:attachmentId", read, services.DownloadAttachmentHandler)
+	app.Delete("/task/:id/attachments/:attachmentId", write, services.DeleteAttachmentHandler)
+	app.Get("/task/:id/dependencies", read, services.GetDependenciesHandler)
+	app.Post("/task/:id/dependencies", write, services.AddDependencyHandler)
+	app.Delete("/task/:id/dependencies/:blockerId", write, services.RemoveDependencyHandler)
+	app.Get("/tasks", read, services.GetAllTasksHandler)
+	app.Get("/tasks/search", read, services.SearchHandler)
+	app.Post("/tasks/batch", write, services.IdempotencyMiddleware, services.BatchTasksHandler)
+	app.Post("/task", write, services.IdempotencyMiddleware, services.CreateTaskHandler)
+	app.Put("/task/:id", write, services.UpdateTaskByIdHandler)
+	app.Patch("/task/:id", write, services.PatchTaskByIdHandler)
+	app.Delete("/task/:id", remove, services.DeleteTaskByIdHandler)
+	app.Post("/task/:id/restore", remove, services.RestoreTaskHandler)
+	app.Get("/trash", read, services.GetTrashHandler)
+	app.Get("/tags", read, services.GetAllTagsHandler)
+	app.Get("/lists", read, services.GetListsHandler)
+	app.Get("/lists/:id", read, services.GetListByIdHandler)
+	app.Get("/lists/:id/tasks", read, services.GetListTasksHandler)
+	app.Post("/lists", write, services.CreateListHandler)
+	app.Put("/lists/:id", write, services.UpdateListHandler)
+	app.Delete("/lists/:id", remove, services.DeleteListHandler)
+	app.Post("/lists/:id/members", write, services.AddListMemberHandler)
+	app.Delete("/lists/:id/members/:userId", write, services.RemoveListMemberHandler)
+	app.Post("/users", services.CreateUserHandler)
+	app.Get("/users/me", services.GetCurrentUserHandler)
+	app.Put("/users/:id/role", manageUsers, services.UpdateUserRoleHandler)
+	app.Post("/users/me/apikeys", services.CreateApiKeyHandler)
+	app.Get("/users/me/apikeys", services.GetApiKeysHandler)
+	app.Delete("/users/me/apikeys/:id", services.DeleteApiKeyHandler)
 }
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"my-todo-app/config"
+	"my-todo-app/repository"
+	"strconv"
+	"time"
+)
+
+// runMigrateCommand handles "migrate up", "migrate down [steps]" and "migrate status"
+// against the database configured in config.yml.
+func runMigrateCommand(args []string) {
+	if len(args) == 0 {
+		log.Fatal("Usage: migrate up | down [steps] | status")
+	}
+
+	migrator, err := repository.NewMigrator(config.SqlDriver, config.DataSourceName)
+	if err != nil {
+		log.Fatal("Error connecting to database with error: ", err)
+	}
+	defer func() { _ = migrator.Close() }()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up()
+		printMigrations("Applied", applied)
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				log.Fatal("Steps for migrate down must be a positive number, got: ", args[1])
+			}
+		}
+		rolledBack, err := migrator.Down(steps)
+		printMigrations("Rolled back", rolledBack)
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied " + time.Unix(0, status.AppliedOn*int64(time.Millisecond)).Format(time.RFC3339)
+			}
+			fmt.Printf("%4d  %-40s %s\n", status.Version, status.Name, state)
+		}
+	default:
+		log.Fatal("Unknown migrate command: ", args[0])
+	}
+}
+
+func printMigrations(action string, migrations []repository.MigrationStatus) {
+	if len(migrations) == 0 {
+		fmt.Println("Nothing to do, schema is up to date")
+	}
+	for _, migration := range migrations {
+		fmt.Printf("%s %d: %s\n", action, migration.Version, migration.Name)
+	}
+}
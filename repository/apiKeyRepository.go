@@ -0,0 +1,122 @@
+package repository
+
+import (
+	sq "github.com/Masterminds/squirrel"
+	"my-todo-app/domain"
+)
+
+var apiKeyColumns = []string{"id", "userId", "name", "prefix", "keyHash", "scope", "addedOn", "lastUsedOn", "tenantId"}
+
+func (r *sqlTaskRepository) CreateApiKey(caller domain.Identity, key domain.ApiKey) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return -1, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	id, err := r.insertReturningId(tx,
+		r.statement.Insert("api_keys").
+			Columns(apiKeyColumns[1:]...).
+			Values(caller.UserId, key.Name, key.Prefix, key.KeyHash, key.Scope, key.AddedOn, key.LastUsedOn, caller.TenantId))
+	if err != nil {
+		return -1, err
+	}
+	return id, nil
+}
+
+func (r *sqlTaskRepository) GetApiKeys(caller domain.Identity) ([]domain.ApiKey, error) {
+	return r.queryApiKeys(sq.Eq{"userId": caller.UserId, "tenantId": caller.TenantId})
+}
+
+func (r *sqlTaskRepository) GetApiKeyByHash(hash string) ([]domain.ApiKey, error) {
+	return r.queryApiKeys(sq.Eq{"keyHash": hash})
+}
+
+// TouchApiKey records that the key with id was used at usedOn.
+func (r *sqlTaskRepository) TouchApiKey(id int64, usedOn int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	_, err = r.statement.Update("api_keys").
+		Set("lastUsedOn", usedOn).
+		Where(sq.Eq{"id": id}).
+		RunWith(tx).
+		Exec()
+	return err
+}
+
+func (r *sqlTaskRepository) DeleteApiKey(caller domain.Identity, id string) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	result, err := r.statement.Delete("api_keys").
+		Where(sq.Eq{"userId": caller.UserId, "tenantId": caller.TenantId}).
+		Where(sq.Eq{"id": parseId(id)}).
+		RunWith(tx).
+		Exec()
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected > 0, err
+}
+
+func (r *sqlTaskRepository) queryApiKeys(filter sq.Sqlizer) ([]domain.ApiKey, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	rows, err := r.statement.Select(apiKeyColumns...).
+		From("api_keys").
+		Where(filter).
+		OrderBy("id").
+		RunWith(tx).
+		Query()
+
+	keys := []domain.ApiKey{}
+	for err == nil && rows.Next() {
+		var key domain.ApiKey
+		err = rows.Scan(&key.Id, &key.UserId, &key.Name, &key.Prefix, &key.KeyHash, &key.Scope, &key.AddedOn, &key.LastUsedOn, &key.TenantId)
+		if err == nil {
+			keys = append(keys, key)
+		}
+	}
+	return keys, err
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"database/sql"
+	sq "github.com/Masterminds/squirrel"
+	"my-todo-app/domain"
+)
+
+var attachmentColumns = []string{"id", "taskId", "name", "contentType", "size", "uploaderId", "uploader", "addedOn",
+	"storageKey", "tenantId"}
+
+// CreateAttachment records attachment on its task, uploaded by caller.
+func (r *sqlTaskRepository) CreateAttachment(caller domain.Identity, attachment domain.Attachment) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return -1, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	id, err := r.insertReturningId(tx,
+		r.statement.Insert("attachments").
+			Columns(attachmentColumns[1:]...).
+			Values(attachment.GetTaskId(), attachment.GetName(), attachment.GetContentType(), attachment.GetSize(),
+				caller.UserId, caller.Username, attachment.GetAddedOn(), attachment.GetStorageKey(), caller.TenantId))
+	if err != nil {
+		return -1, err
+	}
+	return id, nil
+}
+
+// GetAttachments lists the attachments of the task with taskId, the oldest first.
+func (r *sqlTaskRepository) GetAttachments(caller domain.Identity, taskId string) ([]domain.Attachment, error) {
+	return r.queryAttachments(r.statement.Select(attachmentColumns...).
+		From("attachments").
+		Where(sq.Eq{"tenantId": caller.TenantId, "taskId": parseId(taskId)}).
+		OrderBy("addedOn", "id"))
+}
+
+func (r *sqlTaskRepository) GetAttachmentById(caller domain.Identity, taskId string, id string) ([]domain.Attachment, error) {
+	return r.queryAttachments(r.statement.Select(attachmentColumns...).
+		From("attachments").
+		Where(sq.Eq{"tenantId": caller.TenantId, "taskId": parseId(taskId), "id": parseId(id)}))
+}
+
+// DeleteAttachment forgets an attachment, reporting whether it was found. Its
+// blob is left to the caller to remove.
+func (r *sqlTaskRepository) DeleteAttachment(caller domain.Identity, taskId string, id string) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	result, err := r.statement.Delete("attachments").
+		Where(sq.Eq{"tenantId": caller.TenantId, "taskId": parseId(taskId), "id": parseId(id)}).
+		RunWith(tx).
+		Exec()
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected > 0, err
+}
+
+func (r *sqlTaskRepository) queryAttachments(query sq.SelectBuilder) ([]domain.Attachment, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	attachments, err := r.queryAttachmentsIn(tx, query)
+	return attachments, err
+}
+
+// queryAttachmentsIn runs query inside tx.
+func (r *sqlTaskRepository) queryAttachmentsIn(tx *sql.Tx, query sq.SelectBuilder) ([]domain.Attachment, error) {
+	attachments := []domain.Attachment{}
+	rows, err := query.RunWith(tx).Query()
+	if err != nil {
+		return attachments, err
+	}
+
+	for rows.Next() {
+		var attachment domain.Attachment
+		err = rows.Scan(&attachment.Id, &attachment.TaskId, &attachment.Name, &attachment.ContentType, &attachment.Size,
+			&attachment.UploaderId, &attachment.Uploader, &attachment.AddedOn, &attachment.StorageKey, &attachment.TenantId)
+		if err != nil {
+			_ = rows.Close()
+			return attachments, err
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, rows.Err()
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"my-todo-app/domain"
+	"strconv"
+)
+
+// ErrBatchRolledBack is the result of every other write of an atomic batch
+// once one of its writes fails.
+var ErrBatchRolledBack = errors.New("not applied, another operation of the atomic batch failed")
+
+// ApplyBatch stores writes in order within a single transaction, next
+// occurrences included. An atomic batch stops at the first write that fails
+// or finds no task at the expected version and rolls back all of them, the
+// others resulting in ErrBatchRolledBack. Otherwise a savepoint around each
+// write rolls back only the ones failing.
+func (r *sqlTaskRepository) ApplyBatch(caller domain.Identity, writes []domain.TaskWrite, atomic bool) (
+	[]domain.TaskWriteResult, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	results := make([]domain.TaskWriteResult, len(writes))
+	for i, write := range writes {
+		if !atomic {
+			if _, err = tx.Exec("SAVEPOINT batch_write"); err != nil {
+				return nil, err
+			}
+		}
+
+		results[i] = r.applyWrite(tx, caller, write)
+		if atomic && !results[i].Applied {
+			// the deferred rollback undoes every write of the batch
+			err = ErrBatchRolledBack
+			return rollBack(writes, i, results[i]), nil
+		}
+		if !atomic {
+			savepoint := "RELEASE SAVEPOINT batch_write"
+			if results[i].Err != nil {
+				savepoint = "ROLLBACK TO SAVEPOINT batch_write"
+			}
+			if _, err = tx.Exec(savepoint); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return results, nil
+}
+
+func (r *sqlTaskRepository) applyWrite(tx *sql.Tx, caller domain.Identity, write domain.TaskWrite) domain.TaskWriteResult {
+	result := domain.TaskWriteResult{Id: write.Task.GetId()}
+	id := strconv.FormatInt(write.Task.GetId(), 10)
+	switch write.Op {
+	case domain.BatchCreate:
+		result.Id, result.Err = r.createTask(tx, caller, write.Task, write.Revision)
+		result.Applied = result.Err == nil
+	case domain.BatchUpdate:
+		result.Applied, result.Err = r.updateTask(tx, caller, write.Task, id, write.Revision)
+		if result.Applied {
+			result.NextId, result.Err = r.createNextOccurrence(tx, caller, write.Next, write.Revision)
+			result.Applied = result.Err == nil
+		}
+	case domain.BatchDelete:
+		result.Applied, result.Err = r.deleteTask(tx, caller, id, write.Task.GetVersion(), write.Task.GetDeletedOn(),
+			write.Cascade, write.Revision)
+	}
+	return result
+}
+
+// createNextOccurrence creates next, if any, along with the change recorded
+// as revision that completes its previous occurrence.
+func (r *sqlTaskRepository) createNextOccurrence(tx *sql.Tx, caller domain.Identity, next *domain.Task,
+	revision domain.TaskRevision) (int64, error) {
+	if next == nil {
+		return 0, nil
+	}
+	return r.createTask(tx, caller, *next, getNextRevision(revision))
+}
+
+// getNextRevision is the revision of the creation of the next occurrence of
+// a task changed with revision.
+func getNextRevision(revision domain.TaskRevision) domain.TaskRevision {
+	if revision.Action != "" {
+		revision.Action = domain.HistoryCreate
+	}
+	return revision
+}
+
+// rollBack gives every write of an atomic batch ErrBatchRolledBack as its
+// result, except the write with index failed that stopped it.
+func rollBack(writes []domain.TaskWrite, failed int, result domain.TaskWriteResult) []domain.TaskWriteResult {
+	results := make([]domain.TaskWriteResult, len(writes))
+	for i, write := range writes {
+		results[i] = domain.TaskWriteResult{Id: write.Task.GetId(), Err: ErrBatchRolledBack}
+	}
+	result.Applied = false
+	results[failed] = result
+	return results
+}
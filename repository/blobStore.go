@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+const LocalBlobStore = "local"
+
+var (
+	ErrBlobNotFound   = errors.New("blob does not exist")
+	errInvalidBlobKey = errors.New("blob keys are lowercase letters and digits separated by /")
+)
+
+var blobKeyPattern = regexp.MustCompile(`^[0-9a-z]+(/[0-9a-z]+)*$`)
+
+// Blob is the content of a stored file, read and seeked in place so that
+// parts of it are served without loading the rest.
+type Blob interface {
+	io.ReadSeeker
+	io.Closer
+}
+
+// BlobStore keeps the content of files under keys made of lowercase letters
+// and digits separated by /, such as "12/3f9a". Storing a key again replaces
+// its content.
+type BlobStore interface {
+	Put(key string, content io.Reader) (int64, error)
+	// Open fails with ErrBlobNotFound for keys nothing is stored under
+	Open(key string) (Blob, error)
+	// Delete succeeds for keys nothing is stored under
+	Delete(key string) error
+}
+
+// NewBlobStore opens the blob store named by driver at location, which is a
+// directory for LocalBlobStore.
+func NewBlobStore(driver string, location string) (BlobStore, error) {
+	switch driver {
+	case LocalBlobStore:
+		return newLocalBlobStore(location)
+	}
+	return nil, fmt.Errorf("unsupported blob store: %s", driver)
+}
+
+// localBlobStore keeps every blob in a file under its root directory.
+type localBlobStore struct {
+	root string
+}
+
+func newLocalBlobStore(root string) (*localBlobStore, error) {
+	if err := os.MkdirAll(root, 0750); err != nil {
+		return nil, err
+	}
+	return &localBlobStore{root: root}, nil
+}
+
+// Put writes content to a temporary file first and moves it in place once
+// complete, so that a failed upload never leaves part of a blob behind.
+func (s *localBlobStore) Put(key string, content io.Reader) (int64, error) {
+	path, err := s.getPath(key)
+	if err != nil {
+		return 0, err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return 0, err
+	}
+
+	file, err := ioutil.TempFile(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return 0, err
+	}
+	size, err := io.Copy(file, content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		return 0, err
+	}
+	return size, nil
+}
+
+func (s *localBlobStore) Open(key string) (Blob, error) {
+	path, err := s.getPath(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (s *localBlobStore) Delete(key string) error {
+	path, err := s.getPath(key)
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(path); os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// getPath maps key to a file under root, refusing keys that could point
+// anywhere else.
+func (s *localBlobStore) getPath(key string) (string, error) {
+	if !blobKeyPattern.MatchString(key) {
+		return "", errInvalidBlobKey
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
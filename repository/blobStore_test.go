@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewBlobStoreUnsupportedDriver(t *testing.T) {
+	store, err := NewBlobStore("s3", "")
+	if err == nil || store != nil {
+		t.Errorf("Expected error for unsupported blob store, got store: %v, error: %v", store, err)
+	}
+}
+
+func TestLocalBlobStore(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "attachments")
+	store, err := NewBlobStore(LocalBlobStore, root)
+	if err != nil {
+		t.Fatalf("Error opening local blob store: %s", err)
+	}
+
+	t.Run("should store and read back blobs", func(t *testing.T) {
+		size, err := store.Put("12/abc", strings.NewReader("first version"))
+		if err == nil {
+			size, err = store.Put("12/abc", strings.NewReader("hello, blob"))
+		}
+		if err != nil || size != 11 {
+			t.Fatalf("Expected 11 bytes to be stored, Got: %d, error: %v", size, err)
+		}
+
+		blob, err := store.Open("12/abc")
+		if err != nil {
+			t.Fatalf("Error opening blob: %s", err)
+		}
+		defer func() { _ = blob.Close() }()
+		if _, err = blob.Seek(7, io.SeekStart); err != nil {
+			t.Fatalf("Error seeking blob: %s", err)
+		}
+		content, err := ioutil.ReadAll(blob)
+		if err != nil || string(content) != "blob" {
+			t.Errorf("Expected \"blob\", Got: %q, error: %v", content, err)
+		}
+	})
+
+	t.Run("should delete blobs", func(t *testing.T) {
+		_, _ = store.Put("12/def", bytes.NewReader([]byte{1, 2, 3}))
+		if err := store.Delete("12/def"); err != nil {
+			t.Errorf("Error deleting blob: %s", err)
+		}
+		if _, err := store.Open("12/def"); err != ErrBlobNotFound {
+			t.Errorf("Expected %v, Got: %v", ErrBlobNotFound, err)
+		}
+		if err := store.Delete("12/def"); err != nil {
+			t.Errorf("Expected deleting a missing blob to succeed, Got: %s", err)
+		}
+	})
+
+	t.Run("should refuse keys pointing out of the store", func(t *testing.T) {
+		for _, key := range []string{"", "../outside", "12/../../outside", "/etc/passwd", "12//abc", "12/ABC"} {
+			if _, err := store.Put(key, strings.NewReader("x")); err != errInvalidBlobKey {
+				t.Errorf("Expected key %q to be refused, Got: %v", key, err)
+			}
+			if _, err := store.Open(key); err != errInvalidBlobKey {
+				t.Errorf("Expected key %q to be refused, Got: %v", key, err)
+			}
+		}
+	})
+}
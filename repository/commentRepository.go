@@ -0,0 +1,150 @@
+package repository
+
+import (
+	sq "github.com/Masterminds/squirrel"
+	"my-todo-app/domain"
+)
+
+var commentColumns = []string{"id", "taskId", "authorId", "author", "body", "addedOn", "updatedOn", "tenantId"}
+
+// CreateComment stores comment on its task, written by caller.
+func (r *sqlTaskRepository) CreateComment(caller domain.Identity, comment domain.Comment) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return -1, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	id, err := r.insertReturningId(tx,
+		r.statement.Insert("comments").
+			Columns(commentColumns[1:]...).
+			Values(comment.GetTaskId(), caller.UserId, caller.Username, comment.GetBody(), comment.GetAddedOn(),
+				comment.GetUpdatedOn(), caller.TenantId))
+	if err != nil {
+		return -1, err
+	}
+	return id, nil
+}
+
+// GetComments lists the comments on the task with taskId one page at a time,
+// or all of them when page or perPage is -1, the oldest first.
+func (r *sqlTaskRepository) GetComments(caller domain.Identity, taskId string, page int64, perPage int64) (
+	[]domain.Comment, error) {
+	query := r.statement.Select(commentColumns...).
+		From("comments").
+		Where(sq.Eq{"tenantId": caller.TenantId, "taskId": parseId(taskId)}).
+		OrderBy("addedOn", "id")
+	if page != -1 && perPage != -1 {
+		query = query.Limit(uint64(perPage)).Offset(uint64(page * perPage))
+	}
+	return r.queryComments(query)
+}
+
+func (r *sqlTaskRepository) GetCommentById(caller domain.Identity, taskId string, id string) ([]domain.Comment, error) {
+	return r.queryComments(r.statement.Select(commentColumns...).
+		From("comments").
+		Where(sq.Eq{"tenantId": caller.TenantId, "taskId": parseId(taskId), "id": parseId(id)}))
+}
+
+// UpdateComment changes the body of a comment written by caller, reporting
+// whether it was found.
+func (r *sqlTaskRepository) UpdateComment(caller domain.Identity, comment domain.Comment, id string) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	result, err := r.statement.Update("comments").
+		Set("body", comment.GetBody()).
+		Set("updatedOn", comment.GetUpdatedOn()).
+		Where(authoredBy(caller)).
+		Where(sq.Eq{"taskId": comment.GetTaskId(), "id": parseId(id)}).
+		RunWith(tx).
+		Exec()
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected > 0, err
+}
+
+// DeleteComment removes a comment written by caller, reporting whether it was found.
+func (r *sqlTaskRepository) DeleteComment(caller domain.Identity, taskId string, id string) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	result, err := r.statement.Delete("comments").
+		Where(authoredBy(caller)).
+		Where(sq.Eq{"taskId": parseId(taskId), "id": parseId(id)}).
+		RunWith(tx).
+		Exec()
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected > 0, err
+}
+
+func (r *sqlTaskRepository) queryComments(query sq.SelectBuilder) ([]domain.Comment, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	comments := []domain.Comment{}
+	rows, err := query.RunWith(tx).Query()
+	if err != nil {
+		return comments, err
+	}
+
+	for rows.Next() {
+		var comment domain.Comment
+		err = rows.Scan(&comment.Id, &comment.TaskId, &comment.AuthorId, &comment.Author, &comment.Body, &comment.AddedOn,
+			&comment.UpdatedOn, &comment.TenantId)
+		if err != nil {
+			_ = rows.Close()
+			return comments, err
+		}
+		comments = append(comments, comment)
+	}
+	err = rows.Err()
+	return comments, err
+}
+
+// authoredBy restricts a query on comments to the ones caller wrote, within its tenant.
+func authoredBy(caller domain.Identity) sq.Sqlizer {
+	return sq.Eq{"tenantId": caller.TenantId, "authorId": caller.UserId}
+}
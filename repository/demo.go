@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"fmt"
+	"my-todo-app/domain"
+	"time"
+)
+
+const day = int64(24 * time.Hour / time.Millisecond)
+
+// DemoUsername and DemoPassword sign in as the owner of the demo tasks, an admin.
+const (
+	DemoUsername = "demo"
+	DemoPassword = "demo"
+)
+
+// NewDemoRepository returns an in-memory backend seeded with a handful of
+// tasks, so the API can be explored without any database.
+func NewDemoRepository() Repository {
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	store := newMemoryTaskRepository()
+
+	user, err := domain.NewUser(DemoUsername, DemoPassword, now)
+	if err == nil {
+		user.Role = domain.RoleAdmin
+		user.Id, err = store.CreateUser(user)
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error seeding demo user: %s", err))
+	}
+
+	for _, task := range []domain.Task{
+		{AddedOn: now - 3*day, DueBy: now - day, Title: "Send weekly report", Description: "Summarize progress for the team", Status: "done",
+			Priority: "P1"},
+		{AddedOn: now - 2*day, DueBy: now + day, Title: "Review pull requests", Description: "Go through the open review queue", Status: "in-progress",
+			Priority: "P0"},
+		{AddedOn: now - day, DueBy: now + 3*day, Title: "Plan sprint", Description: "Pick stories for the next sprint", Status: "open",
+			Priority: "P2"},
+		{AddedOn: now, DueBy: now + 7*day, Title: "Pay invoices", Description: "Monthly vendor invoices", Status: "open",
+			Priority: "P1"},
+	} {
+		_, _ = store.CreateTask(user.Identity(), task, domain.TaskRevision{})
+	}
+	return store
+}
@@ -0,0 +1,220 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	sq "github.com/Masterminds/squirrel"
+	"my-todo-app/domain"
+)
+
+// ErrDependencyCycle is returned when a task would end up blocked by itself,
+// directly or through the tasks blocking it.
+var ErrDependencyCycle = errors.New("dependency would make a task block itself")
+
+// openBlockers selects the ids of the tasks with a blocker that is neither
+// done nor in the trash.
+const openBlockers = `SELECT d.taskId FROM task_dependencies d JOIN tasks b ON b.id = d.blockedById
+	WHERE b.status <> ? AND b.deletedOn = 0`
+
+// AddDependency blocks the task of dependency by its blocker, reporting
+// whether they were not linked already. Dependencies that close a cycle are
+// refused with ErrDependencyCycle, the dependencies of the tenant staying
+// locked from the check until the new one is stored.
+func (r *sqlTaskRepository) AddDependency(caller domain.Identity, dependency domain.Dependency) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	if err = r.lockDependencies(tx, caller, dependency); err != nil {
+		return false, err
+	}
+
+	var exists int
+	err = r.statement.Select("1").
+		From("task_dependencies").
+		Where(sq.Eq{"taskId": dependency.GetTaskId(), "blockedById": dependency.GetBlockedById()}).
+		RunWith(tx).
+		QueryRow().
+		Scan(&exists)
+	if err == nil {
+		return false, nil
+	}
+	if err != sql.ErrNoRows {
+		return false, err
+	}
+
+	blockers, err := r.getBlockerIds(tx, caller, dependency.GetBlockedById())
+	if err != nil {
+		return false, err
+	}
+	for _, id := range append(blockers, dependency.GetBlockedById()) {
+		if id == dependency.GetTaskId() {
+			err = ErrDependencyCycle
+			return false, err
+		}
+	}
+
+	_, err = r.statement.Insert("task_dependencies").
+		Columns("taskId", "blockedById", "tenantId", "addedOn").
+		Values(dependency.GetTaskId(), dependency.GetBlockedById(), caller.TenantId, dependency.GetAddedOn()).
+		RunWith(tx).
+		Exec()
+	return err == nil, err
+}
+
+// RemoveDependency unblocks the task with taskId from the task with
+// blockerId, reporting whether the first was blocked by the second.
+func (r *sqlTaskRepository) RemoveDependency(caller domain.Identity, taskId string, blockerId string) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	result, err := r.statement.Delete("task_dependencies").
+		Where(sq.Eq{"tenantId": caller.TenantId, "taskId": parseId(taskId), "blockedById": parseId(blockerId)}).
+		RunWith(tx).
+		Exec()
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected > 0, err
+}
+
+// GetBlockers lists the tasks blocking the task with taskId that caller sees,
+// leaving out the ones in the trash.
+func (r *sqlTaskRepository) GetBlockers(caller domain.Identity, taskId string) ([]domain.Task, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	tasks, err := r.queryTasks(tx,
+		r.statement.Select(taskColumns...).
+			From("tasks").
+			Where(visibleTo(caller)).
+			Where(sq.Expr("id IN (SELECT blockedById FROM task_dependencies WHERE taskId = ?)", parseId(taskId))).
+			OrderBy("id"))
+	return tasks, err
+}
+
+// IsBlocked reports whether a task blocking the task with taskId is still
+// open, whether caller sees that task or not.
+func (r *sqlTaskRepository) IsBlocked(caller domain.Identity, taskId string) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	var count int64
+	err = r.statement.Select("COUNT(*)").
+		From("task_dependencies d").
+		Join("tasks b ON b.id = d.blockedById").
+		Where(sq.Eq{"d.tenantId": caller.TenantId, "d.taskId": parseId(taskId)}).
+		Where(sq.NotEq{"b.status": completedStatus}).
+		Where(sq.Expr("b.deletedOn = 0")).
+		RunWith(tx).
+		QueryRow().
+		Scan(&count)
+	return count > 0, err
+}
+
+// lockDependencies keeps other transactions from linking tasks of the tenant
+// of caller until tx ends, so that two dependencies added at once cannot close
+// a cycle together. The tasks of dependency are locked as well, a tenant's
+// first dependencies having no rows to lock yet.
+func (r *sqlTaskRepository) lockDependencies(tx *sql.Tx, caller domain.Identity, dependency domain.Dependency) error {
+	for _, query := range []sq.SelectBuilder{
+		r.statement.Select("taskId").From("task_dependencies").Where(sq.Eq{"tenantId": caller.TenantId}),
+		r.statement.Select("id").From("tasks").Where(sq.Eq{"id": []int64{dependency.GetTaskId(), dependency.GetBlockedById()}}),
+	} {
+		rows, err := r.dialect.lockRows(query).RunWith(tx).Query()
+		if err != nil {
+			return err
+		}
+		if err = rows.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getBlockerIds walks the tasks blocking id one level per query, following
+// the dependencies of the tenant of caller whatever the state of the tasks.
+func (r *sqlTaskRepository) getBlockerIds(tx *sql.Tx, caller domain.Identity, id int64) ([]int64, error) {
+	visited := map[int64]bool{id: true}
+	var blockers []int64
+
+	for level := []int64{id}; len(level) > 0; {
+		rows, err := r.statement.Select("blockedById").
+			From("task_dependencies").
+			Where(sq.Eq{"tenantId": caller.TenantId, "taskId": level}).
+			RunWith(tx).
+			Query()
+		if err != nil {
+			return nil, err
+		}
+
+		var next []int64
+		for rows.Next() {
+			var blockerId int64
+			if err = rows.Scan(&blockerId); err != nil {
+				_ = rows.Close()
+				return nil, err
+			}
+			if !visited[blockerId] {
+				visited[blockerId] = true
+				next = append(next, blockerId)
+			}
+		}
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+
+		blockers = append(blockers, next...)
+		level = next
+	}
+	return blockers, nil
+}
+
+// getBlockedFilter restricts a search to the tasks with an open blocker, or
+// to the ones without when blocked is "false".
+func getBlockedFilter(blocked string) sq.Sqlizer {
+	if blocked == "false" {
+		return sq.Expr("id NOT IN ("+openBlockers+")", completedStatus)
+	}
+	return sq.Expr("id IN ("+openBlockers+")", completedStatus)
+}
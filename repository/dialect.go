@@ -0,0 +1,85 @@
+package repository
+
+import (
+	sq "github.com/Masterminds/squirrel"
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// dialects holds every SQL backend selectable through sql.driver.
+var dialects = map[string]dialect{
+	mysqlDialect.name:    mysqlDialect,
+	sqliteDialect.name:   sqliteDialect,
+	postgresDialect.name: postgresDialect,
+}
+
+// dialect captures everything that differs between the SQL backends.
+type dialect struct {
+	name         string // value of sql.driver selecting this dialect
+	driver       string // database/sql driver name
+	placeholder  sq.PlaceholderFormat
+	maxOpenConns int  // 0 means unlimited
+	returningId  bool // generated ids are read back with RETURNING instead of LastInsertId
+	// serialPrimaryKey replaces {{serial}} in migrations
+	serialPrimaryKey string
+	// dropIndexOnTable keeps the table name in DROP INDEX statements
+	dropIndexOnTable bool
+	// ignoreOption or ignoreSuffix make an INSERT skip rows breaking a unique key
+	ignoreOption string
+	ignoreSuffix string
+	// lockSuffix makes a SELECT lock the rows it reads until the end of the
+	// transaction, and read their latest committed values
+	lockSuffix string
+}
+
+var mysqlDialect = dialect{
+	name:             "mysql",
+	driver:           "mysql",
+	placeholder:      sq.Question,
+	serialPrimaryKey: "INT PRIMARY KEY NOT NULL AUTO_INCREMENT",
+	dropIndexOnTable: true,
+	ignoreOption:     "IGNORE",
+	lockSuffix:       "FOR UPDATE",
+}
+
+// sqliteDialect serializes access through a single connection, which keeps
+// writers from failing with "database is locked" and lets ":memory:" DSNs
+// share one database. Rows need no locks then.
+var sqliteDialect = dialect{
+	name:             "sqlite",
+	driver:           "sqlite3",
+	placeholder:      sq.Question,
+	maxOpenConns:     1,
+	serialPrimaryKey: "INTEGER PRIMARY KEY AUTOINCREMENT",
+	ignoreOption:     "OR IGNORE",
+}
+
+var postgresDialect = dialect{
+	name:             "postgres",
+	driver:           "postgres",
+	placeholder:      sq.Dollar,
+	returningId:      true,
+	serialPrimaryKey: "SERIAL PRIMARY KEY",
+	ignoreSuffix:     "ON CONFLICT DO NOTHING",
+	lockSuffix:       "FOR UPDATE",
+}
+
+// ignoreDuplicates makes insert skip rows breaking a unique key.
+func (d dialect) ignoreDuplicates(insert sq.InsertBuilder) sq.InsertBuilder {
+	if d.ignoreOption != "" {
+		insert = insert.Options(d.ignoreOption)
+	}
+	if d.ignoreSuffix != "" {
+		insert = insert.Suffix(d.ignoreSuffix)
+	}
+	return insert
+}
+
+// lockRows makes query lock the rows it reads, where the dialect needs it.
+func (d dialect) lockRows(query sq.SelectBuilder) sq.SelectBuilder {
+	if d.lockSuffix != "" {
+		query = query.Suffix(d.lockSuffix)
+	}
+	return query
+}
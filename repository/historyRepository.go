@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	sq "github.com/Masterminds/squirrel"
+	"my-todo-app/domain"
+)
+
+var historyColumns = []string{"taskId", "tenantId", "version", "action", "actorId", "actor", "changedOn", "revertedTo",
+	"changes", "snapshot"}
+
+// addRevisions records revision for every task with ids as it is stored now,
+// completed with its change from the task with the same id in before. Tasks
+// missing from before were just created.
+func (r *sqlTaskRepository) addRevisions(tx *sql.Tx, caller domain.Identity, revision domain.TaskRevision, ids []int64,
+	before map[int64]domain.Task) error {
+	after, err := r.getRevisedTasks(tx, revision, ids)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err = r.addTaskRevision(tx, caller, completeRevision(revision, before[id], after[id])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getRevisedTasks reads the tasks with ids whatever their state, locking them
+// until tx ends, for a change to record revision of. Nothing is read for a
+// revision without an action.
+func (r *sqlTaskRepository) getRevisedTasks(tx *sql.Tx, revision domain.TaskRevision, ids []int64) (
+	map[int64]domain.Task, error) {
+	revised := map[int64]domain.Task{}
+	if revision.Action == "" {
+		return revised, nil
+	}
+	tasks, err := r.queryTasks(tx, r.dialect.lockRows(r.statement.Select(taskColumns...).
+		From("tasks").
+		Where(sq.Eq{"id": ids})))
+	for _, task := range tasks {
+		revised[task.GetId()] = task
+	}
+	return revised, err
+}
+
+// addTaskRevision records revision in the history of its task, keeping the
+// changes and the task as they were then as json.
+func (r *sqlTaskRepository) addTaskRevision(tx *sql.Tx, caller domain.Identity, revision domain.TaskRevision) error {
+	changes, err := json.Marshal(revision.Changes)
+	if err != nil {
+		return err
+	}
+	snapshot, err := json.Marshal(revision.Task)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.statement.Insert("task_history").
+		Columns(historyColumns...).
+		Values(revision.TaskId, caller.TenantId, revision.Version, revision.Action, revision.ActorId, revision.Actor,
+			revision.ChangedOn, revision.RevertedTo, string(changes), string(snapshot)).
+		RunWith(tx).
+		Exec()
+	return err
+}
+
+// GetTaskHistory lists the revisions of the task with id one page at a time,
+// or all of them when page or perPage is -1, the latest first.
+func (r *sqlTaskRepository) GetTaskHistory(caller domain.Identity, id string, page int64, perPage int64) (
+	[]domain.TaskRevision, error) {
+	query := r.statement.Select(historyColumns...).
+		From("task_history").
+		Where(sq.Eq{"tenantId": caller.TenantId, "taskId": parseId(id)}).
+		OrderBy("version DESC", "id DESC")
+	if page != -1 && perPage != -1 {
+		query = query.Limit(uint64(perPage)).Offset(uint64(page * perPage))
+	}
+	return r.queryRevisions(query)
+}
+
+// GetTaskRevision finds the revision of the task with id that resulted in version.
+func (r *sqlTaskRepository) GetTaskRevision(caller domain.Identity, id string, version int64) ([]domain.TaskRevision, error) {
+	return r.queryRevisions(r.statement.Select(historyColumns...).
+		From("task_history").
+		Where(sq.Eq{"tenantId": caller.TenantId, "taskId": parseId(id), "version": version}).
+		OrderBy("id DESC").
+		Limit(1))
+}
+
+func (r *sqlTaskRepository) queryRevisions(query sq.SelectBuilder) ([]domain.TaskRevision, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	revisions := []domain.TaskRevision{}
+	rows, err := query.RunWith(tx).Query()
+	if err != nil {
+		return revisions, err
+	}
+
+	for rows.Next() {
+		var revision domain.TaskRevision
+		var changes, snapshot string
+		err = rows.Scan(&revision.TaskId, &revision.TenantId, &revision.Version, &revision.Action, &revision.ActorId,
+			&revision.Actor, &revision.ChangedOn, &revision.RevertedTo, &changes, &snapshot)
+		if err == nil {
+			err = json.Unmarshal([]byte(changes), &revision.Changes)
+		}
+		if err == nil {
+			err = json.Unmarshal([]byte(snapshot), &revision.Task)
+		}
+		if err != nil {
+			_ = rows.Close()
+			return revisions, err
+		}
+		revisions = append(revisions, revision)
+	}
+	err = rows.Err()
+	return revisions, err
+}
+
+// completeRevision fills revision in with the change of a task from before to
+// after, which it keeps as the snapshot a revert goes back to.
+func completeRevision(revision domain.TaskRevision, before domain.Task, after domain.Task) domain.TaskRevision {
+	revision.TaskId = after.GetId()
+	revision.Version = after.GetVersion()
+	revision.Changes = domain.DiffTasks(before, after)
+	revision.Task = getSnapshot(after)
+	return revision
+}
+
+// getSnapshot keeps the stored fields of task, leaving out what is derived
+// from other tasks.
+func getSnapshot(task domain.Task) domain.Task {
+	task.Subtasks = nil
+	task.Children = nil
+	task.NextOccurrenceId = 0
+	task.Attachments = nil
+	return task
+}
@@ -0,0 +1,134 @@
+package repository
+
+import (
+	sq "github.com/Masterminds/squirrel"
+	"my-todo-app/domain"
+)
+
+var idempotencyColumns = []string{"idempotencyKey", "userId", "tenantId", "requestHash", "statusCode", "contentType", "etag",
+	"responseBody", "addedOn", "expiresOn"}
+
+// ReserveIdempotencyKey stores record, still without a response, unless the
+// caller already used its key. Records expired at record.AddedOn are dropped
+// first, so their keys can be used again.
+func (r *sqlTaskRepository) ReserveIdempotencyKey(caller domain.Identity, record domain.IdempotencyRecord) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	_, err = r.statement.Delete("idempotency_keys").
+		Where(sq.LtOrEq{"expiresOn": record.AddedOn}).
+		RunWith(tx).
+		Exec()
+	if err != nil {
+		return false, err
+	}
+
+	var count int64
+	err = r.statement.Select("COUNT(*)").
+		From("idempotency_keys").
+		Where(sq.Eq{"tenantId": caller.TenantId, "userId": caller.UserId, "idempotencyKey": record.Key}).
+		RunWith(tx).
+		QueryRow().
+		Scan(&count)
+	if err != nil || count > 0 {
+		return false, err
+	}
+
+	_, err = r.statement.Insert("idempotency_keys").
+		Columns(idempotencyColumns...).
+		Values(record.Key, caller.UserId, caller.TenantId, record.RequestHash, 0, "", "", "", record.AddedOn, record.ExpiresOn).
+		RunWith(tx).
+		Exec()
+	return err == nil, err
+}
+
+func (r *sqlTaskRepository) GetIdempotencyRecord(caller domain.Identity, key string) ([]domain.IdempotencyRecord, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	rows, err := r.statement.Select(idempotencyColumns...).
+		From("idempotency_keys").
+		Where(sq.Eq{"tenantId": caller.TenantId, "userId": caller.UserId, "idempotencyKey": key}).
+		RunWith(tx).
+		Query()
+
+	records := []domain.IdempotencyRecord{}
+	for err == nil && rows.Next() {
+		var record domain.IdempotencyRecord
+		err = rows.Scan(&record.Key, &record.UserId, &record.TenantId, &record.RequestHash, &record.StatusCode,
+			&record.ContentType, &record.ETag, &record.Body, &record.AddedOn, &record.ExpiresOn)
+		if err == nil {
+			records = append(records, record)
+		}
+	}
+	return records, err
+}
+
+// CompleteIdempotencyKey stores the response of the request that reserved
+// the key of record.
+func (r *sqlTaskRepository) CompleteIdempotencyKey(caller domain.Identity, record domain.IdempotencyRecord) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	_, err = r.statement.Update("idempotency_keys").
+		Set("statusCode", record.StatusCode).
+		Set("contentType", record.ContentType).
+		Set("etag", record.ETag).
+		Set("responseBody", record.Body).
+		Where(sq.Eq{"tenantId": caller.TenantId, "userId": caller.UserId, "idempotencyKey": record.Key}).
+		RunWith(tx).
+		Exec()
+	return err
+}
+
+// ReleaseIdempotencyKey forgets the key, letting a retry handle the request again.
+func (r *sqlTaskRepository) ReleaseIdempotencyKey(caller domain.Identity, key string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	_, err = r.statement.Delete("idempotency_keys").
+		Where(sq.Eq{"tenantId": caller.TenantId, "userId": caller.UserId, "idempotencyKey": key}).
+		RunWith(tx).
+		Exec()
+	return err
+}
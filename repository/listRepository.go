@@ -0,0 +1,287 @@
+package repository
+
+import (
+	"database/sql"
+	sq "github.com/Masterminds/squirrel"
+	"my-todo-app/domain"
+)
+
+var listColumns = []string{"id", "name", "ownerId", "addedOn", "tenantId"}
+
+// CreateList stores list with caller as its owner and first member.
+func (r *sqlTaskRepository) CreateList(caller domain.Identity, list domain.List) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return -1, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	id, err := r.insertReturningId(tx,
+		r.statement.Insert("lists").
+			Columns(listColumns[1:]...).
+			Values(list.GetName(), caller.UserId, list.AddedOn, caller.TenantId))
+	if err == nil {
+		err = r.addListMember(tx, id, caller.UserId, list.AddedOn)
+	}
+	if err != nil {
+		return -1, err
+	}
+	return id, nil
+}
+
+// GetLists returns every list caller is a member of, without their members.
+func (r *sqlTaskRepository) GetLists(caller domain.Identity) ([]domain.List, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	lists, err := r.queryLists(tx, listsOf(caller))
+	return lists, err
+}
+
+// GetListById returns the list with id together with its members, when caller is one of them.
+func (r *sqlTaskRepository) GetListById(caller domain.Identity, id string) ([]domain.List, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	lists, err := r.queryLists(tx, sq.And{listsOf(caller), sq.Eq{"id": parseId(id)}})
+	if err == nil && len(lists) > 0 {
+		lists[0].Members, err = r.getListMembers(tx, lists[0].GetId())
+	}
+	return lists, err
+}
+
+// UpdateList renames a list owned by caller.
+func (r *sqlTaskRepository) UpdateList(caller domain.Identity, list domain.List, id string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	_, err = r.statement.Update("lists").
+		Set("name", list.GetName()).
+		Where(ownedListOf(caller)).
+		Where(sq.Eq{"id": parseId(id)}).
+		RunWith(tx).
+		Exec()
+	return err
+}
+
+// DeleteList removes a list owned by caller. Its tasks are kept and go back
+// to being private to the users who created them.
+func (r *sqlTaskRepository) DeleteList(caller domain.Identity, id string) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	listId := parseId(id)
+	result, err := r.statement.Delete("lists").
+		Where(ownedListOf(caller)).
+		Where(sq.Eq{"id": listId}).
+		RunWith(tx).
+		Exec()
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil || rowsAffected == 0 {
+		return false, err
+	}
+
+	_, err = r.statement.Update("tasks").
+		Set("listId", 0).
+		Set("version", sq.Expr("version + 1")).
+		Where(sq.Eq{"listId": listId}).
+		RunWith(tx).
+		Exec()
+	if err == nil {
+		_, err = r.statement.Delete("list_members").
+			Where(sq.Eq{"listId": listId}).
+			RunWith(tx).
+			Exec()
+	}
+	return err == nil, err
+}
+
+// AddListMember shares a list owned by caller with the user with userId.
+func (r *sqlTaskRepository) AddListMember(caller domain.Identity, id string, userId int64, addedOn int64) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	listId := parseId(id)
+	owned, err := r.isListOwner(tx, caller, listId)
+	if err != nil || !owned {
+		return false, err
+	}
+
+	err = r.addListMember(tx, listId, userId, addedOn)
+	return err == nil, err
+}
+
+// RemoveListMember stops sharing a list with the user with userId. Owners
+// remove anyone but themselves, other members only themselves.
+func (r *sqlTaskRepository) RemoveListMember(caller domain.Identity, id string, userId int64) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	listId := parseId(id)
+	owned, err := r.isListOwner(tx, caller, listId)
+	if err != nil || owned == (userId == caller.UserId) {
+		return false, err
+	}
+
+	result, err := r.statement.Delete("list_members").
+		Where(sq.Eq{"listId": listId}).
+		Where(sq.Eq{"userId": userId}).
+		RunWith(tx).
+		Exec()
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected > 0, err
+}
+
+// GetListTasks pages through the tasks in the list with id the way GetAllTasks does.
+func (r *sqlTaskRepository) GetListTasks(caller domain.Identity, id string, page int64, perPage int64,
+	sort []domain.SortKey) ([]domain.Task, error) {
+	return r.getTaskPage(caller, page, perPage, sort, sq.Eq{"listId": parseId(id)})
+}
+
+func (r *sqlTaskRepository) queryLists(tx *sql.Tx, filter sq.Sqlizer) ([]domain.List, error) {
+	rows, err := r.statement.Select(listColumns...).
+		From("lists").
+		Where(filter).
+		OrderBy("id").
+		RunWith(tx).
+		Query()
+
+	lists := []domain.List{}
+	for err == nil && rows.Next() {
+		var list domain.List
+		err = rows.Scan(&list.Id, &list.Name, &list.OwnerId, &list.AddedOn, &list.TenantId)
+		if err == nil {
+			lists = append(lists, list)
+		}
+	}
+	return lists, err
+}
+
+func (r *sqlTaskRepository) getListMembers(tx *sql.Tx, listId int64) ([]domain.ListMember, error) {
+	rows, err := r.statement.Select("list_members.userId", "users.username", "list_members.addedOn").
+		From("list_members").
+		Join("users ON users.id = list_members.userId").
+		Where(sq.Eq{"list_members.listId": listId}).
+		OrderBy("list_members.userId").
+		RunWith(tx).
+		Query()
+
+	members := []domain.ListMember{}
+	for err == nil && rows.Next() {
+		var member domain.ListMember
+		err = rows.Scan(&member.UserId, &member.Username, &member.AddedOn)
+		if err == nil {
+			members = append(members, member)
+		}
+	}
+	return members, err
+}
+
+func (r *sqlTaskRepository) addListMember(tx *sql.Tx, listId int64, userId int64, addedOn int64) error {
+	_, err := r.statement.Insert("list_members").
+		Columns("listId", "userId", "addedOn").
+		Values(listId, userId, addedOn).
+		RunWith(tx).
+		Exec()
+	return err
+}
+
+func (r *sqlTaskRepository) isListOwner(tx *sql.Tx, caller domain.Identity, listId int64) (bool, error) {
+	var count int64
+	err := r.statement.Select("COUNT(*)").
+		From("lists").
+		Where(ownedListOf(caller)).
+		Where(sq.Eq{"id": listId}).
+		RunWith(tx).
+		QueryRow().
+		Scan(&count)
+	return count > 0, err
+}
+
+// listsOf restricts a query on lists to the ones caller is a member of, within its tenant.
+func listsOf(caller domain.Identity) sq.Sqlizer {
+	return sq.And{
+		sq.Eq{"tenantId": caller.TenantId},
+		sq.Expr("id IN (SELECT listId FROM list_members WHERE userId = ?)", caller.UserId),
+	}
+}
+
+// ownedListOf restricts a query on lists to the ones caller owns, within its tenant.
+func ownedListOf(caller domain.Identity) sq.Sqlizer {
+	return sq.Eq{"ownerId": caller.UserId, "tenantId": caller.TenantId}
+}
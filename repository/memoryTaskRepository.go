@@ -0,0 +1,1308 @@
+package repository
+
+import (
+	"fmt"
+	"my-todo-app/domain"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// memoryTaskRepository keeps tasks in process memory. It is safe for
+// concurrent use and is meant for tests and demo mode.
+type memoryTaskRepository struct {
+	mutex      sync.RWMutex
+	tasks      map[int64]domain.Task
+	nextId     int64
+	users      map[int64]domain.User
+	nextUserId int64
+	apiKeys    map[int64]domain.ApiKey
+	nextKeyId  int64
+	lists      map[int64]domain.List
+	nextListId int64
+	// members holds when each member joined, keyed by list id and user id
+	members         map[int64]map[int64]int64
+	history         map[int64][]domain.TaskRevision
+	comments        map[int64]domain.Comment
+	nextCommentId   int64
+	attachments     map[int64]domain.Attachment
+	nextAttachId    int64
+	idempotencyKeys map[idempotencyKey]domain.IdempotencyRecord
+	// dependencies holds the blockers of each task, keyed by task id and blocker id
+	dependencies map[int64]map[int64]domain.Dependency
+}
+
+// idempotencyKey identifies an Idempotency-Key of a user, keys of different
+// users never clash.
+type idempotencyKey struct {
+	tenantId string
+	userId   int64
+	key      string
+}
+
+func newMemoryTaskRepository() *memoryTaskRepository {
+	return &memoryTaskRepository{
+		tasks:           map[int64]domain.Task{},
+		nextId:          1,
+		users:           map[int64]domain.User{},
+		nextUserId:      1,
+		apiKeys:         map[int64]domain.ApiKey{},
+		nextKeyId:       1,
+		lists:           map[int64]domain.List{},
+		nextListId:      1,
+		members:         map[int64]map[int64]int64{},
+		history:         map[int64][]domain.TaskRevision{},
+		comments:        map[int64]domain.Comment{},
+		nextCommentId:   1,
+		attachments:     map[int64]domain.Attachment{},
+		nextAttachId:    1,
+		dependencies:    map[int64]map[int64]domain.Dependency{},
+		idempotencyKeys: map[idempotencyKey]domain.IdempotencyRecord{},
+	}
+}
+
+func (r *memoryTaskRepository) GetTaskById(caller domain.Identity, id string) ([]domain.Task, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	tasks := []domain.Task{}
+	if task, found := r.getVisibleTask(caller, parseId(id)); found {
+		tasks = append(tasks, readTask(task, r.rollups()))
+	}
+	return tasks, nil
+}
+
+func (r *memoryTaskRepository) GetAllTasks(caller domain.Identity, page int64, perPage int64, keys []domain.SortKey) ([]domain.Task, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.getTaskPage(caller, page, perPage, keys, nil), nil
+}
+
+func (r *memoryTaskRepository) CreateTask(caller domain.Identity, task domain.Task, revision domain.TaskRevision) (int64,
+	error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.createTask(caller, task, revision), nil
+}
+
+func (r *memoryTaskRepository) createTask(caller domain.Identity, task domain.Task, revision domain.TaskRevision) int64 {
+	task.SetId(r.nextId)
+	task.SetOwnerId(caller.UserId)
+	task.SetTenantId(caller.TenantId)
+	task.SetVersion(1)
+	r.tasks[task.GetId()] = cloneTask(task)
+	r.addRevision(caller, revision, domain.Task{}, task.GetId())
+	r.nextId++
+	return task.GetId()
+}
+
+func (r *memoryTaskRepository) UpdateTask(caller domain.Identity, task domain.Task, id string, next *domain.Task,
+	revision domain.TaskRevision) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	updated, err := r.updateTask(caller, task, parseId(id), revision)
+	if err == nil && !updated {
+		err = ErrTaskNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return r.createNextOccurrence(caller, next, revision), nil
+}
+
+func (r *memoryTaskRepository) updateTask(caller domain.Identity, task domain.Task, taskId int64,
+	revision domain.TaskRevision) (bool, error) {
+	existing, found := r.getVisibleTask(caller, taskId)
+	if !found {
+		return false, nil
+	}
+	if !isVersion(existing, task.GetVersion()) {
+		return false, ErrVersionConflict
+	}
+	task.SetId(taskId)
+	task.SetOwnerId(existing.GetOwnerId())
+	task.SetTenantId(existing.GetTenantId())
+	task.SetVersion(existing.GetVersion() + 1)
+	// nil tags were left out of the request and stay as they are
+	if task.GetTags() == nil {
+		task.SetTags(existing.GetTags())
+	}
+	r.tasks[taskId] = cloneTask(task)
+	r.addRevision(caller, revision, existing, taskId)
+	return true, nil
+}
+
+func (r *memoryTaskRepository) PatchTask(caller domain.Identity, task domain.Task, id string, fields []string,
+	next *domain.Task, revision domain.TaskRevision) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	taskId := parseId(id)
+	existing, found := r.getVisibleTask(caller, taskId)
+	if !found {
+		return 0, ErrTaskNotFound
+	}
+	if !isVersion(existing, task.GetVersion()) {
+		return 0, ErrVersionConflict
+	}
+	before := existing
+	existing.SetVersion(existing.GetVersion() + 1)
+	for _, field := range fields {
+		switch field {
+		case "title":
+			existing.SetTitle(task.GetTitle())
+		case "description":
+			existing.SetDescription(task.GetDescription())
+		case "added_on":
+			existing.SetAddedOn(task.GetAddedOn())
+		case "due_by":
+			existing.SetDueBy(task.GetDueBy())
+		case "status":
+			existing.SetStatus(task.GetStatus())
+		case "tags":
+			existing.SetTags(task.GetTags())
+		case "parent_id":
+			existing.SetParentId(task.GetParentId())
+		case "recurrence":
+			existing.SetRecurrence(task.GetRecurrence())
+		case "priority":
+			existing.SetPriority(task.GetPriority())
+		case "list_id":
+			existing.SetListId(task.GetListId())
+		}
+	}
+	r.tasks[taskId] = cloneTask(existing)
+	r.addRevision(caller, revision, before, taskId)
+	return r.createNextOccurrence(caller, next, revision), nil
+}
+
+// createNextOccurrence creates next, if any, along with the change recorded
+// as revision that completes its previous occurrence.
+func (r *memoryTaskRepository) createNextOccurrence(caller domain.Identity, next *domain.Task,
+	revision domain.TaskRevision) int64 {
+	if next == nil {
+		return 0
+	}
+	return r.createTask(caller, *next, getNextRevision(revision))
+}
+
+func (r *memoryTaskRepository) DeleteTask(caller domain.Identity, id string, version int64, deletedOn int64,
+	cascade bool, revision domain.TaskRevision) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.deleteTask(caller, parseId(id), version, deletedOn, cascade, revision)
+}
+
+func (r *memoryTaskRepository) deleteTask(caller domain.Identity, taskId int64, version int64, deletedOn int64,
+	cascade bool, revision domain.TaskRevision) (bool, error) {
+	if existing, found := r.getVisibleTask(caller, taskId); !found || !isVersion(existing, version) {
+		return false, nil
+	}
+	if !cascade && r.rollups()[taskId].Total > 0 {
+		return false, ErrHasSubtasks
+	}
+
+	// subtasks go together with their parent, services reject this up front when configured to;
+	// the ones already in the trash keep the time they were deleted on
+	r.setDeletedOn(caller, taskId, 0, deletedOn, revision)
+	return true, nil
+}
+
+// setDeletedOn moves the task with id and the subtasks below it deleted on
+// from to deletedOn, 0 taking them out of the trash, recording revision for
+// each of them.
+func (r *memoryTaskRepository) setDeletedOn(caller domain.Identity, taskId int64, from int64, deletedOn int64,
+	revision domain.TaskRevision) {
+	for level := []int64{taskId}; len(level) > 0; {
+		var next []int64
+		for _, parentId := range level {
+			before := r.tasks[parentId]
+			task := before
+			task.SetDeletedOn(deletedOn)
+			task.SetVersion(task.GetVersion() + 1)
+			r.tasks[parentId] = task
+			r.addRevision(caller, revision, before, parentId)
+			for childId, child := range r.tasks {
+				if child.GetParentId() == parentId && child.GetDeletedOn() == from {
+					next = append(next, childId)
+				}
+			}
+		}
+		level = next
+	}
+}
+
+// ApplyBatch stores writes in order. An atomic batch stops at the first write
+// that fails or finds no task at the expected version and puts every task
+// back the way it was, the other writes resulting in ErrBatchRolledBack.
+func (r *memoryTaskRepository) ApplyBatch(caller domain.Identity, writes []domain.TaskWrite, atomic bool) (
+	[]domain.TaskWriteResult, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	tasks, history, nextId := r.tasks, r.history, r.nextId
+	if atomic {
+		tasks = make(map[int64]domain.Task, len(r.tasks))
+		for id, task := range r.tasks {
+			tasks[id] = task
+		}
+		// revisions are only ever appended, the copied slices keep the old length
+		history = make(map[int64][]domain.TaskRevision, len(r.history))
+		for id, revisions := range r.history {
+			history[id] = revisions
+		}
+	}
+
+	results := make([]domain.TaskWriteResult, len(writes))
+	for i, write := range writes {
+		result := domain.TaskWriteResult{Id: write.Task.GetId()}
+		switch write.Op {
+		case domain.BatchCreate:
+			result.Id, result.Applied = r.createTask(caller, write.Task, write.Revision), true
+		case domain.BatchUpdate:
+			result.Applied, result.Err = r.updateTask(caller, write.Task, write.Task.GetId(), write.Revision)
+			if result.Applied {
+				result.NextId = r.createNextOccurrence(caller, write.Next, write.Revision)
+			}
+		case domain.BatchDelete:
+			result.Applied, result.Err = r.deleteTask(caller, write.Task.GetId(), write.Task.GetVersion(),
+				write.Task.GetDeletedOn(), write.Cascade, write.Revision)
+		}
+
+		if atomic && !result.Applied {
+			r.tasks, r.history, r.nextId = tasks, history, nextId
+			return rollBack(writes, i, result), nil
+		}
+		results[i] = result
+	}
+	return results, nil
+}
+
+func (r *memoryTaskRepository) SearchTasks(caller domain.Identity, params map[string]string) ([]domain.Task, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	filters, err := getMemoryFilters(params)
+	if err != nil {
+		return nil, err
+	}
+	if blocked, found := params["blocked"]; found {
+		// unlike the other filters, this one depends on other tasks
+		wanted := blocked != "false"
+		filters = append(filters, func(task domain.Task) bool {
+			return r.isBlocked(task) == wanted
+		})
+	}
+	keys, err := domain.ParseSort(params["sort"])
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := []domain.Task{}
+	for _, task := range r.sortedTasks(caller) {
+		if matchesAll(task, filters) {
+			tasks = append(tasks, task)
+		}
+	}
+	sortTasks(tasks, keys)
+	return paginate(tasks, getPageNumber(params["page"]), getPerPage(params["perPage"])), nil
+}
+
+func (r *memoryTaskRepository) GetTagCounts(caller domain.Identity) ([]domain.Tag, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	counts := map[string]int64{}
+	for _, task := range r.tasks {
+		if !r.isVisible(caller, task) {
+			continue
+		}
+		for _, name := range task.GetTags() {
+			counts[name]++
+		}
+	}
+
+	tags := make([]domain.Tag, 0, len(counts))
+	for name, count := range counts {
+		tags = append(tags, domain.Tag{Name: name, Count: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Name < tags[j].Name
+	})
+	return tags, nil
+}
+
+func (r *memoryTaskRepository) GetSubtasks(caller domain.Identity, id string) ([]domain.Task, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	parentId := parseId(id)
+	tasks := []domain.Task{}
+	for _, task := range r.sortedTasks(caller) {
+		if task.GetParentId() == parentId {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks, nil
+}
+
+func (r *memoryTaskRepository) GetTrash(caller domain.Identity, page int64, perPage int64) ([]domain.Task, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	rollups := r.rollups()
+	tasks := []domain.Task{}
+	for _, task := range r.tasks {
+		if task.GetDeletedOn() > 0 && r.isAccessible(caller, task) {
+			tasks = append(tasks, readTask(task, rollups))
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		if tasks[i].GetDeletedOn() != tasks[j].GetDeletedOn() {
+			return tasks[i].GetDeletedOn() > tasks[j].GetDeletedOn()
+		}
+		return tasks[i].GetId() < tasks[j].GetId()
+	})
+	if page == -1 || perPage == -1 {
+		return tasks, nil
+	}
+	return paginate(tasks, page, perPage), nil
+}
+
+func (r *memoryTaskRepository) GetTrashedTask(caller domain.Identity, id string) ([]domain.Task, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	tasks := []domain.Task{}
+	if task, found := r.getTrashedTask(caller, parseId(id)); found {
+		tasks = append(tasks, readTask(task, r.rollups()))
+	}
+	return tasks, nil
+}
+
+func (r *memoryTaskRepository) RestoreTask(caller domain.Identity, id string, revision domain.TaskRevision) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	task, found := r.getTrashedTask(caller, parseId(id))
+	if !found {
+		return false, nil
+	}
+	// subtasks deleted before their parent stay in the trash
+	r.setDeletedOn(caller, task.GetId(), task.GetDeletedOn(), 0, revision)
+	return true, nil
+}
+
+func (r *memoryTaskRepository) PurgeTrash(deletedBefore int64) (int64, []domain.Attachment, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var purged int64
+	for id, task := range r.tasks {
+		if task.GetDeletedOn() > 0 && task.GetDeletedOn() < deletedBefore {
+			delete(r.tasks, id)
+			delete(r.history, id)
+			purged++
+		}
+	}
+	for id, comment := range r.comments {
+		if _, found := r.tasks[comment.GetTaskId()]; !found {
+			delete(r.comments, id)
+		}
+	}
+	var attachments []domain.Attachment
+	for id, attachment := range r.attachments {
+		if _, found := r.tasks[attachment.GetTaskId()]; !found {
+			attachments = append(attachments, attachment)
+			delete(r.attachments, id)
+		}
+	}
+	for taskId, blockers := range r.dependencies {
+		if _, found := r.tasks[taskId]; !found {
+			delete(r.dependencies, taskId)
+			continue
+		}
+		for blockerId := range blockers {
+			if _, found := r.tasks[blockerId]; !found {
+				delete(blockers, blockerId)
+			}
+		}
+	}
+	return purged, attachments, nil
+}
+
+func (r *memoryTaskRepository) GetTaskHistory(caller domain.Identity, id string, page int64, perPage int64) (
+	[]domain.TaskRevision, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	revisions := []domain.TaskRevision{}
+	stored := r.history[parseId(id)]
+	for i := len(stored) - 1; i >= 0; i-- {
+		if stored[i].TenantId == caller.TenantId {
+			revisions = append(revisions, stored[i])
+		}
+	}
+	sort.SliceStable(revisions, func(i, j int) bool {
+		return revisions[i].Version > revisions[j].Version
+	})
+	if page == -1 || perPage == -1 {
+		return revisions, nil
+	}
+	start, end := page*perPage, (page+1)*perPage
+	if start > int64(len(revisions)) {
+		start = int64(len(revisions))
+	}
+	if end > int64(len(revisions)) {
+		end = int64(len(revisions))
+	}
+	return revisions[start:end], nil
+}
+
+func (r *memoryTaskRepository) GetTaskRevision(caller domain.Identity, id string, version int64) ([]domain.TaskRevision, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	revisions := []domain.TaskRevision{}
+	stored := r.history[parseId(id)]
+	for i := len(stored) - 1; i >= 0; i-- {
+		if stored[i].TenantId == caller.TenantId && stored[i].Version == version {
+			return append(revisions, stored[i]), nil
+		}
+	}
+	return revisions, nil
+}
+
+func (r *memoryTaskRepository) CreateComment(caller domain.Identity, comment domain.Comment) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	comment.Id = r.nextCommentId
+	comment.AuthorId = caller.UserId
+	comment.Author = caller.Username
+	comment.TenantId = caller.TenantId
+	r.comments[comment.Id] = comment
+	r.nextCommentId++
+	return comment.Id, nil
+}
+
+func (r *memoryTaskRepository) GetComments(caller domain.Identity, taskId string, page int64, perPage int64) (
+	[]domain.Comment, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	comments := []domain.Comment{}
+	for _, comment := range r.comments {
+		if comment.TenantId == caller.TenantId && comment.GetTaskId() == parseId(taskId) {
+			comments = append(comments, comment)
+		}
+	}
+	sort.Slice(comments, func(i, j int) bool {
+		if comments[i].GetAddedOn() != comments[j].GetAddedOn() {
+			return comments[i].GetAddedOn() < comments[j].GetAddedOn()
+		}
+		return comments[i].GetId() < comments[j].GetId()
+	})
+	if page == -1 || perPage == -1 {
+		return comments, nil
+	}
+	start := page * perPage
+	if start >= int64(len(comments)) {
+		return []domain.Comment{}, nil
+	}
+	end := start + perPage
+	if end > int64(len(comments)) {
+		end = int64(len(comments))
+	}
+	return comments[start:end], nil
+}
+
+func (r *memoryTaskRepository) GetCommentById(caller domain.Identity, taskId string, id string) ([]domain.Comment, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	comments := []domain.Comment{}
+	comment, found := r.comments[parseId(id)]
+	if found && comment.TenantId == caller.TenantId && comment.GetTaskId() == parseId(taskId) {
+		comments = append(comments, comment)
+	}
+	return comments, nil
+}
+
+func (r *memoryTaskRepository) UpdateComment(caller domain.Identity, comment domain.Comment, id string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	existing, found := r.getAuthoredComment(caller, comment.GetTaskId(), parseId(id))
+	if found {
+		existing.Body = comment.GetBody()
+		existing.UpdatedOn = comment.GetUpdatedOn()
+		r.comments[existing.Id] = existing
+	}
+	return found, nil
+}
+
+func (r *memoryTaskRepository) DeleteComment(caller domain.Identity, taskId string, id string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	comment, found := r.getAuthoredComment(caller, parseId(taskId), parseId(id))
+	if found {
+		delete(r.comments, comment.Id)
+	}
+	return found, nil
+}
+
+// getAuthoredComment mirrors authoredBy, looking up the comment with id on
+// the task with taskId among the ones caller wrote.
+func (r *memoryTaskRepository) getAuthoredComment(caller domain.Identity, taskId int64, id int64) (domain.Comment, bool) {
+	comment, found := r.comments[id]
+	return comment, found && comment.TenantId == caller.TenantId && comment.GetAuthorId() == caller.UserId &&
+		comment.GetTaskId() == taskId
+}
+
+func (r *memoryTaskRepository) CreateAttachment(caller domain.Identity, attachment domain.Attachment) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	attachment.Id = r.nextAttachId
+	attachment.UploaderId = caller.UserId
+	attachment.Uploader = caller.Username
+	attachment.TenantId = caller.TenantId
+	r.attachments[attachment.Id] = attachment
+	r.nextAttachId++
+	return attachment.Id, nil
+}
+
+func (r *memoryTaskRepository) GetAttachments(caller domain.Identity, taskId string) ([]domain.Attachment, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	attachments := []domain.Attachment{}
+	for _, attachment := range r.attachments {
+		if attachment.TenantId == caller.TenantId && attachment.GetTaskId() == parseId(taskId) {
+			attachments = append(attachments, attachment)
+		}
+	}
+	sort.Slice(attachments, func(i, j int) bool {
+		if attachments[i].GetAddedOn() != attachments[j].GetAddedOn() {
+			return attachments[i].GetAddedOn() < attachments[j].GetAddedOn()
+		}
+		return attachments[i].GetId() < attachments[j].GetId()
+	})
+	return attachments, nil
+}
+
+func (r *memoryTaskRepository) GetAttachmentById(caller domain.Identity, taskId string, id string) ([]domain.Attachment, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	attachments := []domain.Attachment{}
+	if attachment, found := r.getAttachment(caller, parseId(taskId), parseId(id)); found {
+		attachments = append(attachments, attachment)
+	}
+	return attachments, nil
+}
+
+func (r *memoryTaskRepository) DeleteAttachment(caller domain.Identity, taskId string, id string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	attachment, found := r.getAttachment(caller, parseId(taskId), parseId(id))
+	if found {
+		delete(r.attachments, attachment.Id)
+	}
+	return found, nil
+}
+
+func (r *memoryTaskRepository) getAttachment(caller domain.Identity, taskId int64, id int64) (domain.Attachment, bool) {
+	attachment, found := r.attachments[id]
+	return attachment, found && attachment.TenantId == caller.TenantId && attachment.GetTaskId() == taskId
+}
+
+func (r *memoryTaskRepository) AddDependency(caller domain.Identity, dependency domain.Dependency) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	taskId, blockerId := dependency.GetTaskId(), dependency.GetBlockedById()
+	if _, found := r.dependencies[taskId][blockerId]; found {
+		return false, nil
+	}
+	for _, id := range append(r.getBlockerIds(caller, blockerId), blockerId) {
+		if id == taskId {
+			return false, ErrDependencyCycle
+		}
+	}
+
+	if r.dependencies[taskId] == nil {
+		r.dependencies[taskId] = map[int64]domain.Dependency{}
+	}
+	dependency.TenantId = caller.TenantId
+	r.dependencies[taskId][blockerId] = dependency
+	return true, nil
+}
+
+func (r *memoryTaskRepository) RemoveDependency(caller domain.Identity, taskId string, blockerId string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	blockers := r.dependencies[parseId(taskId)]
+	dependency, found := blockers[parseId(blockerId)]
+	if !found || dependency.TenantId != caller.TenantId {
+		return false, nil
+	}
+	delete(blockers, parseId(blockerId))
+	return true, nil
+}
+
+func (r *memoryTaskRepository) GetBlockers(caller domain.Identity, taskId string) ([]domain.Task, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	blockers := r.dependencies[parseId(taskId)]
+	return r.getTaskPage(caller, -1, -1, nil, func(task domain.Task) bool {
+		_, found := blockers[task.GetId()]
+		return found
+	}), nil
+}
+
+func (r *memoryTaskRepository) IsBlocked(caller domain.Identity, taskId string) (bool, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for blockerId, dependency := range r.dependencies[parseId(taskId)] {
+		if dependency.TenantId == caller.TenantId && r.isOpen(blockerId) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// getBlockerIds mirrors its SQL namesake, listing every task blocking id
+// directly or through other tasks.
+func (r *memoryTaskRepository) getBlockerIds(caller domain.Identity, id int64) []int64 {
+	visited := map[int64]bool{id: true}
+	var blockers []int64
+	for level := []int64{id}; len(level) > 0; {
+		var next []int64
+		for _, taskId := range level {
+			for blockerId, dependency := range r.dependencies[taskId] {
+				if dependency.TenantId == caller.TenantId && !visited[blockerId] {
+					visited[blockerId] = true
+					next = append(next, blockerId)
+				}
+			}
+		}
+		blockers = append(blockers, next...)
+		level = next
+	}
+	return blockers
+}
+
+// isOpen reports whether the task with id still blocks the tasks depending
+// on it: it is neither done nor in the trash.
+func (r *memoryTaskRepository) isOpen(id int64) bool {
+	task, found := r.tasks[id]
+	return found && task.GetStatus() != completedStatus && task.GetDeletedOn() == 0
+}
+
+// isBlocked mirrors getBlockedFilter: a task is blocked while one of its
+// blockers is open.
+func (r *memoryTaskRepository) isBlocked(task domain.Task) bool {
+	for blockerId := range r.dependencies[task.GetId()] {
+		if r.isOpen(blockerId) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *memoryTaskRepository) CreateUser(user domain.User) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, existing := range r.users {
+		if existing.TenantId == user.TenantId && existing.Username == user.Username {
+			return -1, fmt.Errorf("username %s is already taken in tenant %s", user.Username, user.TenantId)
+		}
+	}
+	user.Id = r.nextUserId
+	r.users[user.Id] = user
+	r.nextUserId++
+	return user.Id, nil
+}
+
+func (r *memoryTaskRepository) GetUserById(id int64) ([]domain.User, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	users := []domain.User{}
+	if user, found := r.users[id]; found {
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func (r *memoryTaskRepository) GetUserByName(tenantId string, username string) ([]domain.User, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	users := []domain.User{}
+	for _, user := range r.users {
+		if user.TenantId == tenantId && user.Username == username {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func (r *memoryTaskRepository) UpdateUserRole(id int64, role string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	user, found := r.users[id]
+	if found {
+		user.Role = role
+		r.users[id] = user
+	}
+	return found, nil
+}
+
+func (r *memoryTaskRepository) CreateApiKey(caller domain.Identity, key domain.ApiKey) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key.Id = r.nextKeyId
+	key.UserId = caller.UserId
+	key.TenantId = caller.TenantId
+	key.Key = ""
+	r.apiKeys[key.Id] = key
+	r.nextKeyId++
+	return key.Id, nil
+}
+
+func (r *memoryTaskRepository) GetApiKeys(caller domain.Identity) ([]domain.ApiKey, error) {
+	return r.findApiKeys(func(key domain.ApiKey) bool {
+		return key.UserId == caller.UserId && key.TenantId == caller.TenantId
+	}), nil
+}
+
+func (r *memoryTaskRepository) GetApiKeyByHash(hash string) ([]domain.ApiKey, error) {
+	return r.findApiKeys(func(key domain.ApiKey) bool {
+		return key.KeyHash == hash
+	}), nil
+}
+
+func (r *memoryTaskRepository) TouchApiKey(id int64, usedOn int64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if key, found := r.apiKeys[id]; found {
+		key.LastUsedOn = usedOn
+		r.apiKeys[id] = key
+	}
+	return nil
+}
+
+func (r *memoryTaskRepository) DeleteApiKey(caller domain.Identity, id string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	keyId := parseId(id)
+	if key, found := r.apiKeys[keyId]; !found || key.UserId != caller.UserId || key.TenantId != caller.TenantId {
+		return false, nil
+	}
+	delete(r.apiKeys, keyId)
+	return true, nil
+}
+
+// findApiKeys returns the keys matching filter in id order.
+func (r *memoryTaskRepository) findApiKeys(filter func(key domain.ApiKey) bool) []domain.ApiKey {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	keys := []domain.ApiKey{}
+	for _, key := range r.apiKeys {
+		if filter(key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Id < keys[j].Id
+	})
+	return keys
+}
+
+func (r *memoryTaskRepository) ReserveIdempotencyKey(caller domain.Identity, record domain.IdempotencyRecord) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for key, stored := range r.idempotencyKeys {
+		if stored.ExpiresOn <= record.AddedOn {
+			delete(r.idempotencyKeys, key)
+		}
+	}
+	key := idempotencyKey{tenantId: caller.TenantId, userId: caller.UserId, key: record.Key}
+	if _, found := r.idempotencyKeys[key]; found {
+		return false, nil
+	}
+	record.UserId, record.TenantId = caller.UserId, caller.TenantId
+	record.StatusCode, record.ContentType, record.ETag, record.Body = 0, "", "", ""
+	r.idempotencyKeys[key] = record
+	return true, nil
+}
+
+func (r *memoryTaskRepository) GetIdempotencyRecord(caller domain.Identity, key string) ([]domain.IdempotencyRecord, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	records := []domain.IdempotencyRecord{}
+	if record, found := r.idempotencyKeys[idempotencyKey{tenantId: caller.TenantId, userId: caller.UserId, key: key}]; found {
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (r *memoryTaskRepository) CompleteIdempotencyKey(caller domain.Identity, record domain.IdempotencyRecord) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key := idempotencyKey{tenantId: caller.TenantId, userId: caller.UserId, key: record.Key}
+	if stored, found := r.idempotencyKeys[key]; found {
+		stored.StatusCode, stored.ContentType, stored.ETag, stored.Body = record.StatusCode, record.ContentType, record.ETag, record.Body
+		r.idempotencyKeys[key] = stored
+	}
+	return nil
+}
+
+func (r *memoryTaskRepository) ReleaseIdempotencyKey(caller domain.Identity, key string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.idempotencyKeys, idempotencyKey{tenantId: caller.TenantId, userId: caller.UserId, key: key})
+	return nil
+}
+
+func (r *memoryTaskRepository) CreateList(caller domain.Identity, list domain.List) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	list.Id = r.nextListId
+	list.OwnerId = caller.UserId
+	list.TenantId = caller.TenantId
+	list.Members = nil
+	r.lists[list.Id] = list
+	r.members[list.Id] = map[int64]int64{caller.UserId: list.AddedOn}
+	r.nextListId++
+	return list.Id, nil
+}
+
+func (r *memoryTaskRepository) GetLists(caller domain.Identity) ([]domain.List, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	lists := []domain.List{}
+	for _, list := range r.lists {
+		if r.isListMember(caller, list) {
+			lists = append(lists, list)
+		}
+	}
+	sort.Slice(lists, func(i, j int) bool {
+		return lists[i].Id < lists[j].Id
+	})
+	return lists, nil
+}
+
+func (r *memoryTaskRepository) GetListById(caller domain.Identity, id string) ([]domain.List, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	listId := parseId(id)
+	list, found := r.lists[listId]
+	if !found || !r.isListMember(caller, list) {
+		return []domain.List{}, nil
+	}
+
+	list.Members = []domain.ListMember{}
+	for userId, addedOn := range r.members[listId] {
+		list.Members = append(list.Members, domain.ListMember{UserId: userId, Username: r.users[userId].Username, AddedOn: addedOn})
+	}
+	sort.Slice(list.Members, func(i, j int) bool {
+		return list.Members[i].UserId < list.Members[j].UserId
+	})
+	return []domain.List{list}, nil
+}
+
+func (r *memoryTaskRepository) UpdateList(caller domain.Identity, list domain.List, id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if existing, found := r.getOwnedList(caller, parseId(id)); found {
+		existing.Name = list.GetName()
+		r.lists[existing.Id] = existing
+	}
+	return nil
+}
+
+func (r *memoryTaskRepository) DeleteList(caller domain.Identity, id string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	list, found := r.getOwnedList(caller, parseId(id))
+	if !found {
+		return false, nil
+	}
+
+	// tasks are kept and go back to being private to the users who created them
+	for taskId, task := range r.tasks {
+		if task.GetListId() == list.Id {
+			task.SetListId(0)
+			task.SetVersion(task.GetVersion() + 1)
+			r.tasks[taskId] = task
+		}
+	}
+	delete(r.lists, list.Id)
+	delete(r.members, list.Id)
+	return true, nil
+}
+
+func (r *memoryTaskRepository) AddListMember(caller domain.Identity, id string, userId int64, addedOn int64) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	list, found := r.getOwnedList(caller, parseId(id))
+	if !found {
+		return false, nil
+	}
+	if _, member := r.members[list.Id][userId]; member {
+		return false, fmt.Errorf("user %d is already a member of list %d", userId, list.Id)
+	}
+	r.members[list.Id][userId] = addedOn
+	return true, nil
+}
+
+func (r *memoryTaskRepository) RemoveListMember(caller domain.Identity, id string, userId int64) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	listId := parseId(id)
+	_, owned := r.getOwnedList(caller, listId)
+	if owned == (userId == caller.UserId) {
+		return false, nil
+	}
+	if _, member := r.members[listId][userId]; !member {
+		return false, nil
+	}
+	delete(r.members[listId], userId)
+	return true, nil
+}
+
+func (r *memoryTaskRepository) GetListTasks(caller domain.Identity, id string, page int64, perPage int64,
+	keys []domain.SortKey) ([]domain.Task, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	listId := parseId(id)
+	return r.getTaskPage(caller, page, perPage, keys, func(task domain.Task) bool {
+		return task.GetListId() == listId
+	}), nil
+}
+
+// getOwnedList looks up the list with id, unless caller does not own it.
+func (r *memoryTaskRepository) getOwnedList(caller domain.Identity, id int64) (domain.List, bool) {
+	list, found := r.lists[id]
+	return list, found && list.GetOwnerId() == caller.UserId && list.TenantId == caller.TenantId
+}
+
+// isListMember mirrors listsOf: callers see the lists of their tenant they are a member of.
+func (r *memoryTaskRepository) isListMember(caller domain.Identity, list domain.List) bool {
+	_, member := r.members[list.Id][caller.UserId]
+	return member && list.TenantId == caller.TenantId
+}
+
+// getVisibleTask looks up the stored task with id, unless caller may not see it.
+func (r *memoryTaskRepository) getVisibleTask(caller domain.Identity, id int64) (domain.Task, bool) {
+	task, found := r.tasks[id]
+	return task, found && r.isVisible(caller, task)
+}
+
+// getTrashedTask looks up the deleted task with id, unless caller may not see it.
+func (r *memoryTaskRepository) getTrashedTask(caller domain.Identity, id int64) (domain.Task, bool) {
+	task, found := r.tasks[id]
+	return task, found && task.GetDeletedOn() > 0 && r.isAccessible(caller, task)
+}
+
+// isVisible mirrors visibleTo: callers see the tasks of their tenant they own
+// and the ones in lists they are a member of, as long as they are not deleted.
+func (r *memoryTaskRepository) isVisible(caller domain.Identity, task domain.Task) bool {
+	return task.GetDeletedOn() == 0 && r.isAccessible(caller, task)
+}
+
+func (r *memoryTaskRepository) isAccessible(caller domain.Identity, task domain.Task) bool {
+	if task.GetTenantId() != caller.TenantId {
+		return false
+	}
+	_, member := r.members[task.GetListId()][caller.UserId]
+	return task.GetOwnerId() == caller.UserId || member
+}
+
+// getTaskPage lists the tasks of caller passing filter one page at a time,
+// or all of them when page or perPage is -1.
+func (r *memoryTaskRepository) getTaskPage(caller domain.Identity, page int64, perPage int64, keys []domain.SortKey,
+	filter memoryFilter) []domain.Task {
+	tasks := []domain.Task{}
+	for _, task := range r.sortedTasks(caller) {
+		if filter == nil || filter(task) {
+			tasks = append(tasks, task)
+		}
+	}
+	sortTasks(tasks, keys)
+	if page == -1 || perPage == -1 {
+		return tasks
+	}
+	return paginate(tasks, page, perPage)
+}
+
+// sortedTasks returns a copy of every task visible to caller in id order,
+// mirroring insertion order of the SQL backends.
+func (r *memoryTaskRepository) sortedTasks(caller domain.Identity) []domain.Task {
+	rollups := r.rollups()
+	tasks := make([]domain.Task, 0, len(r.tasks))
+	for _, task := range r.tasks {
+		if r.isVisible(caller, task) {
+			tasks = append(tasks, readTask(task, rollups))
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].GetId() < tasks[j].GetId()
+	})
+	return tasks
+}
+
+type memoryFilter func(task domain.Task) bool
+
+// getMemoryFilters translates search params into predicates with the same
+// semantics as the WHERE clauses built by getSearchQuery.
+func getMemoryFilters(params map[string]string) ([]memoryFilter, error) {
+	var filters []memoryFilter
+	for key, value := range params {
+		switch key {
+		case "id":
+			id := value
+			filters = append(filters, func(task domain.Task) bool {
+				return strconv.FormatInt(task.GetId(), 10) == id
+			})
+		case "status":
+			status := value
+			filters = append(filters, func(task domain.Task) bool {
+				return task.GetStatus() == status
+			})
+		case "parentId":
+			parentId := value
+			filters = append(filters, func(task domain.Task) bool {
+				return strconv.FormatInt(task.GetParentId(), 10) == parentId
+			})
+		case "listId":
+			listId := value
+			filters = append(filters, func(task domain.Task) bool {
+				return strconv.FormatInt(task.GetListId(), 10) == listId
+			})
+		case "priority":
+			priorities := strings.Split(value, ",")
+			filters = append(filters, func(task domain.Task) bool {
+				return containsString(priorities, task.GetPriority())
+			})
+		case "tag":
+			tags := strings.Split(value, ",")
+			matchAll := params["tagMatch"] == "all"
+			filters = append(filters, func(task domain.Task) bool {
+				return hasTags(task, tags, matchAll)
+			})
+		case "addedOnFrom", "dueByFrom", "addedOnTo", "dueByTo":
+			bound, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for %s: %s", key, value)
+			}
+			filters = append(filters, getRangeFilter(key, bound))
+		}
+	}
+	return filters, nil
+}
+
+func getRangeFilter(key string, bound int64) memoryFilter {
+	return func(task domain.Task) bool {
+		switch key {
+		case "addedOnFrom":
+			return task.GetAddedOn() >= bound
+		case "dueByFrom":
+			return task.GetDueBy() >= bound
+		case "addedOnTo":
+			return task.GetAddedOn() <= bound
+		default:
+			return task.GetDueBy() <= bound
+		}
+	}
+}
+
+// sortTasks orders tasks, which arrive in id order, by keys the way orderBy
+// does for the SQL backends.
+func sortTasks(tasks []domain.Task, keys []domain.SortKey) {
+	sort.SliceStable(tasks, func(i, j int) bool {
+		for _, key := range keys {
+			order := compareTasks(tasks[i], tasks[j], key.Column)
+			if key.Descending {
+				order = -order
+			}
+			if order != 0 {
+				return order < 0
+			}
+		}
+		return false
+	})
+}
+
+func compareTasks(a domain.Task, b domain.Task, column string) int {
+	switch column {
+	case "id":
+		return compareInts(a.GetId(), b.GetId())
+	case "addedOn":
+		return compareInts(a.GetAddedOn(), b.GetAddedOn())
+	case "dueBy":
+		return compareInts(a.GetDueBy(), b.GetDueBy())
+	case "title":
+		return strings.Compare(a.GetTitle(), b.GetTitle())
+	case "status":
+		return strings.Compare(a.GetStatus(), b.GetStatus())
+	default:
+		return strings.Compare(a.GetPriority(), b.GetPriority())
+	}
+}
+
+func compareInts(a int64, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+func hasTags(task domain.Task, tags []string, matchAll bool) bool {
+	matched := 0
+	for _, tag := range tags {
+		for _, name := range task.GetTags() {
+			if name == tag {
+				matched++
+				break
+			}
+		}
+	}
+	if matchAll {
+		return matched == len(tags)
+	}
+	return matched > 0
+}
+
+func matchesAll(task domain.Task, filters []memoryFilter) bool {
+	for _, filter := range filters {
+		if !filter(task) {
+			return false
+		}
+	}
+	return true
+}
+
+func paginate(tasks []domain.Task, page int64, perPage int64) []domain.Task {
+	start := page * perPage
+	if start >= int64(len(tasks)) {
+		return []domain.Task{}
+	}
+	end := start + perPage
+	if end > int64(len(tasks)) {
+		end = int64(len(tasks))
+	}
+	return tasks[start:end]
+}
+
+// rollups counts the children outside the trash of every parent, and how many
+// of them are done.
+func (r *memoryTaskRepository) rollups() map[int64]domain.SubtaskRollup {
+	rollups := map[int64]domain.SubtaskRollup{}
+	for _, task := range r.tasks {
+		if task.GetParentId() == 0 || task.GetDeletedOn() > 0 {
+			continue
+		}
+		rollup := rollups[task.GetParentId()]
+		rollup.Total++
+		if task.GetStatus() == completedStatus {
+			rollup.Done++
+		}
+		rollups[task.GetParentId()] = rollup
+	}
+	return rollups
+}
+
+// readTask copies a stored task and attaches its subtask rollup the way the SQL backends do.
+func readTask(task domain.Task, rollups map[int64]domain.SubtaskRollup) domain.Task {
+	task = cloneTask(task)
+	if rollup, found := rollups[task.GetId()]; found {
+		task.SetSubtasks(&rollup)
+	}
+	return task
+}
+
+// addRevision records revision for the task with id as it is stored now,
+// completed with its change from before. Nothing is recorded for a revision
+// without an action.
+func (r *memoryTaskRepository) addRevision(caller domain.Identity, revision domain.TaskRevision, before domain.Task,
+	id int64) {
+	if revision.Action == "" {
+		return
+	}
+	revision = completeRevision(revision, before, cloneTask(r.tasks[id]))
+	revision.TenantId = caller.TenantId
+	r.history[id] = append(r.history[id], revision)
+}
+
+// cloneTask copies the tags of task and drops computed fields, so callers
+// never share state with the store. Like the SQL backends, a task without
+// tags has nil tags.
+func cloneTask(task domain.Task) domain.Task {
+	if len(task.GetTags()) == 0 {
+		task.SetTags(nil)
+	} else {
+		task.SetTags(append([]string{}, task.GetTags()...))
+	}
+	task.SetSubtasks(nil)
+	task.SetChildren(nil)
+	return task
+}
+
+// parseId returns -1 for ids that can never match a stored task.
+func parseId(id string) int64 {
+	taskId, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return taskId
+}
+
+// isVersion reports whether task is at version, any version matching 0.
+func isVersion(task domain.Task, version int64) bool {
+	return version == 0 || task.GetVersion() == version
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"my-todo-app/domain"
+	"my-todo-app/testUtils"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestMemoryTaskRepository(t *testing.T) {
+	store, err := NewRepository(MemoryDriver, "")
+	if err != nil {
+		t.Fatalf("Error opening memory repository: %s", err)
+	}
+
+	runTaskRepositoryContract(t, store)
+}
+
+func TestMemoryTaskRepositoryConcurrentWrites(t *testing.T) {
+	store := newMemoryTaskRepository()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id, _ := store.CreateTask(testUtils.Caller, domain.Task{Title: "concurrent", Status: "open"}, domain.TaskRevision{})
+			_, _ = store.UpdateTask(testUtils.Caller, domain.Task{Title: "concurrent", Status: strconv.Itoa(i)}, strconv.FormatInt(id, 10), nil, domain.TaskRevision{})
+			_, _ = store.SearchTasks(testUtils.Caller, map[string]string{"status": "open"})
+		}(i)
+	}
+	wg.Wait()
+
+	tasks, _ := store.GetAllTasks(testUtils.Caller, -1, -1, nil)
+	if len(tasks) != 50 {
+		t.Errorf("Expected 50 tasks, Got: %d", len(tasks))
+	}
+}
+
+func TestMemoryTaskRepositoryRejectsInvalidRange(t *testing.T) {
+	store := newMemoryTaskRepository()
+
+	_, err := store.SearchTasks(testUtils.Caller, map[string]string{"dueByFrom": "tomorrow"})
+	if err == nil {
+		t.Error("Expected error for non numeric range filter")
+	}
+}
+
+func TestNewDemoRepository(t *testing.T) {
+	store := NewDemoRepository()
+	users, err := store.GetUserByName(domain.DefaultTenant, DemoUsername)
+	if err != nil || len(users) != 1 || !users[0].CheckPassword(DemoPassword) {
+		t.Fatalf("Expected seeded demo user, Got: %v, error: %v", users, err)
+	}
+
+	tasks, err := store.GetAllTasks(users[0].Identity(), -1, -1, nil)
+	if err != nil || len(tasks) == 0 {
+		t.Errorf("Expected seeded tasks, Got: %v, error: %v", tasks, err)
+	}
+}
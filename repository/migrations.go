@@ -0,0 +1,566 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	sq "github.com/Masterminds/squirrel"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// migration is one versioned schema change. Statements may use {{serial}}
+// for the dialect's auto-increment primary key definition, and are written
+// with MySQL's "DROP INDEX name ON table", which is rewritten where needed.
+// Changes the dialects spell differently go in upOn and downOn, keyed by
+// dialect name, which replace up and down on those dialects.
+type migration struct {
+	version int64
+	name    string
+	up      []string
+	down    []string
+	upOn    map[string][]string
+	downOn  map[string][]string
+}
+
+// migrations must stay ordered by version; never edit one that has shipped,
+// append a new version instead.
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create tasks",
+		up: []string{
+			`CREATE TABLE IF NOT EXISTS tasks (
+				id {{serial}},
+				title TEXT NOT NULL,
+				description TEXT NOT NULL,
+				addedOn BIGINT NOT NULL,
+				dueBy BIGINT NOT NULL,
+				status TEXT NOT NULL)`,
+		},
+		down: []string{`DROP TABLE tasks`},
+	},
+	{
+		version: 2,
+		name:    "create tags",
+		up: []string{
+			`CREATE TABLE tags (
+				id {{serial}},
+				name VARCHAR(255) NOT NULL UNIQUE)`,
+			`CREATE TABLE task_tags (
+				taskId BIGINT NOT NULL,
+				tagId BIGINT NOT NULL,
+				PRIMARY KEY (taskId, tagId))`,
+			`CREATE INDEX task_tags_tagId ON task_tags (tagId)`,
+		},
+		down: []string{`DROP TABLE task_tags`, `DROP TABLE tags`},
+	},
+	{
+		version: 3,
+		name:    "add task parent",
+		up: []string{
+			`ALTER TABLE tasks ADD COLUMN parentId BIGINT NOT NULL DEFAULT 0`,
+			`CREATE INDEX tasks_parentId ON tasks (parentId)`,
+		},
+		down: []string{
+			`DROP INDEX tasks_parentId ON tasks`,
+			`ALTER TABLE tasks DROP COLUMN parentId`,
+		},
+	},
+	{
+		// statuses are matched against the configured workflow in lowercase,
+		// rolling back leaves them lowercase
+		version: 4,
+		name:    "normalize task status",
+		up:      []string{`UPDATE tasks SET status = LOWER(TRIM(status))`},
+	},
+	{
+		version: 5,
+		name:    "add task recurrence",
+		up:      []string{`ALTER TABLE tasks ADD COLUMN recurrence VARCHAR(255) NOT NULL DEFAULT ''`},
+		down:    []string{`ALTER TABLE tasks DROP COLUMN recurrence`},
+	},
+	{
+		version: 6,
+		name:    "add task priority",
+		up: []string{
+			`ALTER TABLE tasks ADD COLUMN priority VARCHAR(2) NOT NULL DEFAULT 'P2'`,
+			`CREATE INDEX tasks_priority ON tasks (priority)`,
+		},
+		down: []string{
+			`DROP INDEX tasks_priority ON tasks`,
+			`ALTER TABLE tasks DROP COLUMN priority`,
+		},
+	},
+	{
+		// tasks created before accounts existed keep owner 0 and belong to no one
+		version: 7,
+		name:    "create users and task owner",
+		up: []string{
+			`CREATE TABLE users (
+				id {{serial}},
+				username VARCHAR(255) NOT NULL UNIQUE,
+				passwordHash VARCHAR(255) NOT NULL,
+				addedOn BIGINT NOT NULL)`,
+			`ALTER TABLE tasks ADD COLUMN ownerId BIGINT NOT NULL DEFAULT 0`,
+			`CREATE INDEX tasks_ownerId ON tasks (ownerId)`,
+		},
+		down: []string{
+			`DROP INDEX tasks_ownerId ON tasks`,
+			`ALTER TABLE tasks DROP COLUMN ownerId`,
+			`DROP TABLE users`,
+		},
+	},
+	{
+		version: 8,
+		name:    "create api keys",
+		up: []string{
+			`CREATE TABLE api_keys (
+				id {{serial}},
+				userId BIGINT NOT NULL,
+				name VARCHAR(255) NOT NULL,
+				prefix VARCHAR(16) NOT NULL,
+				keyHash VARCHAR(64) NOT NULL UNIQUE,
+				scope VARCHAR(16) NOT NULL,
+				addedOn BIGINT NOT NULL,
+				lastUsedOn BIGINT NOT NULL DEFAULT 0)`,
+			`CREATE INDEX api_keys_userId ON api_keys (userId)`,
+		},
+		down: []string{`DROP TABLE api_keys`},
+	},
+	{
+		version: 9,
+		name:    "add user role",
+		up:      []string{`ALTER TABLE users ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'editor'`},
+		down:    []string{`ALTER TABLE users DROP COLUMN role`},
+	},
+	{
+		version: 10,
+		name:    "create lists",
+		up: []string{
+			`CREATE TABLE lists (
+				id {{serial}},
+				name VARCHAR(255) NOT NULL,
+				ownerId BIGINT NOT NULL,
+				addedOn BIGINT NOT NULL)`,
+			`CREATE TABLE list_members (
+				listId BIGINT NOT NULL,
+				userId BIGINT NOT NULL,
+				addedOn BIGINT NOT NULL,
+				PRIMARY KEY (listId, userId))`,
+			`CREATE INDEX list_members_userId ON list_members (userId)`,
+			`ALTER TABLE tasks ADD COLUMN listId BIGINT NOT NULL DEFAULT 0`,
+			`CREATE INDEX tasks_listId ON tasks (listId)`,
+		},
+		down: []string{
+			`DROP INDEX tasks_listId ON tasks`,
+			`ALTER TABLE tasks DROP COLUMN listId`,
+			`DROP TABLE list_members`,
+			`DROP TABLE lists`,
+		},
+	},
+	{
+		version: 11,
+		name:    "add tenants",
+		up: []string{
+			`ALTER TABLE tasks ADD COLUMN tenantId VARCHAR(64) NOT NULL DEFAULT 'default'`,
+			`CREATE INDEX tasks_tenantId ON tasks (tenantId)`,
+			`ALTER TABLE users ADD COLUMN tenantId VARCHAR(64) NOT NULL DEFAULT 'default'`,
+			`ALTER TABLE lists ADD COLUMN tenantId VARCHAR(64) NOT NULL DEFAULT 'default'`,
+			`ALTER TABLE api_keys ADD COLUMN tenantId VARCHAR(64) NOT NULL DEFAULT 'default'`,
+		},
+		down: []string{
+			`ALTER TABLE api_keys DROP COLUMN tenantId`,
+			`ALTER TABLE lists DROP COLUMN tenantId`,
+			`ALTER TABLE users DROP COLUMN tenantId`,
+			`DROP INDEX tasks_tenantId ON tasks`,
+			`ALTER TABLE tasks DROP COLUMN tenantId`,
+		},
+	},
+	{
+		version: 12,
+		name:    "add task version",
+		up: []string{
+			`ALTER TABLE tasks ADD COLUMN version BIGINT NOT NULL DEFAULT 1`,
+		},
+		down: []string{
+			`ALTER TABLE tasks DROP COLUMN version`,
+		},
+	},
+	{
+		version: 13,
+		name:    "create idempotency keys",
+		up: []string{
+			`CREATE TABLE idempotency_keys (
+				idempotencyKey VARCHAR(255) NOT NULL,
+				userId BIGINT NOT NULL,
+				tenantId VARCHAR(64) NOT NULL,
+				requestHash VARCHAR(64) NOT NULL,
+				statusCode INT NOT NULL DEFAULT 0,
+				contentType VARCHAR(255) NOT NULL DEFAULT '',
+				etag VARCHAR(255) NOT NULL DEFAULT '',
+				responseBody TEXT NOT NULL,
+				addedOn BIGINT NOT NULL,
+				expiresOn BIGINT NOT NULL,
+				PRIMARY KEY (tenantId, userId, idempotencyKey))`,
+			`CREATE INDEX idempotency_keys_expiresOn ON idempotency_keys (expiresOn)`,
+		},
+		down: []string{`DROP TABLE idempotency_keys`},
+	},
+	{
+		version: 14,
+		name:    "add task trash",
+		up: []string{
+			`ALTER TABLE tasks ADD COLUMN deletedOn BIGINT NOT NULL DEFAULT 0`,
+			`CREATE INDEX tasks_deletedOn ON tasks (deletedOn)`,
+		},
+		down: []string{
+			`DROP INDEX tasks_deletedOn ON tasks`,
+			`ALTER TABLE tasks DROP COLUMN deletedOn`,
+		},
+	},
+	{
+		version: 15,
+		name:    "create task history",
+		up: []string{
+			`CREATE TABLE task_history (
+				id {{serial}},
+				taskId BIGINT NOT NULL,
+				tenantId VARCHAR(64) NOT NULL,
+				version BIGINT NOT NULL,
+				action VARCHAR(16) NOT NULL,
+				actorId BIGINT NOT NULL,
+				actor VARCHAR(255) NOT NULL,
+				changedOn BIGINT NOT NULL,
+				revertedTo BIGINT NOT NULL DEFAULT 0,
+				changes TEXT NOT NULL,
+				snapshot TEXT NOT NULL)`,
+			`CREATE INDEX task_history_taskId ON task_history (taskId)`,
+		},
+		down: []string{`DROP TABLE task_history`},
+	},
+	{
+		version: 16,
+		name:    "create comments",
+		up: []string{
+			`CREATE TABLE comments (
+				id {{serial}},
+				taskId BIGINT NOT NULL,
+				tenantId VARCHAR(64) NOT NULL,
+				authorId BIGINT NOT NULL,
+				author VARCHAR(255) NOT NULL,
+				body TEXT NOT NULL,
+				addedOn BIGINT NOT NULL,
+				updatedOn BIGINT NOT NULL)`,
+			`CREATE INDEX comments_taskId ON comments (taskId)`,
+		},
+		down: []string{`DROP TABLE comments`},
+	},
+	{
+		version: 17,
+		name:    "create attachments",
+		up: []string{
+			`CREATE TABLE attachments (
+				id {{serial}},
+				taskId BIGINT NOT NULL,
+				tenantId VARCHAR(64) NOT NULL,
+				name VARCHAR(255) NOT NULL,
+				contentType VARCHAR(255) NOT NULL,
+				size BIGINT NOT NULL,
+				uploaderId BIGINT NOT NULL,
+				uploader VARCHAR(255) NOT NULL,
+				addedOn BIGINT NOT NULL,
+				storageKey VARCHAR(255) NOT NULL)`,
+			`CREATE INDEX attachments_taskId ON attachments (taskId)`,
+		},
+		down: []string{`DROP TABLE attachments`},
+	},
+	{
+		version: 18,
+		name:    "create task dependencies",
+		up: []string{
+			`CREATE TABLE task_dependencies (
+				taskId BIGINT NOT NULL,
+				blockedById BIGINT NOT NULL,
+				tenantId VARCHAR(64) NOT NULL,
+				addedOn BIGINT NOT NULL,
+				PRIMARY KEY (taskId, blockedById))`,
+			`CREATE INDEX task_dependencies_blockedById ON task_dependencies (blockedById)`,
+		},
+		down: []string{`DROP TABLE task_dependencies`},
+	},
+	{
+		// SQLite cannot drop the UNIQUE constraint of a column, so it rebuilds
+		// the table; rolling back fails while two tenants share a username
+		version: 19,
+		name:    "scope usernames per tenant",
+		up: []string{
+			`CREATE TABLE users_by_tenant (
+				id {{serial}},
+				username VARCHAR(255) NOT NULL,
+				passwordHash VARCHAR(255) NOT NULL,
+				addedOn BIGINT NOT NULL,
+				role VARCHAR(16) NOT NULL DEFAULT 'editor',
+				tenantId VARCHAR(64) NOT NULL DEFAULT 'default')`,
+			`INSERT INTO users_by_tenant (id, username, passwordHash, addedOn, role, tenantId)
+				SELECT id, username, passwordHash, addedOn, role, tenantId FROM users`,
+			`DROP TABLE users`,
+			`ALTER TABLE users_by_tenant RENAME TO users`,
+			`CREATE UNIQUE INDEX users_tenantId_username ON users (tenantId, username)`,
+		},
+		down: []string{
+			`CREATE TABLE users_by_name (
+				id {{serial}},
+				username VARCHAR(255) NOT NULL UNIQUE,
+				passwordHash VARCHAR(255) NOT NULL,
+				addedOn BIGINT NOT NULL,
+				role VARCHAR(16) NOT NULL DEFAULT 'editor',
+				tenantId VARCHAR(64) NOT NULL DEFAULT 'default')`,
+			`INSERT INTO users_by_name (id, username, passwordHash, addedOn, role, tenantId)
+				SELECT id, username, passwordHash, addedOn, role, tenantId FROM users`,
+			`DROP TABLE users`,
+			`ALTER TABLE users_by_name RENAME TO users`,
+		},
+		upOn: map[string][]string{
+			mysqlDialect.name: {
+				`DROP INDEX username ON users`,
+				`CREATE UNIQUE INDEX users_tenantId_username ON users (tenantId, username)`,
+			},
+			postgresDialect.name: {
+				`ALTER TABLE users DROP CONSTRAINT users_username_key`,
+				`CREATE UNIQUE INDEX users_tenantId_username ON users (tenantId, username)`,
+			},
+		},
+		downOn: map[string][]string{
+			mysqlDialect.name: {
+				`DROP INDEX users_tenantId_username ON users`,
+				`CREATE UNIQUE INDEX username ON users (username)`,
+			},
+			postgresDialect.name: {
+				`DROP INDEX users_tenantId_username ON users`,
+				`ALTER TABLE users ADD CONSTRAINT users_username_key UNIQUE (username)`,
+			},
+		},
+	},
+	{
+		// TEXT holds 64 KB on MySQL, too little for the response to a batch;
+		// the other dialects have no such limit
+		version: 20,
+		name:    "widen idempotent response body",
+		upOn: map[string][]string{
+			mysqlDialect.name: {`ALTER TABLE idempotency_keys MODIFY responseBody LONGTEXT NOT NULL`},
+		},
+		downOn: map[string][]string{
+			mysqlDialect.name: {`ALTER TABLE idempotency_keys MODIFY responseBody TEXT NOT NULL`},
+		},
+	},
+}
+
+const schemaMigrationsQuery = `CREATE TABLE IF NOT EXISTS schema_migrations (
+						version BIGINT PRIMARY KEY NOT NULL,
+						name VARCHAR(255) NOT NULL,
+						appliedOn BIGINT NOT NULL)`
+
+// MigrationStatus reports whether a migration known to the code has been applied.
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedOn int64
+}
+
+// Migrator applies and rolls back the versioned schema of a SQL backend.
+type Migrator struct {
+	db        *sql.DB
+	dialect   dialect
+	statement sq.StatementBuilderType
+}
+
+// NewMigrator connects to the SQL backend selected by driver. The memory
+// backend has no schema and is rejected.
+func NewMigrator(driver string, dsn string) (*Migrator, error) {
+	d, found := dialects[driver]
+	if !found {
+		return nil, fmt.Errorf("sql driver %s has no schema to migrate", driver)
+	}
+
+	database, err := connectDatabase(d, dsn)
+	if err != nil {
+		return nil, err
+	}
+	return newMigrator(database, d), nil
+}
+
+func newMigrator(database *sql.DB, d dialect) *Migrator {
+	return &Migrator{
+		db:        database,
+		dialect:   d,
+		statement: sq.StatementBuilder.PlaceholderFormat(d.placeholder),
+	}
+}
+
+func (m *Migrator) Close() error {
+	return m.db.Close()
+}
+
+// Status lists every migration known to the code, oldest first.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		appliedOn, found := applied[migration.version]
+		statuses = append(statuses, MigrationStatus{
+			Version:   migration.version,
+			Name:      migration.name,
+			Applied:   found,
+			AppliedOn: appliedOn,
+		})
+	}
+	return statuses, nil
+}
+
+// Up applies every pending migration in version order and returns the ones it applied.
+func (m *Migrator) Up() ([]MigrationStatus, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []MigrationStatus
+	for i, status := range statuses {
+		if status.Applied {
+			continue
+		}
+		if err = m.apply(migrations[i], true); err != nil {
+			return done, fmt.Errorf("migration %d (%s) failed: %s", status.Version, status.Name, err)
+		}
+		done = append(done, status)
+	}
+	return done, nil
+}
+
+// Down rolls back the latest steps applied migrations, newest first.
+func (m *Migrator) Down(steps int) ([]MigrationStatus, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []MigrationStatus
+	for i := len(statuses) - 1; i >= 0 && len(done) < steps; i-- {
+		if !statuses[i].Applied {
+			continue
+		}
+		if err = m.apply(migrations[i], false); err != nil {
+			return done, fmt.Errorf("rollback of migration %d (%s) failed: %s", statuses[i].Version, statuses[i].Name, err)
+		}
+		done = append(done, statuses[i])
+	}
+	return done, nil
+}
+
+// Pending reports how many migrations known to the code are not applied yet.
+func (m *Migrator) Pending() (int, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return 0, err
+	}
+
+	pending := 0
+	for _, status := range statuses {
+		if !status.Applied {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// apply runs one migration and records it in a single transaction. MySQL
+// commits DDL implicitly, so a failing statement there can leave earlier ones applied.
+func (m *Migrator) apply(migration migration, up bool) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	for _, statement := range migration.statements(m.dialect, up) {
+		if _, err = tx.Exec(m.dialect.expand(statement)); err != nil {
+			return err
+		}
+	}
+
+	if up {
+		_, err = m.statement.Insert("schema_migrations").
+			Columns("version", "name", "appliedOn").
+			Values(migration.version, migration.name, time.Now().UnixNano()/int64(time.Millisecond)).
+			RunWith(tx).
+			Exec()
+	} else {
+		_, err = m.statement.Delete("schema_migrations").
+			Where(sq.Eq{"version": migration.version}).
+			RunWith(tx).
+			Exec()
+	}
+	return err
+}
+
+// statements returns what migration runs on d, rolling it back unless up.
+func (m migration) statements(d dialect, up bool) []string {
+	if up {
+		if statements, found := m.upOn[d.name]; found {
+			return statements
+		}
+		return m.up
+	}
+	if statements, found := m.downOn[d.name]; found {
+		return statements
+	}
+	return m.down
+}
+
+func (m *Migrator) appliedMigrations() (map[int64]int64, error) {
+	if _, err := m.db.Exec(schemaMigrationsQuery); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.statement.Select("version", "appliedOn").
+		From("schema_migrations").
+		RunWith(m.db).
+		Query()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	applied := map[int64]int64{}
+	for rows.Next() {
+		var version, appliedOn int64
+		if err = rows.Scan(&version, &appliedOn); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedOn
+	}
+	return applied, rows.Err()
+}
+
+var dropIndexOnTable = regexp.MustCompile(`DROP INDEX (\w+) ON \w+`)
+
+func (d dialect) expand(statement string) string {
+	statement = strings.ReplaceAll(statement, "{{serial}}", d.serialPrimaryKey)
+	if !d.dropIndexOnTable {
+		statement = dropIndexOnTable.ReplaceAllString(statement, "DROP INDEX $1")
+	}
+	return statement
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrationsAreOrdered(t *testing.T) {
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].version <= migrations[i-1].version {
+			t.Errorf("Migration %d (%s) is not after version %d",
+				migrations[i].version, migrations[i].name, migrations[i-1].version)
+		}
+	}
+}
+
+func TestMigratorUpDownStatus(t *testing.T) {
+	migrator, err := NewMigrator("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Error opening migrator: %s", err)
+	}
+	defer func() { _ = migrator.Close() }()
+
+	applied, err := migrator.Up()
+	if err != nil || len(applied) != len(migrations) {
+		t.Fatalf("Expected %d migrations to be applied, Got: %v, error: %v", len(migrations), applied, err)
+	}
+
+	applied, err = migrator.Up()
+	if err != nil || len(applied) != 0 {
+		t.Errorf("Expected second run to apply nothing, Got: %v, error: %v", applied, err)
+	}
+
+	rolledBack, err := migrator.Down(1)
+	latest := migrations[len(migrations)-1]
+	if err != nil || len(rolledBack) != 1 || rolledBack[0].Version != latest.version {
+		t.Errorf("Expected migration %d to be rolled back, Got: %v, error: %v", latest.version, rolledBack, err)
+	}
+
+	statuses, err := migrator.Status()
+	if err != nil || len(statuses) != len(migrations) || statuses[len(statuses)-1].Applied {
+		t.Errorf("Expected latest migration to be pending, Got: %v, error: %v", statuses, err)
+	}
+
+	rolledBack, err = migrator.Down(len(migrations))
+	if err != nil || len(rolledBack) != len(migrations)-1 {
+		t.Errorf("Expected remaining migrations to be rolled back, Got: %v, error: %v", rolledBack, err)
+	}
+
+	pending, err := migrator.Pending()
+	if err != nil || pending != len(migrations) {
+		t.Errorf("Expected %d pending migrations, Got: %d, error: %v", len(migrations), pending, err)
+	}
+}
+
+func TestNewRepositoryRefusesOutdatedSchema(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "todo_app.db")
+
+	if _, err := openSqlTaskRepository(sqliteDialect, dsn, false); err == nil {
+		t.Fatal("Expected error while schema is behind")
+	}
+
+	migrator, err := NewMigrator("sqlite", dsn)
+	if err != nil {
+		t.Fatalf("Error opening migrator: %s", err)
+	}
+	if _, err = migrator.Up(); err != nil {
+		t.Fatalf("Error applying migrations: %s", err)
+	}
+	_ = migrator.Close()
+
+	if _, err = openSqlTaskRepository(sqliteDialect, dsn, false); err != nil {
+		t.Errorf("Expected repository to open after migrating, got error: %s", err)
+	}
+}
+
+func TestNewMigratorRejectsMemoryDriver(t *testing.T) {
+	if _, err := NewMigrator(MemoryDriver, ""); err == nil {
+		t.Error("Expected error for memory driver")
+	}
+}
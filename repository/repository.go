@@ -0,0 +1,230 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/simukti/sqldb-logger"
+	"github.com/simukti/sqldb-logger/logadapter/zapadapter"
+	"go.uber.org/zap"
+	"my-todo-app/config"
+	"my-todo-app/domain"
+)
+
+// MemoryDriver selects the in-process backend; its DSN is ignored.
+const MemoryDriver = "memory"
+
+var (
+	logger      *zap.Logger
+	autoMigrate bool
+	// completedStatus is the status of tasks counted as done
+	completedStatus string
+)
+
+// ErrVersionConflict is returned when a task to change is no longer at the
+// version the change was based on.
+var ErrVersionConflict = errors.New("task was changed since the expected version")
+
+// ErrTaskNotFound is returned when a task to change is gone or out of reach
+// of the caller by the time the change is written.
+var ErrTaskNotFound = errors.New("task does not exist")
+
+// ErrHasSubtasks is returned when a task to delete without its subtasks still
+// has subtasks outside the trash.
+var ErrHasSubtasks = errors.New("task has subtasks")
+
+func init() {
+	logger = config.AppLogger
+	autoMigrate = config.SqlAutoMigrate
+	completedStatus = config.TaskWorkflow.Completed
+}
+
+// TaskRepository is the storage contract used by the task handlers. Every
+// method only sees the tasks owned by caller, or shared with caller through
+// the lists it is a member of, outside the trash. Changes only apply to tasks
+// still at the version of the task passed in, or the version given to
+// DeleteTask; 0 skips that check. DeleteTask moves a task to the trash, with
+// its subtasks when cascade is set, and refuses it otherwise while it has any.
+// UpdateTask and PatchTask create next, the next occurrence of the task they
+// complete, along with the change when given, and report its id.
+// Changes record the revision they are given, completed with the change
+// itself, for every task they change and in the same transaction; a revision
+// without an action records nothing.
+type TaskRepository interface {
+	GetTaskById(caller domain.Identity, id string) ([]domain.Task, error)
+	GetAllTasks(caller domain.Identity, page int64, perPage int64, sort []domain.SortKey) ([]domain.Task, error)
+	CreateTask(caller domain.Identity, task domain.Task, revision domain.TaskRevision) (int64, error)
+	UpdateTask(caller domain.Identity, task domain.Task, id string, next *domain.Task, revision domain.TaskRevision) (int64,
+		error)
+	PatchTask(caller domain.Identity, task domain.Task, id string, fields []string, next *domain.Task,
+		revision domain.TaskRevision) (int64, error)
+	DeleteTask(caller domain.Identity, id string, version int64, deletedOn int64, cascade bool,
+		revision domain.TaskRevision) (bool, error)
+	ApplyBatch(caller domain.Identity, writes []domain.TaskWrite, atomic bool) ([]domain.TaskWriteResult, error)
+	SearchTasks(caller domain.Identity, params map[string]string) ([]domain.Task, error)
+	GetTagCounts(caller domain.Identity) ([]domain.Tag, error)
+	GetSubtasks(caller domain.Identity, id string) ([]domain.Task, error)
+}
+
+// UserRepository stores the accounts that tasks belong to. Usernames are
+// unique within a tenant.
+type UserRepository interface {
+	CreateUser(user domain.User) (int64, error)
+	GetUserById(id int64) ([]domain.User, error)
+	GetUserByName(tenantId string, username string) ([]domain.User, error)
+	UpdateUserRole(id int64, role string) (bool, error)
+}
+
+// ApiKeyRepository stores the API keys of users. Listing and revoking only
+// see the keys of caller, keys are looked up by hash while authenticating.
+type ApiKeyRepository interface {
+	CreateApiKey(caller domain.Identity, key domain.ApiKey) (int64, error)
+	GetApiKeys(caller domain.Identity) ([]domain.ApiKey, error)
+	GetApiKeyByHash(hash string) ([]domain.ApiKey, error)
+	TouchApiKey(id int64, usedOn int64) error
+	DeleteApiKey(caller domain.Identity, id string) (bool, error)
+}
+
+// ListRepository stores task lists and who they are shared with. Lists are
+// only seen by their members and only changed by their owner.
+type ListRepository interface {
+	CreateList(caller domain.Identity, list domain.List) (int64, error)
+	GetLists(caller domain.Identity) ([]domain.List, error)
+	GetListById(caller domain.Identity, id string) ([]domain.List, error)
+	UpdateList(caller domain.Identity, list domain.List, id string) error
+	DeleteList(caller domain.Identity, id string) (bool, error)
+	AddListMember(caller domain.Identity, id string, userId int64, addedOn int64) (bool, error)
+	RemoveListMember(caller domain.Identity, id string, userId int64) (bool, error)
+	GetListTasks(caller domain.Identity, id string, page int64, perPage int64, sort []domain.SortKey) ([]domain.Task, error)
+}
+
+// TrashRepository keeps deleted tasks until they are restored or purged.
+// Caller sees the deleted tasks it would see otherwise, while PurgeTrash
+// empties the trash of every tenant. It reports the attachments it removed
+// along with the tasks, whose blobs are left to the caller, also when it
+// fails after removing some of them. RestoreTask records revisions like the
+// changes of TaskRepository.
+type TrashRepository interface {
+	GetTrash(caller domain.Identity, page int64, perPage int64) ([]domain.Task, error)
+	GetTrashedTask(caller domain.Identity, id string) ([]domain.Task, error)
+	RestoreTask(caller domain.Identity, id string, revision domain.TaskRevision) (bool, error)
+	PurgeTrash(deletedBefore int64) (int64, []domain.Attachment, error)
+}
+
+// HistoryRepository reads the revisions the changes to tasks record, kept for
+// as long as the tasks are.
+type HistoryRepository interface {
+	GetTaskHistory(caller domain.Identity, id string, page int64, perPage int64) ([]domain.TaskRevision, error)
+	GetTaskRevision(caller domain.Identity, id string, version int64) ([]domain.TaskRevision, error)
+}
+
+// CommentRepository keeps the comments on tasks until the tasks are purged.
+// Comments are only changed by their author.
+type CommentRepository interface {
+	CreateComment(caller domain.Identity, comment domain.Comment) (int64, error)
+	GetComments(caller domain.Identity, taskId string, page int64, perPage int64) ([]domain.Comment, error)
+	GetCommentById(caller domain.Identity, taskId string, id string) ([]domain.Comment, error)
+	UpdateComment(caller domain.Identity, comment domain.Comment, id string) (bool, error)
+	DeleteComment(caller domain.Identity, taskId string, id string) (bool, error)
+}
+
+// AttachmentRepository keeps what is known about the files uploaded to tasks,
+// while a BlobStore keeps their content.
+type AttachmentRepository interface {
+	CreateAttachment(caller domain.Identity, attachment domain.Attachment) (int64, error)
+	GetAttachments(caller domain.Identity, taskId string) ([]domain.Attachment, error)
+	GetAttachmentById(caller domain.Identity, taskId string, id string) ([]domain.Attachment, error)
+	DeleteAttachment(caller domain.Identity, taskId string, id string) (bool, error)
+}
+
+// DependencyRepository keeps which tasks block which within a tenant, and
+// refuses dependencies that close a cycle.
+type DependencyRepository interface {
+	AddDependency(caller domain.Identity, dependency domain.Dependency) (bool, error)
+	RemoveDependency(caller domain.Identity, taskId string, blockerId string) (bool, error)
+	GetBlockers(caller domain.Identity, taskId string) ([]domain.Task, error)
+	IsBlocked(caller domain.Identity, taskId string) (bool, error)
+}
+
+// IdempotencyRepository remembers the responses to requests sent with an
+// Idempotency-Key, per caller, until they expire. A key is reserved by the
+// first request using it, then completed with its response or released
+// when the request failed.
+type IdempotencyRepository interface {
+	ReserveIdempotencyKey(caller domain.Identity, record domain.IdempotencyRecord) (bool, error)
+	GetIdempotencyRecord(caller domain.Identity, key string) ([]domain.IdempotencyRecord, error)
+	CompleteIdempotencyKey(caller domain.Identity, record domain.IdempotencyRecord) error
+	ReleaseIdempotencyKey(caller domain.Identity, key string) error
+}
+
+// Repository is everything a storage backend provides. Every backend
+// selectable through sql.driver implements it. The history, comments,
+// attachments and dependencies of a task leave checking that caller may see
+// the task to the services.
+type Repository interface {
+	TaskRepository
+	UserRepository
+	ApiKeyRepository
+	ListRepository
+	TrashRepository
+	HistoryRepository
+	CommentRepository
+	AttachmentRepository
+	DependencyRepository
+	IdempotencyRepository
+}
+
+// NewRepository returns the backend registered for driver, connected to dsn.
+// SQL backends refuse to open while their schema is behind the migrations in
+// this build, unless sql.autoMigrate is set.
+func NewRepository(driver string, dsn string) (Repository, error) {
+	if driver == MemoryDriver {
+		return newMemoryTaskRepository(), nil
+	}
+
+	d, found := dialects[driver]
+	if !found {
+		return nil, fmt.Errorf("unsupported sql driver: %s", driver)
+	}
+	return openSqlTaskRepository(d, dsn, autoMigrate)
+}
+
+func openSqlTaskRepository(d dialect, dsn string, migrate bool) (*sqlTaskRepository, error) {
+	database, err := connectDatabase(d, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = checkSchema(newMigrator(database, d), migrate); err != nil {
+		_ = database.Close()
+		return nil, err
+	}
+	return newSqlTaskRepository(database, d), nil
+}
+
+func checkSchema(migrator *Migrator, migrate bool) error {
+	if migrate {
+		applied, err := migrator.Up()
+		for _, migration := range applied {
+			logger.Info(fmt.Sprintf("Applied migration %d: %s", migration.Version, migration.Name))
+		}
+		return err
+	}
+
+	pending, err := migrator.Pending()
+	if err == nil && pending > 0 {
+		err = fmt.Errorf("database schema is %d migration(s) behind, run \"migrate up\" first", pending)
+	}
+	return err
+}
+
+func connectDatabase(d dialect, dsn string) (*sql.DB, error) {
+	database, err := sql.Open(d.driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	database = sqldblogger.OpenDriver(dsn, database.Driver(), zapadapter.New(logger))
+	database.SetMaxOpenConns(d.maxOpenConns)
+	return database, nil
+}
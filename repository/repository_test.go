@@ -0,0 +1,28 @@
+package repository
+
+import (
+	sq "github.com/Masterminds/squirrel"
+	"testing"
+)
+
+func TestNewRepositoryUnsupportedDriver(t *testing.T) {
+	taskRepository, err := NewRepository("oracle", "")
+	if err == nil || taskRepository != nil {
+		t.Errorf("Expected error for unsupported driver, got repository: %v, error: %v", taskRepository, err)
+	}
+}
+
+func TestDialectIgnoreDuplicates(t *testing.T) {
+	expected := map[string]string{
+		"mysql":    "INSERT IGNORE INTO tags (name) VALUES (?)",
+		"sqlite":   "INSERT OR IGNORE INTO tags (name) VALUES (?)",
+		"postgres": "INSERT INTO tags (name) VALUES ($1) ON CONFLICT DO NOTHING",
+	}
+	for name, d := range dialects {
+		query, _, err := d.ignoreDuplicates(sq.StatementBuilder.PlaceholderFormat(d.placeholder).
+			Insert("tags").Columns("name").Values("work")).ToSql()
+		if err != nil || query != expected[name] {
+			t.Errorf("Expected %s to insert with: %s, Got: %s, error: %v", name, expected[name], query, err)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package repository
+
+import "testing"
+
+func TestSqliteTaskRepository(t *testing.T) {
+	runTaskRepositoryContract(t, newSqliteTestRepository(t))
+}
+
+// newSqliteTestRepository opens a private in-memory sqlite database with every migration applied.
+func newSqliteTestRepository(t *testing.T) *sqlTaskRepository {
+	store, err := openSqlTaskRepository(sqliteDialect, ":memory:", true)
+	if err != nil {
+		t.Fatalf("Error opening sqlite repository: %s", err)
+	}
+	return store
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"database/sql"
+	sq "github.com/Masterminds/squirrel"
+	"my-todo-app/domain"
+)
+
+// GetSubtasks returns every direct child of the task with id.
+func (r *sqlTaskRepository) GetSubtasks(caller domain.Identity, id string) ([]domain.Task, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	tasks, err := r.queryTasks(tx,
+		r.statement.Select(taskColumns...).
+			From("tasks").
+			Where(visibleTo(caller)).
+			Where(sq.Eq{"parentId": parseId(id)}).
+			OrderBy("id"))
+	return tasks, err
+}
+
+// getDescendantIds walks the hierarchy below id one level per query, only
+// following the subtasks matching filter.
+func (r *sqlTaskRepository) getDescendantIds(tx *sql.Tx, id int64, filter sq.Sqlizer) ([]int64, error) {
+	visited := map[int64]bool{id: true}
+	var descendants []int64
+
+	for level := []int64{id}; len(level) > 0; {
+		rows, err := r.statement.Select("id").
+			From("tasks").
+			Where(sq.Eq{"parentId": level}).
+			Where(filter).
+			RunWith(tx).
+			Query()
+		if err != nil {
+			return nil, err
+		}
+
+		var next []int64
+		for rows.Next() {
+			var childId int64
+			if err = rows.Scan(&childId); err != nil {
+				_ = rows.Close()
+				return nil, err
+			}
+			if !visited[childId] {
+				visited[childId] = true
+				next = append(next, childId)
+			}
+		}
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+
+		descendants = append(descendants, next...)
+		level = next
+	}
+	return descendants, nil
+}
+
+// loadSubtaskRollups counts the children of tasks outside the trash, and how
+// many of them are done.
+func (r *sqlTaskRepository) loadSubtaskRollups(tx *sql.Tx, tasks []domain.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	positions := map[int64]int{}
+	ids := make([]int64, 0, len(tasks))
+	for i, task := range tasks {
+		positions[task.GetId()] = i
+		ids = append(ids, task.GetId())
+	}
+
+	rows, err := r.statement.Select("parentId", "COUNT(*)").
+		Column(sq.Expr("SUM(CASE WHEN status = ? THEN 1 ELSE 0 END)", completedStatus)).
+		From("tasks").
+		Where(sq.Eq{"parentId": ids}).
+		Where(sq.Expr("deletedOn = 0")).
+		GroupBy("parentId").
+		RunWith(tx).
+		Query()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var parentId int64
+		var rollup domain.SubtaskRollup
+		if err = rows.Scan(&parentId, &rollup.Total, &rollup.Done); err != nil {
+			return err
+		}
+		tasks[positions[parentId]].SetSubtasks(&rollup)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"database/sql"
+	sq "github.com/Masterminds/squirrel"
+	"my-todo-app/domain"
+)
+
+// GetTagCounts lists every tag in use on the tasks visible to caller, with the
+// number of those tasks carrying it.
+func (r *sqlTaskRepository) GetTagCounts(caller domain.Identity) ([]domain.Tag, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	rows, err := r.statement.Select("tags.name", "COUNT(task_tags.taskId)").
+		From("tags").
+		Join("task_tags ON task_tags.tagId = tags.id").
+		Join("tasks ON tasks.id = task_tags.taskId").
+		Where(visibleTo(caller)).
+		GroupBy("tags.name").
+		OrderBy("tags.name").
+		RunWith(tx).
+		Query()
+
+	tags := []domain.Tag{}
+	for err == nil && rows.Next() {
+		var tag domain.Tag
+		err = rows.Scan(&tag.Name, &tag.Count)
+		if err == nil {
+			tags = append(tags, tag)
+		}
+	}
+	return tags, err
+}
+
+// loadTags fills in the tags of tasks with a single query.
+func (r *sqlTaskRepository) loadTags(tx *sql.Tx, tasks []domain.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	positions := map[int64]int{}
+	ids := make([]int64, 0, len(tasks))
+	for i, task := range tasks {
+		positions[task.GetId()] = i
+		ids = append(ids, task.GetId())
+	}
+
+	rows, err := r.statement.Select("task_tags.taskId", "tags.name").
+		From("task_tags").
+		Join("tags ON tags.id = task_tags.tagId").
+		Where(sq.Eq{"task_tags.taskId": ids}).
+		OrderBy("tags.name").
+		RunWith(tx).
+		Query()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var taskId int64
+		var name string
+		if err = rows.Scan(&taskId, &name); err != nil {
+			return err
+		}
+		task := &tasks[positions[taskId]]
+		task.SetTags(append(task.GetTags(), name))
+	}
+	return rows.Err()
+}
+
+func (r *sqlTaskRepository) addTags(tx *sql.Tx, taskId int64, tags []string) error {
+	for _, name := range tags {
+		tagId, err := r.getOrCreateTagId(tx, name)
+		if err != nil {
+			return err
+		}
+
+		_, err = r.statement.Insert("task_tags").
+			Columns("taskId", "tagId").
+			Values(taskId, tagId).
+			RunWith(tx).
+			Exec()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceTags swaps the tags of an existing task of caller; unknown tasks are
+// left alone so no dangling task_tags rows are written.
+func (r *sqlTaskRepository) replaceTags(tx *sql.Tx, caller domain.Identity, taskId int64, tags []string) error {
+	visible, err := r.isVisibleTask(tx, caller, taskId)
+	if err != nil || !visible {
+		return err
+	}
+
+	_, err = r.statement.Delete("task_tags").
+		Where(sq.Eq{"taskId": taskId}).
+		RunWith(tx).
+		Exec()
+	if err != nil {
+		return err
+	}
+	return r.addTags(tx, taskId, tags)
+}
+
+// getOrCreateTagId inserts the tag unless it exists, then reads its id, so
+// that requests adding the same new tag at once do not fail on its unique name.
+func (r *sqlTaskRepository) getOrCreateTagId(tx *sql.Tx, name string) (int64, error) {
+	_, err := r.dialect.ignoreDuplicates(r.statement.Insert("tags").Columns("name").Values(name)).
+		RunWith(tx).
+		Exec()
+	if err != nil {
+		return -1, err
+	}
+
+	var tagId int64
+	err = r.dialect.lockRows(r.statement.Select("id").From("tags").Where(sq.Eq{"name": name})).
+		RunWith(tx).
+		QueryRow().
+		Scan(&tagId)
+	return tagId, err
+}
+
+// getTagFilter matches tasks carrying any, or with matchAll every one, of tags.
+func getTagFilter(tags []string, matchAll bool) sq.Sqlizer {
+	subQuery := sq.Select("task_tags.taskId").
+		From("task_tags").
+		Join("tags ON tags.id = task_tags.tagId").
+		Where(sq.Eq{"tags.name": tags})
+	if matchAll {
+		subQuery = subQuery.GroupBy("task_tags.taskId").Having("COUNT(DISTINCT tags.id) = ?", len(tags))
+	}
+
+	query, args, _ := subQuery.ToSql()
+	return sq.Expr("id IN ("+query+")", args...)
+}
@@ -3,55 +3,36 @@ package repository
 import (
 	"database/sql"
 	sq "github.com/Masterminds/squirrel"
-	_ "github.com/go-sql-driver/mysql"
-	"github.com/simukti/sqldb-logger"
-	"github.com/simukti/sqldb-logger/logadapter/zapadapter"
-	"go.uber.org/zap"
-	"my-todo-app/config"
 	"my-todo-app/domain"
 	"strconv"
+	"strings"
 )
 
 var (
-	db        *sql.DB
-	sqlDriver string
-	logger    *zap.Logger
-	columns   = []string{"title", "description", "addedOn", "dueBy", "status"}
-)
-
-const (
-	initDbQuery = `CREATE TABLE IF NOT EXISTS tasks (
-						id INT PRIMARY KEY NOT NULL AUTO_INCREMENT, 
-						title TEXT NOT NULL, 
-						description TEXT NOT NULL, 
-						addedOn BIGINT NOT NULL, 
-						dueBy BIGINT NOT NULL, 
-						status TEXT NOT NULL);`
+	columns     = []string{"title", "description", "addedOn", "dueBy", "status", "parentId", "recurrence", "priority", "ownerId", "listId", "tenantId"}
+	taskColumns = append(append([]string{"id"}, columns...), "version", "deletedOn")
 )
 
-func init() {
-	sqlDriver = config.SqlDriver
-	logger = config.AppLogger
-
-	database := connectDatabase(config.DataSourceName)
-	setDb(database)
-	initializeTable()
+// sqlTaskRepository stores tasks in a relational database, building its
+// queries for the configured dialect.
+type sqlTaskRepository struct {
+	db        *sql.DB
+	dialect   dialect
+	statement sq.StatementBuilderType
 }
 
-func connectDatabase(dsn string) *sql.DB {
-	database, err := sql.Open(sqlDriver, dsn)
-	if err != nil {
-		panic(err)
+func newSqlTaskRepository(database *sql.DB, d dialect) *sqlTaskRepository {
+	return &sqlTaskRepository{
+		db:        database,
+		dialect:   d,
+		statement: sq.StatementBuilder.PlaceholderFormat(d.placeholder),
 	}
-
-	database = sqldblogger.OpenDriver(dsn, database.Driver(), zapadapter.New(logger))
-	return database
 }
 
-func initializeTable() {
-	tx, err := db.Begin()
+func (r *sqlTaskRepository) GetTaskById(caller domain.Identity, id string) ([]domain.Task, error) {
+	tx, err := r.db.Begin()
 	if err != nil {
-		return
+		return nil, err
 	}
 	defer func() {
 		switch err {
@@ -62,18 +43,23 @@ func initializeTable() {
 		}
 	}()
 
-	_, err = db.Exec(initDbQuery)
-	if err != nil {
-		logger.Panic("Failure while initializing database, {}" + err.Error())
-	}
+	tasks, err := r.queryTasks(tx,
+		r.statement.Select(taskColumns...).
+			From("tasks").
+			Where(visibleTo(caller)).
+			Where(sq.Eq{"id": id}))
+	return tasks, err
 }
 
-func setDb(database *sql.DB) {
-	db = database
+func (r *sqlTaskRepository) GetAllTasks(caller domain.Identity, page int64, perPage int64, sort []domain.SortKey) ([]domain.Task, error) {
+	return r.getTaskPage(caller, page, perPage, sort)
 }
 
-func GetTaskById(id string) ([]domain.Task, error) {
-	tx, err := db.Begin()
+// getTaskPage lists the tasks of caller matching filters one page at a time,
+// or all of them when page or perPage is -1.
+func (r *sqlTaskRepository) getTaskPage(caller domain.Identity, page int64, perPage int64, sort []domain.SortKey,
+	filters ...sq.Sqlizer) ([]domain.Task, error) {
+	tx, err := r.db.Begin()
 	if err != nil {
 		return nil, err
 	}
@@ -86,27 +72,24 @@ func GetTaskById(id string) ([]domain.Task, error) {
 		}
 	}()
 
-	rows, err := sq.Select("*").
-		From("tasks").
-		Where(sq.Eq{"id": id}).
-		RunWith(tx).
-		Query()
-
-	tasks := []domain.Task{}
-	for err == nil && rows.Next() {
-		var task domain.Task
-		task, err = scanRow(rows)
-		if err == nil {
-			tasks = append(tasks, task)
-		}
+	query := r.statement.Select(taskColumns...).From("tasks").Where(visibleTo(caller))
+	for _, filter := range filters {
+		query = query.Where(filter)
+	}
+	query = orderBy(query, sort)
+	if page != -1 && perPage != -1 {
+		query = query.Limit(uint64(perPage)).Offset(uint64(page * perPage))
 	}
+
+	tasks, err := r.queryTasks(tx, query)
 	return tasks, err
 }
 
-func GetAllTasks(page int64, perPage int64) ([]domain.Task, error) {
-	tx, err := db.Begin()
+func (r *sqlTaskRepository) CreateTask(caller domain.Identity, task domain.Task, revision domain.TaskRevision) (int64,
+	error) {
+	tx, err := r.db.Begin()
 	if err != nil {
-		return nil, err
+		return -1, err
 	}
 	defer func() {
 		switch err {
@@ -117,30 +100,34 @@ func GetAllTasks(page int64, perPage int64) ([]domain.Task, error) {
 		}
 	}()
 
-	var rows *sql.Rows
-	tasks := []domain.Task{}
-
-	query := sq.Select("*").From("tasks")
-	if page == -1 || perPage == -1 {
-		rows, err = query.RunWith(tx).Query()
-	} else {
-		rows, err = query.Limit(uint64(perPage)).Offset(uint64(page * perPage)).RunWith(tx).Query()
+	id, err := r.createTask(tx, caller, task, revision)
+	if err != nil {
+		return -1, err
 	}
+	return id, nil
+}
 
-	for err == nil && rows.Next() {
-		var task domain.Task
-		task, err = scanRow(rows)
-		if err == nil {
-			tasks = append(tasks, task)
-		}
+func (r *sqlTaskRepository) createTask(tx *sql.Tx, caller domain.Identity, task domain.Task,
+	revision domain.TaskRevision) (int64, error) {
+	id, err := r.insertReturningId(tx,
+		r.statement.Insert("tasks").
+			Columns(columns...).
+			Values(task.GetTitle(), task.GetDescription(), task.GetAddedOn(), task.GetDueBy(), task.GetStatus(),
+				task.GetParentId(), task.GetRecurrence(), task.GetPriority(), caller.UserId, task.GetListId(), caller.TenantId))
+	if err == nil {
+		err = r.addTags(tx, id, task.GetTags())
 	}
-	return tasks, err
+	if err == nil && revision.Action != "" {
+		err = r.addRevisions(tx, caller, revision, []int64{id}, nil)
+	}
+	return id, err
 }
 
-func CreateTask(task domain.Task) (int64, error) {
-	tx, err := db.Begin()
+func (r *sqlTaskRepository) UpdateTask(caller domain.Identity, task domain.Task, id string, next *domain.Task,
+	revision domain.TaskRevision) (int64, error) {
+	tx, err := r.db.Begin()
 	if err != nil {
-		return -1, err
+		return 0, err
 	}
 	defer func() {
 		switch err {
@@ -151,23 +138,57 @@ func CreateTask(task domain.Task) (int64, error) {
 		}
 	}()
 
-	result, err :=
-		sq.Insert("tasks").
-			Columns(columns...).
-			Values(task.GetTitle(), task.GetDescription(), task.GetAddedOn(), task.GetDueBy(), task.GetStatus()).
-			RunWith(tx).
-			Exec()
+	updated, err := r.updateTask(tx, caller, task, id, revision)
+	if err == nil && !updated {
+		err = ErrTaskNotFound
+	}
+	var nextId int64
+	if err == nil {
+		nextId, err = r.createNextOccurrence(tx, caller, next, revision)
+	}
+	return nextId, err
+}
+
+// updateTask replaces the task with id, reporting whether it was found.
+func (r *sqlTaskRepository) updateTask(tx *sql.Tx, caller domain.Identity, task domain.Task, id string,
+	revision domain.TaskRevision) (bool, error) {
+	before, err := r.getRevisedTasks(tx, revision, []int64{parseId(id)})
+	if err != nil {
+		return false, err
+	}
 
-	if err == nil && result != nil {
-		return result.LastInsertId()
+	update := r.statement.Update("tasks").
+		Set("title", task.GetTitle()).
+		Set("description", task.GetDescription()).
+		Set("addedOn", task.GetAddedOn()).
+		Set("dueBy", task.GetDueBy()).
+		Set("status", task.GetStatus()).
+		Set("parentId", task.GetParentId()).
+		Set("recurrence", task.GetRecurrence()).
+		Set("priority", task.GetPriority()).
+		Set("listId", task.GetListId()).
+		Set("version", sq.Expr("version + 1")).
+		Where(visibleTo(caller)).
+		Where(sq.Eq{"id": id})
+	updated, err := r.execVersioned(tx, caller, id, update, task.GetVersion())
+
+	// nil tags were left out of the request and stay as they are
+	if err == nil && updated && task.GetTags() != nil {
+		err = r.replaceTags(tx, caller, parseId(id), task.GetTags())
 	}
-	return -1, err
+	if err == nil && updated && revision.Action != "" {
+		err = r.addRevisions(tx, caller, revision, []int64{parseId(id)}, before)
+	}
+	return updated, err
 }
 
-func UpdateTask(task domain.Task, id string) error {
-	tx, err := db.Begin()
+// PatchTask writes the given domain.TaskFields of task and leaves every other
+// column of the task with id as it is.
+func (r *sqlTaskRepository) PatchTask(caller domain.Identity, task domain.Task, id string, fields []string,
+	next *domain.Task, revision domain.TaskRevision) (int64, error) {
+	tx, err := r.db.Begin()
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer func() {
 		switch err {
@@ -178,20 +199,39 @@ func UpdateTask(task domain.Task, id string) error {
 		}
 	}()
 
-	_, err = sq.Update("tasks").
-		Set("title", task.GetTitle()).
-		Set("description", task.GetDescription()).
-		Set("addedOn", task.GetAddedOn()).
-		Set("dueBy", task.GetDueBy()).
-		Set("status", task.GetStatus()).
-		Where(sq.Eq{"id": id}).
-		RunWith(tx).
-		Exec()
-	return err
+	before, err := r.getRevisedTasks(tx, revision, []int64{parseId(id)})
+	if err != nil {
+		return 0, err
+	}
+
+	// the version moves on even when only the tags change
+	update := r.statement.Update("tasks").Where(visibleTo(caller)).Where(sq.Eq{"id": id})
+	for _, field := range fields {
+		if column, value, found := getColumn(task, field); found {
+			update = update.Set(column, value)
+		}
+	}
+	updated, err := r.execVersioned(tx, caller, id, update.Set("version", sq.Expr("version + 1")), task.GetVersion())
+	if err == nil && !updated {
+		err = ErrTaskNotFound
+	}
+
+	if err == nil && containsString(fields, "tags") {
+		err = r.replaceTags(tx, caller, parseId(id), task.GetTags())
+	}
+	if err == nil && revision.Action != "" {
+		err = r.addRevisions(tx, caller, revision, []int64{parseId(id)}, before)
+	}
+	var nextId int64
+	if err == nil {
+		nextId, err = r.createNextOccurrence(tx, caller, next, revision)
+	}
+	return nextId, err
 }
 
-func DeleteTask(id string) (bool, error) {
-	tx, err := db.Begin()
+func (r *sqlTaskRepository) DeleteTask(caller domain.Identity, id string, version int64, deletedOn int64, cascade bool,
+	revision domain.TaskRevision) (bool, error) {
+	tx, err := r.db.Begin()
 	if err != nil {
 		return false, err
 	}
@@ -204,21 +244,74 @@ func DeleteTask(id string) (bool, error) {
 		}
 	}()
 
-	result, err :=
-		sq.Delete("*").
-			From("tasks").
-			Where(sq.Eq{"id": id}).
-			RunWith(tx).
-			Query()
-	if err == nil && result != nil {
-		return result.Next(), nil
+	deleted, err := r.deleteTask(tx, caller, id, version, deletedOn, cascade, revision)
+	return deleted, err
+}
+
+// deleteTask moves the task with id at version to the trash together with
+// its subtasks, reporting whether it was found. Without cascade, it fails with
+// ErrHasSubtasks while the task has subtasks outside the trash.
+func (r *sqlTaskRepository) deleteTask(tx *sql.Tx, caller domain.Identity, id string, version int64, deletedOn int64,
+	cascade bool, revision domain.TaskRevision) (bool, error) {
+	taskId := parseId(id)
+	before, err := r.getRevisedTasks(tx, revision, []int64{taskId})
+	if err != nil {
+		return false, err
 	}
 
-	return false, err
+	trash := r.statement.Update("tasks").
+		Set("deletedOn", deletedOn).
+		Set("version", sq.Expr("version + 1")).
+		Where(visibleTo(caller)).
+		Where(sq.Eq{"id": id})
+	if version != 0 {
+		trash = trash.Where(sq.Eq{"version": version})
+	}
+	result, err := trash.RunWith(tx).Exec()
+	if err != nil || result == nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil || rowsAffected == 0 {
+		return false, err
+	}
+
+	// subtasks go together with their parent, counted once the parent row is locked by the update
+	// above; the ones already in the trash keep the time they were deleted on
+	ids, err := r.getDescendantIds(tx, taskId, sq.Expr("deletedOn = 0"))
+	if err == nil && len(ids) > 0 && !cascade {
+		err = ErrHasSubtasks
+		return false, err
+	}
+	if err == nil && len(ids) > 0 {
+		var subtasks map[int64]domain.Task
+		subtasks, err = r.getRevisedTasks(tx, revision, ids)
+		for subtaskId, subtask := range subtasks {
+			before[subtaskId] = subtask
+		}
+	}
+	if err == nil && len(ids) > 0 {
+		_, err = r.statement.Update("tasks").
+			Set("deletedOn", deletedOn).
+			Set("version", sq.Expr("version + 1")).
+			Where(sq.Eq{"id": ids}).
+			RunWith(tx).
+			Exec()
+	}
+	if err == nil && revision.Action != "" {
+		err = r.addRevisions(tx, caller, revision, append([]int64{taskId}, ids...), before)
+	}
+	return err == nil, err
 }
 
-func SearchTasks(params map[string]string) ([]domain.Task, error) {
-	tx, err := db.Begin()
+func (r *sqlTaskRepository) SearchTasks(caller domain.Identity, params map[string]string) ([]domain.Task, error) {
+	sort, err := domain.ParseSort(params["sort"])
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.Begin()
 	if err != nil {
 		return nil, err
 	}
@@ -231,31 +324,73 @@ func SearchTasks(params map[string]string) ([]domain.Task, error) {
 		}
 	}()
 
-	var rows *sql.Rows
-	tasks := []domain.Task{}
+	tasks, err := r.queryTasks(tx, r.getSearchQuery(caller, params, sort))
+	return tasks, err
+}
 
-	rows, err = getSearchQuery(params).RunWith(tx).Query()
+// queryTasks runs query inside tx and attaches the tags of every task found.
+func (r *sqlTaskRepository) queryTasks(tx *sql.Tx, query sq.SelectBuilder) ([]domain.Task, error) {
+	tasks := []domain.Task{}
+	rows, err := query.RunWith(tx).Query()
+	if err != nil {
+		return tasks, err
+	}
 
-	for err == nil && rows.Next() {
+	for rows.Next() {
 		var task domain.Task
 		task, err = scanRow(rows)
-		if err == nil {
-			tasks = append(tasks, task)
+		if err != nil {
+			_ = rows.Close()
+			return tasks, err
 		}
+		tasks = append(tasks, task)
+	}
+	if err = rows.Err(); err != nil {
+		return tasks, err
+	}
+
+	err = r.loadTags(tx, tasks)
+	if err == nil {
+		err = r.loadSubtaskRollups(tx, tasks)
 	}
 	return tasks, err
 }
 
-func getSearchQuery(params map[string]string) sq.SelectBuilder {
-	query := sq.Select("*").From("tasks")
+// insertReturningId runs insert inside tx and reports the generated id, asking
+// for it through RETURNING on dialects without LastInsertId support.
+func (r *sqlTaskRepository) insertReturningId(tx *sql.Tx, insert sq.InsertBuilder) (int64, error) {
+	if r.dialect.returningId {
+		var id int64
+		err := insert.Suffix("RETURNING id").RunWith(tx).QueryRow().Scan(&id)
+		if err != nil {
+			return -1, err
+		}
+		return id, nil
+	}
+
+	result, err := insert.RunWith(tx).Exec()
+	if err != nil {
+		return -1, err
+	}
+	return result.LastInsertId()
+}
+
+func (r *sqlTaskRepository) getSearchQuery(caller domain.Identity, params map[string]string, sort []domain.SortKey) sq.SelectBuilder {
+	query := r.statement.Select(taskColumns...).From("tasks").Where(visibleTo(caller))
 
 	page := getPageNumber(params["page"])
 	perPage := getPerPage(params["perPage"])
 
 	for key, value := range params {
 		switch key {
-		case "id", "status":
+		case "id", "status", "parentId", "listId":
 			query = query.Where(sq.Eq{key: value})
+		case "tag":
+			query = query.Where(getTagFilter(strings.Split(value, ","), params["tagMatch"] == "all"))
+		case "priority":
+			query = query.Where(sq.Eq{key: strings.Split(value, ",")})
+		case "blocked":
+			query = query.Where(getBlockedFilter(value))
 		case "addedOnFrom", "dueByFrom":
 			// strip "From" from key, for correct column names
 			key = key[:len(key)-4]
@@ -267,15 +402,113 @@ func getSearchQuery(params map[string]string) sq.SelectBuilder {
 		}
 	}
 
-	return query.Limit(uint64(perPage)).Offset(uint64(page * perPage))
+	return orderBy(query, sort).Limit(uint64(perPage)).Offset(uint64(page * perPage))
+}
+
+// getColumn maps a field of domain.TaskFields to its column and value in
+// task; tags live in their own table and have no column.
+func getColumn(task domain.Task, field string) (string, interface{}, bool) {
+	switch field {
+	case "title":
+		return "title", task.GetTitle(), true
+	case "description":
+		return "description", task.GetDescription(), true
+	case "added_on":
+		return "addedOn", task.GetAddedOn(), true
+	case "due_by":
+		return "dueBy", task.GetDueBy(), true
+	case "status":
+		return "status", task.GetStatus(), true
+	case "parent_id":
+		return "parentId", task.GetParentId(), true
+	case "recurrence":
+		return "recurrence", task.GetRecurrence(), true
+	case "priority":
+		return "priority", task.GetPriority(), true
+	case "list_id":
+		return "listId", task.GetListId(), true
+	}
+	return "", nil, false
+}
+
+// visibleTo restricts a query on tasks to the tenant of caller, and within it
+// to the ones owned by caller and the ones in lists caller is a member of,
+// leaving out the tasks in the trash.
+func visibleTo(caller domain.Identity) sq.Sqlizer {
+	return sq.And{sq.Eq{"tenantId": caller.TenantId}, ownedOrShared(caller), sq.Expr("deletedOn = 0")}
+}
+
+// trashedFor restricts a query on tasks to the ones in the trash that would
+// otherwise be visible to caller.
+func trashedFor(caller domain.Identity) sq.Sqlizer {
+	return sq.And{sq.Eq{"tenantId": caller.TenantId}, ownedOrShared(caller), sq.Expr("deletedOn > 0")}
+}
+
+func ownedOrShared(caller domain.Identity) sq.Sqlizer {
+	return sq.Or{
+		sq.Eq{"ownerId": caller.UserId},
+		sq.Expr("listId IN (SELECT listId FROM list_members WHERE userId = ?)", caller.UserId),
+	}
+}
+
+// orderBy sorts query by the given keys, falling back to id so that pages
+// stay stable across requests.
+func orderBy(query sq.SelectBuilder, sort []domain.SortKey) sq.SelectBuilder {
+	clauses := make([]string, 0, len(sort)+1)
+	for _, key := range sort {
+		if key.Descending {
+			clauses = append(clauses, key.Column+" DESC")
+		} else {
+			clauses = append(clauses, key.Column)
+		}
+	}
+	return query.OrderBy(append(clauses, "id")...)
+}
+
+// execVersioned runs update on the task of caller with id only while it is
+// still at version, any version when 0, and reports whether it changed the
+// task. Tasks out of reach of caller are left alone without an error, like
+// unversioned updates do.
+func (r *sqlTaskRepository) execVersioned(tx *sql.Tx, caller domain.Identity, id string, update sq.UpdateBuilder,
+	version int64) (bool, error) {
+	if version != 0 {
+		update = update.Where(sq.Eq{"version": version})
+	}
+	result, err := update.RunWith(tx).Exec()
+	if err != nil || result == nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil || rowsAffected > 0 || version == 0 {
+		return rowsAffected > 0, err
+	}
+
+	visible, err := r.isVisibleTask(tx, caller, parseId(id))
+	if err == nil && visible {
+		err = ErrVersionConflict
+	}
+	return false, err
+}
+
+func (r *sqlTaskRepository) isVisibleTask(tx *sql.Tx, caller domain.Identity, taskId int64) (bool, error) {
+	var count int64
+	err := r.statement.Select("COUNT(*)").
+		From("tasks").
+		Where(visibleTo(caller)).
+		Where(sq.Eq{"id": taskId}).
+		RunWith(tx).
+		QueryRow().
+		Scan(&count)
+	return count > 0, err
 }
 
 func scanRow(rows *sql.Rows) (domain.Task, error) {
 	var task domain.Task
-	var id, addedOn, dueBy int64
-	var title, description, status string
+	var id, addedOn, dueBy, parentId, ownerId, listId, version, deletedOn int64
+	var title, description, status, recurrence, priority, tenantId string
 
-	err := rows.Scan(&id, &title, &description, &addedOn, &dueBy, &status)
+	err := rows.Scan(&id, &title, &description, &addedOn, &dueBy, &status, &parentId, &recurrence, &priority, &ownerId, &listId,
+		&tenantId, &version, &deletedOn)
 	if err == nil {
 		task = domain.Task{
 			Id:          id,
@@ -284,6 +517,14 @@ func scanRow(rows *sql.Rows) (domain.Task, error) {
 			Title:       title,
 			Description: description,
 			Status:      status,
+			ParentId:    parentId,
+			Recurrence:  recurrence,
+			Priority:    priority,
+			OwnerId:     ownerId,
+			ListId:      listId,
+			TenantId:    tenantId,
+			Version:     version,
+			DeletedOn:   deletedOn,
 		}
 	}
 
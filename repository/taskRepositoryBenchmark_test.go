@@ -2,6 +2,7 @@ package repository
 
 import (
 	"github.com/DATA-DOG/go-sqlmock"
+	"my-todo-app/domain"
 	"my-todo-app/testUtils"
 	"testing"
 )
@@ -11,7 +12,7 @@ func InitialBenchmarkSetup(b *testing.B) {
 	if err != nil {
 		b.Errorf("Error opening stub database connection: %s", err)
 	}
-	setDb(mockDb)
+	taskRepository = newSqlTaskRepository(mockDb, mysqlDialect)
 }
 
 func BenchmarkGetTaskById(b *testing.B) {
@@ -26,7 +27,7 @@ func BenchmarkGetTaskById(b *testing.B) {
 			for i := 0; i < b.N; i++ {
 				testUtils.GetRepositoryMocks(testUtils.GetTaskByIdKey, mock, scenario.ExpectedSQL, id, scenario)
 
-				_, err := GetTaskById(id)
+				_, err := taskRepository.GetTaskById(testUtils.Caller, id)
 				if err != scenario.ScenarioErr {
 					b.Errorf("Expected error: %s, but got: %s", scenario.ScenarioErr, err)
 				}
@@ -47,7 +48,7 @@ func BenchmarkGetAllTasks(b *testing.B) {
 			for i := 0; i < b.N; i++ {
 				testUtils.GetRepositoryMocks(testUtils.GetAllTasksKey, mock, scenario.ExpectedSQL, "", scenario)
 
-				_, err := GetAllTasks(scenario.Page, scenario.PerPage)
+				_, err := taskRepository.GetAllTasks(testUtils.Caller, scenario.Page, scenario.PerPage, nil)
 				if err != scenario.ScenarioErr {
 					b.Errorf("Expected error: %s, but got: %s", scenario.ScenarioErr, err)
 				}
@@ -68,7 +69,7 @@ func BenchmarkCreateTask(b *testing.B) {
 			for i := 0; i < b.N; i++ {
 				testUtils.GetRepositoryMocks(testUtils.CreateTaskKey, mock, scenario.ExpectedSQL, "", scenario)
 
-				_, err := CreateTask(scenario.Task)
+				_, err := taskRepository.CreateTask(testUtils.Caller, scenario.Task, domain.TaskRevision{})
 				if err != scenario.ScenarioErr {
 					b.Errorf("Expected error: %s, but got: %s", scenario.ScenarioErr, err)
 				}
@@ -89,7 +90,7 @@ func BenchmarkUpdateTask(b *testing.B) {
 			for i := 0; i < b.N; i++ {
 				testUtils.GetRepositoryMocks(testUtils.UpdateTaskKey, mock, scenario.ExpectedSQL, scenario.Id, scenario)
 
-				err := UpdateTask(scenario.Task, "8")
+				_, err := taskRepository.UpdateTask(testUtils.Caller, scenario.Task, "8", nil, domain.TaskRevision{})
 				if err != scenario.ScenarioErr {
 					b.Errorf("Expected error: %s, but got: %s", scenario.ScenarioErr, err)
 				}
@@ -111,7 +112,7 @@ func BenchmarkDeleteTask(b *testing.B) {
 			for i := 0; i < b.N; i++ {
 				testUtils.GetRepositoryMocks(testUtils.DeleteTaskKey, mock, scenario.ExpectedSQL, id, scenario)
 
-				_, err := DeleteTask(id)
+				_, err := taskRepository.DeleteTask(testUtils.Caller, id, 0, testUtils.DeletedOn, true, domain.TaskRevision{})
 				if err != scenario.ScenarioErr {
 					b.Errorf("Expected error: %s, but got: %s", scenario.ScenarioErr, err)
 				}
@@ -132,7 +133,7 @@ func BenchmarkSearchTasks(b *testing.B) {
 			for i := 0; i < b.N; i++ {
 				testUtils.GetRepositoryMocks(testUtils.SearchTaskKey, mock, scenario.ExpectedSQL, "", scenario)
 
-				_, err := SearchTasks(scenario.SearchParams)
+				_, err := taskRepository.SearchTasks(testUtils.Caller, scenario.SearchParams)
 				if err != scenario.ScenarioErr {
 					b.Errorf("Expected error: %s, but got: %s", scenario.ScenarioErr, err)
 				}
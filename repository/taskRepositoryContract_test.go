@@ -0,0 +1,1267 @@
+package repository
+
+import (
+	"encoding/json"
+	"my-todo-app/domain"
+	"my-todo-app/testUtils"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// runTaskRepositoryContract exercises a real backend end to end, so every
+// Repository implementation is held to the same behaviour.
+func runTaskRepositoryContract(t *testing.T, store Repository) {
+	owner := testUtils.Caller
+	seed := []domain.Task{
+		{AddedOn: 10, DueBy: 100, Title: "first", Description: "sample", Status: "open", Tags: []string{"home", "urgent"},
+			Priority: "P1"},
+		{AddedOn: 20, DueBy: 200, Title: "second", Description: "sample", Status: "done", Tags: []string{"work"},
+			Recurrence: "FREQ=WEEKLY;BYDAY=MO", Priority: "P0"},
+		{AddedOn: 30, DueBy: 300, Title: "third", Description: "sample", Status: "done", Tags: []string{"urgent", "work"},
+			Priority: "P1"},
+	}
+
+	var ids []string
+	for i := range seed {
+		id, err := store.CreateTask(owner, seed[i], domain.TaskRevision{})
+		if err != nil || id <= 0 {
+			t.Fatalf("Expected task to be created, got id: %d, error: %v", id, err)
+		}
+		seed[i].SetId(id)
+		seed[i].SetOwnerId(owner.UserId)
+		seed[i].SetTenantId(owner.TenantId)
+		seed[i].SetVersion(1)
+		ids = append(ids, strconv.FormatInt(id, 10))
+	}
+
+	t.Run("should get task by id", func(t *testing.T) {
+		tasks, err := store.GetTaskById(owner, ids[1])
+		if err != nil || len(tasks) != 1 || !reflect.DeepEqual(tasks[0], seed[1]) {
+			t.Errorf("Expected: %v, Got: %v, error: %v", seed[1], tasks, err)
+		}
+	})
+
+	t.Run("should get no task for unknown id", func(t *testing.T) {
+		tasks, err := store.GetTaskById(owner, "999999")
+		if err != nil || len(tasks) != 0 {
+			t.Errorf("Expected no tasks, Got: %v, error: %v", tasks, err)
+		}
+	})
+
+	t.Run("should paginate all tasks", func(t *testing.T) {
+		tasks, err := store.GetAllTasks(owner, 1, 2, nil)
+		if err != nil || len(tasks) != 1 || !reflect.DeepEqual(tasks[0], seed[2]) {
+			t.Errorf("Expected: %v, Got: %v, error: %v", seed[2:], tasks, err)
+		}
+
+		tasks, err = store.GetAllTasks(owner, -1, -1, nil)
+		if err != nil || len(tasks) != len(seed) {
+			t.Errorf("Expected %d tasks, Got: %v, error: %v", len(seed), tasks, err)
+		}
+	})
+
+	t.Run("should search tasks", func(t *testing.T) {
+		searches := map[string]struct {
+			params   map[string]string
+			expected []domain.Task
+		}{
+			"by id":          {map[string]string{"id": ids[0]}, seed[:1]},
+			"by status":      {map[string]string{"status": "done"}, seed[1:]},
+			"by dueBy range": {map[string]string{"dueByFrom": "150", "dueByTo": "250"}, seed[1:2]},
+			"by addedOn":     {map[string]string{"addedOnTo": "20", "perPage": "1", "page": "1"}, seed[1:2]},
+			"by any tag":     {map[string]string{"tag": "home,work", "tagMatch": "any"}, seed},
+			"by all tags":    {map[string]string{"tag": "urgent,work", "tagMatch": "all"}, seed[2:]},
+			"by unknown tag": {map[string]string{"tag": "garden"}, nil},
+			"by priority":    {map[string]string{"priority": "P1"}, []domain.Task{seed[0], seed[2]}},
+			"by priorities":  {map[string]string{"priority": "P0,P3"}, seed[1:2]},
+		}
+
+		for name, search := range searches {
+			tasks, err := store.SearchTasks(owner, search.params)
+			if err != nil || !sameTasks(tasks, search.expected) {
+				t.Errorf("Search %s, Expected: %v, Got: %v, error: %v", name, search.expected, tasks, err)
+			}
+		}
+	})
+
+	t.Run("should sort tasks", func(t *testing.T) {
+		sort, _ := domain.ParseSort("priority,-dueBy")
+		expected := []domain.Task{seed[1], seed[2], seed[0]}
+
+		tasks, err := store.GetAllTasks(owner, -1, -1, sort)
+		if err != nil || !reflect.DeepEqual(tasks, expected) {
+			t.Errorf("Expected: %v, Got: %v, error: %v", expected, tasks, err)
+		}
+
+		tasks, err = store.SearchTasks(owner, map[string]string{"sort": "priority,-dueBy", "perPage": "2"})
+		if err != nil || !reflect.DeepEqual(tasks, expected[:2]) {
+			t.Errorf("Expected: %v, Got: %v, error: %v", expected[:2], tasks, err)
+		}
+
+		if _, err = store.SearchTasks(owner, map[string]string{"sort": "description"}); err == nil {
+			t.Errorf("Expected sorting by a column outside the whitelist to fail")
+		}
+	})
+
+	t.Run("should count tags in use", func(t *testing.T) {
+		expected := []domain.Tag{{Name: "home", Count: 1}, {Name: "urgent", Count: 2}, {Name: "work", Count: 2}}
+		tags, err := store.GetTagCounts(owner)
+		if err != nil || !reflect.DeepEqual(tags, expected) {
+			t.Errorf("Expected: %v, Got: %v, error: %v", expected, tags, err)
+		}
+	})
+
+	t.Run("should hide tasks of other users", func(t *testing.T) {
+		other := domain.Identity{UserId: owner.UserId + 1, Username: "intruder"}
+		tasks, err := store.GetTaskById(other, ids[0])
+		if err != nil || len(tasks) != 0 {
+			t.Errorf("Expected no task, Got: %v, error: %v", tasks, err)
+		}
+		tasks, err = store.GetAllTasks(other, -1, -1, nil)
+		if err != nil || len(tasks) != 0 {
+			t.Errorf("Expected no tasks, Got: %v, error: %v", tasks, err)
+		}
+		tasks, err = store.SearchTasks(other, map[string]string{"tag": "work"})
+		if err != nil || len(tasks) != 0 {
+			t.Errorf("Expected no tasks, Got: %v, error: %v", tasks, err)
+		}
+		tags, err := store.GetTagCounts(other)
+		if err != nil || len(tags) != 0 {
+			t.Errorf("Expected no tags, Got: %v, error: %v", tags, err)
+		}
+
+		hijacked := seed[0]
+		hijacked.SetTitle("hijacked")
+		hijacked.SetTags([]string{"hijacked"})
+		if _, err = store.UpdateTask(other, hijacked, ids[0], nil, domain.TaskRevision{}); err != ErrTaskNotFound {
+			t.Errorf("Expected task not to be found, error: %v", err)
+		}
+		deleted, err := store.DeleteTask(other, ids[0], 0, 10, true, domain.TaskRevision{})
+		if err != nil || deleted {
+			t.Errorf("Expected nothing to be deleted, error: %v", err)
+		}
+		tasks, err = store.GetTaskById(owner, ids[0])
+		if err != nil || len(tasks) != 1 || !reflect.DeepEqual(tasks[0], seed[0]) {
+			t.Errorf("Expected: %v, Got: %v, error: %v", seed[0], tasks, err)
+		}
+	})
+
+	t.Run("should update task", func(t *testing.T) {
+		updated := seed[0]
+		updated.SetStatus("done")
+		updated.SetTitle("first, updated")
+		updated.SetTags(nil)
+		if _, err := store.UpdateTask(owner, updated, ids[0], nil, domain.TaskRevision{}); err != nil {
+			t.Fatalf("Error updating task: %v", err)
+		}
+
+		// tags left out of an update are kept
+		updated.SetTags(seed[0].GetTags())
+		updated.SetVersion(2)
+		tasks, err := store.GetTaskById(owner, ids[0])
+		if err != nil || len(tasks) != 1 || !reflect.DeepEqual(tasks[0], updated) {
+			t.Errorf("Expected: %v, Got: %v, error: %v", updated, tasks, err)
+		}
+	})
+
+	t.Run("should replace and clear tags", func(t *testing.T) {
+		updated := seed[1]
+		updated.SetTags([]string{"home"})
+		if _, err := store.UpdateTask(owner, updated, ids[1], nil, domain.TaskRevision{}); err != nil {
+			t.Fatalf("Error updating task: %v", err)
+		}
+		tasks, err := store.GetTaskById(owner, ids[1])
+		if err != nil || len(tasks) != 1 || !reflect.DeepEqual(tasks[0].GetTags(), []string{"home"}) {
+			t.Errorf("Expected tags [home], Got: %v, error: %v", tasks, err)
+		}
+
+		updated.SetTags([]string{})
+		updated.SetVersion(0)
+		if _, err = store.UpdateTask(owner, updated, ids[1], nil, domain.TaskRevision{}); err != nil {
+			t.Fatalf("Error updating task: %v", err)
+		}
+		tasks, err = store.GetTaskById(owner, ids[1])
+		if err != nil || len(tasks) != 1 || tasks[0].GetTags() != nil {
+			t.Errorf("Expected no tags, Got: %v, error: %v", tasks, err)
+		}
+	})
+
+	t.Run("should patch only the given fields", func(t *testing.T) {
+		patch := domain.Task{Title: "ignored", Description: "patched", Status: "open", Tags: []string{"patched"}, Priority: "P3"}
+		if _, err := store.PatchTask(owner, patch, ids[2], []string{"description", "tags", "priority"}, nil, domain.TaskRevision{}); err != nil {
+			t.Fatalf("Error patching task: %v", err)
+		}
+
+		patched := seed[2]
+		patched.SetDescription("patched")
+		patched.SetTags([]string{"patched"})
+		patched.SetPriority("P3")
+		patched.SetVersion(2)
+		tasks, err := store.GetTaskById(owner, ids[2])
+		if err != nil || len(tasks) != 1 || !reflect.DeepEqual(tasks[0], patched) {
+			t.Errorf("Expected: %v, Got: %v, error: %v", patched, tasks, err)
+		}
+
+		stranger := domain.Identity{UserId: owner.UserId + 100, Username: "stranger", TenantId: owner.TenantId}
+		if _, err = store.PatchTask(stranger, domain.Task{Title: "stolen"}, ids[2], []string{"title", "tags"}, nil, domain.TaskRevision{}); err != ErrTaskNotFound {
+			t.Errorf("Expected task not to be found, error: %v", err)
+		}
+		tasks, err = store.GetTaskById(owner, ids[2])
+		if err != nil || len(tasks) != 1 || !reflect.DeepEqual(tasks[0], patched) {
+			t.Errorf("Expected task to be left alone, Got: %v, error: %v", tasks, err)
+		}
+	})
+
+	t.Run("should only change tasks still at the expected version", func(t *testing.T) {
+		stale := seed[2]
+		stale.SetTitle("stale")
+		next := &domain.Task{Title: "next", Status: "open"}
+		existing, _ := store.GetAllTasks(owner, -1, -1, nil)
+		if _, err := store.UpdateTask(owner, stale, ids[2], next, domain.TaskRevision{}); err != ErrVersionConflict {
+			t.Errorf("Expected a version conflict updating, Got: %v", err)
+		}
+		if _, err := store.PatchTask(owner, stale, ids[2], []string{"title"}, next, domain.TaskRevision{}); err != ErrVersionConflict {
+			t.Errorf("Expected a version conflict patching, Got: %v", err)
+		}
+		if tasks, _ := store.GetAllTasks(owner, -1, -1, nil); len(tasks) != len(existing) {
+			t.Errorf("Expected no next occurrence of a stale change, Got: %v", tasks)
+		}
+		deleted, err := store.DeleteTask(owner, ids[2], 1, 10, true, domain.TaskRevision{})
+		if err != nil || deleted {
+			t.Errorf("Expected nothing to be deleted, error: %v", err)
+		}
+
+		tasks, err := store.GetTaskById(owner, ids[2])
+		if err != nil || len(tasks) != 1 || tasks[0].GetTitle() != "third" || tasks[0].GetVersion() != 2 {
+			t.Errorf("Expected task to be left at version 2, Got: %v, error: %v", tasks, err)
+		}
+	})
+
+	t.Run("should delete task only once", func(t *testing.T) {
+		deleted, err := store.DeleteTask(owner, ids[2], 0, 10, true, domain.TaskRevision{})
+		if err != nil || !deleted {
+			t.Errorf("Expected task to be deleted, error: %v", err)
+		}
+
+		deleted, err = store.DeleteTask(owner, ids[2], 0, 10, true, domain.TaskRevision{})
+		if err != nil || deleted {
+			t.Errorf("Expected nothing to be deleted, error: %v", err)
+		}
+	})
+
+	runSubtaskContract(t, store, owner)
+	runUserContract(t, store)
+	runApiKeyContract(t, store, owner)
+	runListContract(t, store)
+	runTenantContract(t, store, owner)
+	runBatchContract(t, store, owner)
+	runIdempotencyContract(t, store, owner)
+	runTrashContract(t, store, owner)
+	runHistoryContract(t, store, owner)
+	runCommentContract(t, store, owner)
+	runAttachmentContract(t, store, owner)
+	runDependencyContract(t, store, owner)
+}
+
+func runSubtaskContract(t *testing.T, store TaskRepository, owner domain.Identity) {
+	create := func(task domain.Task) string {
+		id, err := store.CreateTask(owner, task, domain.TaskRevision{})
+		if err != nil {
+			t.Fatalf("Error creating task: %v", err)
+		}
+		return strconv.FormatInt(id, 10)
+	}
+
+	parentId := create(domain.Task{Title: "project", Status: "open"})
+	parent := parseId(parentId)
+	doneChildId := create(domain.Task{Title: "done child", Status: completedStatus, ParentId: parent})
+	openChildId := create(domain.Task{Title: "open child", Status: "open", ParentId: parent})
+	grandchildId := create(domain.Task{Title: "grandchild", Status: "open", ParentId: parseId(doneChildId)})
+
+	t.Run("should roll up children of parent", func(t *testing.T) {
+		tasks, err := store.GetTaskById(owner, parentId)
+		expected := &domain.SubtaskRollup{Total: 2, Done: 1}
+		if err != nil || len(tasks) != 1 || !reflect.DeepEqual(tasks[0].GetSubtasks(), expected) {
+			t.Errorf("Expected rollup: %v, Got: %v, error: %v", expected, tasks, err)
+		}
+	})
+
+	t.Run("should get direct subtasks only", func(t *testing.T) {
+		tasks, err := store.GetSubtasks(owner, parentId)
+		if err != nil || len(tasks) != 2 ||
+			strconv.FormatInt(tasks[0].GetId(), 10) != doneChildId ||
+			strconv.FormatInt(tasks[1].GetId(), 10) != openChildId {
+			t.Errorf("Expected children %s and %s, Got: %v, error: %v", doneChildId, openChildId, tasks, err)
+		}
+	})
+
+	t.Run("should search by parent", func(t *testing.T) {
+		tasks, err := store.SearchTasks(owner, map[string]string{"parentId": doneChildId})
+		if err != nil || len(tasks) != 1 || strconv.FormatInt(tasks[0].GetId(), 10) != grandchildId {
+			t.Errorf("Expected grandchild %s, Got: %v, error: %v", grandchildId, tasks, err)
+		}
+	})
+
+	t.Run("should delete subtasks with their parent", func(t *testing.T) {
+		deleted, err := store.DeleteTask(owner, parentId, 0, 10, true, domain.TaskRevision{})
+		if err != nil || !deleted {
+			t.Fatalf("Expected parent to be deleted, error: %v", err)
+		}
+
+		for _, id := range []string{doneChildId, openChildId, grandchildId} {
+			tasks, err := store.GetTaskById(owner, id)
+			if err != nil || len(tasks) != 0 {
+				t.Errorf("Expected subtask %s to be deleted, Got: %v, error: %v", id, tasks, err)
+			}
+		}
+	})
+}
+
+func runUserContract(t *testing.T, store UserRepository) {
+	user := domain.User{Username: "alice", PasswordHash: "hash", Role: domain.RoleEditor, TenantId: "acme", AddedOn: 10}
+	id, err := store.CreateUser(user)
+	if err != nil || id <= 0 {
+		t.Fatalf("Expected user to be created, got id: %d, error: %v", id, err)
+	}
+	user.Id = id
+
+	t.Run("should get user by name", func(t *testing.T) {
+		users, err := store.GetUserByName("acme", "alice")
+		if err != nil || len(users) != 1 || !reflect.DeepEqual(users[0], user) {
+			t.Errorf("Expected: %v, Got: %v, error: %v", user, users, err)
+		}
+
+		for _, scenario := range []struct{ tenantId, username string }{{"acme", "bob"}, {"globex", "alice"}} {
+			users, err = store.GetUserByName(scenario.tenantId, scenario.username)
+			if err != nil || len(users) != 0 {
+				t.Errorf("Expected no user %s in tenant %s, Got: %v, error: %v", scenario.username, scenario.tenantId, users, err)
+			}
+		}
+	})
+
+	t.Run("should get user by id", func(t *testing.T) {
+		users, err := store.GetUserById(id)
+		if err != nil || len(users) != 1 || !reflect.DeepEqual(users[0], user) {
+			t.Errorf("Expected: %v, Got: %v, error: %v", user, users, err)
+		}
+	})
+
+	t.Run("should update role", func(t *testing.T) {
+		found, err := store.UpdateUserRole(id, domain.RoleViewer)
+		if err != nil || !found {
+			t.Fatalf("Expected role to be updated, error: %v", err)
+		}
+
+		users, err := store.GetUserById(id)
+		if err != nil || len(users) != 1 || users[0].GetRole() != domain.RoleViewer {
+			t.Errorf("Expected viewer, Got: %v, error: %v", users, err)
+		}
+
+		found, err = store.UpdateUserRole(id+1, domain.RoleViewer)
+		if err != nil || found {
+			t.Errorf("Expected no user to be found, error: %v", err)
+		}
+	})
+
+	t.Run("should reject taken username", func(t *testing.T) {
+		if _, err := store.CreateUser(user); err == nil {
+			t.Error("Expected error for duplicate username")
+		}
+	})
+
+	t.Run("should take usernames of other tenants", func(t *testing.T) {
+		other := domain.User{Username: "alice", PasswordHash: "hash", Role: domain.RoleEditor, TenantId: "globex", AddedOn: 20}
+		otherId, err := store.CreateUser(other)
+		if err != nil || otherId == id {
+			t.Errorf("Expected alice to be created in another tenant, Got id: %d, error: %v", otherId, err)
+		}
+	})
+}
+
+func runApiKeyContract(t *testing.T, store ApiKeyRepository, owner domain.Identity) {
+	other := domain.Identity{UserId: owner.UserId + 1, Username: "other", TenantId: owner.TenantId}
+	key := domain.ApiKey{Name: "ci", Prefix: "todo_abcdefg", KeyHash: "hash", Scope: domain.ScopeReadOnly, AddedOn: 10}
+	id, err := store.CreateApiKey(owner, key)
+	if err != nil || id <= 0 {
+		t.Fatalf("Expected API key to be created, got id: %d, error: %v", id, err)
+	}
+	key.Id = id
+	key.UserId = owner.UserId
+	key.TenantId = owner.TenantId
+
+	t.Run("should list API keys of their owner only", func(t *testing.T) {
+		keys, err := store.GetApiKeys(owner)
+		if err != nil || len(keys) != 1 || !reflect.DeepEqual(keys[0], key) {
+			t.Errorf("Expected: %v, Got: %v, error: %v", key, keys, err)
+		}
+
+		keys, err = store.GetApiKeys(other)
+		if err != nil || len(keys) != 0 {
+			t.Errorf("Expected no API keys, Got: %v, error: %v", keys, err)
+		}
+	})
+
+	t.Run("should find API key by hash and record its use", func(t *testing.T) {
+		if err := store.TouchApiKey(id, 20); err != nil {
+			t.Fatalf("Error touching API key: %v", err)
+		}
+
+		keys, err := store.GetApiKeyByHash("hash")
+		if err != nil || len(keys) != 1 || keys[0].LastUsedOn != 20 || keys[0].UserId != owner.UserId {
+			t.Errorf("Expected key used on 20, Got: %v, error: %v", keys, err)
+		}
+
+		keys, err = store.GetApiKeyByHash("other")
+		if err != nil || len(keys) != 0 {
+			t.Errorf("Expected no API keys, Got: %v, error: %v", keys, err)
+		}
+	})
+
+	t.Run("should revoke API keys of their owner only", func(t *testing.T) {
+		idString := strconv.FormatInt(id, 10)
+		deleted, err := store.DeleteApiKey(other, idString)
+		if err != nil || deleted {
+			t.Errorf("Expected nothing to be deleted, error: %v", err)
+		}
+
+		deleted, err = store.DeleteApiKey(owner, idString)
+		if err != nil || !deleted {
+			t.Errorf("Expected API key to be deleted, error: %v", err)
+		}
+
+		keys, err := store.GetApiKeyByHash("hash")
+		if err != nil || len(keys) != 0 {
+			t.Errorf("Expected no API keys, Got: %v, error: %v", keys, err)
+		}
+	})
+}
+
+func runListContract(t *testing.T, store Repository) {
+	var identities []domain.Identity
+	for _, username := range []string{"carol", "dave"} {
+		user := domain.User{Username: username, PasswordHash: "hash", Role: domain.RoleEditor, TenantId: domain.DefaultTenant}
+		id, err := store.CreateUser(user)
+		if err != nil {
+			t.Fatalf("Error creating user %s: %v", username, err)
+		}
+		user.Id = id
+		identities = append(identities, user.Identity())
+	}
+	carol, dave := identities[0], identities[1]
+
+	list := domain.List{Name: "Sprint 12", AddedOn: 10}
+	listId, err := store.CreateList(carol, list)
+	if err != nil || listId <= 0 {
+		t.Fatalf("Expected list to be created, got id: %d, error: %v", listId, err)
+	}
+	id := strconv.FormatInt(listId, 10)
+	list.Id = listId
+	list.OwnerId = carol.UserId
+	list.TenantId = carol.TenantId
+
+	task := domain.Task{AddedOn: 10, DueBy: 100, Title: "plan", Description: "sample", Status: "open", ListId: listId}
+	taskId, err := store.CreateTask(carol, task, domain.TaskRevision{})
+	if err != nil {
+		t.Fatalf("Error creating task: %v", err)
+	}
+	task.SetId(taskId)
+	task.SetOwnerId(carol.UserId)
+	task.SetTenantId(carol.TenantId)
+	task.SetVersion(1)
+	privateId, _ := store.CreateTask(carol, domain.Task{Title: "private", Status: "open"}, domain.TaskRevision{})
+
+	t.Run("should only show lists to their members", func(t *testing.T) {
+		lists, err := store.GetLists(carol)
+		if err != nil || len(lists) != 1 || !reflect.DeepEqual(lists[0], list) {
+			t.Errorf("Expected: %v, Got: %v, error: %v", list, lists, err)
+		}
+
+		lists, err = store.GetListById(dave, id)
+		if err != nil || len(lists) != 0 {
+			t.Errorf("Expected no lists, Got: %v, error: %v", lists, err)
+		}
+
+		tasks, err := store.GetTaskById(dave, strconv.FormatInt(taskId, 10))
+		if err != nil || len(tasks) != 0 {
+			t.Errorf("Expected no tasks, Got: %v, error: %v", tasks, err)
+		}
+	})
+
+	t.Run("should only let owners share lists", func(t *testing.T) {
+		added, err := store.AddListMember(dave, id, dave.UserId, 20)
+		if err != nil || added {
+			t.Errorf("Expected only the owner to add members, error: %v", err)
+		}
+
+		added, err = store.AddListMember(carol, id, dave.UserId, 20)
+		if err != nil || !added {
+			t.Fatalf("Expected dave to be added, error: %v", err)
+		}
+
+		lists, err := store.GetListById(dave, id)
+		expected := []domain.ListMember{{UserId: carol.UserId, Username: "carol", AddedOn: 10}, {UserId: dave.UserId, Username: "dave", AddedOn: 20}}
+		if err != nil || len(lists) != 1 || !reflect.DeepEqual(lists[0].Members, expected) {
+			t.Errorf("Expected members: %v, Got: %v, error: %v", expected, lists, err)
+		}
+	})
+
+	t.Run("should share tasks with members", func(t *testing.T) {
+		tasks, err := store.GetListTasks(dave, id, 0, 10, nil)
+		if err != nil || len(tasks) != 1 || !reflect.DeepEqual(tasks[0], task) {
+			t.Errorf("Expected: %v, Got: %v, error: %v", task, tasks, err)
+		}
+
+		tasks, err = store.SearchTasks(dave, map[string]string{"listId": id})
+		if err != nil || len(tasks) != 1 || tasks[0].GetId() != taskId {
+			t.Errorf("Expected task %d, Got: %v, error: %v", taskId, tasks, err)
+		}
+
+		tasks, err = store.GetTaskById(dave, strconv.FormatInt(privateId, 10))
+		if err != nil || len(tasks) != 0 {
+			t.Errorf("Expected private task to stay hidden, Got: %v, error: %v", tasks, err)
+		}
+
+		task.SetTitle("plan sprint")
+		if _, err = store.UpdateTask(dave, task, strconv.FormatInt(taskId, 10), nil, domain.TaskRevision{}); err != nil {
+			t.Fatalf("Error updating task: %v", err)
+		}
+		task.SetVersion(2)
+		tasks, err = store.GetTaskById(carol, strconv.FormatInt(taskId, 10))
+		if err != nil || len(tasks) != 1 || !reflect.DeepEqual(tasks[0], task) {
+			t.Errorf("Expected: %v, Got: %v, error: %v", task, tasks, err)
+		}
+	})
+
+	t.Run("should only let owners change lists", func(t *testing.T) {
+		renamed := domain.List{Name: "Sprint 13"}
+		if err := store.UpdateList(dave, renamed, id); err != nil {
+			t.Fatalf("Error updating list: %v", err)
+		}
+		lists, _ := store.GetListById(carol, id)
+		if len(lists) != 1 || lists[0].GetName() != "Sprint 12" {
+			t.Errorf("Expected list to keep its name, Got: %v", lists)
+		}
+
+		if err := store.UpdateList(carol, renamed, id); err != nil {
+			t.Fatalf("Error updating list: %v", err)
+		}
+		lists, _ = store.GetListById(carol, id)
+		if len(lists) != 1 || lists[0].GetName() != "Sprint 13" {
+			t.Errorf("Expected list to be renamed, Got: %v", lists)
+		}
+
+		deleted, err := store.DeleteList(dave, id)
+		if err != nil || deleted {
+			t.Errorf("Expected nothing to be deleted, error: %v", err)
+		}
+	})
+
+	t.Run("should let members leave but not owners", func(t *testing.T) {
+		removed, err := store.RemoveListMember(carol, id, carol.UserId)
+		if err != nil || removed {
+			t.Errorf("Expected owner to stay, error: %v", err)
+		}
+
+		removed, err = store.RemoveListMember(dave, id, dave.UserId)
+		if err != nil || !removed {
+			t.Errorf("Expected dave to leave, error: %v", err)
+		}
+
+		tasks, err := store.GetListTasks(dave, id, 0, 10, nil)
+		if err != nil || len(tasks) != 0 {
+			t.Errorf("Expected no tasks, Got: %v, error: %v", tasks, err)
+		}
+	})
+
+	t.Run("should keep tasks of deleted lists", func(t *testing.T) {
+		deleted, err := store.DeleteList(carol, id)
+		if err != nil || !deleted {
+			t.Fatalf("Expected list to be deleted, error: %v", err)
+		}
+
+		// leaving the list is a change of the task like any other
+		task.SetListId(0)
+		task.SetVersion(task.GetVersion() + 1)
+		tasks, err := store.GetTaskById(carol, strconv.FormatInt(taskId, 10))
+		if err != nil || len(tasks) != 1 || !reflect.DeepEqual(tasks[0], task) {
+			t.Errorf("Expected: %v, Got: %v, error: %v", task, tasks, err)
+		}
+
+		lists, err := store.GetLists(carol)
+		if err != nil || len(lists) != 0 {
+			t.Errorf("Expected no lists, Got: %v, error: %v", lists, err)
+		}
+	})
+}
+
+// runTenantContract checks that guessing ids gets nowhere from another tenant,
+// not even for an identity with the same user id as the owner.
+func runTenantContract(t *testing.T, store Repository, owner domain.Identity) {
+	intruder := owner
+	intruder.TenantId = "globex"
+
+	parentId, err := store.CreateTask(owner, domain.Task{Title: "secret", Status: "open", Tags: []string{"secret"}}, domain.TaskRevision{})
+	if err != nil {
+		t.Fatalf("Error creating task: %v", err)
+	}
+	_, _ = store.CreateTask(owner, domain.Task{Title: "secret child", Status: "open", ParentId: parentId}, domain.TaskRevision{})
+	id := strconv.FormatInt(parentId, 10)
+	listId, err := store.CreateList(owner, domain.List{Name: "Secrets", AddedOn: 10})
+	if err != nil {
+		t.Fatalf("Error creating list: %v", err)
+	}
+	list := strconv.FormatInt(listId, 10)
+
+	t.Run("should not read tasks of other tenants", func(t *testing.T) {
+		tasks, err := store.GetTaskById(intruder, id)
+		if err != nil || len(tasks) != 0 {
+			t.Errorf("Expected no tasks, Got: %v, error: %v", tasks, err)
+		}
+
+		tasks, err = store.GetAllTasks(intruder, -1, -1, nil)
+		if err != nil || len(tasks) != 0 {
+			t.Errorf("Expected no tasks, Got: %v, error: %v", tasks, err)
+		}
+
+		tasks, err = store.SearchTasks(intruder, map[string]string{"id": id})
+		if err != nil || len(tasks) != 0 {
+			t.Errorf("Expected no tasks, Got: %v, error: %v", tasks, err)
+		}
+
+		tasks, err = store.GetSubtasks(intruder, id)
+		if err != nil || len(tasks) != 0 {
+			t.Errorf("Expected no subtasks, Got: %v, error: %v", tasks, err)
+		}
+
+		tags, err := store.GetTagCounts(intruder)
+		if err != nil || len(tags) != 0 {
+			t.Errorf("Expected no tags, Got: %v, error: %v", tags, err)
+		}
+	})
+
+	t.Run("should not change tasks of other tenants", func(t *testing.T) {
+		if _, err := store.UpdateTask(intruder, domain.Task{Title: "stolen", Status: "done"}, id, nil, domain.TaskRevision{}); err != ErrTaskNotFound {
+			t.Errorf("Expected task not to be found, error: %v", err)
+		}
+
+		deleted, err := store.DeleteTask(intruder, id, 0, 10, true, domain.TaskRevision{})
+		if err != nil || deleted {
+			t.Errorf("Expected nothing to be deleted, error: %v", err)
+		}
+
+		tasks, err := store.GetTaskById(owner, id)
+		if err != nil || len(tasks) != 1 || tasks[0].GetTitle() != "secret" || tasks[0].GetSubtasks() == nil {
+			t.Errorf("Expected task to be left alone, Got: %v, error: %v", tasks, err)
+		}
+	})
+
+	t.Run("should not reach lists of other tenants", func(t *testing.T) {
+		lists, err := store.GetListById(intruder, list)
+		if err != nil || len(lists) != 0 {
+			t.Errorf("Expected no lists, Got: %v, error: %v", lists, err)
+		}
+
+		added, err := store.AddListMember(intruder, list, intruder.UserId+1, 20)
+		if err != nil || added {
+			t.Errorf("Expected no member to be added, error: %v", err)
+		}
+
+		deleted, err := store.DeleteList(intruder, list)
+		if err != nil || deleted {
+			t.Errorf("Expected nothing to be deleted, error: %v", err)
+		}
+
+		lists, err = store.GetLists(owner)
+		if err != nil || len(lists) != 1 || lists[0].GetId() != listId {
+			t.Errorf("Expected list to be left alone, Got: %v, error: %v", lists, err)
+		}
+	})
+}
+
+func sameTasks(actual []domain.Task, expected []domain.Task) bool {
+	return len(actual) == len(expected) && (len(actual) == 0 || reflect.DeepEqual(actual, expected))
+}
+
+// runBatchContract checks that an atomic batch is stored whole or not at all,
+// while the writes of other batches stand alone.
+func runBatchContract(t *testing.T, store TaskRepository, owner domain.Identity) {
+	baseId, err := store.CreateTask(owner, domain.Task{Title: "base", Status: "open"}, domain.TaskRevision{})
+	if err != nil {
+		t.Fatalf("Error creating task: %v", err)
+	}
+	base := strconv.FormatInt(baseId, 10)
+	before, _ := store.GetAllTasks(owner, -1, -1, nil)
+
+	t.Run("should roll back atomic batches", func(t *testing.T) {
+		results, err := store.ApplyBatch(owner, []domain.TaskWrite{
+			{Op: domain.BatchCreate, Task: domain.Task{Title: "imported", Status: "open", Tags: []string{"imported"}}},
+			{Op: domain.BatchUpdate, Task: domain.Task{Id: baseId, Title: "changed", Status: "open", Version: 1},
+				Next: &domain.Task{Title: "next", Status: "open"}},
+			{Op: domain.BatchUpdate, Task: domain.Task{Id: baseId, Title: "changed again", Status: "open", Version: 1}},
+		}, true)
+		if err != nil || len(results) != 3 || results[0].Err != ErrBatchRolledBack || results[1].Err != ErrBatchRolledBack ||
+			results[2].Err != ErrVersionConflict || results[2].Applied {
+			t.Fatalf("Expected the stale update to roll back the batch, Got: %v, error: %v", results, err)
+		}
+
+		after, _ := store.GetAllTasks(owner, -1, -1, nil)
+		tasks, err := store.GetTaskById(owner, base)
+		if err != nil || len(tasks) != 1 || tasks[0].GetTitle() != "base" || tasks[0].GetVersion() != 1 || len(after) != len(before) {
+			t.Errorf("Expected nothing to be stored, Got: %v, error: %v", tasks, err)
+		}
+	})
+
+	t.Run("should create next occurrences with their update", func(t *testing.T) {
+		results, err := store.ApplyBatch(owner, []domain.TaskWrite{
+			{Op: domain.BatchUpdate, Task: domain.Task{Id: baseId, Title: "base", Status: "open", Version: 1},
+				Next: &domain.Task{Title: "next", Status: "open"}},
+		}, true)
+		if err != nil || len(results) != 1 || !results[0].Applied || results[0].NextId <= 0 {
+			t.Fatalf("Expected the update and its next occurrence to be stored, Got: %v, error: %v", results, err)
+		}
+
+		tasks, err := store.GetTaskById(owner, strconv.FormatInt(results[0].NextId, 10))
+		if err != nil || len(tasks) != 1 || tasks[0].GetTitle() != "next" {
+			t.Errorf("Expected next occurrence, Got: %v, error: %v", tasks, err)
+		}
+	})
+
+	t.Run("should apply best effort batches write by write", func(t *testing.T) {
+		results, err := store.ApplyBatch(owner, []domain.TaskWrite{
+			{Op: domain.BatchCreate, Task: domain.Task{Title: "imported", Status: "open", Tags: []string{"imported"}}},
+			{Op: domain.BatchUpdate, Task: domain.Task{Id: baseId, Title: "changed", Status: "open", Version: 7}},
+			{Op: domain.BatchDelete, Task: domain.Task{Id: baseId, Version: 2, DeletedOn: 10}},
+		}, false)
+		if err != nil || len(results) != 3 || !results[0].Applied || results[1].Err != ErrVersionConflict || !results[2].Applied {
+			t.Fatalf("Expected the stale update alone to fail, Got: %v, error: %v", results, err)
+		}
+
+		tasks, err := store.GetTaskById(owner, strconv.FormatInt(results[0].Id, 10))
+		if err != nil || len(tasks) != 1 || !reflect.DeepEqual(tasks[0].GetTags(), []string{"imported"}) {
+			t.Errorf("Expected imported task, Got: %v, error: %v", tasks, err)
+		}
+		tasks, err = store.GetTaskById(owner, base)
+		if err != nil || len(tasks) != 0 {
+			t.Errorf("Expected task to be deleted, Got: %v, error: %v", tasks, err)
+		}
+	})
+}
+
+// runIdempotencyContract checks that an Idempotency-Key is reserved once per
+// caller until it expires or is released.
+func runIdempotencyContract(t *testing.T, store IdempotencyRepository, owner domain.Identity) {
+	other := domain.Identity{UserId: owner.UserId + 1, Username: "other", TenantId: owner.TenantId}
+	record := domain.IdempotencyRecord{Key: "retry-1", RequestHash: "hash", AddedOn: 10, ExpiresOn: 20}
+
+	t.Run("should reserve keys once per caller", func(t *testing.T) {
+		for _, expected := range []struct {
+			caller   domain.Identity
+			reserved bool
+		}{{owner, true}, {owner, false}, {other, true}} {
+			reserved, err := store.ReserveIdempotencyKey(expected.caller, record)
+			if err != nil || reserved != expected.reserved {
+				t.Errorf("Expected key of %s reserved: %t, Got: %t, error: %v", expected.caller.Username, expected.reserved, reserved, err)
+			}
+		}
+	})
+
+	t.Run("should store the response of a key", func(t *testing.T) {
+		completed := record
+		completed.StatusCode, completed.ContentType, completed.ETag, completed.Body = 200, "application/json", `"1"`, `{"id":1}`
+		if err := store.CompleteIdempotencyKey(owner, completed); err != nil {
+			t.Fatalf("Error completing key: %v", err)
+		}
+
+		completed.UserId, completed.TenantId = owner.UserId, owner.TenantId
+		records, err := store.GetIdempotencyRecord(owner, record.Key)
+		if err != nil || len(records) != 1 || !reflect.DeepEqual(records[0], completed) {
+			t.Errorf("Expected: %v, Got: %v, error: %v", completed, records, err)
+		}
+		records, err = store.GetIdempotencyRecord(other, record.Key)
+		if err != nil || len(records) != 1 || records[0].StatusCode != 0 {
+			t.Errorf("Expected the key of other to be in progress, Got: %v, error: %v", records, err)
+		}
+	})
+
+	t.Run("should free released and expired keys", func(t *testing.T) {
+		if err := store.ReleaseIdempotencyKey(other, record.Key); err != nil {
+			t.Fatalf("Error releasing key: %v", err)
+		}
+		records, err := store.GetIdempotencyRecord(other, record.Key)
+		if err != nil || len(records) != 0 {
+			t.Errorf("Expected no record, Got: %v, error: %v", records, err)
+		}
+
+		later := domain.IdempotencyRecord{Key: record.Key, RequestHash: "other hash", AddedOn: 20, ExpiresOn: 30}
+		reserved, err := store.ReserveIdempotencyKey(owner, later)
+		if err != nil || !reserved {
+			t.Errorf("Expected the expired key to be reserved again, error: %v", err)
+		}
+	})
+}
+
+// runTrashContract checks that deleted tasks wait in the trash, out of every
+// other query, until they are restored or purged.
+func runTrashContract(t *testing.T, store Repository, owner domain.Identity) {
+	other := domain.Identity{UserId: owner.UserId + 1, Username: "other", TenantId: owner.TenantId}
+	create := func(task domain.Task) string {
+		id, err := store.CreateTask(owner, task, domain.TaskRevision{})
+		if err != nil {
+			t.Fatalf("Error creating task: %v", err)
+		}
+		return strconv.FormatInt(id, 10)
+	}
+	parentId := create(domain.Task{Title: "parent", Status: "open"})
+	childId := create(domain.Task{Title: "child", Status: "open", ParentId: parseId(parentId)})
+	grandchildId := create(domain.Task{Title: "grandchild", Status: "open", ParentId: parseId(childId)})
+	loneId := create(domain.Task{Title: "lone", Status: "open", Tags: []string{"trashed"}})
+
+	deleted, err := store.DeleteTask(owner, parentId, 0, 50, false, domain.TaskRevision{})
+	if tasks, _ := store.GetTaskById(owner, parentId); err != ErrHasSubtasks || deleted || len(tasks) != 1 ||
+		tasks[0].GetVersion() != 1 {
+		t.Errorf("Expected a task with subtasks to be kept without cascade, Got: %v, error: %v", tasks, err)
+	}
+
+	for _, deletion := range []struct {
+		id        string
+		deletedOn int64
+	}{{childId, 100}, {parentId, 200}, {loneId, 300}} {
+		if deleted, err := store.DeleteTask(owner, deletion.id, 0, deletion.deletedOn, true, domain.TaskRevision{}); err != nil || !deleted {
+			t.Fatalf("Expected task %s to be deleted, error: %v", deletion.id, err)
+		}
+	}
+
+	t.Run("should leave deleted tasks out of other queries", func(t *testing.T) {
+		for _, id := range []string{parentId, childId, grandchildId, loneId} {
+			tasks, err := store.GetTaskById(owner, id)
+			if err != nil || len(tasks) != 0 {
+				t.Errorf("Expected task %s to be in the trash, Got: %v, error: %v", id, tasks, err)
+			}
+		}
+		tags, err := store.GetTagCounts(owner)
+		for _, tag := range tags {
+			if tag.Name == "trashed" || err != nil {
+				t.Errorf("Expected tags of deleted tasks to be left out, Got: %v, error: %v", tags, err)
+			}
+		}
+	})
+
+	t.Run("should list the trash of caller, most recently deleted first", func(t *testing.T) {
+		tasks, err := store.GetTrash(owner, 0, 4)
+		var got []string
+		var deletedOn []int64
+		for _, task := range tasks {
+			got = append(got, strconv.FormatInt(task.GetId(), 10))
+			deletedOn = append(deletedOn, task.GetDeletedOn())
+		}
+		expected := []string{loneId, parentId, childId, grandchildId}
+		if err != nil || !reflect.DeepEqual(got, expected) || !reflect.DeepEqual(deletedOn, []int64{300, 200, 100, 100}) {
+			t.Errorf("Expected: %v, Got: %v deleted on %v, error: %v", expected, got, deletedOn, err)
+		}
+
+		tasks, err = store.GetTrashedTask(other, parentId)
+		if err != nil || len(tasks) != 0 {
+			t.Errorf("Expected no task, Got: %v, error: %v", tasks, err)
+		}
+	})
+
+	t.Run("should restore tasks with the subtasks deleted along with them", func(t *testing.T) {
+		restored, err := store.RestoreTask(other, parentId, domain.TaskRevision{})
+		if err != nil || restored {
+			t.Errorf("Expected nothing to be restored, error: %v", err)
+		}
+
+		restored, err = store.RestoreTask(owner, parentId, domain.TaskRevision{})
+		tasks, _ := store.GetTaskById(owner, parentId)
+		if err != nil || !restored || len(tasks) != 1 || tasks[0].GetDeletedOn() != 0 || tasks[0].GetVersion() != 3 {
+			t.Fatalf("Expected parent to be restored, Got: %v, error: %v", tasks, err)
+		}
+		if tasks, _ = store.GetTaskById(owner, childId); len(tasks) != 0 {
+			t.Errorf("Expected the child deleted before its parent to stay in the trash, Got: %v", tasks)
+		}
+
+		restored, err = store.RestoreTask(owner, childId, domain.TaskRevision{})
+		tasks, _ = store.GetSubtasks(owner, childId)
+		if err != nil || !restored || len(tasks) != 1 || strconv.FormatInt(tasks[0].GetId(), 10) != grandchildId {
+			t.Errorf("Expected the grandchild to be restored with the child, Got: %v, error: %v", tasks, err)
+		}
+
+		restored, err = store.RestoreTask(owner, parentId, domain.TaskRevision{})
+		if err != nil || restored {
+			t.Errorf("Expected nothing to be restored, error: %v", err)
+		}
+	})
+
+	t.Run("should purge tasks deleted before the given time", func(t *testing.T) {
+		defer func(size uint64) { purgeChunkSize = size }(purgeChunkSize)
+		purgeChunkSize = 1
+
+		purged, _, err := store.PurgeTrash(300)
+		tasks, _ := store.GetTrashedTask(owner, loneId)
+		if err != nil || purged < 2 || len(tasks) != 1 {
+			t.Errorf("Expected the tasks deleted before 300 to be purged, Got: %d purged, error: %v", purged, err)
+		}
+
+		purged, _, err = store.PurgeTrash(301)
+		tasks, _ = store.GetTrashedTask(owner, loneId)
+		if err != nil || purged != 1 || len(tasks) != 0 {
+			t.Errorf("Expected the lone task to be purged, Got: %d purged, error: %v", purged, err)
+		}
+		if restored, err := store.RestoreTask(owner, loneId, domain.TaskRevision{}); err != nil || restored {
+			t.Errorf("Expected nothing to be restored, error: %v", err)
+		}
+	})
+}
+
+func runHistoryContract(t *testing.T, store Repository, owner domain.Identity) {
+	stranger := domain.Identity{UserId: owner.UserId, Username: owner.Username, TenantId: owner.TenantId + "-other"}
+	revision := func(action string, changedOn int64) domain.TaskRevision {
+		return domain.TaskRevision{Action: action, ActorId: owner.UserId, Actor: owner.Username, ChangedOn: changedOn}
+	}
+	taskId, err := store.CreateTask(owner, domain.Task{Title: "tracked", Status: "open", Tags: []string{"v1"}},
+		revision(domain.HistoryCreate, 1))
+	if err != nil {
+		t.Fatalf("Error creating task: %v", err)
+	}
+	id := strconv.FormatInt(taskId, 10)
+	for i, title := range []string{"renamed", "renamed again"} {
+		task := domain.Task{Title: title, Status: "open", Tags: []string{"v" + strconv.Itoa(i+2)}}
+		if _, err = store.UpdateTask(owner, task, id, nil, revision(domain.HistoryUpdate, int64(i+2))); err != nil {
+			t.Fatalf("Error updating task: %v", err)
+		}
+	}
+
+	t.Run("should list the history of a task, the latest first", func(t *testing.T) {
+		revisions, err := store.GetTaskHistory(owner, id, 0, 2)
+		var versions []int64
+		for _, revision := range revisions {
+			versions = append(versions, revision.Version)
+		}
+		if err != nil || !reflect.DeepEqual(versions, []int64{3, 2}) {
+			t.Fatalf("Expected versions [3 2], Got: %v, error: %v", versions, err)
+		}
+		changes, _ := json.Marshal(revisions[0].Changes)
+		expected := `[{"field":"title","before":"renamed","after":"renamed again"},{"field":"tags","before":["v2"],"after":["v3"]}]`
+		if string(changes) != expected || revisions[0].Actor != owner.Username || revisions[0].ChangedOn != 3 {
+			t.Errorf("Expected changes: %s, Got: %s in %v", expected, changes, revisions[0])
+		}
+
+		revisions, err = store.GetTaskHistory(owner, id, -1, -1)
+		if err != nil || len(revisions) != 3 {
+			t.Errorf("Expected the whole history, Got: %v, error: %v", revisions, err)
+		}
+		revisions, err = store.GetTaskHistory(stranger, id, -1, -1)
+		if err != nil || len(revisions) != 0 {
+			t.Errorf("Expected no history for another tenant, Got: %v, error: %v", revisions, err)
+		}
+	})
+
+	t.Run("should find a revision with the task as it was", func(t *testing.T) {
+		revisions, err := store.GetTaskRevision(owner, id, 2)
+		if err != nil || len(revisions) != 1 || revisions[0].Task.GetTitle() != "renamed" ||
+			!reflect.DeepEqual(revisions[0].Task.GetTags(), []string{"v2"}) {
+			t.Errorf("Expected revision 2, Got: %v, error: %v", revisions, err)
+		}
+		revisions, err = store.GetTaskRevision(owner, id, 4)
+		if err != nil || len(revisions) != 0 {
+			t.Errorf("Expected no revision, Got: %v, error: %v", revisions, err)
+		}
+	})
+
+	t.Run("should record the subtasks deleted and restored along with a task", func(t *testing.T) {
+		childId, _ := store.CreateTask(owner, domain.Task{Title: "child", Status: "open", ParentId: taskId},
+			domain.TaskRevision{})
+		if _, err := store.DeleteTask(owner, id, 0, 40, true, revision(domain.HistoryDelete, 4)); err != nil {
+			t.Fatalf("Error deleting task: %v", err)
+		}
+		if _, err := store.RestoreTask(owner, id, revision(domain.HistoryRestore, 5)); err != nil {
+			t.Fatalf("Error restoring task: %v", err)
+		}
+
+		revisions, err := store.GetTaskHistory(owner, strconv.FormatInt(childId, 10), -1, -1)
+		var actions []string
+		for _, revision := range revisions {
+			actions = append(actions, revision.Action+" "+strconv.FormatInt(revision.Version, 10))
+		}
+		if expected := []string{"restore 3", "delete 2"}; err != nil || !reflect.DeepEqual(actions, expected) {
+			t.Errorf("Expected: %v, Got: %v, error: %v", expected, actions, err)
+		}
+		if revisions, _ = store.GetTaskHistory(owner, id, -1, -1); len(revisions) != 5 {
+			t.Errorf("Expected the delete and restore of the task, Got: %v", revisions)
+		}
+	})
+
+	t.Run("should roll revisions back with their change", func(t *testing.T) {
+		results, err := store.ApplyBatch(owner, []domain.TaskWrite{
+			{Op: domain.BatchUpdate, Task: domain.Task{Id: taskId, Title: "rolled back", Status: "open"},
+				Revision: revision(domain.HistoryUpdate, 6)},
+			{Op: domain.BatchDelete, Task: domain.Task{Id: -1}, Revision: revision(domain.HistoryDelete, 6)},
+		}, true)
+		if err != nil || results[0].Err != ErrBatchRolledBack {
+			t.Fatalf("Expected the batch to be rolled back, Got: %v, error: %v", results, err)
+		}
+		if revisions, _ := store.GetTaskHistory(owner, id, -1, -1); len(revisions) != 5 {
+			t.Errorf("Expected no revision of the rolled back update, Got: %v", revisions)
+		}
+	})
+
+	t.Run("should purge the history along with the task", func(t *testing.T) {
+		if _, err := store.DeleteTask(owner, id, 0, 50, true, domain.TaskRevision{}); err != nil {
+			t.Fatalf("Error deleting task: %v", err)
+		}
+		if _, _, err := store.PurgeTrash(51); err != nil {
+			t.Fatalf("Error purging trash: %v", err)
+		}
+		revisions, err := store.GetTaskHistory(owner, id, -1, -1)
+		if err != nil || len(revisions) != 0 {
+			t.Errorf("Expected the history to be purged, Got: %v, error: %v", revisions, err)
+		}
+	})
+}
+
+func runCommentContract(t *testing.T, store Repository, owner domain.Identity) {
+	other := domain.Identity{UserId: owner.UserId + 1, Username: "other", TenantId: owner.TenantId}
+	stranger := domain.Identity{UserId: owner.UserId, Username: owner.Username, TenantId: owner.TenantId + "-other"}
+	taskId, err := store.CreateTask(owner, domain.Task{Title: "discussed", Status: "open"}, domain.TaskRevision{})
+	if err != nil {
+		t.Fatalf("Error creating task: %v", err)
+	}
+	id := strconv.FormatInt(taskId, 10)
+	var commentIds []string
+	for i, author := range []domain.Identity{owner, other, owner} {
+		commentId, err := store.CreateComment(author, domain.Comment{TaskId: taskId, Body: "comment " + strconv.Itoa(i),
+			AddedOn: int64(10 - i), UpdatedOn: int64(10 - i)})
+		if err != nil {
+			t.Fatalf("Error creating comment: %v", err)
+		}
+		commentIds = append(commentIds, strconv.FormatInt(commentId, 10))
+	}
+	getBodies := func(comments []domain.Comment) []string {
+		var bodies []string
+		for _, comment := range comments {
+			bodies = append(bodies, comment.GetBody())
+		}
+		return bodies
+	}
+
+	t.Run("should list the comments on a task, the oldest first", func(t *testing.T) {
+		comments, err := store.GetComments(owner, id, 0, 2)
+		if bodies := getBodies(comments); err != nil || !reflect.DeepEqual(bodies, []string{"comment 2", "comment 1"}) {
+			t.Errorf("Expected the first page, Got: %v, error: %v", bodies, err)
+		}
+		if comments[1].GetAuthorId() != other.UserId || comments[1].Author != other.Username {
+			t.Errorf("Expected the author to be kept, Got: %v", comments[1])
+		}
+		comments, err = store.GetComments(owner, id, -1, -1)
+		if err != nil || len(comments) != 3 {
+			t.Errorf("Expected every comment, Got: %v, error: %v", comments, err)
+		}
+		comments, err = store.GetComments(stranger, id, -1, -1)
+		if err != nil || len(comments) != 0 {
+			t.Errorf("Expected no comments for another tenant, Got: %v, error: %v", comments, err)
+		}
+	})
+
+	t.Run("should let only the author change a comment", func(t *testing.T) {
+		updated, err := store.UpdateComment(owner, domain.Comment{TaskId: taskId, Body: "edited", UpdatedOn: 20}, commentIds[1])
+		if err != nil || updated {
+			t.Errorf("Expected the comment of another user to be kept, error: %v", err)
+		}
+		updated, err = store.UpdateComment(other, domain.Comment{TaskId: taskId, Body: "edited", UpdatedOn: 20}, commentIds[1])
+		comments, _ := store.GetCommentById(owner, id, commentIds[1])
+		if err != nil || !updated || len(comments) != 1 || comments[0].GetBody() != "edited" || comments[0].GetUpdatedOn() != 20 {
+			t.Errorf("Expected the comment to be edited, Got: %v, error: %v", comments, err)
+		}
+
+		deleted, err := store.DeleteComment(other, id, commentIds[0])
+		if err != nil || deleted {
+			t.Errorf("Expected the comment of another user to be kept, error: %v", err)
+		}
+		deleted, err = store.DeleteComment(owner, id, commentIds[0])
+		comments, _ = store.GetCommentById(owner, id, commentIds[0])
+		if err != nil || !deleted || len(comments) != 0 {
+			t.Errorf("Expected the comment to be deleted, Got: %v, error: %v", comments, err)
+		}
+	})
+
+	t.Run("should purge the comments along with the task", func(t *testing.T) {
+		if _, err := store.DeleteTask(owner, id, 0, 50, true, domain.TaskRevision{}); err != nil {
+			t.Fatalf("Error deleting task: %v", err)
+		}
+		if _, _, err := store.PurgeTrash(51); err != nil {
+			t.Fatalf("Error purging trash: %v", err)
+		}
+		comments, err := store.GetComments(owner, id, -1, -1)
+		if err != nil || len(comments) != 0 {
+			t.Errorf("Expected the comments to be purged, Got: %v, error: %v", comments, err)
+		}
+	})
+}
+
+func runAttachmentContract(t *testing.T, store Repository, owner domain.Identity) {
+	stranger := domain.Identity{UserId: owner.UserId, Username: owner.Username, TenantId: owner.TenantId + "-other"}
+	taskId, err := store.CreateTask(owner, domain.Task{Title: "attached", Status: "open"}, domain.TaskRevision{})
+	if err != nil {
+		t.Fatalf("Error creating task: %v", err)
+	}
+	id := strconv.FormatInt(taskId, 10)
+	var attachmentIds []string
+	for i, name := range []string{"screenshot.png", "spec.pdf"} {
+		attachmentId, err := store.CreateAttachment(owner, domain.Attachment{TaskId: taskId, Name: name,
+			ContentType: "application/pdf", Size: int64(100 + i), AddedOn: int64(10 - i), StorageKey: id + "/key" + strconv.Itoa(i)})
+		if err != nil {
+			t.Fatalf("Error creating attachment: %v", err)
+		}
+		attachmentIds = append(attachmentIds, strconv.FormatInt(attachmentId, 10))
+	}
+
+	t.Run("should list the attachments of a task, the oldest first", func(t *testing.T) {
+		attachments, err := store.GetAttachments(owner, id)
+		if err != nil || len(attachments) != 2 || attachments[0].GetName() != "spec.pdf" ||
+			attachments[1].GetName() != "screenshot.png" {
+			t.Fatalf("Expected both attachments, Got: %v, error: %v", attachments, err)
+		}
+		expected := domain.Attachment{Id: parseId(attachmentIds[1]), TaskId: taskId, Name: "spec.pdf", ContentType: "application/pdf",
+			Size: 101, UploaderId: owner.UserId, Uploader: owner.Username, AddedOn: 9, StorageKey: id + "/key1", TenantId: owner.TenantId}
+		if !reflect.DeepEqual(attachments[0], expected) {
+			t.Errorf("Expected: %v, Got: %v", expected, attachments[0])
+		}
+
+		attachments, err = store.GetAttachments(stranger, id)
+		if err != nil || len(attachments) != 0 {
+			t.Errorf("Expected no attachments for another tenant, Got: %v, error: %v", attachments, err)
+		}
+		attachments, err = store.GetAttachmentById(owner, id, attachmentIds[0])
+		if err != nil || len(attachments) != 1 || attachments[0].GetStorageKey() != id+"/key0" {
+			t.Errorf("Expected the first attachment, Got: %v, error: %v", attachments, err)
+		}
+	})
+
+	t.Run("should delete attachments", func(t *testing.T) {
+		deleted, err := store.DeleteAttachment(stranger, id, attachmentIds[0])
+		if err != nil || deleted {
+			t.Errorf("Expected the attachment to be kept for another tenant, error: %v", err)
+		}
+		deleted, err = store.DeleteAttachment(owner, id, attachmentIds[0])
+		attachments, _ := store.GetAttachmentById(owner, id, attachmentIds[0])
+		if err != nil || !deleted || len(attachments) != 0 {
+			t.Errorf("Expected the attachment to be deleted, Got: %v, error: %v", attachments, err)
+		}
+	})
+
+	t.Run("should purge the attachments along with the task and report them", func(t *testing.T) {
+		if _, err := store.DeleteTask(owner, id, 0, 50, true, domain.TaskRevision{}); err != nil {
+			t.Fatalf("Error deleting task: %v", err)
+		}
+		_, purged, err := store.PurgeTrash(51)
+		if err != nil || len(purged) != 1 || purged[0].GetStorageKey() != id+"/key1" {
+			t.Errorf("Expected the remaining attachment to be purged, Got: %v, error: %v", purged, err)
+		}
+		attachments, err := store.GetAttachments(owner, id)
+		if err != nil || len(attachments) != 0 {
+			t.Errorf("Expected no attachments, Got: %v, error: %v", attachments, err)
+		}
+	})
+}
+
+func runDependencyContract(t *testing.T, store Repository, owner domain.Identity) {
+	stranger := domain.Identity{UserId: owner.UserId, Username: owner.Username, TenantId: owner.TenantId + "-other"}
+	var ids []string
+	for _, title := range []string{"design", "build", "ship"} {
+		taskId, err := store.CreateTask(owner, domain.Task{Title: title, Status: "open"}, domain.TaskRevision{})
+		if err != nil {
+			t.Fatalf("Error creating task: %v", err)
+		}
+		ids = append(ids, strconv.FormatInt(taskId, 10))
+	}
+	dependency := func(id string, blockerId string) domain.Dependency {
+		return domain.Dependency{TaskId: parseId(id), BlockedById: parseId(blockerId), AddedOn: 1}
+	}
+	isBlocked := func(id string, blocked string) bool {
+		tasks, err := store.SearchTasks(owner, map[string]string{"id": id, "blocked": blocked})
+		if err != nil {
+			t.Fatalf("Error searching tasks: %v", err)
+		}
+		return len(tasks) == 1
+	}
+
+	t.Run("should add dependencies once", func(t *testing.T) {
+		for _, edge := range [][2]string{{ids[1], ids[0]}, {ids[2], ids[1]}} {
+			added, err := store.AddDependency(owner, dependency(edge[0], edge[1]))
+			if err != nil || !added {
+				t.Fatalf("Expected task %s to be blocked by task %s, error: %v", edge[0], edge[1], err)
+			}
+		}
+		added, err := store.AddDependency(owner, dependency(ids[1], ids[0]))
+		if err != nil || added {
+			t.Errorf("Expected the dependency to exist already, error: %v", err)
+		}
+		blockers, err := store.GetBlockers(owner, ids[1])
+		if err != nil || len(blockers) != 1 || blockers[0].GetTitle() != "design" {
+			t.Errorf("Expected task %s to be blocked by design, Got: %v, error: %v", ids[1], blockers, err)
+		}
+		blockers, err = store.GetBlockers(stranger, ids[1])
+		if err != nil || len(blockers) != 0 {
+			t.Errorf("Expected no blockers for another tenant, Got: %v, error: %v", blockers, err)
+		}
+	})
+
+	t.Run("should refuse dependencies closing a cycle", func(t *testing.T) {
+		for _, blockerId := range []string{ids[0], ids[1], ids[2]} {
+			added, err := store.AddDependency(owner, dependency(ids[0], blockerId))
+			if err != ErrDependencyCycle || added {
+				t.Errorf("Expected task %s blocking task %s to be refused, Got: %v", blockerId, ids[0], err)
+			}
+		}
+	})
+
+	t.Run("should only count open blockers", func(t *testing.T) {
+		blocked, err := store.IsBlocked(owner, ids[1])
+		if err != nil || !blocked || !isBlocked(ids[1], "true") || isBlocked(ids[1], "false") {
+			t.Errorf("Expected task %s to be blocked, error: %v", ids[1], err)
+		}
+		if blocked, err = store.IsBlocked(owner, ids[0]); err != nil || blocked || !isBlocked(ids[0], "false") {
+			t.Errorf("Expected task %s not to be blocked, error: %v", ids[0], err)
+		}
+
+		if _, err = store.UpdateTask(owner, domain.Task{Id: parseId(ids[0]), Title: "design", Status: completedStatus}, ids[0], nil, domain.TaskRevision{}); err != nil {
+			t.Fatalf("Error completing task: %v", err)
+		}
+		if blocked, err = store.IsBlocked(owner, ids[1]); err != nil || blocked || isBlocked(ids[1], "true") {
+			t.Errorf("Expected task %s not to be blocked by a done task, error: %v", ids[1], err)
+		}
+		if _, err = store.DeleteTask(owner, ids[1], 0, 50, true, domain.TaskRevision{}); err != nil {
+			t.Fatalf("Error deleting task: %v", err)
+		}
+		if blocked, err = store.IsBlocked(owner, ids[2]); err != nil || blocked {
+			t.Errorf("Expected task %s not to be blocked by a task in the trash, error: %v", ids[2], err)
+		}
+	})
+
+	t.Run("should remove dependencies", func(t *testing.T) {
+		removed, err := store.RemoveDependency(stranger, ids[1], ids[0])
+		if err != nil || removed {
+			t.Errorf("Expected the dependency to be kept for another tenant, error: %v", err)
+		}
+		removed, err = store.RemoveDependency(owner, ids[1], ids[0])
+		if err != nil || !removed {
+			t.Errorf("Expected the dependency to be removed, error: %v", err)
+		}
+		removed, err = store.RemoveDependency(owner, ids[1], ids[0])
+		if err != nil || removed {
+			t.Errorf("Expected the dependency to be gone, error: %v", err)
+		}
+	})
+
+	t.Run("should purge the dependencies along with the task", func(t *testing.T) {
+		if _, _, err := store.PurgeTrash(51); err != nil {
+			t.Fatalf("Error purging trash: %v", err)
+		}
+		// the blocker is gone for good, so linking the tasks the other way round is fine
+		added, err := store.AddDependency(owner, dependency(ids[1], ids[2]))
+		if err != nil || !added {
+			t.Errorf("Expected the purged dependency to be forgotten, error: %v", err)
+		}
+	})
+}
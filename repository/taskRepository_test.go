@@ -3,15 +3,17 @@ package repository
 import (
 	"database/sql"
 	"github.com/DATA-DOG/go-sqlmock"
+	"my-todo-app/domain"
 	"my-todo-app/testUtils"
 	"reflect"
 	"testing"
 )
 
 var (
-	mockDb *sql.DB
-	mock   sqlmock.Sqlmock
-	err    error
+	mockDb         *sql.DB
+	mock           sqlmock.Sqlmock
+	taskRepository TaskRepository
+	err            error
 )
 
 func InitialSetup(t *testing.T) {
@@ -19,7 +21,7 @@ func InitialSetup(t *testing.T) {
 	if err != nil {
 		t.Errorf("Error opening stub database connection: %s", err)
 	}
-	setDb(mockDb)
+	taskRepository = newSqlTaskRepository(mockDb, mysqlDialect)
 }
 
 func TestGetTaskById(t *testing.T) {
@@ -31,7 +33,7 @@ func TestGetTaskById(t *testing.T) {
 			id := scenario.Id
 			testUtils.GetRepositoryMocks(testUtils.GetTaskByIdKey, mock, scenario.ExpectedSQL, id, scenario)
 
-			tasks, err := GetTaskById(id)
+			tasks, err := taskRepository.GetTaskById(testUtils.Caller, id)
 			if err != scenario.ScenarioErr {
 				t.Errorf("Expected error: %s, but got: %s", scenario.ScenarioErr, err)
 			} else if mock.ExpectationsWereMet() != nil {
@@ -52,7 +54,7 @@ func TestGetAllTasks(t *testing.T) {
 		t.Run(scenario.Name, func(t *testing.T) {
 			testUtils.GetRepositoryMocks(testUtils.GetAllTasksKey, mock, scenario.ExpectedSQL, "", scenario)
 
-			tasks, err := GetAllTasks(scenario.Page, scenario.PerPage)
+			tasks, err := taskRepository.GetAllTasks(testUtils.Caller, scenario.Page, scenario.PerPage, nil)
 			if err != scenario.ScenarioErr {
 				t.Errorf("Expected error: %s, but got: %s", scenario.ScenarioErr, err)
 			} else if mock.ExpectationsWereMet() != nil {
@@ -73,7 +75,7 @@ func TestCreateTask(t *testing.T) {
 		t.Run(scenario.Name, func(t *testing.T) {
 			testUtils.GetRepositoryMocks(testUtils.CreateTaskKey, mock, scenario.ExpectedSQL, "", scenario)
 
-			insertId, err := CreateTask(scenario.Task)
+			insertId, err := taskRepository.CreateTask(testUtils.Caller, scenario.Task, domain.TaskRevision{})
 			if err != scenario.ScenarioErr {
 				t.Errorf("Expected error: %s, but got: %s", scenario.ScenarioErr, err)
 			} else if mock.ExpectationsWereMet() != nil {
@@ -94,7 +96,7 @@ func TestUpdateTask(t *testing.T) {
 		t.Run(scenario.Name, func(t *testing.T) {
 			testUtils.GetRepositoryMocks(testUtils.UpdateTaskKey, mock, scenario.ExpectedSQL, scenario.Id, scenario)
 
-			err := UpdateTask(scenario.Task, "8")
+			_, err := taskRepository.UpdateTask(testUtils.Caller, scenario.Task, "8", nil, domain.TaskRevision{})
 			if err != scenario.ScenarioErr {
 				t.Errorf("Expected error: %s, but got: %s", scenario.ScenarioErr, err)
 			} else if mock.ExpectationsWereMet() != nil {
@@ -114,7 +116,7 @@ func TestDeleteTask(t *testing.T) {
 		t.Run(scenario.Name, func(t *testing.T) {
 			testUtils.GetRepositoryMocks(testUtils.DeleteTaskKey, mock, scenario.ExpectedSQL, id, scenario)
 
-			rowsAffected, err := DeleteTask(id)
+			rowsAffected, err := taskRepository.DeleteTask(testUtils.Caller, id, 0, testUtils.DeletedOn, true, domain.TaskRevision{})
 			if err != scenario.ScenarioErr {
 				t.Errorf("Expected error: %s, but got: %s", scenario.ScenarioErr, err)
 			} else if mock.ExpectationsWereMet() != nil {
@@ -135,7 +137,7 @@ func TestSearchTasks(t *testing.T) {
 		t.Run(scenario.Name, func(t *testing.T) {
 			testUtils.GetRepositoryMocks(testUtils.SearchTaskKey, mock, scenario.ExpectedSQL, "", scenario)
 
-			tasks, err := SearchTasks(scenario.SearchParams)
+			tasks, err := taskRepository.SearchTasks(testUtils.Caller, scenario.SearchParams)
 			if err != scenario.ScenarioErr {
 				t.Errorf("Expected error: %s, but got: %s", scenario.ScenarioErr, err)
 			} else if mock.ExpectationsWereMet() != nil {
@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"database/sql"
+	sq "github.com/Masterminds/squirrel"
+	"my-todo-app/domain"
+)
+
+// GetTrash lists the deleted tasks of caller one page at a time, or all of
+// them when page or perPage is -1, the most recently deleted first.
+func (r *sqlTaskRepository) GetTrash(caller domain.Identity, page int64, perPage int64) ([]domain.Task, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := r.statement.Select(taskColumns...).
+		From("tasks").
+		Where(trashedFor(caller)).
+		OrderBy("deletedOn DESC", "id")
+	if page != -1 && perPage != -1 {
+		query = query.Limit(uint64(perPage)).Offset(uint64(page * perPage))
+	}
+
+	tasks, err := r.queryTasks(tx, query)
+	return tasks, err
+}
+
+func (r *sqlTaskRepository) GetTrashedTask(caller domain.Identity, id string) ([]domain.Task, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	tasks, err := r.queryTasks(tx,
+		r.statement.Select(taskColumns...).
+			From("tasks").
+			Where(trashedFor(caller)).
+			Where(sq.Eq{"id": parseId(id)}))
+	return tasks, err
+}
+
+// RestoreTask takes the task with id out of the trash together with the
+// subtasks deleted along with it, reporting whether it was in the trash.
+func (r *sqlTaskRepository) RestoreTask(caller domain.Identity, id string, revision domain.TaskRevision) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	taskId := parseId(id)
+	var deletedOn int64
+	err = r.statement.Select("deletedOn").
+		From("tasks").
+		Where(trashedFor(caller)).
+		Where(sq.Eq{"id": taskId}).
+		RunWith(tx).
+		QueryRow().
+		Scan(&deletedOn)
+	if err == sql.ErrNoRows {
+		err = nil
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// subtasks deleted before their parent stay in the trash
+	ids, err := r.getDescendantIds(tx, taskId, sq.Eq{"deletedOn": deletedOn})
+	ids = append([]int64{taskId}, ids...)
+	var before map[int64]domain.Task
+	if err == nil {
+		before, err = r.getRevisedTasks(tx, revision, ids)
+	}
+	if err == nil {
+		_, err = r.statement.Update("tasks").
+			Set("deletedOn", 0).
+			Set("version", sq.Expr("version + 1")).
+			Where(sq.Eq{"id": ids}).
+			RunWith(tx).
+			Exec()
+	}
+	if err == nil && revision.Action != "" {
+		err = r.addRevisions(tx, caller, revision, ids, before)
+	}
+	return err == nil, err
+}
+
+// purgeChunkSize bounds the tasks PurgeTrash removes in one transaction, and
+// so the locks it holds and the parameters of its queries.
+var purgeChunkSize uint64 = 500
+
+// PurgeTrash removes for good the tasks of every tenant deleted before
+// deletedBefore, with their tags, history, comments, attachments and
+// dependencies, and reports how many tasks it removed and which attachments.
+// Tasks are removed purgeChunkSize at a time, so what a failing chunk leaves
+// behind is reported along with the error.
+func (r *sqlTaskRepository) PurgeTrash(deletedBefore int64) (int64, []domain.Attachment, error) {
+	var purged int64
+	var attachments []domain.Attachment
+	for {
+		ids, removed, err := r.purgeTrashChunk(deletedBefore)
+		purged += int64(len(ids))
+		attachments = append(attachments, removed...)
+		if err != nil || uint64(len(ids)) < purgeChunkSize {
+			return purged, attachments, err
+		}
+	}
+}
+
+// purgeTrashChunk removes up to purgeChunkSize of the tasks PurgeTrash
+// removes, reporting their ids and attachments.
+func (r *sqlTaskRepository) purgeTrashChunk(deletedBefore int64) ([]int64, []domain.Attachment, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	rows, err := r.statement.Select("id").
+		From("tasks").
+		Where(sq.And{sq.Expr("deletedOn > 0"), sq.Lt{"deletedOn": deletedBefore}}).
+		OrderBy("id").
+		Limit(purgeChunkSize).
+		RunWith(tx).
+		Query()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			return nil, nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil || len(ids) == 0 {
+		return nil, nil, err
+	}
+
+	var attachments []domain.Attachment
+	attachments, err = r.queryAttachmentsIn(tx, r.statement.Select(attachmentColumns...).
+		From("attachments").
+		Where(sq.Eq{"taskId": ids}))
+	for _, table := range []string{"task_tags", "task_history", "comments", "attachments"} {
+		if err == nil {
+			_, err = r.statement.Delete(table).
+				Where(sq.Eq{"taskId": ids}).
+				RunWith(tx).
+				Exec()
+		}
+	}
+	if err == nil {
+		_, err = r.statement.Delete("task_dependencies").
+			Where(sq.Or{sq.Eq{"taskId": ids}, sq.Eq{"blockedById": ids}}).
+			RunWith(tx).
+			Exec()
+	}
+	if err == nil {
+		_, err = r.statement.Delete("tasks").
+			Where(sq.Eq{"id": ids}).
+			RunWith(tx).
+			Exec()
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return ids, attachments, nil
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	sq "github.com/Masterminds/squirrel"
+	"my-todo-app/domain"
+)
+
+var userColumns = []string{"id", "username", "passwordHash", "addedOn", "role", "tenantId"}
+
+// CreateUser stores user; the unique index on tenantId and username rejects
+// a username taken in the tenant of user.
+func (r *sqlTaskRepository) CreateUser(user domain.User) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return -1, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	id, err := r.insertReturningId(tx,
+		r.statement.Insert("users").
+			Columns(userColumns[1:]...).
+			Values(user.Username, user.PasswordHash, user.AddedOn, user.Role, user.TenantId))
+	if err != nil {
+		return -1, err
+	}
+	return id, nil
+}
+
+func (r *sqlTaskRepository) GetUserById(id int64) ([]domain.User, error) {
+	return r.queryUsers(sq.Eq{"id": id})
+}
+
+func (r *sqlTaskRepository) GetUserByName(tenantId string, username string) ([]domain.User, error) {
+	return r.queryUsers(sq.Eq{"tenantId": tenantId, "username": username})
+}
+
+// UpdateUserRole gives the user with id a new role, reporting whether the user exists.
+func (r *sqlTaskRepository) UpdateUserRole(id int64, role string) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	result, err := r.statement.Update("users").
+		Set("role", role).
+		Where(sq.Eq{"id": id}).
+		RunWith(tx).
+		Exec()
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected > 0, err
+}
+
+func (r *sqlTaskRepository) queryUsers(filter sq.Sqlizer) ([]domain.User, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			_ = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}()
+
+	rows, err := r.statement.Select(userColumns...).
+		From("users").
+		Where(filter).
+		RunWith(tx).
+		Query()
+
+	users := []domain.User{}
+	for err == nil && rows.Next() {
+		var user domain.User
+		err = rows.Scan(&user.Id, &user.Username, &user.PasswordHash, &user.AddedOn, &user.Role, &user.TenantId)
+		if err == nil {
+			users = append(users, user)
+		}
+	}
+	return users, err
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"my-todo-app/config"
+	"my-todo-app/domain"
+	"my-todo-app/repository"
+)
+
+// runRoleCommand handles "role <username> <role> [tenant]" against the
+// database configured in config.yml, to appoint the first admin of a tenant.
+func runRoleCommand(args []string) {
+	if len(args) != 2 && len(args) != 3 {
+		log.Fatal("Usage: role <username> viewer | editor | admin [tenant]")
+	}
+	tenant := domain.DefaultTenant
+	if len(args) == 3 {
+		tenant = args[2]
+	}
+	if !domain.IsTenantId(tenant) {
+		log.Fatal("Invalid tenant: ", tenant)
+	}
+	if !domain.IsRole(args[1]) {
+		log.Fatal("Unknown role: ", args[1])
+	}
+
+	backend, err := repository.NewRepository(config.SqlDriver, config.DataSourceName)
+	if err != nil {
+		log.Fatal("Error connecting to database with error: ", err)
+	}
+
+	users, err := backend.GetUserByName(tenant, domain.NormalizeUsername(args[0]))
+	if err == nil && len(users) == 0 {
+		log.Fatalf("No user found with name %s in tenant %s", args[0], tenant)
+	}
+	if err == nil {
+		_, err = backend.UpdateUserRole(users[0].GetId(), args[1])
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("User %s is now %s\n", users[0].GetUsername(), args[1])
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"my-todo-app/domain"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	maxApiKeyNameLength = 64
+	// apiKeyTouchInterval limits how often the last use of a key is written,
+	// so busy scripts do not turn every read into a write
+	apiKeyTouchInterval = time.Minute
+)
+
+// CreateApiKeyHandler creates a key for the caller. The response is the only
+// place the key itself is ever shown.
+func CreateApiKeyHandler(c *fiber.Ctx) error {
+	caller := getCaller(c)
+	if caller.IsApiKey() {
+		logger.Info(fmt.Sprintf("Rejected creating API key with API key of user: %s", caller.Username))
+		return c.SendStatus(http.StatusForbidden)
+	}
+
+	var request domain.ApiKey
+	err := json.Unmarshal(c.Body(), &request)
+	if err == nil {
+		err = validateApiKey(request)
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("Invalid API key: %s", err))
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	key, err := domain.NewApiKey(caller.UserId, strings.TrimSpace(request.Name), request.Scope, toMillis(time.Now()))
+	if err == nil {
+		key.Id, err = apiKeyRepository.CreateApiKey(caller, key)
+	}
+	if err == nil {
+		logger.Info(fmt.Sprintf("Created API key %s for user: %s", key.Prefix, caller.Username))
+		return c.Status(http.StatusCreated).JSON(key)
+	}
+
+	logger.Error(fmt.Sprintf("Error creating API key for user %s: %s", caller.Username, err))
+	return c.SendStatus(http.StatusInternalServerError)
+}
+
+// GetApiKeysHandler lists the keys of the caller, without the keys themselves.
+func GetApiKeysHandler(c *fiber.Ctx) error {
+	caller := getCaller(c)
+	keys, err := apiKeyRepository.GetApiKeys(caller)
+	if err == nil {
+		return c.JSON(keys)
+	}
+
+	logger.Error(fmt.Sprintf("Error fetching API keys of user %s: %s", caller.Username, err))
+	return c.SendStatus(http.StatusInternalServerError)
+}
+
+// DeleteApiKeyHandler revokes a key of the caller.
+func DeleteApiKeyHandler(c *fiber.Ctx) error {
+	caller := getCaller(c)
+	if caller.IsApiKey() {
+		logger.Info(fmt.Sprintf("Rejected revoking API key with API key of user: %s", caller.Username))
+		return c.SendStatus(http.StatusForbidden)
+	}
+
+	id := c.Params("id")
+	deleted, err := apiKeyRepository.DeleteApiKey(caller, id)
+	if err == nil && !deleted {
+		logger.Info(fmt.Sprintf("No API key found with id: %s", id))
+		return c.SendStatus(http.StatusNotFound)
+	}
+	if err == nil {
+		logger.Info(fmt.Sprintf("Revoked API key with id: %s", id))
+		return c.SendStatus(http.StatusNoContent)
+	}
+
+	logger.Error(fmt.Sprintf("Error revoking API key with id %s: %s", id, err))
+	return c.SendStatus(http.StatusInternalServerError)
+}
+
+// authenticateApiKey looks up the user owning key and limits the identity to
+// the scope of the key. Keys are unique across tenants, so tenant is not needed.
+func authenticateApiKey(_ string, key string) (domain.Identity, bool, error) {
+	keys, err := apiKeyRepository.GetApiKeyByHash(domain.HashApiKey(key))
+	if err != nil || len(keys) == 0 {
+		return domain.Identity{}, false, err
+	}
+
+	users, err := userRepository.GetUserById(keys[0].UserId)
+	if err != nil || len(users) == 0 {
+		logger.Info(fmt.Sprintf("No user found for API key: %s", keys[0].Prefix))
+		return domain.Identity{}, false, err
+	}
+
+	now := toMillis(time.Now())
+	if now-keys[0].LastUsedOn >= apiKeyTouchInterval.Milliseconds() {
+		if err := apiKeyRepository.TouchApiKey(keys[0].Id, now); err != nil {
+			logger.Error(fmt.Sprintf("Error recording use of API key %s: %s", keys[0].Prefix, err))
+		}
+	}
+
+	caller := users[0].Identity()
+	caller.Scope = keys[0].Scope
+	return caller, true, nil
+}
+
+func validateApiKey(key domain.ApiKey) error {
+	name := strings.TrimSpace(key.Name)
+	if name == "" || len(name) > maxApiKeyNameLength {
+		return fmt.Errorf("name must be 1 to %d characters", maxApiKeyNameLength)
+	}
+	if !domain.IsScope(key.Scope) {
+		return errors.New("scope must be read-only or read-write")
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"my-todo-app/domain"
+	"my-todo-app/repository"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestApiKeys(t *testing.T) {
+	store, _ := repository.NewRepository(repository.MemoryDriver, "")
+	SetRepository(store)
+	user, _ := domain.NewUser("alice", "correct horse", 0)
+	user.Id, _ = store.CreateUser(user)
+
+	app := fiber.New()
+	app.Use(AuthMiddleware)
+	app.Post("/users/me/apikeys", CreateApiKeyHandler)
+	app.Get("/users/me/apikeys", GetApiKeysHandler)
+	app.Delete("/users/me/apikeys/:id", DeleteApiKeyHandler)
+	app.Get("/tasks", GetAllTasksHandler)
+	app.Post("/task", CreateTaskHandler)
+
+	request := func(method string, url string, body string, authorization string) *http.Response {
+		req := httptest.NewRequest(method, "http://localhost.com"+url, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", authorization)
+		response, _ := app.Test(req, -1)
+		return response
+	}
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte("alice:correct horse"))
+	createKey := func(scope string) domain.ApiKey {
+		response := request("POST", "/users/me/apikeys", `{"name": "ci", "scope": "`+scope+`"}`, basic)
+		var key domain.ApiKey
+		_ = json.NewDecoder(response.Body).Decode(&key)
+		if response.StatusCode != http.StatusCreated || !strings.HasPrefix(key.Key, key.Prefix) || key.Scope != scope {
+			t.Fatalf("Expected %s key to be created, Got: %d with %v", scope, response.StatusCode, key)
+		}
+		return key
+	}
+	readOnly := createKey(domain.ScopeReadOnly)
+	readWrite := createKey(domain.ScopeReadWrite)
+
+	t.Run("should reject invalid API keys", func(t *testing.T) {
+		for _, body := range []string{`{"name": " ", "scope": "read-only"}`, `{"name": "ci", "scope": "admin"}`, `{"name"`} {
+			response := request("POST", "/users/me/apikeys", body, basic)
+			compareResponses(t, http.StatusBadRequest, nil, response)
+		}
+	})
+
+	t.Run("should list API keys without the keys", func(t *testing.T) {
+		response := request("GET", "/users/me/apikeys", "", basic)
+		body := getStringFromResponseBody(response.Body)
+		if response.StatusCode != http.StatusOK || !strings.Contains(body, readOnly.Prefix) ||
+			strings.Contains(body, readOnly.Key) || strings.Contains(body, domain.HashApiKey(readOnly.Key)) {
+			t.Errorf("Expected keys without secrets, Got: %d with %s", response.StatusCode, body)
+		}
+	})
+
+	t.Run("should enforce scopes", func(t *testing.T) {
+		response := request("GET", "/tasks", "", "ApiKey "+readOnly.Key)
+		compareResponses(t, http.StatusOK, []domain.Task{}, response)
+
+		response = request("POST", "/task", `{"title": "from ci"}`, "ApiKey "+readOnly.Key)
+		compareResponses(t, http.StatusForbidden, nil, response)
+
+		response = request("POST", "/task", `{"title": "from ci"}`, "ApiKey "+readWrite.Key)
+		compareResponses(t, http.StatusOK, domain.Task{Id: 1, Title: "from ci", Status: "open", Priority: "P2", OwnerId: user.GetId()}, response)
+
+		response = request("POST", "/users/me/apikeys", `{"name": "ci", "scope": "read-write"}`, "ApiKey "+readWrite.Key)
+		compareResponses(t, http.StatusForbidden, nil, response)
+	})
+
+	t.Run("should record last use", func(t *testing.T) {
+		keys, _ := store.GetApiKeyByHash(domain.HashApiKey(readOnly.Key))
+		if len(keys) != 1 || keys[0].LastUsedOn == 0 {
+			t.Errorf("Expected last use to be recorded, Got: %v", keys)
+		}
+	})
+
+	t.Run("should revoke API keys", func(t *testing.T) {
+		url := "/users/me/apikeys/" + strconv.FormatInt(readOnly.Id, 10)
+		compareResponses(t, http.StatusNoContent, nil, request("DELETE", url, "", basic))
+		compareResponses(t, http.StatusNotFound, nil, request("DELETE", url, "", basic))
+		compareResponses(t, http.StatusUnauthorized, nil, request("GET", "/tasks", "", "ApiKey "+readOnly.Key))
+		compareResponses(t, http.StatusUnauthorized, nil, request("GET", "/tasks", "", "ApiKey todo_guess"))
+	})
+}
@@ -0,0 +1,273 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+	"io"
+	"my-todo-app/config"
+	"my-todo-app/domain"
+	"my-todo-app/repository"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	attachmentField         = "file"
+	maxAttachmentNameLength = 255
+	// sniffLength is how much of a file http.DetectContentType looks at
+	sniffLength = 512
+	// multipartOverhead is the room left for the multipart framing of an upload
+	multipartOverhead = 64 * 1024
+)
+
+var attachmentUploadPath = regexp.MustCompile(`^/task/[^/]+/attachments/?$`)
+
+var (
+	blobStore         repository.BlobStore
+	maxAttachmentSize int64
+	attachmentTypes   []string
+)
+
+func init() {
+	maxAttachmentSize = config.AttachmentMaxSize
+	attachmentTypes = config.AttachmentTypes
+}
+
+// SetBlobStore wires the store keeping the content of attachments.
+func SetBlobStore(store repository.BlobStore) {
+	blobStore = store
+}
+
+// AttachmentBodyLimit is the HeaderReceived hook of the server that lets
+// attachment uploads take bodies of up to app.attachments.maxSize, with room
+// for the multipart framing. Every other request keeps the default body limit
+// of fiber.
+func AttachmentBodyLimit(header *fasthttp.RequestHeader) fasthttp.RequestConfig {
+	uri := fasthttp.AcquireURI()
+	defer fasthttp.ReleaseURI(uri)
+	if err := uri.Parse(header.Host(), header.RequestURI()); err != nil {
+		return fasthttp.RequestConfig{}
+	}
+	limit := maxAttachmentSize + multipartOverhead
+	if string(header.Method()) != fiber.MethodPost || !attachmentUploadPath.Match(uri.Path()) || limit <= fiber.DefaultBodyLimit {
+		return fasthttp.RequestConfig{}
+	}
+	return fasthttp.RequestConfig{MaxRequestBodySize: int(limit)}
+}
+
+// UploadAttachmentHandler stores the file sent in the "file" field of a
+// multipart form and attaches it to a task. The type of the file is detected
+// from its content, whatever name or type the client gave it.
+func UploadAttachmentHandler(c *fiber.Ctx) error {
+	taskId := c.Params("id")
+	caller := getCaller(c)
+	if status := checkVisibleTask(caller, taskId); status != http.StatusOK {
+		return c.SendStatus(status)
+	}
+
+	header, err := c.FormFile(attachmentField)
+	if err != nil {
+		logger.Error(fmt.Sprintf("No %q file to attach to task with id=%s: %s", attachmentField, taskId, err))
+		return c.SendStatus(http.StatusBadRequest)
+	}
+	if header.Size > maxAttachmentSize {
+		logger.Info(fmt.Sprintf("Refusing attachment of %d bytes to task with id=%s", header.Size, taskId))
+		return c.Status(http.StatusRequestEntityTooLarge).
+			JSON(fiber.Map{"error": fmt.Sprintf("attachments take at most %d bytes", maxAttachmentSize)})
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error reading attachment of task with id=%s: %s", taskId, err))
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+	defer func() { _ = file.Close() }()
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		logger.Error(fmt.Sprintf("Error reading attachment of task with id=%s: %s", taskId, err))
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+	contentType := http.DetectContentType(head[:n])
+	if !domain.IsAllowedType(contentType, attachmentTypes) {
+		logger.Info(fmt.Sprintf("Refusing attachment of type %s to task with id=%s", contentType, taskId))
+		return c.Status(http.StatusUnsupportedMediaType).
+			JSON(fiber.Map{"error": fmt.Sprintf("%s is not one of the accepted types %v", contentType, attachmentTypes)})
+	}
+
+	attachment := domain.Attachment{
+		Name:        getAttachmentName(header.Filename),
+		ContentType: contentType,
+		UploaderId:  caller.UserId,
+		Uploader:    caller.Username,
+		AddedOn:     toMillis(time.Now()),
+	}
+	attachment.TaskId, _ = strconv.ParseInt(taskId, 10, 64)
+	attachment.StorageKey, err = newStorageKey(attachment.GetTaskId())
+	if err == nil {
+		attachment.Size, err = blobStore.Put(attachment.GetStorageKey(), io.MultiReader(bytes.NewReader(head[:n]), file))
+	}
+	if err == nil {
+		attachment.Id, err = attachmentRepository.CreateAttachment(caller, attachment)
+		if err != nil {
+			deleteBlob(attachment)
+		}
+	}
+	if err == nil {
+		logger.Info(fmt.Sprintf("Attached %s with id: %d to task with id: %s", attachment.GetName(), attachment.GetId(), taskId))
+		return c.Status(http.StatusCreated).JSON(attachment)
+	}
+
+	logger.Error(fmt.Sprintf("Error attaching file to task with id=%s: %s", taskId, err))
+	return c.SendStatus(http.StatusInternalServerError)
+}
+
+func GetAttachmentsHandler(c *fiber.Ctx) error {
+	taskId := c.Params("id")
+	caller := getCaller(c)
+	if status := checkVisibleTask(caller, taskId); status != http.StatusOK {
+		return c.SendStatus(status)
+	}
+
+	attachments, err := attachmentRepository.GetAttachments(caller, taskId)
+	if err == nil {
+		logger.Info(fmt.Sprintf("No. of attachments fetched for task with id=%s: %d", taskId, len(attachments)))
+		return c.JSON(attachments)
+	}
+
+	logger.Error(fmt.Sprintf("Error fetching attachments of task with id=%s: %s", taskId, err))
+	return c.SendStatus(http.StatusInternalServerError)
+}
+
+// DownloadAttachmentHandler sends the content of an attachment. A Range
+// header asking for a single range of bytes is answered with only those,
+// any other range with the whole content.
+func DownloadAttachmentHandler(c *fiber.Ctx) error {
+	taskId, id := c.Params("id"), c.Params("attachmentId")
+	attachment, status := getAttachment(getCaller(c), taskId, id)
+	if status != http.StatusOK {
+		return c.SendStatus(status)
+	}
+
+	blob, err := blobStore.Open(attachment.GetStorageKey())
+	if err == repository.ErrBlobNotFound {
+		logger.Error(fmt.Sprintf("Content of attachment with id=%s is missing from the blob store", id))
+		return c.SendStatus(http.StatusNotFound)
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error opening attachment with id=%s: %s", id, err))
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	size := attachment.GetSize()
+	c.Attachment(attachment.GetName())
+	c.Set(fiber.HeaderContentType, attachment.GetContentType())
+	c.Set(fiber.HeaderAcceptRanges, "bytes")
+	start, end := int64(0), size-1
+	if c.Get(fiber.HeaderRange) != "" {
+		ranges, err := c.Range(int(size))
+		if err == fiber.ErrRangeUnsatisfiable {
+			_ = blob.Close()
+			c.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes */%d", size))
+			return c.SendStatus(http.StatusRequestedRangeNotSatisfiable)
+		}
+		if err == nil && ranges.Type == "bytes" && len(ranges.Ranges) == 1 {
+			start, end = int64(ranges.Ranges[0].Start), int64(ranges.Ranges[0].End)
+			c.Status(http.StatusPartialContent)
+			c.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+		}
+	}
+
+	if _, err = blob.Seek(start, io.SeekStart); err != nil {
+		_ = blob.Close()
+		logger.Error(fmt.Sprintf("Error reading attachment with id=%s: %s", id, err))
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+	// the stream is closed once sent, since it is an io.Closer
+	stream := struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(blob, end-start+1), blob}
+	return c.SendStream(stream, int(end-start+1))
+}
+
+// DeleteAttachmentHandler removes an attachment together with its content.
+func DeleteAttachmentHandler(c *fiber.Ctx) error {
+	taskId, id := c.Params("id"), c.Params("attachmentId")
+	caller := getCaller(c)
+	attachment, status := getAttachment(caller, taskId, id)
+	if status != http.StatusOK {
+		return c.SendStatus(status)
+	}
+
+	deleted, err := attachmentRepository.DeleteAttachment(caller, taskId, id)
+	if err == nil && deleted {
+		deleteBlob(attachment)
+		logger.Info(fmt.Sprintf("Deleted attachment with id: %s", id))
+		return c.SendStatus(http.StatusNoContent)
+	}
+	if err == nil {
+		logger.Info(fmt.Sprintf("No attachment found with id: %s for deletion", id))
+		return c.SendStatus(http.StatusNotFound)
+	}
+
+	logger.Error(fmt.Sprintf("Error deleting attachment with id=%s: %s", id, err))
+	return c.SendStatus(http.StatusInternalServerError)
+}
+
+// getAttachment looks up the attachment with id of the task with taskId and
+// answers with http.StatusOK unless it is found on a task caller sees.
+func getAttachment(caller domain.Identity, taskId string, id string) (domain.Attachment, int) {
+	if status := checkVisibleTask(caller, taskId); status != http.StatusOK {
+		return domain.Attachment{}, status
+	}
+
+	attachments, err := attachmentRepository.GetAttachmentById(caller, taskId, id)
+	switch {
+	case err != nil:
+		logger.Error(fmt.Sprintf("Error fetching attachment with id=%s: %s", id, err))
+		return domain.Attachment{}, http.StatusInternalServerError
+	case len(attachments) == 0:
+		logger.Info(fmt.Sprintf("No attachment found with id: %s on task with id: %s", id, taskId))
+		return domain.Attachment{}, http.StatusNotFound
+	}
+	return attachments[0], http.StatusOK
+}
+
+// deleteBlob removes the content of an attachment that is no longer known.
+// A failure leaves an unreachable blob behind, so it is only logged.
+func deleteBlob(attachment domain.Attachment) {
+	if err := blobStore.Delete(attachment.GetStorageKey()); err != nil {
+		logger.Error(fmt.Sprintf("Error deleting content of attachment with id=%d: %s", attachment.GetId(), err))
+	}
+}
+
+// newStorageKey picks a key no other blob uses, grouping the blobs of a task.
+func newStorageKey(taskId int64) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d/%s", taskId, hex.EncodeToString(random)), nil
+}
+
+// getAttachmentName keeps the base name of an uploaded file, without any
+// directory the client sent along.
+func getAttachmentName(filename string) string {
+	name := strings.TrimSpace(filepath.Base(strings.ReplaceAll(filename, `\`, "/")))
+	if name == "" || name == "." || name == "/" {
+		return "attachment"
+	}
+	if len(name) > maxAttachmentNameLength {
+		name = name[len(name)-maxAttachmentNameLength:]
+	}
+	return name
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+	"io/ioutil"
+	"mime/multipart"
+	"my-todo-app/domain"
+	"my-todo-app/repository"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAttachments(t *testing.T) {
+	store, _ := repository.NewRepository(repository.MemoryDriver, "")
+	SetRepository(store)
+	blobs, _ := repository.NewBlobStore(repository.LocalBlobStore, t.TempDir())
+	SetBlobStore(blobs)
+	defer func(size int64, types []string) { maxAttachmentSize, attachmentTypes = size, types }(maxAttachmentSize, attachmentTypes)
+	maxAttachmentSize, attachmentTypes = 1024, []string{"image/png", "application/pdf"}
+	defer func(retention time.Duration) { trashRetention = retention }(trashRetention)
+
+	app := fiber.New()
+	app.Post("/task", CreateTaskHandler)
+	app.Get("/task/:id", GetTaskByIdHandler)
+	app.Delete("/task/:id", DeleteTaskByIdHandler)
+	app.Get("/task/:id/attachments", GetAttachmentsHandler)
+	app.Post("/task/:id/attachments", UploadAttachmentHandler)
+	app.Get("/task/:id/attachments/:attachmentId", DownloadAttachmentHandler)
+	app.Delete("/task/:id/attachments/:attachmentId", DeleteAttachmentHandler)
+
+	request := func(method string, url string, headers map[string]string) *http.Response {
+		req := httptest.NewRequest(method, "http://localhost.com"+url, nil)
+		req.Header.Set(fiber.HeaderIfMatch, "*")
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		response, _ := app.Test(req)
+		return response
+	}
+	upload := func(url string, filename string, content []byte) *http.Response {
+		body := &bytes.Buffer{}
+		form := multipart.NewWriter(body)
+		part, _ := form.CreateFormFile("file", filename)
+		_, _ = part.Write(content)
+		_ = form.Close()
+		req := httptest.NewRequest("POST", "http://localhost.com"+url, body)
+		req.Header.Set(fiber.HeaderContentType, form.FormDataContentType())
+		response, _ := app.Test(req)
+		return response
+	}
+	pdf := []byte("%PDF-1.4\n" + string(bytes.Repeat([]byte("0123456789"), 20)))
+
+	req := httptest.NewRequest("POST", "http://localhost.com/task", bytes.NewBufferString(`{"title": "with files"}`))
+	_, _ = app.Test(req)
+
+	t.Run("should attach files of accepted types and sizes", func(t *testing.T) {
+		response := upload("/task/1/attachments", `C:\Users\me\spec.pdf`, pdf)
+		var attachment domain.Attachment
+		_ = json.NewDecoder(response.Body).Decode(&attachment)
+		if response.StatusCode != http.StatusCreated || attachment.GetId() != 1 || attachment.GetName() != "spec.pdf" ||
+			attachment.GetContentType() != "application/pdf" || attachment.GetSize() != int64(len(pdf)) {
+			t.Errorf("Expected the pdf to be attached, Got: %d with %v", response.StatusCode, attachment)
+		}
+
+		compareResponses(t, http.StatusUnsupportedMediaType, nil, upload("/task/1/attachments", "fake.pdf", []byte("plain text")))
+		compareResponses(t, http.StatusRequestEntityTooLarge, nil,
+			upload("/task/1/attachments", "big.pdf", append(pdf, make([]byte, 1024)...)))
+		compareResponses(t, http.StatusNotFound, nil, upload("/task/9/attachments", "spec.pdf", pdf))
+		compareResponses(t, http.StatusBadRequest, nil, request("POST", "/task/1/attachments", nil))
+	})
+
+	t.Run("should list attachments with the task", func(t *testing.T) {
+		var task domain.Task
+		_ = json.NewDecoder(request("GET", "/task/1", nil).Body).Decode(&task)
+		if len(task.GetAttachments()) != 1 || task.GetAttachments()[0].GetName() != "spec.pdf" {
+			t.Errorf("Expected the attachment in the task, Got: %v", task)
+		}
+		var attachments []domain.Attachment
+		_ = json.NewDecoder(request("GET", "/task/1/attachments", nil).Body).Decode(&attachments)
+		if len(attachments) != 1 || attachments[0].GetId() != 1 {
+			t.Errorf("Expected the attachment to be listed, Got: %v", attachments)
+		}
+	})
+
+	t.Run("should download whole attachments and ranges of them", func(t *testing.T) {
+		response := request("GET", "/task/1/attachments/1", nil)
+		content, _ := ioutil.ReadAll(response.Body)
+		if response.StatusCode != http.StatusOK || !bytes.Equal(content, pdf) ||
+			response.Header.Get(fiber.HeaderContentType) != "application/pdf" ||
+			response.Header.Get(fiber.HeaderContentDisposition) != `attachment; filename="spec.pdf"` {
+			t.Errorf("Expected the whole pdf, Got: %d with %v", response.StatusCode, response.Header)
+		}
+
+		for _, expected := range []struct{ header, content, contentRange string }{
+			{"bytes=0-3", "%PDF", "bytes 0-3/209"},
+			{"bytes=-4", "6789", "bytes 205-208/209"},
+			{"bytes=206-", "789", "bytes 206-208/209"},
+		} {
+			response = request("GET", "/task/1/attachments/1", map[string]string{fiber.HeaderRange: expected.header})
+			content, _ = ioutil.ReadAll(response.Body)
+			if response.StatusCode != http.StatusPartialContent || string(content) != expected.content ||
+				response.Header.Get(fiber.HeaderContentRange) != expected.contentRange {
+				t.Errorf("Expected %q in %s for %s, Got: %d with %q in %s", expected.content, expected.contentRange,
+					expected.header, response.StatusCode, content, response.Header.Get(fiber.HeaderContentRange))
+			}
+		}
+		response = request("GET", "/task/1/attachments/1", map[string]string{fiber.HeaderRange: "bytes=0-1,4-5"})
+		if content, _ = ioutil.ReadAll(response.Body); response.StatusCode != http.StatusOK || !bytes.Equal(content, pdf) {
+			t.Errorf("Expected the whole pdf for several ranges, Got: %d", response.StatusCode)
+		}
+
+		response = request("GET", "/task/1/attachments/1", map[string]string{fiber.HeaderRange: "bytes=500-600"})
+		if response.StatusCode != http.StatusRequestedRangeNotSatisfiable ||
+			response.Header.Get(fiber.HeaderContentRange) != "bytes */209" {
+			t.Errorf("Expected 416, Got: %d with %v", response.StatusCode, response.Header)
+		}
+		compareResponses(t, http.StatusNotFound, nil, request("GET", "/task/1/attachments/9", nil))
+	})
+
+	t.Run("should delete attachments with their content", func(t *testing.T) {
+		upload("/task/1/attachments", "other.pdf", pdf)
+		attachments, _ := store.GetAttachmentById(domain.Identity{}, "1", "1")
+		compareResponses(t, http.StatusNoContent, nil, request("DELETE", "/task/1/attachments/1", nil))
+		compareResponses(t, http.StatusNotFound, nil, request("GET", "/task/1/attachments/1", nil))
+		if _, err := blobs.Open(attachments[0].GetStorageKey()); err != repository.ErrBlobNotFound {
+			t.Errorf("Expected the content to be deleted, Got: %v", err)
+		}
+
+		attachments, _ = store.GetAttachmentById(domain.Identity{}, "1", "2")
+		compareResponses(t, http.StatusNoContent, nil, request("DELETE", "/task/1", nil))
+		compareResponses(t, http.StatusNotFound, nil, request("GET", "/task/1/attachments/2", nil))
+		trashRetention = time.Millisecond
+		time.Sleep(5 * time.Millisecond)
+		PurgeTrash()
+		if _, err := blobs.Open(attachments[0].GetStorageKey()); err != repository.ErrBlobNotFound {
+			t.Errorf("Expected the content to be purged with the task, Got: %v", err)
+		}
+	})
+}
+
+func TestAttachmentBodyLimit(t *testing.T) {
+	store, _ := repository.NewRepository(repository.MemoryDriver, "")
+	SetRepository(store)
+	blobs, _ := repository.NewBlobStore(repository.LocalBlobStore, t.TempDir())
+	SetBlobStore(blobs)
+	defer func(size int64, types []string) { maxAttachmentSize, attachmentTypes = size, types }(maxAttachmentSize, attachmentTypes)
+	maxAttachmentSize, attachmentTypes = 2*fiber.DefaultBodyLimit, []string{"application/pdf"}
+
+	app := fiber.New()
+	app.Server().HeaderReceived = AttachmentBodyLimit
+	app.Post("/task", CreateTaskHandler)
+	app.Post("/task/:id/attachments", UploadAttachmentHandler)
+	_, _ = app.Test(httptest.NewRequest("POST", "http://localhost.com/task", bytes.NewBufferString(`{"title": "with files"}`)))
+	large := append([]byte("%PDF-1.4\n"), make([]byte, fiber.DefaultBodyLimit)...)
+
+	t.Run("should take uploads larger than the default body limit", func(t *testing.T) {
+		body := &bytes.Buffer{}
+		form := multipart.NewWriter(body)
+		part, _ := form.CreateFormFile("file", "large.pdf")
+		_, _ = part.Write(large)
+		_ = form.Close()
+		req := httptest.NewRequest("POST", "http://localhost.com/task/1/attachments", body)
+		req.Header.Set(fiber.HeaderContentType, form.FormDataContentType())
+		response, _ := app.Test(req, -1)
+		compareResponses(t, http.StatusCreated, nil, response)
+	})
+
+	t.Run("should keep the default body limit on other routes", func(t *testing.T) {
+		body := `{"title": "` + string(bytes.Repeat([]byte("a"), len(large))) + `"}`
+		_, err := app.Test(httptest.NewRequest("POST", "http://localhost.com/task", bytes.NewBufferString(body)), -1)
+		if err != fasthttp.ErrBodyTooLarge {
+			t.Errorf("Expected error: %v, Got: %v", fasthttp.ErrBodyTooLarge, err)
+		}
+	})
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"my-todo-app/config"
+	"my-todo-app/domain"
+	"net/http"
+	"strings"
+)
+
+const callerKey = "caller"
+
+var (
+	publicRoutes []string
+	defaultRole  string
+	jwtKeys      domain.JwtKeys
+)
+
+func init() {
+	publicRoutes = config.PublicRoutes
+	defaultRole = config.DefaultRole
+	jwtKeys = config.JwtKeys
+}
+
+// authenticators resolve the credentials of an Authorization header to the
+// identity of a registered user of tenant, keyed by lowercase scheme. found is
+// false for credentials that are malformed or do not check out.
+var authenticators = map[string]func(tenant string, credentials string) (caller domain.Identity, found bool, err error){
+	"basic":  authenticateBasic,
+	"bearer": authenticateBearer,
+	"apikey": authenticateApiKey,
+}
+
+// AuthMiddleware authenticates every request with HTTP basic credentials, a
+// JWT bearer token or an API key and keeps the caller on the context for the
+// handlers, answering 401 when they are missing or wrong outside the public
+// routes, 403 when a read-only key is used to change data and 403 when the
+// request asks for another tenant than the one of the caller.
+func AuthMiddleware(c *fiber.Ctx) error {
+	tenant, err := resolveTenant(c)
+	if err != nil {
+		logger.Info(fmt.Sprintf("Rejected %s %s: %s", c.Method(), c.Path(), err))
+		return c.SendStatus(http.StatusBadRequest)
+	}
+	c.Locals(tenantKey, tenant)
+
+	if isPublicRoute(c.Method(), c.Path()) {
+		return c.Next()
+	}
+
+	caller, found, err := authenticate(c, tenant)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error authenticating %s %s: %s", c.Method(), c.Path(), err))
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+	if !found {
+		return sendUnauthorized(c)
+	}
+	if tenant != "" && tenant != caller.TenantId {
+		logger.Warn(fmt.Sprintf("Denied %s %s in tenant %s to user %s of tenant %s",
+			c.Method(), c.Path(), tenant, caller.Username, caller.TenantId))
+		return c.SendStatus(http.StatusForbidden)
+	}
+	if !caller.CanWrite() && !isReadOnlyMethod(c.Method()) {
+		logger.Info(fmt.Sprintf("Rejected %s %s with read-only API key of user: %s", c.Method(), c.Path(), caller.Username))
+		return c.SendStatus(http.StatusForbidden)
+	}
+
+	c.Locals(callerKey, caller)
+	return c.Next()
+}
+
+// getCaller returns the identity AuthMiddleware authenticated, or the zero
+// identity when the middleware is not installed.
+func getCaller(c *fiber.Ctx) domain.Identity {
+	caller, _ := c.Locals(callerKey).(domain.Identity)
+	return caller
+}
+
+// authenticate resolves the Authorization header of a request asking for
+// tenant, where usernames are looked up; requests naming no tenant ask for
+// the default one.
+func authenticate(c *fiber.Ctx, tenant string) (domain.Identity, bool, error) {
+	scheme, credentials := splitAuthorization(c.Get(fiber.HeaderAuthorization))
+	authenticator, found := authenticators[strings.ToLower(scheme)]
+	if !found {
+		return domain.Identity{}, false, nil
+	}
+	if tenant == "" {
+		tenant = domain.DefaultTenant
+	}
+	return authenticator(tenant, credentials)
+}
+
+func authenticateBasic(tenant string, credentials string) (domain.Identity, bool, error) {
+	decoded, err := base64.StdEncoding.DecodeString(credentials)
+	if err != nil {
+		return domain.Identity{}, false, nil
+	}
+	parts := strings.SplitN(string(decoded), ":", 2)
+	if len(parts) != 2 {
+		return domain.Identity{}, false, nil
+	}
+
+	users, err := userRepository.GetUserByName(tenant, domain.NormalizeUsername(parts[0]))
+	if err != nil || len(users) == 0 {
+		return domain.Identity{}, false, err
+	}
+	if !users[0].CheckPassword(parts[1]) {
+		logger.Info(fmt.Sprintf("Rejected password of user: %s", users[0].GetUsername()))
+		return domain.Identity{}, false, nil
+	}
+	return users[0].Identity(), true, nil
+}
+
+// authenticateBearer verifies a JWT against the configured keys; its subject
+// is the name of a registered user of the tenant in its tenant claim, or of
+// tenant when it has none.
+func authenticateBearer(tenant string, token string) (domain.Identity, bool, error) {
+	if jwtKeys.IsEmpty() {
+		logger.Info("Rejected bearer token, no JWT keys are configured")
+		return domain.Identity{}, false, nil
+	}
+
+	subject, claimed, err := jwtKeys.Verify(token)
+	if err != nil {
+		logger.Info(fmt.Sprintf("Rejected bearer token: %s", err))
+		return domain.Identity{}, false, nil
+	}
+	if claimed != "" {
+		tenant = claimed
+	}
+
+	users, err := userRepository.GetUserByName(tenant, domain.NormalizeUsername(subject))
+	if err != nil || len(users) == 0 {
+		logger.Info(fmt.Sprintf("No user found in tenant %s for token subject: %s", tenant, subject))
+		return domain.Identity{}, false, err
+	}
+	return users[0].Identity(), true, nil
+}
+
+func isReadOnlyMethod(method string) bool {
+	return method == fiber.MethodGet || method == fiber.MethodHead || method == fiber.MethodOptions
+}
+
+func splitAuthorization(header string) (string, string) {
+	parts := strings.SplitN(strings.TrimSpace(header), " ", 2)
+	if len(parts) != 2 {
+		return "", ""
+	}
+	return parts[0], strings.TrimSpace(parts[1])
+}
+
+// isPublicRoute matches a request against the configured public routes,
+// written as "METHOD /path". A * method matches any method, a :name segment
+// any single segment and a trailing * segment the rest of the path.
+func isPublicRoute(method string, path string) bool {
+	for _, route := range publicRoutes {
+		parts := strings.Fields(route)
+		if len(parts) == 2 && (parts[0] == "*" || strings.EqualFold(parts[0], method)) && matchPath(parts[1], path) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPath(pattern string, path string) bool {
+	patternSegments := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range patternSegments {
+		if segment == "*" && i == len(patternSegments)-1 {
+			return true
+		}
+		if i >= len(pathSegments) ||
+			(segment != pathSegments[i] && !(strings.HasPrefix(segment, ":") && pathSegments[i] != "")) {
+			return false
+		}
+	}
+	return len(patternSegments) == len(pathSegments)
+}
+
+func sendUnauthorized(c *fiber.Ctx) error {
+	c.Append(fiber.HeaderWWWAuthenticate, `Basic realm="my-todo-app"`, `ApiKey realm="my-todo-app"`)
+	if !jwtKeys.IsEmpty() {
+		c.Append(fiber.HeaderWWWAuthenticate, `Bearer realm="my-todo-app"`)
+	}
+	return c.SendStatus(http.StatusUnauthorized)
+}
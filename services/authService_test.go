@@ -0,0 +1,146 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt"
+	"math/big"
+	"my-todo-app/domain"
+	"my-todo-app/repository"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsPublicRoute(t *testing.T) {
+	defer func(routes []string) { publicRoutes = routes }(publicRoutes)
+	publicRoutes = []string{"POST /users", "GET /task/:id/occurrences", "* /docs/*"}
+
+	scenarios := []struct {
+		method string
+		path   string
+		public bool
+	}{
+		{"POST", "/users", true},
+		{"POST", "/users/", true},
+		{"GET", "/users", false},
+		{"GET", "/users/me", false},
+		{"GET", "/task/1/occurrences", true},
+		{"GET", "/task//occurrences", false},
+		{"GET", "/task/1", false},
+		{"DELETE", "/docs/api/index.html", true},
+		{"GET", "/docs", true},
+		{"GET", "/documents", false},
+	}
+
+	for _, scenario := range scenarios {
+		if public := isPublicRoute(scenario.method, scenario.path); public != scenario.public {
+			t.Errorf("Expected %s %s public: %t, Got: %t", scenario.method, scenario.path, scenario.public, public)
+		}
+	}
+}
+
+func TestJwtAuthentication(t *testing.T) {
+	defer func(keys domain.JwtKeys) { jwtKeys = keys }(jwtKeys)
+
+	store, _ := repository.NewRepository(repository.MemoryDriver, "")
+	SetRepository(store)
+	user, _ := domain.NewUser("alice", "correct horse", 0)
+	user.Id, _ = store.CreateUser(user)
+
+	secret := []byte("secret")
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	jwtKeys = domain.NewJwtKeys("https://issuer.example.com", "")
+	jwtKeys.Hmac[""] = secret
+	jwtKeys.TenantClaim = "tenant"
+	if err := jwtKeys.AddJwks(getJwks(t, "rsa-1", &rsaKey.PublicKey)); err != nil {
+		t.Fatalf("Error loading JWKS: %s", err)
+	}
+
+	app := fiber.New()
+	app.Use(AuthMiddleware)
+	app.Get("/users/me", GetCurrentUserHandler)
+
+	claims := func(subject string, issuer string, expiresIn time.Duration) jwt.MapClaims {
+		return jwt.MapClaims{"sub": subject, "iss": issuer, "exp": time.Now().Add(expiresIn).Unix()}
+	}
+	sign := func(method jwt.SigningMethod, kid string, claims jwt.MapClaims, key interface{}) string {
+		token := jwt.NewWithClaims(method, claims)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatalf("Error signing token: %s", err)
+		}
+		return signed
+	}
+	valid := claims("Alice", "https://issuer.example.com", time.Hour)
+	withTenant := func(tenant interface{}) jwt.MapClaims {
+		tenantClaims := claims("alice", "https://issuer.example.com", time.Hour)
+		tenantClaims["tenant"] = tenant
+		return tenantClaims
+	}
+	publicKeyPem := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: marshalPublicKey(t, &rsaKey.PublicKey)})
+
+	scenarios := []struct {
+		name       string
+		token      string
+		statusCode int
+	}{
+		{"should accept HS256 token", sign(jwt.SigningMethodHS256, "", valid, secret), http.StatusOK},
+		{"should accept RS256 token from JWKS", sign(jwt.SigningMethodRS256, "rsa-1", valid, rsaKey), http.StatusOK},
+		{"should reject expired token", sign(jwt.SigningMethodHS256, "", claims("alice", "https://issuer.example.com", -time.Hour), secret), http.StatusUnauthorized},
+		{"should reject token without expiry", sign(jwt.SigningMethodHS256, "", jwt.MapClaims{"sub": "alice", "iss": "https://issuer.example.com"}, secret), http.StatusUnauthorized},
+		{"should reject wrong secret", sign(jwt.SigningMethodHS256, "", valid, []byte("guess")), http.StatusUnauthorized},
+		{"should reject other issuer", sign(jwt.SigningMethodHS256, "", claims("alice", "https://evil.example.com", time.Hour), secret), http.StatusUnauthorized},
+		{"should reject unknown subject", sign(jwt.SigningMethodHS256, "", claims("mallory", "https://issuer.example.com", time.Hour), secret), http.StatusUnauthorized},
+		{"should reject unknown kid", sign(jwt.SigningMethodRS256, "rsa-2", valid, rsaKey), http.StatusUnauthorized},
+		{"should reject unsigned token", sign(jwt.SigningMethodNone, "", valid, jwt.UnsafeAllowNoneSignatureType), http.StatusUnauthorized},
+		{"should reject public key used as HMAC secret", sign(jwt.SigningMethodHS256, "rsa-1", valid, publicKeyPem), http.StatusUnauthorized},
+		{"should accept token of the tenant of its subject", sign(jwt.SigningMethodHS256, "", withTenant(domain.DefaultTenant), secret), http.StatusOK},
+		{"should reject token of another tenant", sign(jwt.SigningMethodHS256, "", withTenant("acme"), secret), http.StatusUnauthorized},
+		{"should reject invalid tenant claim", sign(jwt.SigningMethodHS256, "", withTenant(42), secret), http.StatusUnauthorized},
+		{"should reject garbage", "not.a.token", http.StatusUnauthorized},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			request := httptest.NewRequest("GET", "http://localhost.com/users/me", nil)
+			request.Header.Set("Authorization", "Bearer "+scenario.token)
+			response, _ := app.Test(request)
+			compareResponses(t, scenario.statusCode, user, response)
+			if scenario.statusCode == http.StatusUnauthorized &&
+				!strings.Contains(response.Header.Get("WWW-Authenticate"), "Bearer") {
+				t.Errorf("Expected bearer challenge, Got: %v", response.Header)
+			}
+		})
+	}
+}
+
+func getJwks(t *testing.T, kid string, key *rsa.PublicKey) []byte {
+	encode := base64.RawURLEncoding.EncodeToString
+	jwks, err := json.Marshal(map[string]interface{}{"keys": []map[string]string{
+		{"kty": "RSA", "kid": kid, "use": "sig", "n": encode(key.N.Bytes()), "e": encode(big.NewInt(int64(key.E)).Bytes())},
+		{"kty": "EC", "kid": "ec-1", "crv": "P-256"},
+	}})
+	if err != nil {
+		t.Fatalf("Error encoding JWKS: %s", err)
+	}
+	return jwks
+}
+
+func marshalPublicKey(t *testing.T, key *rsa.PublicKey) []byte {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatalf("Error encoding public key: %s", err)
+	}
+	return der
+}
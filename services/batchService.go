@@ -0,0 +1,261 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"my-todo-app/config"
+	"my-todo-app/domain"
+	"my-todo-app/repository"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	errTaskNotFound    = errors.New("task does not exist")
+	errDeleteForbidden = errors.New("role of the caller may not delete tasks")
+)
+
+var maxBatchSize int
+
+func init() {
+	maxBatchSize = config.MaxBatchSize
+}
+
+// batchWrite is an operation of a batch ready to be stored, along with the
+// task it changes as it is now.
+type batchWrite struct {
+	index   int
+	write   domain.TaskWrite
+	current domain.Task
+}
+
+// BatchTasksHandler applies the create, update and delete operations of a
+// domain.BatchRequest in one go, checking each of them like the single task
+// routes do. It answers 200 when every operation succeeded and 207 with the
+// result of each operation otherwise.
+func BatchTasksHandler(c *fiber.Ctx) error {
+	var batch domain.BatchRequest
+	err := json.Unmarshal(c.Body(), &batch)
+	if err == nil && (len(batch.Operations) == 0 || len(batch.Operations) > maxBatchSize) {
+		err = fmt.Errorf("a batch takes 1 to %d operations, got %d", maxBatchSize, len(batch.Operations))
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("Invalid task batch: %s", err))
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	caller := getCaller(c)
+	response := domain.BatchResponse{Atomic: batch.Atomic, Results: make([]domain.BatchResult, len(batch.Operations))}
+	var prepared []batchWrite
+	refused := false
+	for i, operation := range batch.Operations {
+		response.Results[i] = domain.BatchResult{Op: operation.Op, Id: operation.Id}
+		write, status, err := prepareOperation(caller, operation)
+		if err != nil {
+			setBatchFailure(&response.Results[i], status, err)
+			refused = true
+			continue
+		}
+		write.index = i
+		prepared = append(prepared, write)
+	}
+
+	// nothing of an atomic batch is stored once one of its operations is refused
+	if batch.Atomic && refused {
+		for _, write := range prepared {
+			setBatchFailure(&response.Results[write.index], http.StatusFailedDependency, repository.ErrBatchRolledBack)
+		}
+		prepared = nil
+	}
+	if len(prepared) > 0 {
+		writes := make([]domain.TaskWrite, len(prepared))
+		for i, write := range prepared {
+			writes[i] = write.write
+		}
+		results, err := taskRepository.ApplyBatch(caller, writes, batch.Atomic)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Error storing batch of %d tasks: %s", len(writes), err))
+			return c.SendStatus(http.StatusInternalServerError)
+		}
+		for i, result := range results {
+			setBatchResult(&response.Results[prepared[i].index], prepared[i], result)
+		}
+	}
+
+	status := http.StatusOK
+	for _, result := range response.Results {
+		if result.Status >= http.StatusMultipleChoices {
+			status = http.StatusMultiStatus
+		}
+	}
+	logger.Info(fmt.Sprintf("Applied batch of %d operations for %s, atomic: %t, status: %d",
+		len(batch.Operations), caller.Username, batch.Atomic, status))
+	return c.Status(status).JSON(response)
+}
+
+func prepareOperation(caller domain.Identity, operation domain.BatchOperation) (batchWrite, int, error) {
+	switch operation.Op {
+	case domain.BatchCreate:
+		return prepareCreate(caller, operation)
+	case domain.BatchUpdate:
+		return prepareUpdate(caller, operation)
+	case domain.BatchDelete:
+		return prepareDelete(caller, operation)
+	}
+	return batchWrite{}, http.StatusBadRequest, fmt.Errorf("unknown operation %q, expected one of: %s, %s, %s",
+		operation.Op, domain.BatchCreate, domain.BatchUpdate, domain.BatchDelete)
+}
+
+func prepareCreate(caller domain.Identity, operation domain.BatchOperation) (batchWrite, int, error) {
+	var task domain.Task
+	err := json.Unmarshal(operation.Task, &task)
+	if err == nil {
+		err = setNormalizedTags(&task)
+	}
+	if err == nil {
+		err = setNormalizedRecurrence(&task)
+	}
+	if err == nil {
+		err = setNormalizedPriority(&task, domain.DefaultPriority)
+	}
+	if err != nil {
+		return batchWrite{}, http.StatusBadRequest, err
+	}
+	if err = validateParent(caller, task, ""); err != nil {
+		return batchWrite{}, getParentErrorStatus(err), err
+	}
+	if err = validateList(caller, task); err != nil {
+		return batchWrite{}, getListErrorStatus(err), err
+	}
+	if err = checkNewStatus(&task); err != nil {
+		return batchWrite{}, http.StatusUnprocessableEntity, err
+	}
+
+	task.SetOwnerId(caller.UserId)
+	write := domain.TaskWrite{Op: domain.BatchCreate, Task: task, Revision: newRevision(caller, domain.HistoryCreate)}
+	return batchWrite{write: write}, 0, nil
+}
+
+func prepareUpdate(caller domain.Identity, operation domain.BatchOperation) (batchWrite, int, error) {
+	id := strconv.FormatInt(operation.Id, 10)
+	var task domain.Task
+	err := json.Unmarshal(operation.Task, &task)
+	if err == nil {
+		err = setNormalizedTags(&task)
+	}
+	if err == nil {
+		err = setNormalizedRecurrence(&task)
+	}
+	if err == nil && task.GetId() != 0 && task.GetId() != operation.Id {
+		err = fmt.Errorf("id %d of task is different from id %d of operation", task.GetId(), operation.Id)
+	}
+	if err != nil {
+		return batchWrite{}, http.StatusBadRequest, err
+	}
+	task.SetId(operation.Id)
+
+	current, version, status, err := getBatchTask(caller, id, operation.IfMatch)
+	if err != nil {
+		return batchWrite{}, status, err
+	}
+	task.SetVersion(version)
+	if err = validateParent(caller, task, id); err != nil {
+		return batchWrite{}, getParentErrorStatus(err), err
+	}
+	if err = validateList(caller, task); err != nil {
+		return batchWrite{}, getListErrorStatus(err), err
+	}
+	if err = setNormalizedPriority(&task, current.GetPriority()); err != nil {
+		return batchWrite{}, http.StatusBadRequest, err
+	}
+	if err = checkStatusUpdate(&task, current.GetStatus()); err != nil {
+		return batchWrite{}, http.StatusUnprocessableEntity, err
+	}
+	if err = checkBlockers(caller, id, task.GetStatus(), current.GetStatus()); err == errBlocked {
+		return batchWrite{}, http.StatusConflict, err
+	}
+	if err != nil {
+		return batchWrite{}, http.StatusInternalServerError, err
+	}
+
+	task.SetOwnerId(current.GetOwnerId())
+	next, err := getNextOccurrence(caller, &task, current)
+	if err != nil {
+		return batchWrite{}, http.StatusInternalServerError, err
+	}
+	write := domain.TaskWrite{Op: domain.BatchUpdate, Task: task, Next: next, Revision: newRevision(caller, domain.HistoryUpdate)}
+	return batchWrite{write: write, current: current}, 0, nil
+}
+
+func prepareDelete(caller domain.Identity, operation domain.BatchOperation) (batchWrite, int, error) {
+	if !caller.Can(domain.PermissionDeleteTasks) {
+		return batchWrite{}, http.StatusForbidden, errDeleteForbidden
+	}
+
+	id := strconv.FormatInt(operation.Id, 10)
+	_, version, status, err := getBatchTask(caller, id, operation.IfMatch)
+	if err != nil {
+		return batchWrite{}, status, err
+	}
+
+	task := domain.Task{Id: operation.Id, Version: version, DeletedOn: toMillis(time.Now())}
+	write := domain.TaskWrite{Op: domain.BatchDelete, Task: task, Cascade: isCascadeDelete(),
+		Revision: newRevision(caller, domain.HistoryDelete)}
+	return batchWrite{write: write}, 0, nil
+}
+
+// getBatchTask reads the task an update or delete changes and checks the
+// ETag the operation is based on, returning the version it applies to.
+func getBatchTask(caller domain.Identity, id string, ifMatch string) (domain.Task, int64, int, error) {
+	current, err := taskRepository.GetTaskById(caller, id)
+	if err != nil {
+		return domain.Task{}, 0, http.StatusInternalServerError, err
+	}
+	if len(current) == 0 {
+		return domain.Task{}, 0, http.StatusNotFound, errTaskNotFound
+	}
+	version, err := checkIfMatch(ifMatch, current[0])
+	if err != nil {
+		return domain.Task{}, 0, getVersionErrorStatus(err), err
+	}
+	return current[0], version, 0, nil
+}
+
+// setBatchResult reports what storing an operation did, the way the single
+// task route would have answered it.
+func setBatchResult(result *domain.BatchResult, prepared batchWrite, stored domain.TaskWriteResult) {
+	task := prepared.write.Task
+	switch {
+	case stored.Err == repository.ErrBatchRolledBack:
+		setBatchFailure(result, http.StatusFailedDependency, stored.Err)
+	case stored.Err == repository.ErrHasSubtasks:
+		setBatchFailure(result, http.StatusConflict, stored.Err)
+	case stored.Err != nil:
+		setBatchFailure(result, getVersionErrorStatus(stored.Err), stored.Err)
+	case !stored.Applied && prepared.write.Op == domain.BatchDelete && task.GetVersion() != 0:
+		setBatchFailure(result, http.StatusPreconditionFailed, errStaleVersion)
+	case !stored.Applied:
+		setBatchFailure(result, http.StatusNotFound, errTaskNotFound)
+	case prepared.write.Op == domain.BatchDelete:
+		result.Status = http.StatusNoContent
+	default:
+		task.SetId(stored.Id)
+		task.SetVersion(prepared.current.GetVersion() + 1)
+		result.Id, result.Status, result.ETag, result.Task = stored.Id, http.StatusOK, formatETag(task.GetVersion()), &task
+		addNextOccurrence(&task, prepared.write.Next, stored.NextId)
+	}
+}
+
+// setBatchFailure reports the error of an operation, keeping the details of
+// server errors in the log.
+func setBatchFailure(result *domain.BatchResult, status int, err error) {
+	message := err.Error()
+	if status == http.StatusInternalServerError {
+		logger.Error(fmt.Sprintf("Error in batch %s of task with id=%d: %s", result.Op, result.Id, err))
+		message = http.StatusText(status)
+	}
+	result.Status, result.Error = status, message
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"my-todo-app/domain"
+	"my-todo-app/repository"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBatchTasksHandler(t *testing.T) {
+	store, _ := repository.NewRepository(repository.MemoryDriver, "")
+	SetRepository(store)
+	user, _ := domain.NewUser("alice", "correct horse", 0)
+	_, _ = store.CreateUser(user)
+
+	app := fiber.New()
+	app.Use(AuthMiddleware)
+	app.Post("/tasks/batch", RequirePermission(domain.PermissionWriteTasks), BatchTasksHandler)
+	app.Get("/tasks", GetAllTasksHandler)
+	app.Get("/task/:id", GetTaskByIdHandler)
+
+	request := func(method string, url string, body string) *http.Response {
+		req := httptest.NewRequest(method, "http://localhost.com"+url, bytes.NewBufferString(body))
+		req.SetBasicAuth("alice", "correct horse")
+		response, _ := app.Test(req, -1)
+		return response
+	}
+	batch := func(t *testing.T, body string, statusCode int, statuses []int) domain.BatchResponse {
+		response := request("POST", "/tasks/batch", body)
+		var batch domain.BatchResponse
+		_ = json.NewDecoder(response.Body).Decode(&batch)
+		var got []int
+		for _, result := range batch.Results {
+			got = append(got, result.Status)
+		}
+		if response.StatusCode != statusCode || !reflect.DeepEqual(got, statuses) {
+			t.Fatalf("Expected status code: %d with results %v, Got: %d with %v", statusCode, statuses, response.StatusCode, batch.Results)
+		}
+		return batch
+	}
+	countTasks := func() int {
+		var tasks []domain.Task
+		_ = json.NewDecoder(request("GET", "/tasks?perPage=100", "").Body).Decode(&tasks)
+		return len(tasks)
+	}
+
+	t.Run("should reject malformed batches", func(t *testing.T) {
+		defer func(size int) { maxBatchSize = size }(maxBatchSize)
+		maxBatchSize = 2
+
+		compareResponses(t, http.StatusBadRequest, nil, request("POST", "/tasks/batch", `[]`))
+		compareResponses(t, http.StatusBadRequest, nil, request("POST", "/tasks/batch", `{"operations": []}`))
+		compareResponses(t, http.StatusBadRequest, nil, request("POST", "/tasks/batch",
+			`{"operations": [{"op": "delete", "id": 1}, {"op": "delete", "id": 2}, {"op": "delete", "id": 3}]}`))
+	})
+
+	t.Run("should apply every operation of a batch", func(t *testing.T) {
+		result := batch(t, `{"atomic": true, "operations": [
+			{"op": "create", "task": {"title": "first", "recurrence": "FREQ=DAILY", "due_by": 86400000}},
+			{"op": "create", "task": {"title": "second", "tags": ["Import"]}},
+			{"op": "create", "task": {"title": "third"}}
+		]}`, http.StatusOK, []int{http.StatusOK, http.StatusOK, http.StatusOK})
+		second := result.Results[1]
+		if second.Id != 2 || second.ETag != `"1"` || second.Task == nil || !reflect.DeepEqual(second.Task.GetTags(), []string{"import"}) {
+			t.Errorf("Expected the created task with its ETag, Got: %v", second)
+		}
+
+		result = batch(t, `{"atomic": true, "operations": [
+			{"op": "update", "id": 1, "if_match": "\"1\"", "task": {"title": "first", "status": "done", "recurrence": "FREQ=DAILY", "due_by": 86400000}},
+			{"op": "update", "id": 2, "if_match": "*", "task": {"id": 2, "title": "second, renamed"}},
+			{"op": "delete", "id": 3, "if_match": "\"1\""}
+		]}`, http.StatusOK, []int{http.StatusOK, http.StatusOK, http.StatusNoContent})
+		if first := result.Results[0]; first.ETag != `"2"` || first.Task.GetNextOccurrenceId() != 4 {
+			t.Errorf("Expected the next occurrence to be scheduled, Got: %v", first)
+		}
+		compareResponses(t, http.StatusOK, domain.Task{Id: 2, Title: "second, renamed", Status: "open", Tags: []string{"import"},
+			Priority: "P2", OwnerId: 1}, request("GET", "/task/2", ""))
+	})
+
+	t.Run("should store nothing of a failing atomic batch", func(t *testing.T) {
+		before := countTasks()
+		result := batch(t, `{"atomic": true, "operations": [
+			{"op": "create", "task": {"title": "fourth"}},
+			{"op": "update", "id": 2, "if_match": "\"1\"", "task": {"title": "stale"}},
+			{"op": "archive", "id": 2}
+		]}`, http.StatusMultiStatus, []int{http.StatusFailedDependency, http.StatusPreconditionFailed, http.StatusBadRequest})
+		if !strings.Contains(result.Results[2].Error, "unknown operation") {
+			t.Errorf("Expected an error message, Got: %v", result.Results[2])
+		}
+		if after := countTasks(); after != before {
+			t.Errorf("Expected %d tasks, Got: %d", before, after)
+		}
+	})
+
+	t.Run("should apply the valid operations of a best effort batch", func(t *testing.T) {
+		before := countTasks()
+		batch(t, `{"operations": [
+			{"op": "create", "task": {"title": "fifth"}},
+			{"op": "create", "task": {"title": "sixth", "status": "finished"}},
+			{"op": "update", "id": 2, "task": {"title": "unconditional"}},
+			{"op": "update", "id": 99, "if_match": "*", "task": {"title": "missing"}},
+			{"op": "delete", "id": 1, "if_match": "\"2\""}
+		]}`, http.StatusMultiStatus, []int{http.StatusOK, http.StatusUnprocessableEntity, http.StatusPreconditionRequired,
+			http.StatusNotFound, http.StatusNoContent})
+		if after := countTasks(); after != before {
+			t.Errorf("Expected one task created and one deleted, Got: %d tasks instead of %d", after, before)
+		}
+	})
+}
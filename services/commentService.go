@@ -0,0 +1,161 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"my-todo-app/domain"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxCommentLength = 10000
+
+// GetCommentsHandler pages through the comments on a task, the oldest first.
+func GetCommentsHandler(c *fiber.Ctx) error {
+	taskId := c.Params("id")
+	caller := getCaller(c)
+	page, _ := strconv.ParseInt(c.Query("page", "0"), 10, 64)
+	perPage, _ := strconv.ParseInt(c.Query("perPage", "10"), 10, 64)
+	if status := checkVisibleTask(caller, taskId); status != http.StatusOK {
+		return c.SendStatus(status)
+	}
+
+	comments, err := commentRepository.GetComments(caller, taskId, page, perPage)
+	if err == nil {
+		logger.Info(fmt.Sprintf("No. of comments fetched for task with id=%s: %d", taskId, len(comments)))
+		return c.JSON(comments)
+	}
+
+	logger.Error(fmt.Sprintf("Error fetching comments on task with id=%s: %s", taskId, err))
+	return c.SendStatus(http.StatusInternalServerError)
+}
+
+func CreateCommentHandler(c *fiber.Ctx) error {
+	taskId := c.Params("id")
+	caller := getCaller(c)
+	comment, err := parseComment(c.Body())
+	if err != nil {
+		logger.Error(fmt.Sprintf("Invalid comment on task with id=%s: %s", taskId, err))
+		return c.SendStatus(http.StatusBadRequest)
+	}
+	if status := checkVisibleTask(caller, taskId); status != http.StatusOK {
+		return c.SendStatus(status)
+	}
+
+	comment.TaskId, _ = strconv.ParseInt(taskId, 10, 64)
+	comment.AuthorId, comment.Author = caller.UserId, caller.Username
+	comment.AddedOn = toMillis(time.Now())
+	comment.UpdatedOn = comment.AddedOn
+	comment.Id, err = commentRepository.CreateComment(caller, comment)
+	if err == nil {
+		logger.Info(fmt.Sprintf("Created comment with id: %d on task with id: %s", comment.GetId(), taskId))
+		return c.Status(http.StatusCreated).JSON(comment)
+	}
+
+	logger.Error(fmt.Sprintf("Error creating comment on task with id=%s: %s", taskId, err))
+	return c.SendStatus(http.StatusInternalServerError)
+}
+
+// UpdateCommentHandler replaces the body of a comment, for its author only.
+func UpdateCommentHandler(c *fiber.Ctx) error {
+	taskId, id := c.Params("id"), c.Params("commentId")
+	caller := getCaller(c)
+	update, err := parseComment(c.Body())
+	if err != nil {
+		logger.Error(fmt.Sprintf("Invalid update of comment with id=%s: %s", id, err))
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	comment, status := getAuthoredComment(caller, taskId, id)
+	if status != http.StatusOK {
+		return c.SendStatus(status)
+	}
+
+	comment.Body = update.GetBody()
+	comment.UpdatedOn = toMillis(time.Now())
+	updated, err := commentRepository.UpdateComment(caller, comment, id)
+	if err == nil && updated {
+		return c.JSON(comment)
+	}
+	if err == nil {
+		logger.Info(fmt.Sprintf("No comment found with id: %s for update", id))
+		return c.SendStatus(http.StatusNotFound)
+	}
+
+	logger.Error(fmt.Sprintf("Error updating comment with id=%s: %s", id, err))
+	return c.SendStatus(http.StatusInternalServerError)
+}
+
+// DeleteCommentHandler removes a comment, for its author only.
+func DeleteCommentHandler(c *fiber.Ctx) error {
+	taskId, id := c.Params("id"), c.Params("commentId")
+	caller := getCaller(c)
+	if _, status := getAuthoredComment(caller, taskId, id); status != http.StatusOK {
+		return c.SendStatus(status)
+	}
+
+	deleted, err := commentRepository.DeleteComment(caller, taskId, id)
+	if err == nil && deleted {
+		logger.Info(fmt.Sprintf("Deleted comment with id: %s", id))
+		return c.SendStatus(http.StatusNoContent)
+	}
+	if err == nil {
+		logger.Info(fmt.Sprintf("No comment found with id: %s for deletion", id))
+		return c.SendStatus(http.StatusNotFound)
+	}
+
+	logger.Error(fmt.Sprintf("Error deleting comment with id=%s: %s", id, err))
+	return c.SendStatus(http.StatusInternalServerError)
+}
+
+// checkVisibleTask answers with the status to give a request on the comments,
+// attachments or dependencies of the task with taskId: 404 unless caller sees
+// the task, which is not the case for tasks in the trash.
+func checkVisibleTask(caller domain.Identity, taskId string) int {
+	tasks, err := taskRepository.GetTaskById(caller, taskId)
+	switch {
+	case err != nil:
+		logger.Error(fmt.Sprintf("Error fetching task with id=%s: %s", taskId, err))
+		return http.StatusInternalServerError
+	case len(tasks) == 0:
+		logger.Info(fmt.Sprintf("No task found with id: %s", taskId))
+		return http.StatusNotFound
+	}
+	return http.StatusOK
+}
+
+// getAuthoredComment looks up the comment with id on the task with taskId and
+// answers with http.StatusOK unless it is found and written by caller: 404 for
+// comments on tasks caller does not see and 403 for comments of someone else.
+func getAuthoredComment(caller domain.Identity, taskId string, id string) (domain.Comment, int) {
+	if status := checkVisibleTask(caller, taskId); status != http.StatusOK {
+		return domain.Comment{}, status
+	}
+
+	comments, err := commentRepository.GetCommentById(caller, taskId, id)
+	switch {
+	case err != nil:
+		logger.Error(fmt.Sprintf("Error fetching comment with id=%s: %s", id, err))
+		return domain.Comment{}, http.StatusInternalServerError
+	case len(comments) == 0:
+		logger.Info(fmt.Sprintf("No comment found with id: %s on task with id: %s", id, taskId))
+		return domain.Comment{}, http.StatusNotFound
+	case comments[0].GetAuthorId() != caller.UserId:
+		logger.Info(fmt.Sprintf("User %s may not change comment %s", caller.Username, id))
+		return domain.Comment{}, http.StatusForbidden
+	}
+	return comments[0], http.StatusOK
+}
+
+func parseComment(body []byte) (domain.Comment, error) {
+	var comment domain.Comment
+	err := json.Unmarshal(body, &comment)
+	comment.Body = strings.TrimSpace(comment.Body)
+	if err == nil && (comment.Body == "" || len(comment.Body) > maxCommentLength) {
+		err = fmt.Errorf("body must be 1 to %d characters", maxCommentLength)
+	}
+	return comment, err
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"my-todo-app/domain"
+	"my-todo-app/repository"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestComments(t *testing.T) {
+	store, _ := repository.NewRepository(repository.MemoryDriver, "")
+	SetRepository(store)
+	for _, username := range []string{"alice", "bob", "carol"} {
+		user, _ := domain.NewUser(username, "correct horse", 0)
+		_, _ = store.CreateUser(user)
+	}
+
+	app := fiber.New()
+	app.Use(AuthMiddleware)
+	app.Post("/lists", CreateListHandler)
+	app.Post("/lists/:id/members", AddListMemberHandler)
+	app.Post("/task", CreateTaskHandler)
+	app.Delete("/task/:id", DeleteTaskByIdHandler)
+	app.Post("/task/:id/restore", RestoreTaskHandler)
+	app.Get("/task/:id/comments", GetCommentsHandler)
+	app.Post("/task/:id/comments", CreateCommentHandler)
+	app.Put("/task/:id/comments/:commentId", UpdateCommentHandler)
+	app.Delete("/task/:id/comments/:commentId", DeleteCommentHandler)
+
+	request := func(method string, url string, body string, username string) *http.Response {
+		req := httptest.NewRequest(method, "http://localhost.com"+url, bytes.NewBufferString(body))
+		req.SetBasicAuth(username, "correct horse")
+		req.Header.Set(fiber.HeaderIfMatch, "*")
+		response, _ := app.Test(req, -1)
+		return response
+	}
+	getBodies := func(url string, username string) []string {
+		var comments []domain.Comment
+		_ = json.NewDecoder(request("GET", url, "", username).Body).Decode(&comments)
+		bodies := []string{}
+		for _, comment := range comments {
+			bodies = append(bodies, comment.GetBody())
+		}
+		return bodies
+	}
+
+	request("POST", "/lists", `{"name": "Sprint 12"}`, "alice")
+	request("POST", "/lists/1/members", `{"username": "bob"}`, "alice")
+	request("POST", "/task", `{"title": "shared", "list_id": 1}`, "alice")
+
+	t.Run("should add comments with their author", func(t *testing.T) {
+		response := request("POST", "/task/1/comments", `{"body": " looks good "}`, "alice")
+		var comment domain.Comment
+		_ = json.NewDecoder(response.Body).Decode(&comment)
+		if response.StatusCode != http.StatusCreated || comment.GetBody() != "looks good" || comment.Author != "alice" ||
+			comment.GetTaskId() != 1 || comment.GetAddedOn() == 0 || comment.GetUpdatedOn() != comment.GetAddedOn() {
+			t.Errorf("Expected comment to be created, Got: %d with %v", response.StatusCode, comment)
+		}
+		request("POST", "/task/1/comments", `{"body": "on it"}`, "bob")
+		request("POST", "/task/1/comments", `{"body": "done"}`, "bob")
+
+		compareResponses(t, http.StatusBadRequest, nil, request("POST", "/task/1/comments", `{"body": " "}`, "bob"))
+		compareResponses(t, http.StatusBadRequest, nil,
+			request("POST", "/task/1/comments", `{"body": "`+strings.Repeat("a", maxCommentLength+1)+`"}`, "bob"))
+		compareResponses(t, http.StatusNotFound, nil, request("POST", "/task/1/comments", `{"body": "hi"}`, "carol"))
+		compareResponses(t, http.StatusNotFound, nil, request("POST", "/task/9/comments", `{"body": "hi"}`, "alice"))
+	})
+
+	t.Run("should list comments oldest first, paginated", func(t *testing.T) {
+		if bodies := getBodies("/task/1/comments?page=0&perPage=2", "bob"); !reflect.DeepEqual(bodies, []string{"looks good", "on it"}) {
+			t.Errorf("Expected the first page, Got: %v", bodies)
+		}
+		if bodies := getBodies("/task/1/comments?page=1&perPage=2", "alice"); !reflect.DeepEqual(bodies, []string{"done"}) {
+			t.Errorf("Expected the second page, Got: %v", bodies)
+		}
+		compareResponses(t, http.StatusNotFound, nil, request("GET", "/task/1/comments", "", "carol"))
+	})
+
+	t.Run("should let only the author change a comment", func(t *testing.T) {
+		compareResponses(t, http.StatusForbidden, nil, request("PUT", "/task/1/comments/2", `{"body": "not on it"}`, "alice"))
+		compareResponses(t, http.StatusForbidden, nil, request("DELETE", "/task/1/comments/2", "", "alice"))
+		compareResponses(t, http.StatusNotFound, nil, request("PUT", "/task/1/comments/9", `{"body": "edited"}`, "bob"))
+		compareResponses(t, http.StatusNotFound, nil, request("PUT", "/task/2/comments/2", `{"body": "edited"}`, "bob"))
+
+		response := request("PUT", "/task/1/comments/2", `{"body": "almost done"}`, "bob")
+		var comment domain.Comment
+		_ = json.NewDecoder(response.Body).Decode(&comment)
+		if response.StatusCode != http.StatusOK || comment.GetBody() != "almost done" || comment.GetUpdatedOn() < comment.GetAddedOn() {
+			t.Errorf("Expected comment to be updated, Got: %d with %v", response.StatusCode, comment)
+		}
+		compareResponses(t, http.StatusNoContent, nil, request("DELETE", "/task/1/comments/3", "", "bob"))
+		compareResponses(t, http.StatusNotFound, nil, request("DELETE", "/task/1/comments/3", "", "bob"))
+
+		if bodies := getBodies("/task/1/comments", "alice"); !reflect.DeepEqual(bodies, []string{"looks good", "almost done"}) {
+			t.Errorf("Expected the remaining comments, Got: %v", bodies)
+		}
+	})
+
+	t.Run("should hide the comments of a task in the trash", func(t *testing.T) {
+		compareResponses(t, http.StatusNoContent, nil, request("DELETE", "/task/1", "", "alice"))
+		compareResponses(t, http.StatusNotFound, nil, request("GET", "/task/1/comments", "", "alice"))
+		compareResponses(t, http.StatusNotFound, nil, request("PUT", "/task/1/comments/1", `{"body": "edited"}`, "alice"))
+
+		request("POST", "/task/1/restore", "", "alice")
+		if bodies := getBodies("/task/1/comments", "alice"); len(bodies) != 2 {
+			t.Errorf("Expected the comments to be restored with the task, Got: %v", bodies)
+		}
+	})
+}
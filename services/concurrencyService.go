@@ -0,0 +1,77 @@
+package services
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"my-todo-app/domain"
+	"my-todo-app/repository"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	errMissingIfMatch = errors.New("If-Match is required to change a task")
+	errStaleVersion   = errors.New("If-Match does not match the current version of the task")
+)
+
+// formatETag quotes the version of a task as a strong entity tag.
+func formatETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+func setETag(c *fiber.Ctx, task domain.Task) {
+	c.Set(fiber.HeaderETag, formatETag(task.GetVersion()))
+}
+
+// matchesETag reports whether the comma separated entity tags of header
+// include version, or are "*". Weak tags only match with weak comparison,
+// the one If-None-Match uses.
+func matchesETag(header string, version int64, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if weak {
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == "*" || tag == formatETag(version) {
+			return true
+		}
+	}
+	return false
+}
+
+// isNotModified reports whether the If-None-Match header of a conditional
+// GET still matches task.
+func isNotModified(c *fiber.Ctx, task domain.Task) bool {
+	header := c.Get(fiber.HeaderIfNoneMatch)
+	return header != "" && matchesETag(header, task.GetVersion(), true)
+}
+
+// getExpectedVersion checks the If-Match header of a change to current and
+// returns the version the change applies to, 0 for any version when "*".
+func getExpectedVersion(c *fiber.Ctx, current domain.Task) (int64, error) {
+	return checkIfMatch(c.Get(fiber.HeaderIfMatch), current)
+}
+
+func checkIfMatch(header string, current domain.Task) (int64, error) {
+	header = strings.TrimSpace(header)
+	switch {
+	case header == "":
+		return 0, errMissingIfMatch
+	case header == "*":
+		return 0, nil
+	case !matchesETag(header, current.GetVersion(), false):
+		return 0, errStaleVersion
+	}
+	return current.GetVersion(), nil
+}
+
+func getVersionErrorStatus(err error) int {
+	switch err {
+	case errMissingIfMatch:
+		return http.StatusPreconditionRequired
+	case errStaleVersion, repository.ErrVersionConflict:
+		return http.StatusPreconditionFailed
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"bytes"
+	"github.com/gofiber/fiber/v2"
+	"my-todo-app/domain"
+	"my-todo-app/repository"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOptimisticConcurrency(t *testing.T) {
+	store, _ := repository.NewRepository(repository.MemoryDriver, "")
+	SetRepository(store)
+	app := fiber.New()
+	app.Get("/task/:id", GetTaskByIdHandler)
+	app.Post("/task", CreateTaskHandler)
+	app.Put("/task/:id", UpdateTaskByIdHandler)
+	app.Patch("/task/:id", PatchTaskByIdHandler)
+	app.Delete("/task/:id", DeleteTaskByIdHandler)
+	app.Post("/task/:id/transition/:action", TransitionTaskHandler)
+
+	request := func(method string, url string, body string, header string, tag string) *http.Response {
+		req := httptest.NewRequest(method, "http://localhost.com"+url, bytes.NewBufferString(body))
+		if tag != "" {
+			req.Header.Set(header, tag)
+		}
+		response, _ := app.Test(req)
+		return response
+	}
+	expectETag := func(t *testing.T, response *http.Response, statusCode int, tag string) {
+		if response.StatusCode != statusCode || response.Header.Get(fiber.HeaderETag) != tag {
+			t.Errorf("Expected status code: %d with ETag %s, Got: %d with ETag %s", statusCode, tag, response.StatusCode,
+				response.Header.Get(fiber.HeaderETag))
+		}
+	}
+
+	expectETag(t, request("POST", "/task", `{"title": "sample", "status": "open"}`, "", ""), http.StatusOK, `"1"`)
+
+	t.Run("should answer conditional gets", func(t *testing.T) {
+		expectETag(t, request("GET", "/task/1", "", "", ""), http.StatusOK, `"1"`)
+		expectETag(t, request("GET", "/task/1", "", fiber.HeaderIfNoneMatch, `"1"`), http.StatusNotModified, `"1"`)
+		expectETag(t, request("GET", "/task/1", "", fiber.HeaderIfNoneMatch, `"7", W/"1"`), http.StatusNotModified, `"1"`)
+		expectETag(t, request("GET", "/task/1", "", fiber.HeaderIfNoneMatch, `"7"`), http.StatusOK, `"1"`)
+	})
+
+	t.Run("should require a matching If-Match to change a task", func(t *testing.T) {
+		body := `{"id": 1, "title": "renamed", "status": "open"}`
+		compareResponses(t, http.StatusPreconditionRequired, nil, request("PUT", "/task/1", body, "", ""))
+		compareResponses(t, http.StatusPreconditionFailed, nil, request("PUT", "/task/1", body, fiber.HeaderIfMatch, `"7"`))
+		compareResponses(t, http.StatusPreconditionFailed, nil, request("PUT", "/task/1", body, fiber.HeaderIfMatch, `W/"1"`))
+		expectETag(t, request("PUT", "/task/1", body, fiber.HeaderIfMatch, `"1"`), http.StatusOK, `"2"`)
+
+		compareResponses(t, http.StatusPreconditionRequired, nil, request("PATCH", "/task/1", `{"priority": "P0"}`, "", ""))
+		compareResponses(t, http.StatusPreconditionFailed, nil, request("PATCH", "/task/1", `{"priority": "P0"}`, fiber.HeaderIfMatch, `"1"`))
+		expectETag(t, request("PATCH", "/task/1", `{"priority": "P0"}`, fiber.HeaderIfMatch, `"1", "2"`), http.StatusOK, `"3"`)
+		expectETag(t, request("PATCH", "/task/1", `{"priority": "P0"}`, fiber.HeaderIfMatch, `"3"`), http.StatusOK, `"3"`)
+
+		expectETag(t, request("POST", "/task/1/transition/complete", "", "", ""), http.StatusOK, `"4"`)
+		compareResponses(t, http.StatusOK, domain.Task{Id: 1, Title: "renamed", Status: "done", Priority: "P0"},
+			request("GET", "/task/1", "", "", ""))
+	})
+
+	t.Run("should only delete the current version", func(t *testing.T) {
+		compareResponses(t, http.StatusPreconditionRequired, nil, request("DELETE", "/task/1", "", "", ""))
+		compareResponses(t, http.StatusPreconditionFailed, nil, request("DELETE", "/task/1", "", fiber.HeaderIfMatch, `"3"`))
+		compareResponses(t, http.StatusNoContent, nil, request("DELETE", "/task/1", "", fiber.HeaderIfMatch, `"4"`))
+		compareResponses(t, http.StatusNotFound, nil, request("DELETE", "/task/1", "", fiber.HeaderIfMatch, "*"))
+	})
+}
+
+func TestChangesToTasksDeletedMeanwhile(t *testing.T) {
+	taskRepository = taskRepositoryMock{}
+	taskRepositoryGetByIdMock = func(id string) ([]domain.Task, error) {
+		return []domain.Task{{Id: 1, Title: "sample", Status: "open", Version: 1}}, nil
+	}
+	taskRepositoryUpdateTaskMock = func(task domain.Task, id string) error {
+		return repository.ErrTaskNotFound
+	}
+	taskRepositoryPatchTaskMock = func(task domain.Task, id string, fields []string) error {
+		return repository.ErrTaskNotFound
+	}
+
+	app := fiber.New()
+	app.Put("/task/:id", UpdateTaskByIdHandler)
+	app.Patch("/task/:id", PatchTaskByIdHandler)
+	app.Post("/task/:id/transition/:action", TransitionTaskHandler)
+
+	for _, scenario := range []struct{ method, url, body string }{
+		{"PUT", "/task/1", `{"id": 1, "title": "renamed", "status": "open"}`},
+		{"PATCH", "/task/1", `{"title": "renamed"}`},
+		{"POST", "/task/1/transition/start", ""},
+	} {
+		req := httptest.NewRequest(scenario.method, "http://localhost.com"+scenario.url, bytes.NewBufferString(scenario.body))
+		req.Header.Set(fiber.HeaderIfMatch, "*")
+		response, _ := app.Test(req)
+		if response.StatusCode != http.StatusNotFound || response.Header.Get(fiber.HeaderETag) != "" {
+			t.Errorf("Expected %s %s to give 404 without an ETag, Got: %d with ETag %s", scenario.method, scenario.url,
+				response.StatusCode, response.Header.Get(fiber.HeaderETag))
+		}
+	}
+}
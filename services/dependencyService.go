@@ -0,0 +1,126 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"my-todo-app/domain"
+	"my-todo-app/repository"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var errBlocked = errors.New("task is blocked by tasks that are not done yet")
+
+// GetDependenciesHandler lists the tasks blocking a task, as far as caller
+// sees them.
+func GetDependenciesHandler(c *fiber.Ctx) error {
+	taskId := c.Params("id")
+	caller := getCaller(c)
+	if status := checkVisibleTask(caller, taskId); status != http.StatusOK {
+		return c.SendStatus(status)
+	}
+
+	blockers, err := dependencyRepository.GetBlockers(caller, taskId)
+	if err == nil {
+		logger.Info(fmt.Sprintf("No. of blockers fetched for task with id=%s: %d", taskId, len(blockers)))
+		return c.JSON(blockers)
+	}
+
+	logger.Error(fmt.Sprintf("Error fetching blockers of task with id=%s: %s", taskId, err))
+	return c.SendStatus(http.StatusInternalServerError)
+}
+
+// AddDependencyHandler blocks a task by the task in the "blocked_by" field of
+// the body. Dependencies making a task block itself, directly or through
+// other tasks, are refused.
+func AddDependencyHandler(c *fiber.Ctx) error {
+	taskId := c.Params("id")
+	caller := getCaller(c)
+	var dependency domain.Dependency
+	if err := json.Unmarshal(c.Body(), &dependency); err != nil || dependency.GetBlockedById() <= 0 {
+		logger.Error(fmt.Sprintf("Invalid dependency for task with id=%s: %v", taskId, err))
+		return c.SendStatus(http.StatusBadRequest)
+	}
+	if status := checkVisibleTask(caller, taskId); status != http.StatusOK {
+		return c.SendStatus(status)
+	}
+
+	blockerId := strconv.FormatInt(dependency.GetBlockedById(), 10)
+	blockers, err := taskRepository.GetTaskById(caller, blockerId)
+	if err == nil && len(blockers) == 0 {
+		logger.Info(fmt.Sprintf("No task found with id: %s to block task with id: %s", blockerId, taskId))
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	added := false
+	if err == nil {
+		dependency.TaskId, _ = strconv.ParseInt(taskId, 10, 64)
+		dependency.AddedOn = toMillis(time.Now())
+		added, err = dependencyRepository.AddDependency(caller, dependency)
+	}
+	if err == nil && added {
+		logger.Info(fmt.Sprintf("Task with id: %s is now blocked by task with id: %s", taskId, blockerId))
+		return c.Status(http.StatusCreated).JSON(dependency)
+	}
+	if err == nil {
+		logger.Info(fmt.Sprintf("Task with id: %s is already blocked by task with id: %s", taskId, blockerId))
+		return c.SendStatus(http.StatusConflict)
+	}
+	if err == repository.ErrDependencyCycle {
+		logger.Info(fmt.Sprintf("Refusing to block task with id: %s by task with id: %s: %s", taskId, blockerId, err))
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	logger.Error(fmt.Sprintf("Error adding dependency to task with id=%s: %s", taskId, err))
+	return c.SendStatus(http.StatusInternalServerError)
+}
+
+func RemoveDependencyHandler(c *fiber.Ctx) error {
+	taskId, blockerId := c.Params("id"), c.Params("blockerId")
+	caller := getCaller(c)
+	if status := checkVisibleTask(caller, taskId); status != http.StatusOK {
+		return c.SendStatus(status)
+	}
+
+	removed, err := dependencyRepository.RemoveDependency(caller, taskId, blockerId)
+	if err == nil && removed {
+		logger.Info(fmt.Sprintf("Task with id: %s is no longer blocked by task with id: %s", taskId, blockerId))
+		return c.SendStatus(http.StatusNoContent)
+	}
+	if err == nil {
+		logger.Info(fmt.Sprintf("Task with id: %s is not blocked by task with id: %s", taskId, blockerId))
+		return c.SendStatus(http.StatusNotFound)
+	}
+
+	logger.Error(fmt.Sprintf("Error removing dependency of task with id=%s: %s", taskId, err))
+	return c.SendStatus(http.StatusInternalServerError)
+}
+
+// checkBlockers refuses to complete the task with id while one of the tasks
+// blocking it is still open. Tasks that were done already stay done.
+func checkBlockers(caller domain.Identity, id string, status string, previousStatus string) error {
+	if status != workflow.Completed || previousStatus == workflow.Completed {
+		return nil
+	}
+	blocked, err := dependencyRepository.IsBlocked(caller, id)
+	if err == nil && blocked {
+		err = errBlocked
+	}
+	return err
+}
+
+func sendBlocked(c *fiber.Ctx, id string) error {
+	logger.Info(fmt.Sprintf("Refusing to complete task with id=%s: %s", id, errBlocked))
+	return c.Status(http.StatusConflict).JSON(fiber.Map{"error": errBlocked.Error()})
+}
+
+func checkBlockedQuery(blocked string) error {
+	switch blocked {
+	case "", "true", "false":
+		return nil
+	}
+	return fmt.Errorf("blocked must be true or false, got %q", blocked)
+}
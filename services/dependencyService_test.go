@@ -0,0 +1,145 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"my-todo-app/domain"
+	"my-todo-app/repository"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestDependencies(t *testing.T) {
+	store, _ := repository.NewRepository(repository.MemoryDriver, "")
+	SetRepository(store)
+	for _, username := range []string{"alice", "carol"} {
+		user, _ := domain.NewUser(username, "correct horse", 0)
+		_, _ = store.CreateUser(user)
+	}
+
+	app := fiber.New()
+	app.Use(AuthMiddleware)
+	app.Post("/task", CreateTaskHandler)
+	app.Patch("/task/:id", PatchTaskByIdHandler)
+	app.Delete("/task/:id", DeleteTaskByIdHandler)
+	app.Post("/task/:id/transition/:action", TransitionTaskHandler)
+	app.Get("/tasks/search", SearchHandler)
+	app.Get("/task/:id/dependencies", GetDependenciesHandler)
+	app.Post("/task/:id/dependencies", AddDependencyHandler)
+	app.Delete("/task/:id/dependencies/:blockerId", RemoveDependencyHandler)
+
+	request := func(method string, url string, body string, username string) *http.Response {
+		req := httptest.NewRequest(method, "http://localhost.com"+url, bytes.NewBufferString(body))
+		req.SetBasicAuth(username, "correct horse")
+		req.Header.Set(fiber.HeaderIfMatch, "*")
+		response, _ := app.Test(req, -1)
+		return response
+	}
+	getIds := func(url string) []int64 {
+		var tasks []domain.Task
+		_ = json.NewDecoder(request("GET", url, "", "alice").Body).Decode(&tasks)
+		ids := []int64{}
+		for _, task := range tasks {
+			ids = append(ids, task.GetId())
+		}
+		return ids
+	}
+
+	for _, title := range []string{"design", "build", "ship", "announce"} {
+		request("POST", "/task", `{"title": "`+title+`"}`, "alice")
+	}
+	request("POST", "/task", `{"title": "private"}`, "carol")
+
+	t.Run("should block tasks by other tasks", func(t *testing.T) {
+		response := request("POST", "/task/2/dependencies", `{"blocked_by": 1}`, "alice")
+		var dependency domain.Dependency
+		_ = json.NewDecoder(response.Body).Decode(&dependency)
+		if response.StatusCode != http.StatusCreated || dependency.GetTaskId() != 2 || dependency.GetBlockedById() != 1 ||
+			dependency.GetAddedOn() == 0 {
+			t.Errorf("Expected dependency to be added, Got: %d with %v", response.StatusCode, dependency)
+		}
+		compareResponses(t, http.StatusCreated, nil, request("POST", "/task/3/dependencies", `{"blocked_by": 2}`, "alice"))
+		compareResponses(t, http.StatusCreated, nil, request("POST", "/task/4/dependencies", `{"blocked_by": 3}`, "alice"))
+
+		compareResponses(t, http.StatusConflict, nil, request("POST", "/task/2/dependencies", `{"blocked_by": 1}`, "alice"))
+		compareResponses(t, http.StatusBadRequest, nil, request("POST", "/task/2/dependencies", `{"blocked_by": 0}`, "alice"))
+		compareResponses(t, http.StatusBadRequest, nil, request("POST", "/task/2/dependencies", `{"blocked_by": 5}`, "alice"))
+		compareResponses(t, http.StatusNotFound, nil, request("POST", "/task/9/dependencies", `{"blocked_by": 1}`, "alice"))
+		compareResponses(t, http.StatusNotFound, nil, request("POST", "/task/2/dependencies", `{"blocked_by": 5}`, "carol"))
+
+		if ids := getIds("/task/2/dependencies"); !reflect.DeepEqual(ids, []int64{1}) {
+			t.Errorf("Expected task 2 to be blocked by task 1, Got: %v", ids)
+		}
+	})
+
+	t.Run("should refuse dependencies closing a cycle", func(t *testing.T) {
+		for _, scenario := range []struct{ url, body string }{
+			{"/task/1/dependencies", `{"blocked_by": 1}`},
+			{"/task/1/dependencies", `{"blocked_by": 2}`},
+			{"/task/1/dependencies", `{"blocked_by": 4}`},
+		} {
+			response := request("POST", scenario.url, scenario.body, "alice")
+			var body map[string]string
+			_ = json.NewDecoder(response.Body).Decode(&body)
+			if response.StatusCode != http.StatusBadRequest || body["error"] != repository.ErrDependencyCycle.Error() {
+				t.Errorf("Expected %s to %s to be refused, Got: %d with %v", scenario.body, scenario.url,
+					response.StatusCode, body)
+			}
+		}
+	})
+
+	t.Run("should search by blocked state", func(t *testing.T) {
+		if ids := getIds("/tasks/search?blocked=true"); !reflect.DeepEqual(ids, []int64{2, 3, 4}) {
+			t.Errorf("Expected blocked tasks, Got: %v", ids)
+		}
+		if ids := getIds("/tasks/search?blocked=false"); !reflect.DeepEqual(ids, []int64{1}) {
+			t.Errorf("Expected unblocked tasks, Got: %v", ids)
+		}
+		compareResponses(t, http.StatusBadRequest, nil, request("GET", "/tasks/search?blocked=maybe", "", "alice"))
+	})
+
+	t.Run("should refuse to complete tasks with open blockers", func(t *testing.T) {
+		response := request("PATCH", "/task/2", `{"status": "done"}`, "alice")
+		var body map[string]string
+		_ = json.NewDecoder(response.Body).Decode(&body)
+		if response.StatusCode != http.StatusConflict || body["error"] != errBlocked.Error() {
+			t.Errorf("Expected completing task 2 to be refused, Got: %d with %v", response.StatusCode, body)
+		}
+		compareResponses(t, http.StatusConflict, nil, request("POST", "/task/2/transition/complete", "", "alice"))
+
+		for _, scenario := range []struct{ method, url, body string }{
+			{"POST", "/task/2/transition/start", ""},
+			{"POST", "/task/1/transition/complete", ""},
+			{"PATCH", "/task/2", `{"status": "done"}`},
+		} {
+			if response := request(scenario.method, scenario.url, scenario.body, "alice"); response.StatusCode != http.StatusOK {
+				t.Errorf("Expected %s %s to succeed, Got: %d", scenario.method, scenario.url, response.StatusCode)
+			}
+		}
+		if ids := getIds("/tasks/search?blocked=true"); !reflect.DeepEqual(ids, []int64{4}) {
+			t.Errorf("Expected only task 4 to stay blocked, Got: %v", ids)
+		}
+	})
+
+	t.Run("should not be blocked by tasks in the trash", func(t *testing.T) {
+		compareResponses(t, http.StatusNoContent, nil, request("DELETE", "/task/3", "", "alice"))
+		if ids := getIds("/tasks/search?blocked=true"); len(ids) != 0 {
+			t.Errorf("Expected no blocked tasks, Got: %v", ids)
+		}
+		if ids := getIds("/task/4/dependencies"); len(ids) != 0 {
+			t.Errorf("Expected blockers in the trash to be left out, Got: %v", ids)
+		}
+	})
+
+	t.Run("should remove dependencies", func(t *testing.T) {
+		compareResponses(t, http.StatusNoContent, nil, request("DELETE", "/task/2/dependencies/1", "", "alice"))
+		compareResponses(t, http.StatusNotFound, nil, request("DELETE", "/task/2/dependencies/1", "", "alice"))
+		compareResponses(t, http.StatusNotFound, nil, request("DELETE", "/task/4/dependencies/3", "", "carol"))
+		if ids := getIds("/task/2/dependencies"); len(ids) != 0 {
+			t.Errorf("Expected task 2 to have no blockers left, Got: %v", ids)
+		}
+	})
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"my-todo-app/domain"
+	"my-todo-app/repository"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// GetTaskHistoryHandler lists the revisions of a task, the latest first. The
+// history of a task in the trash stays readable.
+func GetTaskHistoryHandler(c *fiber.Ctx) error {
+	id := c.Params("id")
+	caller := getCaller(c)
+	page, _ := strconv.ParseInt(c.Query("page", "0"), 10, 64)
+	perPage, _ := strconv.ParseInt(c.Query("perPage", "10"), 10, 64)
+
+	found, err := isKnownTask(caller, id)
+	if err == nil && !found {
+		logger.Info(fmt.Sprintf("No task found with id: %s for history", id))
+		return c.SendStatus(http.StatusNotFound)
+	}
+	var revisions []domain.TaskRevision
+	if err == nil {
+		revisions, err = historyRepository.GetTaskHistory(caller, id, page, perPage)
+	}
+	if err == nil {
+		logger.Info(fmt.Sprintf("No. of revisions fetched for task with id=%s: %d", id, len(revisions)))
+		return c.JSON(revisions)
+	}
+
+	logger.Error(fmt.Sprintf("Error fetching history of task with id=%s: %s", id, err))
+	return c.SendStatus(http.StatusInternalServerError)
+}
+
+// RevertTaskHandler sets the fields of a task back to what they were at the
+// version in the URL. The revert is a change like any other: it needs the
+// current ETag in If-Match and is itself recorded in the history. Recurrence
+// rules are not reverted past the completion that handed them on.
+func RevertTaskHandler(c *fiber.Ctx) error {
+	id := c.Params("id")
+	caller := getCaller(c)
+	revisionVersion, err := strconv.ParseInt(c.Params("version"), 10, 64)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Invalid version to revert task with id=%s to: %s", id, c.Params("version")))
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	current, err := taskRepository.GetTaskById(caller, id)
+	if err == nil && len(current) == 0 {
+		logger.Info(fmt.Sprintf("No task found with id: %s for revert", id))
+		return c.SendStatus(http.StatusNotFound)
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error fetching task with id=%s: %s", id, err))
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+	version, err := getExpectedVersion(c, current[0])
+	if err != nil {
+		logger.Info(fmt.Sprintf("Refusing to revert task with id=%s: %s", id, err))
+		return c.SendStatus(getVersionErrorStatus(err))
+	}
+
+	revisions, err := historyRepository.GetTaskRevision(caller, id, revisionVersion)
+	if err == nil && len(revisions) == 0 {
+		logger.Info(fmt.Sprintf("No revision %d found for task with id: %s", revisionVersion, id))
+		return c.SendStatus(http.StatusNotFound)
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error fetching revision %d of task with id=%s: %s", revisionVersion, id, err))
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	handedOn, err := isRuleHandedOn(caller, id, revisionVersion)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error fetching history of task with id=%s: %s", id, err))
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+	task := getRevertedTask(current[0], revisions[0].Task)
+	if handedOn || (task.GetStatus() == workflow.Completed && task.GetRecurrence() == "") {
+		// a rule handed on since stays with its occurrence, and a revert
+		// completing the task hands on the rule it has now
+		task.SetRecurrence(current[0].GetRecurrence())
+	}
+	// the history read above must still be that of the task changed
+	if version == 0 {
+		version = current[0].GetVersion()
+	}
+	task.SetVersion(version)
+	if task.GetParentId() != current[0].GetParentId() {
+		if err = validateParent(caller, task, id); err != nil {
+			logger.Error(fmt.Sprintf("Error validating parent of task with id=%s: %s", id, err))
+			return c.SendStatus(getParentErrorStatus(err))
+		}
+	}
+	if task.GetListId() != current[0].GetListId() {
+		if err = validateList(caller, task); err != nil {
+			logger.Error(fmt.Sprintf("Error validating list of task with id=%s: %s", id, err))
+			return c.SendStatus(getListErrorStatus(err))
+		}
+	}
+	if err = checkStatusUpdate(&task, current[0].GetStatus()); err != nil {
+		return sendUnprocessable(c, err)
+	}
+	if err = checkBlockers(caller, id, task.GetStatus(), current[0].GetStatus()); err == errBlocked {
+		return sendBlocked(c, id)
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error checking blockers of task with id=%s: %s", id, err))
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	next, err := getNextOccurrence(caller, &task, current[0])
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error scheduling next occurrence of task with id=%s: %s", id, err))
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+	fields := domain.ChangedFields(current[0], task)
+	var nextId int64
+	if len(fields) > 0 {
+		revision := newRevision(caller, domain.HistoryRevert)
+		revision.RevertedTo = revisionVersion
+		nextId, err = taskRepository.PatchTask(caller, task, id, fields, next, revision)
+		task.SetVersion(current[0].GetVersion() + 1)
+	} else {
+		task.SetVersion(current[0].GetVersion())
+	}
+	if err == nil {
+		addNextOccurrence(&task, next, nextId)
+		logger.Info(fmt.Sprintf("Reverted fields %v of task with id: %s to version %d", fields, id, revisionVersion))
+		setETag(c, task)
+		return c.JSON(task)
+	}
+	if err == repository.ErrVersionConflict {
+		logger.Info(fmt.Sprintf("Task with id=%s changed while reverting it", id))
+		return c.SendStatus(http.StatusPreconditionFailed)
+	}
+	if err == repository.ErrTaskNotFound {
+		logger.Info(fmt.Sprintf("Task with id=%s was deleted while reverting it", id))
+		return c.SendStatus(http.StatusNotFound)
+	}
+
+	logger.Error(fmt.Sprintf("Error while reverting task with id=%s: %s", id, err))
+	return c.SendStatus(http.StatusInternalServerError)
+}
+
+// newRevision starts the revision of a change by caller, which the repository
+// completes with the change itself when storing it.
+func newRevision(caller domain.Identity, action string) domain.TaskRevision {
+	return domain.TaskRevision{Action: action, ActorId: caller.UserId, Actor: caller.Username,
+		ChangedOn: toMillis(time.Now())}
+}
+
+// getRevertedTask is current with the TaskFields of snapshot.
+func getRevertedTask(current domain.Task, snapshot domain.Task) domain.Task {
+	task := snapshot
+	task.SetId(current.GetId())
+	task.SetOwnerId(current.GetOwnerId())
+	task.SetTenantId(current.GetTenantId())
+	task.SetDeletedOn(0)
+	if task.GetTags() == nil {
+		task.SetTags([]string{})
+	}
+	return task
+}
+
+// isKnownTask reports whether the task with id is visible to caller, either
+// live or in the trash.
+func isKnownTask(caller domain.Identity, id string) (bool, error) {
+	tasks, err := taskRepository.GetTaskById(caller, id)
+	if err == nil && len(tasks) == 0 {
+		tasks, err = trashRepository.GetTrashedTask(caller, id)
+	}
+	return len(tasks) > 0, err
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"my-todo-app/domain"
+	"my-todo-app/repository"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	store, _ := repository.NewRepository(repository.MemoryDriver, "")
+	SetRepository(store)
+	app := fiber.New()
+	app.Post("/task", CreateTaskHandler)
+	app.Patch("/task/:id", PatchTaskByIdHandler)
+	app.Delete("/task/:id", DeleteTaskByIdHandler)
+	app.Post("/task/:id/transition/:action", TransitionTaskHandler)
+	app.Get("/task/:id/history", GetTaskHistoryHandler)
+	app.Post("/task/:id/history/:version/revert", RevertTaskHandler)
+
+	request := func(method string, url string, body string, ifMatch string) *http.Response {
+		req := httptest.NewRequest(method, "http://localhost.com"+url, bytes.NewBufferString(body))
+		if ifMatch != "" {
+			req.Header.Set(fiber.HeaderIfMatch, ifMatch)
+		}
+		response, _ := app.Test(req)
+		return response
+	}
+	getHistory := func(url string) []domain.TaskRevision {
+		var revisions []domain.TaskRevision
+		_ = json.NewDecoder(request("GET", url, "", "").Body).Decode(&revisions)
+		return revisions
+	}
+	getActions := func(revisions []domain.TaskRevision) []string {
+		actions := []string{}
+		for _, revision := range revisions {
+			actions = append(actions, revision.Action)
+		}
+		return actions
+	}
+
+	request("POST", "/task", `{"title": "draft", "status": "open", "tags": ["work"]}`, "")
+	request("PATCH", "/task/1", `{"title": "final", "tags": null}`, "*")
+	request("POST", "/task/1/transition/start", "", "")
+
+	t.Run("should record every change with a field diff", func(t *testing.T) {
+		revisions := getHistory("/task/1/history")
+		expected := []string{domain.HistoryTransition, domain.HistoryUpdate, domain.HistoryCreate}
+		if actions := getActions(revisions); !reflect.DeepEqual(actions, expected) {
+			t.Fatalf("Expected actions: %v, Got: %v", expected, actions)
+		}
+		changes, _ := json.Marshal(revisions[1].Changes)
+		expectedChanges := `[{"field":"title","before":"draft","after":"final"},{"field":"tags","before":["work"],"after":[]}]`
+		if string(changes) != expectedChanges || revisions[1].Version != 2 || revisions[1].ChangedOn == 0 {
+			t.Errorf("Expected changes: %s, Got: %s in %v", expectedChanges, changes, revisions[1])
+		}
+		if revisions = getHistory("/task/1/history?page=1&perPage=2"); len(revisions) != 1 || revisions[0].Version != 1 {
+			t.Errorf("Expected the creation on page 2, Got: %v", revisions)
+		}
+		compareResponses(t, http.StatusNotFound, nil, request("GET", "/task/9/history", "", ""))
+	})
+
+	t.Run("should revert a task to an earlier revision", func(t *testing.T) {
+		compareResponses(t, http.StatusPreconditionRequired, nil, request("POST", "/task/1/history/1/revert", "", ""))
+		compareResponses(t, http.StatusPreconditionFailed, nil, request("POST", "/task/1/history/1/revert", "", `"2"`))
+		compareResponses(t, http.StatusNotFound, nil, request("POST", "/task/1/history/9/revert", "", `"3"`))
+		compareResponses(t, http.StatusBadRequest, nil, request("POST", "/task/1/history/first/revert", "", `"3"`))
+
+		response := request("POST", "/task/1/history/1/revert", "", `"3"`)
+		var task domain.Task
+		_ = json.NewDecoder(response.Body).Decode(&task)
+		if response.StatusCode != http.StatusOK || task.GetTitle() != "draft" || task.GetStatus() != "open" ||
+			!reflect.DeepEqual(task.GetTags(), []string{"work"}) || response.Header.Get(fiber.HeaderETag) != `"4"` {
+			t.Errorf("Expected task 1 as it was created, Got: %d %v", response.StatusCode, task)
+		}
+
+		revisions := getHistory("/task/1/history")
+		if revisions[0].Action != domain.HistoryRevert || revisions[0].RevertedTo != 1 || revisions[0].Version != 4 ||
+			len(revisions[0].Changes) != 3 {
+			t.Errorf("Expected the revert to be recorded, Got: %v", revisions[0])
+		}
+	})
+
+	t.Run("should keep the history of a task in the trash", func(t *testing.T) {
+		compareResponses(t, http.StatusNoContent, nil, request("DELETE", "/task/1", "", "*"))
+		revisions := getHistory("/task/1/history")
+		if len(revisions) != 5 || revisions[0].Action != domain.HistoryDelete || len(revisions[0].Changes) != 0 {
+			t.Errorf("Expected the deletion to be recorded, Got: %v", revisions)
+		}
+		compareResponses(t, http.StatusNotFound, nil, request("POST", "/task/1/history/1/revert", "", "*"))
+	})
+
+	t.Run("should revert recurring tasks without repeating occurrences", func(t *testing.T) {
+		revert := func(url string, ifMatch string) domain.Task {
+			var task domain.Task
+			response := request("POST", url, "", ifMatch)
+			_ = json.NewDecoder(response.Body).Decode(&task)
+			if response.StatusCode != http.StatusOK {
+				t.Errorf("Expected status code: %d, Got: %d", http.StatusOK, response.StatusCode)
+			}
+			return task
+		}
+		request("POST", "/task", `{"title": "standup", "status": "open", "due_by": 1614589200000, "recurrence": "FREQ=DAILY"}`, "")
+		request("POST", "/task/2/transition/complete", "", "")
+		request("POST", "/task/2/transition/reopen", "", "")
+
+		if task := revert("/task/2/history/1/revert", `"3"`); task.GetRecurrence() != "" || task.GetStatus() != "open" {
+			t.Errorf("Expected the rule handed on to task 3 to stay there, Got: %v", task)
+		}
+
+		request("PATCH", "/task/2", `{"recurrence": "FREQ=WEEKLY"}`, "*")
+		task := revert("/task/2/history/2/revert", `"4"`)
+		if task.GetStatus() != "done" || task.GetRecurrence() != "" || task.GetNextOccurrenceId() != 4 {
+			t.Errorf("Expected the completing revert to hand the rule on to task 4, Got: %v", task)
+		}
+		tasks, _ := store.GetTaskById(domain.Identity{}, "4")
+		if len(tasks) != 1 || tasks[0].GetRecurrence() != "FREQ=WEEKLY" {
+			t.Errorf("Expected the next occurrence to take the rule over, Got: %v", tasks)
+		}
+	})
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"my-todo-app/config"
+	"my-todo-app/domain"
+	"net/http"
+	"time"
+)
+
+const (
+	headerIdempotencyKey     = "Idempotency-Key"
+	headerIdempotentReplayed = "Idempotent-Replayed"
+)
+
+var (
+	errInvalidIdempotencyKey = fmt.Errorf("%s takes 1 to %d printable ASCII characters",
+		headerIdempotencyKey, domain.MaxIdempotencyKeyLength)
+	errIdempotencyKeyReused = errors.New("Idempotency-Key was already used for another request")
+	errIdempotencyKeyInUse  = errors.New("a request with this Idempotency-Key is still being handled")
+)
+
+var idempotencyTtl time.Duration
+
+func init() {
+	idempotencyTtl = config.IdempotencyTtl
+}
+
+// IdempotencyMiddleware makes the handlers registered after it safe to retry.
+// The response to a request sent with an Idempotency-Key header is stored
+// for app.idempotency.ttl and replayed to the retries of that request, with
+// an Idempotent-Replayed header. Reusing a key for another method, path or
+// body answers 409, as does a retry while the first request is still being
+// handled. Server errors are not kept, so the request may be retried. A
+// response that fails to be stored is kept without its body, or, failing
+// that, its key stays in progress until it expires.
+func IdempotencyMiddleware(c *fiber.Ctx) error {
+	key := c.Get(headerIdempotencyKey)
+	if key == "" {
+		return c.Next()
+	}
+	if !domain.IsIdempotencyKey(key) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": errInvalidIdempotencyKey.Error()})
+	}
+
+	caller := getCaller(c)
+	now := time.Now()
+	record := domain.IdempotencyRecord{
+		Key:         key,
+		RequestHash: hashRequest(c),
+		AddedOn:     toMillis(now),
+		ExpiresOn:   toMillis(now.Add(idempotencyTtl)),
+	}
+	reserved, err := idempotencyRepository.ReserveIdempotencyKey(caller, record)
+	if err != nil {
+		// a retry sent at the same time may have reserved the key first
+		if stored, getErr := idempotencyRepository.GetIdempotencyRecord(caller, key); getErr == nil && len(stored) > 0 {
+			reserved, err = false, nil
+		}
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error reserving idempotency key for user %s: %s", caller.Username, err))
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+	if !reserved {
+		return replay(c, caller, record)
+	}
+
+	if err = c.Next(); err != nil || c.Response().StatusCode() >= http.StatusInternalServerError {
+		if releaseErr := idempotencyRepository.ReleaseIdempotencyKey(caller, key); releaseErr != nil {
+			logger.Error(fmt.Sprintf("Error releasing idempotency key for user %s: %s", caller.Username, releaseErr))
+		}
+		return err
+	}
+
+	record.StatusCode = c.Response().StatusCode()
+	record.ContentType = string(c.Response().Header.ContentType())
+	record.ETag = string(c.Response().Header.Peek(fiber.HeaderETag))
+	record.Body = string(c.Response().Body())
+	if err = idempotencyRepository.CompleteIdempotencyKey(caller, record); err != nil {
+		// the request was handled, so releasing the key would let a retry
+		// handle it twice: keep at least its status and ETag, or else leave the
+		// key in progress until it expires
+		logger.Error(fmt.Sprintf("Error storing idempotent response for user %s: %s", caller.Username, err))
+		record.ContentType, record.Body = "", ""
+		if err = idempotencyRepository.CompleteIdempotencyKey(caller, record); err != nil {
+			logger.Error(fmt.Sprintf("Error storing idempotent status for user %s: %s", caller.Username, err))
+		}
+	}
+	return nil
+}
+
+// replay answers a retry with the stored response to the request that
+// reserved its key.
+func replay(c *fiber.Ctx, caller domain.Identity, request domain.IdempotencyRecord) error {
+	stored, err := idempotencyRepository.GetIdempotencyRecord(caller, request.Key)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error reading idempotency key for user %s: %s", caller.Username, err))
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+	switch {
+	case len(stored) == 0:
+		// released by a failed first request in the meantime
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": errIdempotencyKeyInUse.Error()})
+	case stored[0].RequestHash != request.RequestHash:
+		logger.Info(fmt.Sprintf("Rejected %s %s of user %s: %s", c.Method(), c.Path(), caller.Username, errIdempotencyKeyReused))
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": errIdempotencyKeyReused.Error()})
+	case stored[0].StatusCode == 0:
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": errIdempotencyKeyInUse.Error()})
+	}
+
+	c.Set(headerIdempotentReplayed, "true")
+	if stored[0].ContentType != "" {
+		c.Set(fiber.HeaderContentType, stored[0].ContentType)
+	}
+	if stored[0].ETag != "" {
+		c.Set(fiber.HeaderETag, stored[0].ETag)
+	}
+	return c.Status(stored[0].StatusCode).SendString(stored[0].Body)
+}
+
+// hashRequest fingerprints the method, path and body of a request, telling
+// a retry apart from another request reusing its key.
+func hashRequest(c *fiber.Ctx) string {
+	hash := sha256.New()
+	hash.Write([]byte(c.Method() + " " + c.Path() + "\n"))
+	hash.Write(c.Body())
+	return hex.EncodeToString(hash.Sum(nil))
+}
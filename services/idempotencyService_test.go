@@ -0,0 +1,126 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"my-todo-app/domain"
+	"my-todo-app/repository"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	store, _ := repository.NewRepository(repository.MemoryDriver, "")
+	SetRepository(store)
+	app := fiber.New()
+	app.Post("/task", IdempotencyMiddleware, CreateTaskHandler)
+	app.Get("/tasks", GetAllTasksHandler)
+
+	create := func(key string, body string) *http.Response {
+		req := httptest.NewRequest("POST", "http://localhost.com/task", bytes.NewBufferString(body))
+		if key != "" {
+			req.Header.Set(headerIdempotencyKey, key)
+		}
+		response, _ := app.Test(req)
+		return response
+	}
+	countTasks := func() int {
+		response, _ := app.Test(httptest.NewRequest("GET", "http://localhost.com/tasks?perPage=100", nil))
+		return strings.Count(getStringFromResponseBody(response.Body), `"title"`)
+	}
+	body := `{"title": "sample", "status": "open"}`
+
+	t.Run("should replay the response to a retry", func(t *testing.T) {
+		expected := domain.Task{Id: 1, Title: "sample", Status: "open", Priority: "P2", Version: 1}
+		first := create("retry-1", body)
+		compareResponses(t, http.StatusOK, expected, first)
+
+		retry := create("retry-1", body)
+		if retry.Header.Get(headerIdempotentReplayed) != "true" || retry.Header.Get(fiber.HeaderETag) != `"1"` ||
+			retry.Header.Get(fiber.HeaderContentType) != fiber.MIMEApplicationJSON {
+			t.Errorf("Expected a replayed response, Got headers: %v", retry.Header)
+		}
+		compareResponses(t, http.StatusOK, expected, retry)
+		if count := countTasks(); count != 1 {
+			t.Errorf("Expected 1 task, Got: %d", count)
+		}
+	})
+
+	t.Run("should reject a key reused for another request", func(t *testing.T) {
+		response := create("retry-1", `{"title": "another", "status": "open"}`)
+		if response.StatusCode != http.StatusConflict || !strings.Contains(getStringFromResponseBody(response.Body), "already used") {
+			t.Errorf("Expected status code: %d, Got: %d", http.StatusConflict, response.StatusCode)
+		}
+	})
+
+	t.Run("should reject invalid keys", func(t *testing.T) {
+		compareResponses(t, http.StatusBadRequest, nil, create(strings.Repeat("k", domain.MaxIdempotencyKeyLength+1), body))
+	})
+
+	t.Run("should create a task per request without a key", func(t *testing.T) {
+		create("", body)
+		create("", body)
+		if count := countTasks(); count != 3 {
+			t.Errorf("Expected 3 tasks, Got: %d", count)
+		}
+	})
+
+	t.Run("should keep the status of responses whose body fails to be stored", func(t *testing.T) {
+		defer func(stored repository.IdempotencyRepository) { idempotencyRepository = stored }(idempotencyRepository)
+		idempotencyRepository = failingIdempotencyRepository{IdempotencyRepository: idempotencyRepository}
+
+		create("retry-3", body)
+		response := create("retry-3", body)
+		if response.StatusCode != http.StatusOK || response.Header.Get(headerIdempotentReplayed) != "true" ||
+			response.Header.Get(fiber.HeaderETag) != `"1"` {
+			t.Errorf("Expected the status to be replayed, Got status code: %d, headers: %v", response.StatusCode, response.Header)
+		}
+		if count := countTasks(); count != 4 {
+			t.Errorf("Expected 4 tasks, Got: %d", count)
+		}
+	})
+
+	t.Run("should keep keys whose response fails to be stored in progress", func(t *testing.T) {
+		defer func(stored repository.IdempotencyRepository) { idempotencyRepository = stored }(idempotencyRepository)
+		idempotencyRepository = failingIdempotencyRepository{IdempotencyRepository: idempotencyRepository, failStatus: true}
+
+		create("retry-4", body)
+		if response := create("retry-4", body); response.StatusCode != http.StatusConflict {
+			t.Errorf("Expected status code: %d, Got: %d", http.StatusConflict, response.StatusCode)
+		}
+		if count := countTasks(); count != 5 {
+			t.Errorf("Expected 5 tasks, Got: %d", count)
+		}
+	})
+
+	t.Run("should expire stored responses", func(t *testing.T) {
+		defer func(ttl time.Duration) { idempotencyTtl = ttl }(idempotencyTtl)
+		idempotencyTtl = 0
+
+		create("retry-2", body)
+		if response := create("retry-2", body); response.StatusCode != http.StatusOK || response.Header.Get(headerIdempotentReplayed) != "" {
+			t.Errorf("Expected the expired key to create another task, Got status code: %d", response.StatusCode)
+		}
+		if count := countTasks(); count != 7 {
+			t.Errorf("Expected 7 tasks, Got: %d", count)
+		}
+	})
+}
+
+// failingIdempotencyRepository reserves keys but fails to store response
+// bodies, and with failStatus any response at all.
+type failingIdempotencyRepository struct {
+	repository.IdempotencyRepository
+	failStatus bool
+}
+
+func (f failingIdempotencyRepository) CompleteIdempotencyKey(caller domain.Identity, record domain.IdempotencyRecord) error {
+	if record.Body != "" || f.failStatus {
+		return errors.New("response too large")
+	}
+	return f.IdempotencyRepository.CompleteIdempotencyKey(caller, record)
+}
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"my-todo-app/domain"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxListNameLength = 255
+
+var errInvalidList = errors.New("list does not exist, or is not shared with the caller")
+
+func CreateListHandler(c *fiber.Ctx) error {
+	caller := getCaller(c)
+	list, err := parseList(c.Body())
+	if err != nil {
+		logger.Error(fmt.Sprintf("Invalid list: %s", err))
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	list.OwnerId = caller.UserId
+	list.AddedOn = toMillis(time.Now())
+	list.Id, err = listRepository.CreateList(caller, list)
+	if err == nil {
+		logger.Info(fmt.Sprintf("Created list with id: %d", list.GetId()))
+		return c.Status(http.StatusCreated).JSON(list)
+	}
+
+	logger.Error(fmt.Sprintf("Error creating list: %s", err))
+	return c.SendStatus(http.StatusInternalServerError)
+}
+
+// GetListsHandler lists every list the caller owns or is a member of.
+func GetListsHandler(c *fiber.Ctx) error {
+	lists, err := listRepository.GetLists(getCaller(c))
+	if err == nil {
+		logger.Info(fmt.Sprintf("No. of lists fetched: %d", len(lists)))
+		return c.JSON(lists)
+	}
+
+	logger.Error(fmt.Sprintf("Error fetching lists: %s", err))
+	return c.SendStatus(http.StatusInternalServerError)
+}
+
+func GetListByIdHandler(c *fiber.Ctx) error {
+	id := c.Params("id")
+	lists, err := listRepository.GetListById(getCaller(c), id)
+	if err == nil && len(lists) == 0 {
+		logger.Info(fmt.Sprintf("No list found with id: %s", id))
+		return c.SendStatus(http.StatusNotFound)
+	}
+	if err == nil {
+		return c.JSON(lists[0])
+	}
+
+	logger.Error(fmt.Sprintf("Error fetching list with id=%s: %s", id, err))
+	return c.SendStatus(http.StatusInternalServerError)
+}
+
+// UpdateListHandler renames a list, for its owner only.
+func UpdateListHandler(c *fiber.Ctx) error {
+	id := c.Params("id")
+	caller := getCaller(c)
+	update, err := parseList(c.Body())
+	if err != nil {
+		logger.Error(fmt.Sprintf("Invalid update of list with id=%s: %s", id, err))
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	list, status := getOwnedList(caller, id)
+	if status != http.StatusOK {
+		return c.SendStatus(status)
+	}
+
+	list.Name = update.GetName()
+	err = listRepository.UpdateList(caller, list, id)
+	if err == nil {
+		return c.JSON(list)
+	}
+
+	logger.Error(fmt.Sprintf("Error updating list with id=%s: %s", id, err))
+	return c.SendStatus(http.StatusInternalServerError)
+}
+
+// DeleteListHandler removes a list, for its owner only. Its tasks are kept.
+func DeleteListHandler(c *fiber.Ctx) error {
+	id := c.Params("id")
+	caller := getCaller(c)
+	_, status := getOwnedList(caller, id)
+	if status != http.StatusOK {
+		return c.SendStatus(status)
+	}
+
+	deleted, err := listRepository.DeleteList(caller, id)
+	if err == nil && deleted {
+		logger.Info(fmt.Sprintf("Deleted list with id: %s", id))
+		return c.SendStatus(http.StatusNoContent)
+	}
+	if err == nil {
+		return c.SendStatus(http.StatusNotFound)
+	}
+
+	logger.Error(fmt.Sprintf("Error deleting list with id=%s: %s", id, err))
+	return c.SendStatus(http.StatusInternalServerError)
+}
+
+// GetListTasksHandler pages through the tasks of a list like GetAllTasksHandler.
+func GetListTasksHandler(c *fiber.Ctx) error {
+	id := c.Params("id")
+	caller := getCaller(c)
+	page, perPage, sort, err := getPagination(c)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Invalid sort for tasks of list %s: %s", id, err))
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	lists, err := listRepository.GetListById(caller, id)
+	if err == nil && len(lists) == 0 {
+		logger.Info(fmt.Sprintf("No list found with id: %s", id))
+		return c.SendStatus(http.StatusNotFound)
+	}
+
+	var tasks []domain.Task
+	if err == nil {
+		tasks, err = listRepository.GetListTasks(caller, id, page, perPage, sort)
+	}
+	if err == nil {
+		logger.Info(fmt.Sprintf("No. of tasks fetched for list %s: %d", id, len(tasks)))
+		return c.JSON(tasks)
+	}
+
+	logger.Error(fmt.Sprintf("Error fetching tasks of list with id=%s: %s", id, err))
+	return c.SendStatus(http.StatusInternalServerError)
+}
+
+// AddListMemberHandler shares a list with the user named in the body, for its owner only.
+func AddListMemberHandler(c *fiber.Ctx) error {
+	id := c.Params("id")
+	caller := getCaller(c)
+	var member domain.ListMember
+	if err := json.Unmarshal(c.Body(), &member); err != nil || strings.TrimSpace(member.Username) == "" {
+		logger.Error(fmt.Sprintf("Invalid member for list with id=%s: %v", id, err))
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	list, status := getOwnedList(caller, id)
+	if status != http.StatusOK {
+		return c.SendStatus(status)
+	}
+
+	// users of other tenants are as unknown as users that do not exist
+	users, err := userRepository.GetUserByName(caller.TenantId, domain.NormalizeUsername(member.Username))
+	if err == nil && len(users) == 0 {
+		logger.Info(fmt.Sprintf("No user found with name: %s", member.Username))
+		return c.SendStatus(http.StatusBadRequest)
+	}
+	if err == nil {
+		for _, existing := range list.Members {
+			if existing.UserId == users[0].GetId() {
+				logger.Info(fmt.Sprintf("User %s is already a member of list %s", existing.Username, id))
+				return c.SendStatus(http.StatusConflict)
+			}
+		}
+
+		member = domain.ListMember{UserId: users[0].GetId(), Username: users[0].GetUsername(), AddedOn: toMillis(time.Now())}
+		_, err = listRepository.AddListMember(caller, id, member.UserId, member.AddedOn)
+	}
+	if err == nil {
+		logger.Info(fmt.Sprintf("Shared list with id: %s with user: %s", id, member.Username))
+		return c.Status(http.StatusCreated).JSON(member)
+	}
+
+	logger.Error(fmt.Sprintf("Error sharing list with id=%s: %s", id, err))
+	return c.SendStatus(http.StatusInternalServerError)
+}
+
+// RemoveListMemberHandler stops sharing a list. Owners remove other members,
+// members remove themselves to leave the list.
+func RemoveListMemberHandler(c *fiber.Ctx) error {
+	id := c.Params("id")
+	caller := getCaller(c)
+	userId, err := strconv.ParseInt(c.Params("userId"), 10, 64)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Invalid member id for list with id=%s: %s", id, err))
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	lists, err := listRepository.GetListById(caller, id)
+	if err == nil && len(lists) == 0 {
+		logger.Info(fmt.Sprintf("No list found with id: %s", id))
+		return c.SendStatus(http.StatusNotFound)
+	}
+	if err == nil {
+		switch owner := lists[0].GetOwnerId(); {
+		case userId == owner:
+			logger.Info(fmt.Sprintf("Refusing to remove the owner of list %s", id))
+			return c.SendStatus(http.StatusConflict)
+		case caller.UserId != owner && caller.UserId != userId:
+			logger.Info(fmt.Sprintf("User %s may not remove members of list %s", caller.Username, id))
+			return c.SendStatus(http.StatusForbidden)
+		}
+	}
+
+	removed := false
+	if err == nil {
+		removed, err = listRepository.RemoveListMember(caller, id, userId)
+	}
+	if err == nil && !removed {
+		logger.Info(fmt.Sprintf("User %d is no member of list %s", userId, id))
+		return c.SendStatus(http.StatusNotFound)
+	}
+	if err == nil {
+		logger.Info(fmt.Sprintf("Removed user %d from list with id: %s", userId, id))
+		return c.SendStatus(http.StatusNoContent)
+	}
+
+	logger.Error(fmt.Sprintf("Error removing member of list with id=%s: %s", id, err))
+	return c.SendStatus(http.StatusInternalServerError)
+}
+
+// getOwnedList fetches the list with id, answering with the status to send
+// unless it is found and owned by caller: 404 for lists caller is no member
+// of and 403 for lists shared with caller by someone else.
+func getOwnedList(caller domain.Identity, id string) (domain.List, int) {
+	lists, err := listRepository.GetListById(caller, id)
+	switch {
+	case err != nil:
+		logger.Error(fmt.Sprintf("Error fetching list with id=%s: %s", id, err))
+		return domain.List{}, http.StatusInternalServerError
+	case len(lists) == 0:
+		logger.Info(fmt.Sprintf("No list found with id: %s", id))
+		return domain.List{}, http.StatusNotFound
+	case lists[0].GetOwnerId() != caller.UserId:
+		logger.Info(fmt.Sprintf("User %s may not change list %s", caller.Username, id))
+		return domain.List{}, http.StatusForbidden
+	}
+	return lists[0], http.StatusOK
+}
+
+// validateList checks that caller is a member of the list task is put in.
+func validateList(caller domain.Identity, task domain.Task) error {
+	if task.GetListId() == 0 {
+		return nil
+	}
+
+	lists, err := listRepository.GetListById(caller, strconv.FormatInt(task.GetListId(), 10))
+	if err == nil && len(lists) == 0 {
+		err = errInvalidList
+	}
+	return err
+}
+
+func getListErrorStatus(err error) int {
+	if err == errInvalidList {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func parseList(body []byte) (domain.List, error) {
+	var list domain.List
+	err := json.Unmarshal(body, &list)
+	list.Name = strings.TrimSpace(list.Name)
+	if err == nil && (list.Name == "" || len(list.Name) > maxListNameLength) {
+		err = fmt.Errorf("name must be 1 to %d characters", maxListNameLength)
+	}
+	return list, err
+}
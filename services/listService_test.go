@@ -0,0 +1,122 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"my-todo-app/domain"
+	"my-todo-app/repository"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestSharedLists(t *testing.T) {
+	store, _ := repository.NewRepository(repository.MemoryDriver, "")
+	SetRepository(store)
+	users := map[string]domain.User{}
+	for _, username := range []string{"alice", "bob", "carol"} {
+		user, _ := domain.NewUser(username, "correct horse", 0)
+		user.Id, _ = store.CreateUser(user)
+		users[username] = user
+	}
+
+	app := fiber.New()
+	app.Use(AuthMiddleware)
+	app.Get("/lists", GetListsHandler)
+	app.Get("/lists/:id", GetListByIdHandler)
+	app.Get("/lists/:id/tasks", GetListTasksHandler)
+	app.Post("/lists", CreateListHandler)
+	app.Put("/lists/:id", UpdateListHandler)
+	app.Delete("/lists/:id", DeleteListHandler)
+	app.Post("/lists/:id/members", AddListMemberHandler)
+	app.Delete("/lists/:id/members/:userId", RemoveListMemberHandler)
+	app.Post("/task", CreateTaskHandler)
+	app.Get("/task/:id", GetTaskByIdHandler)
+
+	request := func(method string, url string, body string, username string) *http.Response {
+		req := httptest.NewRequest(method, "http://localhost.com"+url, bytes.NewBufferString(body))
+		req.SetBasicAuth(username, "correct horse")
+		response, _ := app.Test(req, -1)
+		return response
+	}
+
+	response := request("POST", "/lists", `{"name": " Sprint 12 "}`, "alice")
+	var list domain.List
+	_ = json.NewDecoder(response.Body).Decode(&list)
+	if response.StatusCode != http.StatusCreated || list.GetName() != "Sprint 12" || list.GetOwnerId() != users["alice"].Id {
+		t.Fatalf("Expected list to be created, Got: %d with %v", response.StatusCode, list)
+	}
+	url := "/lists/" + strconv.FormatInt(list.GetId(), 10)
+	bobId := strconv.FormatInt(users["bob"].Id, 10)
+
+	for i := 1; i <= 3; i++ {
+		body := `{"title": "task ` + strconv.Itoa(i) + `", "list_id": ` + strconv.FormatInt(list.GetId(), 10) + `}`
+		compareResponses(t, http.StatusOK, domain.Task{Id: int64(i), Title: "task " + strconv.Itoa(i), Status: "open", Priority: "P2",
+			OwnerId: users["alice"].Id, ListId: list.GetId()}, request("POST", "/task", body, "alice"))
+	}
+
+	t.Run("should reject invalid lists", func(t *testing.T) {
+		compareResponses(t, http.StatusBadRequest, nil, request("POST", "/lists", `{"name": " "}`, "alice"))
+		compareResponses(t, http.StatusBadRequest, nil, request("POST", "/lists", `{"name"`, "alice"))
+	})
+
+	t.Run("should hide lists from non-members", func(t *testing.T) {
+		compareResponses(t, http.StatusOK, []domain.List{}, request("GET", "/lists", "", "bob"))
+		compareResponses(t, http.StatusNotFound, nil, request("GET", url+"/tasks", "", "bob"))
+		compareResponses(t, http.StatusNotFound, nil, request("GET", "/task/1", "", "bob"))
+		compareResponses(t, http.StatusBadRequest, nil, request("POST", "/task", `{"title": "sneaky", "list_id": 1}`, "bob"))
+		compareResponses(t, http.StatusNotFound, nil, request("POST", url+"/members", `{"username": "bob"}`, "bob"))
+	})
+
+	t.Run("should share lists with members", func(t *testing.T) {
+		response := request("POST", url+"/members", `{"username": "BOB"}`, "alice")
+		var member domain.ListMember
+		_ = json.NewDecoder(response.Body).Decode(&member)
+		if response.StatusCode != http.StatusCreated || member.UserId != users["bob"].Id || member.Username != "bob" {
+			t.Errorf("Expected bob to be added, Got: %d with %v", response.StatusCode, member)
+		}
+
+		compareResponses(t, http.StatusConflict, nil, request("POST", url+"/members", `{"username": "bob"}`, "alice"))
+		compareResponses(t, http.StatusBadRequest, nil, request("POST", url+"/members", `{"username": "mallory"}`, "alice"))
+		compareResponses(t, http.StatusForbidden, nil, request("POST", url+"/members", `{"username": "carol"}`, "bob"))
+	})
+
+	t.Run("should page through tasks of a list", func(t *testing.T) {
+		response := request("GET", url+"/tasks?page=1&perPage=2&sort=-id", "", "bob")
+		var tasks []domain.Task
+		_ = json.NewDecoder(response.Body).Decode(&tasks)
+		if response.StatusCode != http.StatusOK || len(tasks) != 1 || tasks[0].GetId() != 1 {
+			t.Errorf("Expected task 1 on the second page, Got: %d with %v", response.StatusCode, tasks)
+		}
+
+		compareResponses(t, http.StatusBadRequest, nil, request("GET", url+"/tasks?sort=owner", "", "bob"))
+	})
+
+	t.Run("should only let owners change lists", func(t *testing.T) {
+		compareResponses(t, http.StatusForbidden, nil, request("PUT", url, `{"name": "Mine"}`, "bob"))
+		compareResponses(t, http.StatusForbidden, nil, request("DELETE", url, "", "bob"))
+		compareResponses(t, http.StatusConflict, nil, request("DELETE", url+"/members/"+strconv.FormatInt(users["alice"].Id, 10), "", "bob"))
+
+		response := request("PUT", url, `{"name": "Sprint 13"}`, "alice")
+		var renamed domain.List
+		_ = json.NewDecoder(response.Body).Decode(&renamed)
+		if response.StatusCode != http.StatusOK || renamed.GetName() != "Sprint 13" {
+			t.Errorf("Expected list to be renamed, Got: %d with %v", response.StatusCode, renamed)
+		}
+	})
+
+	t.Run("should let members leave", func(t *testing.T) {
+		compareResponses(t, http.StatusNoContent, nil, request("DELETE", url+"/members/"+bobId, "", "bob"))
+		compareResponses(t, http.StatusNotFound, nil, request("DELETE", url+"/members/"+bobId, "", "alice"))
+		compareResponses(t, http.StatusNotFound, nil, request("GET", url, "", "bob"))
+	})
+
+	t.Run("should keep tasks of deleted lists", func(t *testing.T) {
+		compareResponses(t, http.StatusNoContent, nil, request("DELETE", url, "", "alice"))
+		compareResponses(t, http.StatusNotFound, nil, request("GET", url, "", "alice"))
+		compareResponses(t, http.StatusOK, domain.Task{Id: 1, Title: "task 1", Status: "open", Priority: "P2",
+			OwnerId: users["alice"].Id}, request("GET", "/task/1", "", "alice"))
+	})
+}
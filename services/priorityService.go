@@ -0,0 +1,49 @@
+package services
+
+import (
+	"fmt"
+	"my-todo-app/domain"
+	"strings"
+)
+
+// setNormalizedPriority uppercases the priority of task, falling back to
+// fallback when it is left out.
+func setNormalizedPriority(task *domain.Task, fallback string) error {
+	priority := strings.ToUpper(strings.TrimSpace(task.GetPriority()))
+	if priority == "" {
+		priority = fallback
+	}
+	if priority == "" {
+		priority = domain.DefaultPriority
+	}
+	if !isPriority(priority) {
+		return fmt.Errorf("unknown priority %q, expected one of %v", task.GetPriority(), domain.Priorities)
+	}
+	task.SetPriority(priority)
+	return nil
+}
+
+// getPriorityQuery normalizes a comma separated list of priorities to filter by.
+func getPriorityQuery(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	priorities := strings.Split(strings.ToUpper(value), ",")
+	for i, priority := range priorities {
+		priorities[i] = strings.TrimSpace(priority)
+		if !isPriority(priorities[i]) {
+			return "", fmt.Errorf("unknown priority %q, expected one of %v", priority, domain.Priorities)
+		}
+	}
+	return strings.Join(priorities, ","), nil
+}
+
+func isPriority(priority string) bool {
+	for _, known := range domain.Priorities {
+		if known == priority {
+			return true
+		}
+	}
+	return false
+}
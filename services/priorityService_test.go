@@ -0,0 +1,97 @@
+package services
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"my-todo-app/domain"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestSetNormalizedPriority(t *testing.T) {
+	scenarios := []struct {
+		name     string
+		priority string
+		fallback string
+		expected string
+		valid    bool
+	}{
+		{"should uppercase priority", " p1", domain.DefaultPriority, "P1", true},
+		{"should fall back when left out", "", "P0", "P0", true},
+		{"should default without fallback", "", "", domain.DefaultPriority, true},
+		{"should reject unknown priority", "P4", domain.DefaultPriority, "", false},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			task := domain.Task{Priority: scenario.priority}
+			err := setNormalizedPriority(&task, scenario.fallback)
+			if (err == nil) != scenario.valid {
+				t.Fatalf("Expected valid: %t, Got error: %v", scenario.valid, err)
+			}
+			if scenario.valid && task.GetPriority() != scenario.expected {
+				t.Errorf("Expected: %s, Got: %s", scenario.expected, task.GetPriority())
+			}
+		})
+	}
+}
+
+func TestSearchHandlerPriorityAndSort(t *testing.T) {
+	taskRepository = taskRepositoryMock{}
+	app := fiber.New()
+	app.Get("/tasks/search", SearchHandler)
+
+	scenarios := []struct {
+		name       string
+		url        string
+		priority   string
+		sort       string
+		statusCode int
+	}{
+		{"should normalize priorities", "/tasks/search?priority=p0,P1", "P0,P1", "", http.StatusOK},
+		{"should pass sort through", "/tasks/search?sort=priority,-dueBy", "", "priority,-dueBy", http.StatusOK},
+		{"should reject unknown priority", "/tasks/search?priority=urgent", "", "", http.StatusBadRequest},
+		{"should reject sort outside whitelist", "/tasks/search?sort=-description", "", "", http.StatusBadRequest},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			var params map[string]string
+			taskRepositorySearchTasksMock = func(p map[string]string) ([]domain.Task, error) {
+				params = p
+				return []domain.Task{}, nil
+			}
+
+			response, _ := app.Test(httptest.NewRequest("GET", "http://localhost.com"+scenario.url, nil))
+			if response.StatusCode != scenario.statusCode {
+				t.Fatalf("Expected status code: %d, Got: %d", scenario.statusCode, response.StatusCode)
+			}
+			if scenario.statusCode == http.StatusOK &&
+				(params["priority"] != scenario.priority || params["sort"] != scenario.sort) {
+				t.Errorf("Expected priority: %q and sort: %q, Got: %v", scenario.priority, scenario.sort, params)
+			}
+		})
+	}
+}
+
+func TestGetAllTasksHandlerSort(t *testing.T) {
+	taskRepository = taskRepositoryMock{}
+	app := fiber.New()
+	app.Get("/tasks", GetAllTasksHandler)
+
+	var sort []domain.SortKey
+	taskRepositoryGetAllTasksMock = func(page int64, perPage int64, keys []domain.SortKey) ([]domain.Task, error) {
+		sort = keys
+		return []domain.Task{}, nil
+	}
+
+	response, _ := app.Test(httptest.NewRequest("GET", "http://localhost.com/tasks?sort=priority,-dueBy", nil))
+	expected := []domain.SortKey{{Column: "priority"}, {Column: "dueBy", Descending: true}}
+	if response.StatusCode != http.StatusOK || !reflect.DeepEqual(sort, expected) {
+		t.Errorf("Expected status code: %d with sort %v, Got: %d with sort %v", http.StatusOK, expected, response.StatusCode, sort)
+	}
+
+	response, _ = app.Test(httptest.NewRequest("GET", "http://localhost.com/tasks?sort=secret", nil))
+	compareResponses(t, http.StatusBadRequest, nil, response)
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"my-todo-app/domain"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const maxPreviewedOccurrences = 100
+
+// GetOccurrencesHandler previews the due dates of the next occurrences of a
+// recurring task, as epoch milliseconds.
+func GetOccurrencesHandler(c *fiber.Ctx) error {
+	id := c.Params("id")
+	count, err := strconv.Atoi(c.Query("count", "5"))
+	if err != nil || count < 1 || count > maxPreviewedOccurrences {
+		logger.Error(fmt.Sprintf("Invalid occurrence count: %s", c.Query("count")))
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	tasks, err := taskRepository.GetTaskById(getCaller(c), id)
+	if err == nil && len(tasks) == 0 {
+		logger.Info(fmt.Sprintf("No task found with id: %s", id))
+		return c.SendStatus(http.StatusNotFound)
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error fetching task with id=%s: %s", id, err))
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	occurrences := []int64{}
+	if tasks[0].GetRecurrence() != "" {
+		recurrence, err := domain.ParseRecurrence(tasks[0].GetRecurrence())
+		if err != nil {
+			logger.Error(fmt.Sprintf("Stored recurrence of task with id=%s is invalid: %s", id, err))
+			return c.SendStatus(http.StatusInternalServerError)
+		}
+		for _, occurrence := range recurrence.Occurrences(getRecurrenceStart(tasks[0]), count) {
+			occurrences = append(occurrences, toMillis(occurrence))
+		}
+	}
+	return c.JSON(occurrences)
+}
+
+func setNormalizedRecurrence(task *domain.Task) error {
+	if task.GetRecurrence() == "" {
+		return nil
+	}
+	recurrence, err := domain.ParseRecurrence(task.GetRecurrence())
+	if err == nil {
+		task.SetRecurrence(recurrence.String())
+	}
+	return err
+}
+
+// getNextOccurrence takes the rule off a recurring task that is being
+// completed from current and returns the occurrence following it, nil when
+// there is none. The rule moves on to the new task, stored along with the
+// completion, so completing the same task again after reopening it does not
+// repeat the occurrence.
+func getNextOccurrence(caller domain.Identity, task *domain.Task, current domain.Task) (*domain.Task, error) {
+	if task.GetRecurrence() == "" || task.GetStatus() != workflow.Completed || current.GetStatus() == workflow.Completed {
+		return nil, nil
+	}
+
+	recurrence, err := domain.ParseRecurrence(task.GetRecurrence())
+	if err != nil {
+		return nil, err
+	}
+	task.SetRecurrence("")
+
+	occurrences := recurrence.Occurrences(getRecurrenceStart(*task), 1)
+	if len(occurrences) == 0 {
+		logger.Info(fmt.Sprintf("Recurrence of task with id: %d has ended", task.GetId()))
+		return nil, nil
+	}
+
+	// only one of the completions of current made at once may hand the rule on
+	if task.GetVersion() == 0 {
+		task.SetVersion(current.GetVersion())
+	}
+	return &domain.Task{
+		AddedOn:     toMillis(time.Now()),
+		DueBy:       toMillis(occurrences[0]),
+		Title:       task.GetTitle(),
+		Description: task.GetDescription(),
+		Status:      workflow.InitialStatus(),
+		Tags:        task.GetTags(),
+		ParentId:    task.GetParentId(),
+		Priority:    task.GetPriority(),
+		Recurrence:  recurrence.Following().String(),
+		OwnerId:     caller.UserId,
+		ListId:      task.GetListId(),
+	}, nil
+}
+
+// addNextOccurrence links task to its next occurrence next, if any, stored
+// with nextId.
+func addNextOccurrence(task *domain.Task, next *domain.Task, nextId int64) {
+	if next == nil {
+		return
+	}
+	logger.Info(fmt.Sprintf("Scheduled task with id: %d as next occurrence of %d", nextId, task.GetId()))
+	task.SetNextOccurrenceId(nextId)
+}
+
+// isRuleHandedOn reports whether a change made to the task with id after
+// version completed it and took its rule off, handing the rule on to a next
+// occurrence or ending the series.
+func isRuleHandedOn(caller domain.Identity, id string, version int64) (bool, error) {
+	revisions, err := historyRepository.GetTaskHistory(caller, id, -1, -1)
+	for _, revision := range revisions {
+		if revision.Version <= version || revision.Task.GetStatus() != workflow.Completed {
+			continue
+		}
+		for _, change := range revision.Changes {
+			if change.Field == "recurrence" {
+				return true, nil
+			}
+		}
+	}
+	return false, err
+}
+
+// getRecurrenceStart anchors the rule at the due date of task, or at the
+// current time for tasks without one.
+func getRecurrenceStart(task domain.Task) time.Time {
+	if task.GetDueBy() > 0 {
+		return time.Unix(0, task.GetDueBy()*int64(time.Millisecond))
+	}
+	return time.Now()
+}
+
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
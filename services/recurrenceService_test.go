@@ -0,0 +1,194 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"my-todo-app/domain"
+	"my-todo-app/repository"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRecurrence(t *testing.T) {
+	scenarios := []struct {
+		name      string
+		rule      string
+		canonical string
+		valid     bool
+	}{
+		{"should canonicalize rule", "rrule:byday=th,mo,th;freq=weekly;interval=2", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH", true},
+		{"should accept count", "FREQ=DAILY;COUNT=3", "FREQ=DAILY;COUNT=3", true},
+		{"should extend date until to end of day", "FREQ=MONTHLY;BYMONTHDAY=-1;UNTIL=20211231", "FREQ=MONTHLY;BYMONTHDAY=-1;UNTIL=20211231T235959Z", true},
+		{"should reject missing freq", "INTERVAL=2", "", false},
+		{"should reject unsupported freq", "FREQ=HOURLY", "", false},
+		{"should reject unsupported parts", "FREQ=WEEKLY;BYSETPOS=1", "", false},
+		{"should reject count with until", "FREQ=DAILY;COUNT=2;UNTIL=20211231", "", false},
+		{"should reject byday outside weekly rules", "FREQ=MONTHLY;BYDAY=MO", "", false},
+		{"should reject invalid bymonthday", "FREQ=MONTHLY;BYMONTHDAY=32", "", false},
+		{"should reject zero interval", "FREQ=DAILY;INTERVAL=0", "", false},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			recurrence, err := domain.ParseRecurrence(scenario.rule)
+			if (err == nil) != scenario.valid {
+				t.Fatalf("Expected valid: %t, Got error: %v", scenario.valid, err)
+			}
+			if scenario.valid && recurrence.String() != scenario.canonical {
+				t.Errorf("Expected: %s, Got: %s", scenario.canonical, recurrence.String())
+			}
+		})
+	}
+}
+
+func TestRecurrenceOccurrences(t *testing.T) {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 9, 0, 0, 0, time.UTC)
+	}
+
+	scenarios := []struct {
+		name     string
+		rule     string
+		start    time.Time
+		expected []time.Time
+	}{
+		{"should repeat every other day", "FREQ=DAILY;INTERVAL=2", date(2021, 3, 1),
+			[]time.Time{date(2021, 3, 3), date(2021, 3, 5), date(2021, 3, 7)}},
+		{"should repeat on weekdays of the week", "FREQ=WEEKLY;BYDAY=MO,FR", date(2021, 3, 3),
+			[]time.Time{date(2021, 3, 5), date(2021, 3, 8), date(2021, 3, 12)}},
+		{"should skip months without the day", "FREQ=MONTHLY", date(2021, 1, 31),
+			[]time.Time{date(2021, 3, 31), date(2021, 5, 31), date(2021, 7, 31)}},
+		{"should count month days from the end", "FREQ=MONTHLY;BYMONTHDAY=-1", date(2021, 1, 31),
+			[]time.Time{date(2021, 2, 28), date(2021, 3, 31), date(2021, 4, 30)}},
+		{"should skip years without leap day", "FREQ=YEARLY", date(2020, 2, 29),
+			[]time.Time{date(2024, 2, 29), date(2028, 2, 29), date(2032, 2, 29)}},
+		{"should stop after count", "FREQ=DAILY;COUNT=2", date(2021, 3, 1),
+			[]time.Time{date(2021, 3, 2)}},
+		{"should stop after until", "FREQ=WEEKLY;UNTIL=20210315", date(2021, 3, 1),
+			[]time.Time{date(2021, 3, 8), date(2021, 3, 15)}},
+		{"should give up on rules that never match", "FREQ=MONTHLY;INTERVAL=12;BYMONTHDAY=30", date(2021, 2, 1),
+			[]time.Time{}},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			recurrence, err := domain.ParseRecurrence(scenario.rule)
+			if err != nil {
+				t.Fatalf("Error parsing %s: %s", scenario.rule, err)
+			}
+
+			occurrences := recurrence.Occurrences(scenario.start, 3)
+			if !reflect.DeepEqual(occurrences, scenario.expected) {
+				t.Errorf("Expected: %v, Got: %v", scenario.expected, occurrences)
+			}
+		})
+	}
+}
+
+func TestRecurringTaskCompletion(t *testing.T) {
+	taskRepository, _ = repository.NewRepository(repository.MemoryDriver, "")
+	app := fiber.New()
+	app.Get("/task/:id", GetTaskByIdHandler)
+	app.Get("/task/:id/occurrences", GetOccurrencesHandler)
+	app.Post("/task", CreateTaskHandler)
+	app.Post("/task/:id/transition/:action", TransitionTaskHandler)
+
+	dueBy := toMillis(time.Date(2021, 3, 1, 9, 0, 0, 0, time.UTC))
+	day := int64(24 * time.Hour / time.Millisecond)
+	response, _ := app.Test(httptest.NewRequest("POST", "http://localhost.com/task", bytes.NewBufferString(
+		`{"title": "standup", "status": "open", "due_by": 1614589200000, "tags": ["work"], "recurrence": "freq=daily;count=2"}`)))
+	compareResponses(t, http.StatusOK, domain.Task{Id: 1, Title: "standup", Status: "open", DueBy: dueBy,
+		Tags: []string{"work"}, Recurrence: "FREQ=DAILY;COUNT=2", Priority: "P2"}, response)
+
+	t.Run("should reject invalid recurrence", func(t *testing.T) {
+		response, _ := app.Test(httptest.NewRequest("POST", "http://localhost.com/task",
+			bytes.NewBufferString(`{"title": "standup", "recurrence": "FREQ=HOURLY"}`)))
+		compareResponses(t, http.StatusBadRequest, nil, response)
+	})
+
+	t.Run("should preview occurrences", func(t *testing.T) {
+		response, _ := app.Test(httptest.NewRequest("GET", "http://localhost.com/task/1/occurrences?count=3", nil))
+		compareResponses(t, http.StatusOK, []int64{dueBy + day}, response)
+
+		response, _ = app.Test(httptest.NewRequest("GET", "http://localhost.com/task/1/occurrences?count=0", nil))
+		compareResponses(t, http.StatusBadRequest, nil, response)
+
+		response, _ = app.Test(httptest.NewRequest("GET", "http://localhost.com/task/9/occurrences", nil))
+		compareResponses(t, http.StatusNotFound, nil, response)
+	})
+
+	t.Run("should schedule next occurrence on completion", func(t *testing.T) {
+		response, _ := app.Test(httptest.NewRequest("POST", "http://localhost.com/task/1/transition/complete", nil))
+		compareResponses(t, http.StatusOK, domain.Task{Id: 1, Title: "standup", Status: "done", DueBy: dueBy,
+			Tags: []string{"work"}, Priority: "P2", NextOccurrenceId: 2}, response)
+
+		response, _ = app.Test(httptest.NewRequest("GET", "http://localhost.com/task/2", nil))
+		var next domain.Task
+		_ = json.NewDecoder(response.Body).Decode(&next)
+		if next.GetDueBy() != dueBy+day || next.GetStatus() != "open" || next.GetRecurrence() != "FREQ=DAILY;COUNT=1" ||
+			!reflect.DeepEqual(next.GetTags(), []string{"work"}) {
+			t.Errorf("Unexpected next occurrence: %v", next)
+		}
+	})
+
+	t.Run("should end series after count", func(t *testing.T) {
+		response, _ := app.Test(httptest.NewRequest("POST", "http://localhost.com/task/2/transition/complete", nil))
+		var completed domain.Task
+		_ = json.NewDecoder(response.Body).Decode(&completed)
+		if completed.GetNextOccurrenceId() != 0 {
+			t.Errorf("Expected no next occurrence, Got: %d", completed.GetNextOccurrenceId())
+		}
+
+		response, _ = app.Test(httptest.NewRequest("GET", "http://localhost.com/task/3", nil))
+		compareResponses(t, http.StatusNotFound, nil, response)
+	})
+}
+
+// changedMeanwhileRepository renames every task right after it is read, as a
+// concurrent request would before the change of the reader is stored.
+type changedMeanwhileRepository struct {
+	repository.TaskRepository
+}
+
+func (r changedMeanwhileRepository) GetTaskById(caller domain.Identity, id string) ([]domain.Task, error) {
+	tasks, err := r.TaskRepository.GetTaskById(caller, id)
+	if err == nil {
+		renamed := tasks[0]
+		renamed.Title = "renamed"
+		_, _ = r.TaskRepository.UpdateTask(caller, renamed, id, nil, domain.TaskRevision{})
+	}
+	return tasks, err
+}
+
+func TestStaleRecurringTaskCompletion(t *testing.T) {
+	store, _ := repository.NewRepository(repository.MemoryDriver, "")
+	_, _ = store.CreateTask(domain.Identity{}, domain.Task{Title: "standup", Status: "open", Version: 1,
+		DueBy: toMillis(time.Date(2021, 3, 1, 9, 0, 0, 0, time.UTC)), Recurrence: "FREQ=DAILY"}, domain.TaskRevision{})
+	taskRepository = changedMeanwhileRepository{store}
+	app := fiber.New()
+	app.Put("/task/:id", UpdateTaskByIdHandler)
+	app.Patch("/task/:id", PatchTaskByIdHandler)
+	app.Post("/task/:id/transition/:action", TransitionTaskHandler)
+
+	for _, scenario := range []struct{ method, url, body string }{
+		{"PUT", "/task/1", `{"id": 1, "title": "standup", "status": "done", "due_by": 1614589200000, "recurrence": "FREQ=DAILY"}`},
+		{"PATCH", "/task/1", `{"status": "done"}`},
+		{"POST", "/task/1/transition/complete", ""},
+	} {
+		t.Run("should not schedule next occurrence on stale "+scenario.method, func(t *testing.T) {
+			req := httptest.NewRequest(scenario.method, "http://localhost.com"+scenario.url, bytes.NewBufferString(scenario.body))
+			req.Header.Set(fiber.HeaderIfMatch, "*")
+			response, _ := app.Test(req)
+			compareResponses(t, http.StatusPreconditionFailed, nil, response)
+
+			tasks, _ := store.GetAllTasks(domain.Identity{}, -1, -1, nil)
+			if len(tasks) != 1 || tasks[0].GetStatus() != "open" {
+				t.Errorf("Expected only the open task, Got: %v", tasks)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"my-todo-app/domain"
+	"net/http"
+	"strconv"
+)
+
+// RequirePermission guards the handlers registered after it, answering 403
+// when the role of the caller does not grant permission.
+func RequirePermission(permission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		caller := getCaller(c)
+		if !caller.Can(permission) {
+			logger.Warn(fmt.Sprintf("Denied %s %s to user %s with role %q, missing permission: %s",
+				c.Method(), c.Path(), caller.Username, caller.Role, permission))
+			return c.SendStatus(http.StatusForbidden)
+		}
+		return c.Next()
+	}
+}
+
+// UpdateUserRoleHandler gives a user another role, for admins of the tenant
+// of that user only.
+func UpdateUserRoleHandler(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	var user domain.User
+	if err == nil {
+		err = json.Unmarshal(c.Body(), &user)
+	}
+	if err == nil && !domain.IsRole(user.GetRole()) {
+		err = fmt.Errorf("unknown role %q", user.GetRole())
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("Invalid role update: %s", err))
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	caller := getCaller(c)
+	users, err := userRepository.GetUserById(id)
+	if err == nil && (len(users) == 0 || users[0].GetTenantId() != caller.TenantId) {
+		logger.Info(fmt.Sprintf("No user found with id: %d", id))
+		return c.SendStatus(http.StatusNotFound)
+	}
+	if err == nil {
+		_, err = userRepository.UpdateUserRole(id, user.GetRole())
+	}
+	if err == nil {
+		users, err = userRepository.GetUserById(id)
+	}
+	if err == nil && len(users) > 0 {
+		logger.Info(fmt.Sprintf("User %s gave user %d the role: %s", caller.Username, id, user.GetRole()))
+		return c.JSON(users[0])
+	}
+
+	logger.Error(fmt.Sprintf("Error updating role of user %d: %s", id, err))
+	return c.SendStatus(http.StatusInternalServerError)
+}
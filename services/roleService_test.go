@@ -0,0 +1,83 @@
+package services
+
+import (
+	"bytes"
+	"github.com/gofiber/fiber/v2"
+	"my-todo-app/domain"
+	"my-todo-app/repository"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRolePermissions(t *testing.T) {
+	store, _ := repository.NewRepository(repository.MemoryDriver, "")
+	SetRepository(store)
+	users := map[string]domain.User{}
+	for _, role := range []string{domain.RoleViewer, domain.RoleEditor, domain.RoleAdmin} {
+		user, _ := domain.NewUser(role, "correct horse", 0)
+		user.Role = role
+		user.Id, _ = store.CreateUser(user)
+		users[role] = user
+	}
+
+	app := fiber.New()
+	app.Use(AuthMiddleware)
+	app.Get("/tasks", RequirePermission(domain.PermissionReadTasks), GetAllTasksHandler)
+	app.Get("/tasks/search", RequirePermission(domain.PermissionReadTasks), SearchHandler)
+	app.Post("/task", RequirePermission(domain.PermissionWriteTasks), CreateTaskHandler)
+	app.Delete("/task/:id", RequirePermission(domain.PermissionDeleteTasks), DeleteTaskByIdHandler)
+	app.Put("/users/:id/role", RequirePermission(domain.PermissionManageUsers), UpdateUserRoleHandler)
+
+	request := func(method string, url string, body string, username string) *http.Response {
+		req := httptest.NewRequest(method, "http://localhost.com"+url, bytes.NewBufferString(body))
+		req.SetBasicAuth(username, "correct horse")
+		req.Header.Set(fiber.HeaderIfMatch, "*")
+		response, _ := app.Test(req, -1)
+		return response
+	}
+
+	scenarios := []struct {
+		method     string
+		url        string
+		body       string
+		role       string
+		statusCode int
+	}{
+		{"GET", "/tasks", "", domain.RoleViewer, http.StatusOK},
+		{"GET", "/tasks/search?status=open", "", domain.RoleViewer, http.StatusOK},
+		{"POST", "/task", `{"title": "viewer's task"}`, domain.RoleViewer, http.StatusForbidden},
+		{"DELETE", "/task/1", "", domain.RoleViewer, http.StatusForbidden},
+		{"POST", "/task", `{"title": "editor's task"}`, domain.RoleEditor, http.StatusOK},
+		{"DELETE", "/task/1", "", domain.RoleEditor, http.StatusNoContent},
+		{"PUT", "/users/1/role", `{"role": "editor"}`, domain.RoleEditor, http.StatusForbidden},
+		{"POST", "/task", `{"title": "admin's task"}`, domain.RoleAdmin, http.StatusOK},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.role+" "+scenario.method+" "+scenario.url, func(t *testing.T) {
+			response := request(scenario.method, scenario.url, scenario.body, scenario.role)
+			if response.StatusCode != scenario.statusCode {
+				t.Errorf("Expected status code: %d, Got: %d", scenario.statusCode, response.StatusCode)
+			}
+		})
+	}
+
+	t.Run("admin should update roles", func(t *testing.T) {
+		url := "/users/" + strconv.FormatInt(users[domain.RoleViewer].Id, 10) + "/role"
+		response := request("PUT", url, `{"role": "editor"}`, domain.RoleAdmin)
+		body := getStringFromResponseBody(response.Body)
+		if response.StatusCode != http.StatusOK || !strings.Contains(body, `"role":"editor"`) {
+			t.Errorf("Expected viewer to become editor, Got: %d with %s", response.StatusCode, body)
+		}
+
+		response = request("POST", "/task", `{"title": "former viewer's task"}`, domain.RoleViewer)
+		compareResponses(t, http.StatusOK, domain.Task{Id: 3, Title: "former viewer's task", Status: "open", Priority: "P2",
+			OwnerId: users[domain.RoleViewer].Id}, response)
+
+		compareResponses(t, http.StatusBadRequest, nil, request("PUT", url, `{"role": "owner"}`, domain.RoleAdmin))
+		compareResponses(t, http.StatusNotFound, nil, request("PUT", "/users/99/role", `{"role": "viewer"}`, domain.RoleAdmin))
+	})
+}
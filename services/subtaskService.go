@@ -0,0 +1,93 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"my-todo-app/domain"
+	"net/http"
+	"strconv"
+)
+
+// maxSubtaskDepth bounds how deep hierarchies are walked, both when nesting
+// children and when looking for cycles.
+const maxSubtaskDepth = 10
+
+var errInvalidParent = errors.New("parent task does not exist, or would make the hierarchy cyclic or too deep")
+
+func GetSubtasksHandler(c *fiber.Ctx) error {
+	id := c.Params("id")
+	caller := getCaller(c)
+	parent, err := taskRepository.GetTaskById(caller, id)
+	if err == nil && len(parent) == 0 {
+		logger.Info(fmt.Sprintf("No task found with id: %s", id))
+		return c.SendStatus(http.StatusNotFound)
+	}
+
+	var tasks []domain.Task
+	if err == nil {
+		tasks, err = taskRepository.SearchTasks(caller, map[string]string{
+			"parentId": id,
+			"page":     c.Query("page", domain.SupportedSearchParams["page"]),
+			"perPage":  c.Query("perPage", domain.SupportedSearchParams["perPage"]),
+		})
+	}
+	if err == nil {
+		logger.Info(fmt.Sprintf("No. of subtasks fetched for task %s: %d", id, len(tasks)))
+		return c.JSON(tasks)
+	}
+
+	logger.Error(fmt.Sprintf("Error fetching subtasks of task with id=%s: %s", id, err))
+	return c.SendStatus(http.StatusInternalServerError)
+}
+
+// attachChildren nests the subtasks of task, depth levels deep.
+func attachChildren(caller domain.Identity, task *domain.Task, depth int) error {
+	if depth == 0 || task.GetSubtasks() == nil {
+		return nil
+	}
+
+	children, err := taskRepository.GetSubtasks(caller, strconv.FormatInt(task.GetId(), 10))
+	for i := 0; err == nil && i < len(children); i++ {
+		err = attachChildren(caller, &children[i], depth-1)
+	}
+	if err == nil {
+		task.SetChildren(children)
+	}
+	return err
+}
+
+// validateParent checks that the parent of task exists among the tasks of
+// caller and that hanging task with id under it keeps the hierarchy acyclic.
+// id is empty for new tasks.
+func validateParent(caller domain.Identity, task domain.Task, id string) error {
+	parentId := task.GetParentId()
+	for depth := 0; parentId != 0; depth++ {
+		if depth == maxSubtaskDepth || strconv.FormatInt(parentId, 10) == id {
+			return errInvalidParent
+		}
+
+		ancestors, err := taskRepository.GetTaskById(caller, strconv.FormatInt(parentId, 10))
+		if err != nil {
+			return err
+		}
+		if len(ancestors) == 0 {
+			return errInvalidParent
+		}
+		parentId = ancestors[0].GetParentId()
+	}
+	return nil
+}
+
+// isCascadeDelete reports whether the configured policy deletes subtasks
+// along with their parent, instead of refusing to delete a task that has any.
+func isCascadeDelete() bool {
+	return deleteParentPolicy == domain.DeleteParentCascade
+}
+
+func getParentErrorStatus(err error) int {
+	if err == errInvalidParent {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"my-todo-app/domain"
+	"my-todo-app/repository"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetSubtasksHandler(t *testing.T) {
+	taskRepository = taskRepositoryMock{}
+	app := fiber.New()
+	app.Get("/task/:id/subtasks", GetSubtasksHandler)
+
+	parent := []domain.Task{{Id: 1, Title: "parent", Status: "open"}}
+	subtasks := []domain.Task{{Id: 2, Title: "child", Status: "open", ParentId: 1}}
+	scenarios := []struct {
+		name       string
+		parent     []domain.Task
+		parentErr  error
+		searchErr  error
+		statusCode int
+	}{
+		{"should list subtasks", parent, nil, nil, http.StatusOK},
+		{"should give 404 for missing parent", []domain.Task{}, nil, nil, http.StatusNotFound},
+		{"should give 500 when parent lookup fails", nil, errors.New("error while fetching Data"), nil, http.StatusInternalServerError},
+		{"should give 500 when search fails", parent, nil, errors.New("error while fetching Data"), http.StatusInternalServerError},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			var params map[string]string
+			taskRepositoryGetByIdMock = func(id string) ([]domain.Task, error) {
+				return scenario.parent, scenario.parentErr
+			}
+			taskRepositorySearchTasksMock = func(p map[string]string) ([]domain.Task, error) {
+				params = p
+				return subtasks, scenario.searchErr
+			}
+
+			response, _ := app.Test(httptest.NewRequest("GET", "http://localhost.com/task/1/subtasks?perPage=5", nil))
+			compareResponses(t, scenario.statusCode, subtasks, response)
+			if scenario.statusCode == http.StatusOK && (params["parentId"] != "1" || params["perPage"] != "5") {
+				t.Errorf("Expected subtasks of task 1, 5 per page, Got: %v", params)
+			}
+		})
+	}
+}
+
+func TestSubtaskHierarchy(t *testing.T) {
+	taskRepository, _ = repository.NewRepository(repository.MemoryDriver, "")
+	app := fiber.New()
+	app.Get("/task/:id", GetTaskByIdHandler)
+	app.Post("/task", CreateTaskHandler)
+	app.Put("/task/:id", UpdateTaskByIdHandler)
+	app.Delete("/task/:id", DeleteTaskByIdHandler)
+
+	post := func(body string) int {
+		response, _ := app.Test(httptest.NewRequest("POST", "http://localhost.com/task", bytes.NewBufferString(body)))
+		return response.StatusCode
+	}
+	if status := post(`{"title": "root", "status": "open"}`); status != http.StatusOK {
+		t.Fatalf("Expected root task to be created, Got: %d", status)
+	}
+	if status := post(`{"title": "child", "status": "done", "parent_id": 1}`); status != http.StatusOK {
+		t.Fatalf("Expected child task to be created, Got: %d", status)
+	}
+	if status := post(`{"title": "grandchild", "status": "open", "parent_id": 2}`); status != http.StatusOK {
+		t.Fatalf("Expected grandchild task to be created, Got: %d", status)
+	}
+
+	t.Run("should reject missing parent", func(t *testing.T) {
+		if status := post(`{"title": "orphan", "status": "open", "parent_id": 42}`); status != http.StatusBadRequest {
+			t.Errorf("Expected status code: %d, Got: %d", http.StatusBadRequest, status)
+		}
+	})
+
+	t.Run("should reject cycles", func(t *testing.T) {
+		for _, body := range []string{
+			`{"id": 1, "title": "root", "status": "open", "parent_id": 3}`,
+			`{"id": 1, "title": "root", "status": "open", "parent_id": 1}`,
+		} {
+			response, _ := app.Test(httptest.NewRequest("PUT", "http://localhost.com/task/1", bytes.NewBufferString(body)))
+			if response.StatusCode != http.StatusBadRequest {
+				t.Errorf("Expected status code: %d for %s, Got: %d", http.StatusBadRequest, body, response.StatusCode)
+			}
+		}
+	})
+
+	t.Run("should nest children with rollups", func(t *testing.T) {
+		grandchild := domain.Task{Id: 3, Title: "grandchild", Status: "open", ParentId: 2, Priority: "P2"}
+		child := domain.Task{Id: 2, Title: "child", Status: "done", ParentId: 1, Priority: "P2",
+			Subtasks: &domain.SubtaskRollup{Total: 1}, Children: []domain.Task{grandchild}}
+		root := domain.Task{Id: 1, Title: "root", Status: "open", Priority: "P2",
+			Subtasks: &domain.SubtaskRollup{Total: 1, Done: 1}, Children: []domain.Task{child}}
+
+		response, _ := app.Test(httptest.NewRequest("GET", "http://localhost.com/task/1?include=children", nil))
+		compareResponses(t, http.StatusOK, root, response)
+	})
+
+	t.Run("should apply delete parent policy", func(t *testing.T) {
+		defer func(policy string) { deleteParentPolicy = policy }(deleteParentPolicy)
+		remove := func() *http.Response {
+			request := httptest.NewRequest("DELETE", "http://localhost.com/task/1", nil)
+			request.Header.Set(fiber.HeaderIfMatch, "*")
+			response, _ := app.Test(request)
+			return response
+		}
+
+		deleteParentPolicy = domain.DeleteParentReject
+		compareResponses(t, http.StatusConflict, nil, remove())
+
+		deleteParentPolicy = domain.DeleteParentCascade
+		compareResponses(t, http.StatusNoContent, nil, remove())
+
+		response, _ := app.Test(httptest.NewRequest("GET", "http://localhost.com/task/3", nil))
+		compareResponses(t, http.StatusNotFound, nil, response)
+	})
+}
+
+func TestDeleteParentPolicyCountsHiddenSubtasks(t *testing.T) {
+	defer func(policy string) { deleteParentPolicy = policy }(deleteParentPolicy)
+	deleteParentPolicy = domain.DeleteParentReject
+	store, _ := repository.NewRepository(repository.MemoryDriver, "")
+	SetRepository(store)
+	for _, username := range []string{"alice", "bob"} {
+		user, _ := domain.NewUser(username, "correct horse", 0)
+		_, _ = store.CreateUser(user)
+	}
+
+	app := fiber.New()
+	app.Use(AuthMiddleware)
+	app.Post("/lists", CreateListHandler)
+	app.Post("/lists/:id/members", AddListMemberHandler)
+	app.Post("/task", CreateTaskHandler)
+	app.Get("/task/:id", GetTaskByIdHandler)
+	app.Delete("/task/:id", DeleteTaskByIdHandler)
+	request := func(method string, url string, body string, username string) *http.Response {
+		req := httptest.NewRequest(method, "http://localhost.com"+url, bytes.NewBufferString(body))
+		req.SetBasicAuth(username, "correct horse")
+		req.Header.Set(fiber.HeaderIfMatch, "*")
+		response, _ := app.Test(req, -1)
+		return response
+	}
+
+	request("POST", "/lists", `{"name": "Sprint 12"}`, "alice")
+	request("POST", "/lists/1/members", `{"username": "bob"}`, "alice")
+	request("POST", "/task", `{"title": "shared", "list_id": 1}`, "alice")
+	// the subtask stays private to alice, so bob does not see it
+	request("POST", "/task", `{"title": "private", "parent_id": 1}`, "alice")
+
+	compareResponses(t, http.StatusNotFound, nil, request("GET", "/task/2", "", "bob"))
+	compareResponses(t, http.StatusConflict, nil, request("DELETE", "/task/1", "", "bob"))
+	if response := request("GET", "/task/2", "", "alice"); response.StatusCode != http.StatusOK {
+		t.Errorf("Expected the subtask to be kept, Got: %d", response.StatusCode)
+	}
+}
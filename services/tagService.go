@@ -0,0 +1,65 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const maxTagLength = 64
+
+func GetAllTagsHandler(c *fiber.Ctx) error {
+	tags, err := taskRepository.GetTagCounts(getCaller(c))
+	if err == nil {
+		logger.Info(fmt.Sprintf("No. of tags fetched: %d", len(tags)))
+		return c.JSON(tags)
+	}
+
+	logger.Error(fmt.Sprintf("Error fetching tags: %s", err))
+	return c.SendStatus(http.StatusInternalServerError)
+}
+
+// normalizeTags trims, lower-cases, de-duplicates and sorts tags. nil stays
+// nil, so an update without tags leaves the stored ones untouched.
+func normalizeTags(tags []string) ([]string, error) {
+	if tags == nil {
+		return nil, nil
+	}
+
+	seen := map[string]bool{}
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		switch {
+		case tag == "":
+			return nil, errors.New("tags must not be empty")
+		case len(tag) > maxTagLength || strings.Contains(tag, ","):
+			return nil, fmt.Errorf("invalid tag: %s", tag)
+		case !seen[tag]:
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+// getTagsQuery collects tags from repeated and comma separated query
+// params, e.g. ?tag=a&tag=b or ?tag=a,b, joined for the repository.
+func getTagsQuery(c *fiber.Ctx) (string, error) {
+	var tags []string
+	for _, value := range c.Context().QueryArgs().PeekMulti("tag") {
+		for _, tag := range strings.Split(string(value), ",") {
+			if strings.TrimSpace(tag) != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+
+	tags, err := normalizeTags(tags)
+	return strings.Join(tags, ","), err
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"my-todo-app/domain"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestGetAllTagsHandler(t *testing.T) {
+	taskRepository = taskRepositoryMock{}
+	app := fiber.New()
+	app.Get("/tags", GetAllTagsHandler)
+
+	scenarios := []struct {
+		name       string
+		tags       []domain.Tag
+		err        error
+		statusCode int
+	}{
+		{"should list tags with counts", []domain.Tag{{Name: "home", Count: 2}}, nil, http.StatusOK},
+		{"should list no tags", []domain.Tag{}, nil, http.StatusOK},
+		{"should give 500 for database errors", nil, errors.New("error while fetching Data"), http.StatusInternalServerError},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			taskRepositoryGetTagCountsMock = func() ([]domain.Tag, error) {
+				return scenario.tags, scenario.err
+			}
+
+			response, _ := app.Test(httptest.NewRequest("GET", "http://localhost.com/tags", nil))
+			compareResponses(t, scenario.statusCode, scenario.tags, response)
+		})
+	}
+}
+
+func TestSearchHandlerTagFilter(t *testing.T) {
+	taskRepository = taskRepositoryMock{}
+	app := fiber.New()
+	app.Get("/tasks/search", SearchHandler)
+
+	scenarios := []struct {
+		name       string
+		url        string
+		tag        string
+		tagMatch   string
+		statusCode int
+	}{
+		{"should join repeated tags", "/tasks/search?tag=Work&tag=home", "home,work", "any", http.StatusOK},
+		{"should accept comma separated tags", "/tasks/search?tag=work,home&tagMatch=all", "home,work", "all", http.StatusOK},
+		{"should leave tag out when not given", "/tasks/search", "", "any", http.StatusOK},
+		{"should reject unknown tag match", "/tasks/search?tag=work&tagMatch=some", "", "", http.StatusBadRequest},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			var params map[string]string
+			taskRepositorySearchTasksMock = func(p map[string]string) ([]domain.Task, error) {
+				params = p
+				return []domain.Task{}, nil
+			}
+
+			response, _ := app.Test(httptest.NewRequest("GET", "http://localhost.com"+scenario.url, nil))
+			if response.StatusCode != scenario.statusCode {
+				t.Fatalf("Expected status code: %d, Got: %d", scenario.statusCode, response.StatusCode)
+			}
+			if scenario.statusCode == http.StatusOK &&
+				(params["tag"] != scenario.tag || params["tagMatch"] != scenario.tagMatch) {
+				t.Errorf("Expected tag: %q with match %q, Got: %v", scenario.tag, scenario.tagMatch, params)
+			}
+		})
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	scenarios := []struct {
+		name     string
+		tags     []string
+		expected []string
+		valid    bool
+	}{
+		{"should keep nil tags", nil, nil, true},
+		{"should keep empty tags", []string{}, []string{}, true},
+		{"should trim, lower-case, de-duplicate and sort", []string{" Work", "home", "work "}, []string{"home", "work"}, true},
+		{"should reject blank tag", []string{"  "}, nil, false},
+		{"should reject comma in tag", []string{"a,b"}, nil, false},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			tags, err := normalizeTags(scenario.tags)
+			if (err == nil) != scenario.valid {
+				t.Errorf("Expected valid: %t, got error: %v", scenario.valid, err)
+			} else if scenario.valid && !reflect.DeepEqual(tags, scenario.expected) {
+				t.Errorf("Expected: %v, Got: %v", scenario.expected, tags)
+			}
+		})
+	}
+}
@@ -7,28 +7,67 @@ import (
 	"go.uber.org/zap"
 	"my-todo-app/config"
 	"my-todo-app/domain"
+	"my-todo-app/repository"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 var (
-	taskRepository ITaskRepository
-	logger         *zap.Logger
+	taskRepository        repository.TaskRepository
+	userRepository        repository.UserRepository
+	apiKeyRepository      repository.ApiKeyRepository
+	listRepository        repository.ListRepository
+	trashRepository       repository.TrashRepository
+	historyRepository     repository.HistoryRepository
+	commentRepository     repository.CommentRepository
+	attachmentRepository  repository.AttachmentRepository
+	dependencyRepository  repository.DependencyRepository
+	idempotencyRepository repository.IdempotencyRepository
+	logger                *zap.Logger
+	deleteParentPolicy    string
+	workflow              domain.Workflow
 )
 
 func init() {
-	taskRepository = TaskRepository{}
 	logger = config.AppLogger
+	deleteParentPolicy = config.DeleteParentPolicy
+	workflow = config.TaskWorkflow
+}
+
+// SetRepository wires the storage backend used by every handler.
+func SetRepository(r repository.Repository) {
+	taskRepository = r
+	userRepository = r
+	apiKeyRepository = r
+	listRepository = r
+	trashRepository = r
+	historyRepository = r
+	commentRepository = r
+	attachmentRepository = r
+	dependencyRepository = r
+	idempotencyRepository = r
 }
 
 func GetTaskByIdHandler(c *fiber.Ctx) error {
 	id := c.Params("id")
-	task, err := taskRepository.getTaskById(id)
+	caller := getCaller(c)
+	task, err := taskRepository.GetTaskById(caller, id)
+	if err == nil && len(task) > 0 && c.Query("include") == "children" {
+		err = attachChildren(caller, &task[0], maxSubtaskDepth)
+	}
+	if err == nil && len(task) > 0 {
+		task[0].Attachments, err = attachmentRepository.GetAttachments(caller, id)
+	}
 	if err == nil {
 		if len(task) == 0 {
 			logger.Info(fmt.Sprintf("No task found with id: %s", id))
 			return c.SendStatus(http.StatusNotFound)
 		}
+		setETag(c, task[0])
+		if isNotModified(c, task[0]) {
+			return c.SendStatus(http.StatusNotModified)
+		}
 		return c.JSON(task[0])
 	}
 
@@ -37,10 +76,13 @@ func GetTaskByIdHandler(c *fiber.Ctx) error {
 }
 
 func GetAllTasksHandler(c *fiber.Ctx) error {
-	page, _ := strconv.ParseInt(c.Query("page", "0"), 10, 64)
-	perPage, _ := strconv.ParseInt(c.Query("perPage", "10"), 10, 64)
+	page, perPage, sort, err := getPagination(c)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Invalid sort for tasks: %s", err))
+		return c.SendStatus(http.StatusBadRequest)
+	}
 
-	tasks, err := taskRepository.getAllTasks(page, perPage)
+	tasks, err := taskRepository.GetAllTasks(getCaller(c), page, perPage, sort)
 	if err == nil {
 		logger.Info(fmt.Sprintf("No. of tasks fetched: %d", len(tasks)))
 		return c.JSON(tasks)
@@ -51,16 +93,40 @@ func GetAllTasksHandler(c *fiber.Ctx) error {
 }
 
 func CreateTaskHandler(c *fiber.Ctx) error {
+	caller := getCaller(c)
 	var task domain.Task
 	err := json.Unmarshal(c.Body(), &task)
+	if err == nil {
+		err = setNormalizedTags(&task)
+	}
+	if err == nil {
+		err = setNormalizedRecurrence(&task)
+	}
+	if err == nil {
+		err = setNormalizedPriority(&task, domain.DefaultPriority)
+	}
 	if err != nil {
 		logger.Error(fmt.Sprintf("Error converting json to valid task body: %s", err))
 		return c.SendStatus(http.StatusBadRequest)
 	}
+	if err = validateParent(caller, task, ""); err != nil {
+		logger.Error(fmt.Sprintf("Error validating parent of new task: %s", err))
+		return c.SendStatus(getParentErrorStatus(err))
+	}
+	if err = validateList(caller, task); err != nil {
+		logger.Error(fmt.Sprintf("Error validating list of new task: %s", err))
+		return c.SendStatus(getListErrorStatus(err))
+	}
+	if err = checkNewStatus(&task); err != nil {
+		return sendUnprocessable(c, err)
+	}
 
-	createdId, err := taskRepository.createTask(task)
+	task.SetOwnerId(caller.UserId)
+	createdId, err := taskRepository.CreateTask(caller, task, newRevision(caller, domain.HistoryCreate))
 	if err == nil {
 		task.SetId(createdId)
+		task.SetVersion(1)
+		setETag(c, task)
 		return c.JSON(task)
 	}
 
@@ -70,46 +136,246 @@ func CreateTaskHandler(c *fiber.Ctx) error {
 
 func UpdateTaskByIdHandler(c *fiber.Ctx) error {
 	id := c.Params("id")
+	caller := getCaller(c)
 
 	var task domain.Task
 	err := json.Unmarshal(c.Body(), &task)
+	if err == nil {
+		err = setNormalizedTags(&task)
+	}
+	if err == nil {
+		err = setNormalizedRecurrence(&task)
+	}
 	if err != nil || strconv.FormatInt(task.GetId(), 10) != id {
 		logger.Error("Bad data passed for update, or id in body is different from id in URL")
 		return c.SendStatus(http.StatusBadRequest)
 	}
+	if err = validateParent(caller, task, id); err != nil {
+		logger.Error(fmt.Sprintf("Error validating parent of task with id=%s: %s", id, err))
+		return c.SendStatus(getParentErrorStatus(err))
+	}
+	if err = validateList(caller, task); err != nil {
+		logger.Error(fmt.Sprintf("Error validating list of task with id=%s: %s", id, err))
+		return c.SendStatus(getListErrorStatus(err))
+	}
 
-	err = taskRepository.updateTask(task, id)
+	current, err := taskRepository.GetTaskById(caller, id)
+	if err == nil && len(current) == 0 {
+		logger.Info(fmt.Sprintf("No task found with id: %s for update", id))
+		return c.SendStatus(http.StatusNotFound)
+	}
 	if err == nil {
+		var version int64
+		if version, err = getExpectedVersion(c, current[0]); err != nil {
+			logger.Info(fmt.Sprintf("Refusing to update task with id=%s: %s", id, err))
+			return c.SendStatus(getVersionErrorStatus(err))
+		}
+		task.SetVersion(version)
+		if err = setNormalizedPriority(&task, current[0].GetPriority()); err != nil {
+			logger.Error(fmt.Sprintf("Bad priority passed for update of task with id=%s: %s", id, err))
+			return c.SendStatus(http.StatusBadRequest)
+		}
+		if err = checkStatusUpdate(&task, current[0].GetStatus()); err != nil {
+			return sendUnprocessable(c, err)
+		}
+		task.SetOwnerId(current[0].GetOwnerId())
+		err = checkBlockers(caller, id, task.GetStatus(), current[0].GetStatus())
+	}
+	var next *domain.Task
+	if err == nil {
+		next, err = getNextOccurrence(caller, &task, current[0])
+	}
+	var nextId int64
+	if err == nil {
+		nextId, err = taskRepository.UpdateTask(caller, task, id, next, newRevision(caller, domain.HistoryUpdate))
+	}
+	if err == nil {
+		addNextOccurrence(&task, next, nextId)
+		task.SetVersion(current[0].GetVersion() + 1)
+		setETag(c, task)
 		return c.JSON(task)
 	}
+	if err == repository.ErrVersionConflict {
+		logger.Info(fmt.Sprintf("Task with id=%s changed while updating it", id))
+		return c.SendStatus(http.StatusPreconditionFailed)
+	}
+	if err == repository.ErrTaskNotFound {
+		logger.Info(fmt.Sprintf("Task with id=%s was deleted while updating it", id))
+		return c.SendStatus(http.StatusNotFound)
+	}
+	if err == errBlocked {
+		return sendBlocked(c, id)
+	}
 
 	logger.Error(fmt.Sprintf("Error while updating task with id=%s: %s", id, err))
 	return c.SendStatus(http.StatusInternalServerError)
 }
 
+// PatchTaskByIdHandler applies a JSON merge patch (RFC 7396) to a task, so
+// that only the fields present in the body change and null clears a field.
+// Only the fields that end up different are written.
+func PatchTaskByIdHandler(c *fiber.Ctx) error {
+	id := c.Params("id")
+	caller := getCaller(c)
+	if !domain.IsMergePatchObject(c.Body()) {
+		logger.Error(fmt.Sprintf("Patch of task with id=%s is not a JSON object", id))
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	current, err := taskRepository.GetTaskById(caller, id)
+	if err == nil && len(current) == 0 {
+		logger.Info(fmt.Sprintf("No task found with id: %s for patch", id))
+		return c.SendStatus(http.StatusNotFound)
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error fetching task with id=%s: %s", id, err))
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+	version, err := getExpectedVersion(c, current[0])
+	if err != nil {
+		logger.Info(fmt.Sprintf("Refusing to patch task with id=%s: %s", id, err))
+		return c.SendStatus(getVersionErrorStatus(err))
+	}
+
+	task, err := applyPatch(current[0], c.Body())
+	task.SetVersion(version)
+	if err == nil {
+		err = setNormalizedTags(&task)
+	}
+	if err == nil {
+		err = setNormalizedRecurrence(&task)
+	}
+	if err == nil {
+		err = setNormalizedPriority(&task, current[0].GetPriority())
+	}
+	if err != nil || task.GetId() != current[0].GetId() {
+		logger.Error(fmt.Sprintf("Bad data passed for patch of task with id=%s, or id in body is different from id in URL: %v", id, err))
+		return c.SendStatus(http.StatusBadRequest)
+	}
+	if task.GetParentId() != current[0].GetParentId() {
+		if err = validateParent(caller, task, id); err != nil {
+			logger.Error(fmt.Sprintf("Error validating parent of task with id=%s: %s", id, err))
+			return c.SendStatus(getParentErrorStatus(err))
+		}
+	}
+	if task.GetListId() != current[0].GetListId() {
+		if err = validateList(caller, task); err != nil {
+			logger.Error(fmt.Sprintf("Error validating list of task with id=%s: %s", id, err))
+			return c.SendStatus(getListErrorStatus(err))
+		}
+	}
+	if err = checkStatusUpdate(&task, current[0].GetStatus()); err != nil {
+		return sendUnprocessable(c, err)
+	}
+
+	err = checkBlockers(caller, id, task.GetStatus(), current[0].GetStatus())
+	var next *domain.Task
+	if err == nil {
+		next, err = getNextOccurrence(caller, &task, current[0])
+	}
+	fields := domain.ChangedFields(current[0], task)
+	var nextId int64
+	if err == nil && len(fields) > 0 {
+		nextId, err = taskRepository.PatchTask(caller, task, id, fields, next, newRevision(caller, domain.HistoryUpdate))
+		task.SetVersion(current[0].GetVersion() + 1)
+	} else {
+		task.SetVersion(current[0].GetVersion())
+	}
+	if err == nil {
+		addNextOccurrence(&task, next, nextId)
+		logger.Info(fmt.Sprintf("Patched fields %v of task with id: %s", fields, id))
+		setETag(c, task)
+		return c.JSON(task)
+	}
+	if err == repository.ErrVersionConflict {
+		logger.Info(fmt.Sprintf("Task with id=%s changed while patching it", id))
+		return c.SendStatus(http.StatusPreconditionFailed)
+	}
+	if err == repository.ErrTaskNotFound {
+		logger.Info(fmt.Sprintf("Task with id=%s was deleted while patching it", id))
+		return c.SendStatus(http.StatusNotFound)
+	}
+	if err == errBlocked {
+		return sendBlocked(c, id)
+	}
+
+	logger.Error(fmt.Sprintf("Error while patching task with id=%s: %s", id, err))
+	return c.SendStatus(http.StatusInternalServerError)
+}
+
 func DeleteTaskByIdHandler(c *fiber.Ctx) error {
 	id := c.Params("id")
-	rowsAffected, err := taskRepository.deleteTask(id)
+	caller := getCaller(c)
+	current, err := taskRepository.GetTaskById(caller, id)
+	if err == nil && len(current) == 0 {
+		logger.Info(fmt.Sprintf("No task found with id: %s for deletion", id))
+		return c.SendStatus(http.StatusNotFound)
+	}
+	var version int64
+	if err == nil {
+		if version, err = getExpectedVersion(c, current[0]); err != nil {
+			logger.Info(fmt.Sprintf("Refusing to delete task with id=%s: %s", id, err))
+			return c.SendStatus(getVersionErrorStatus(err))
+		}
+	}
+
+	rowsAffected := false
+	if err == nil {
+		rowsAffected, err = taskRepository.DeleteTask(caller, id, version, toMillis(time.Now()), isCascadeDelete(),
+			newRevision(caller, domain.HistoryDelete))
+	}
 	if err == nil {
 		if rowsAffected {
-			logger.Info(fmt.Sprintf("Deleted task with id: %s", id))
+			logger.Info(fmt.Sprintf("Moved task with id: %s to the trash", id))
 			return c.SendStatus(http.StatusNoContent)
 		}
+		if version != 0 {
+			logger.Info(fmt.Sprintf("Task with id=%s changed while deleting it", id))
+			return c.SendStatus(http.StatusPreconditionFailed)
+		}
 		logger.Info(fmt.Sprintf("No task found with id: %s for deletion", id))
 		return c.SendStatus(http.StatusNotFound)
 	}
+	if err == repository.ErrHasSubtasks {
+		logger.Info(fmt.Sprintf("Refusing to delete task with id: %s while it has subtasks", id))
+		return c.SendStatus(http.StatusConflict)
+	}
 
 	logger.Error(fmt.Sprintf("Error deleting task with id=%s : %s", id, err))
 	return c.SendStatus(http.StatusInternalServerError)
 }
 
 func SearchHandler(c *fiber.Ctx) error {
+	tags, err := getTagsQuery(c)
+	if err != nil || !isValidTagMatch(c.Query("tagMatch", domain.SupportedSearchParams["tagMatch"])) {
+		logger.Error(fmt.Sprintf("Invalid tag filter for search: %v", err))
+		return c.SendStatus(http.StatusBadRequest)
+	}
+	priorities, err := getPriorityQuery(c.Query("priority"))
+	if err == nil {
+		_, err = domain.ParseSort(c.Query("sort"))
+	}
+	if err == nil {
+		err = checkBlockedQuery(c.Query("blocked"))
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("Invalid priority filter, blocked filter or sort for search: %s", err))
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
 	params := map[string]string{}
 	for key, value := range domain.SupportedSearchParams {
-		buildQueryParams(key, c.Query(key, value), &params)
+		switch key {
+		case "tag":
+			buildQueryParams(key, tags, &params)
+		case "priority":
+			buildQueryParams(key, priorities, &params)
+		default:
+			buildQueryParams(key, c.Query(key, value), &params)
+		}
 	}
 
-	tasks, err := taskRepository.searchTasks(params)
+	tasks, err := taskRepository.SearchTasks(getCaller(c), params)
 	if err == nil {
 		logger.Info(fmt.Sprintf("No. of tasks fetched: %d", len(tasks)))
 		return c.JSON(tasks)
@@ -119,9 +385,17 @@ func SearchHandler(c *fiber.Ctx) error {
 	return c.SendStatus(http.StatusInternalServerError)
 }
 
+// getPagination reads the page, perPage and sort query params of a task listing.
+func getPagination(c *fiber.Ctx) (int64, int64, []domain.SortKey, error) {
+	page, _ := strconv.ParseInt(c.Query("page", "0"), 10, 64)
+	perPage, _ := strconv.ParseInt(c.Query("perPage", "10"), 10, 64)
+	sort, err := domain.ParseSort(c.Query("sort"))
+	return page, perPage, sort, err
+}
+
 func buildQueryParams(key string, value string, params *map[string]string) {
 	switch key {
-	case "id", "status":
+	case "id", "status", "tag", "parentId", "listId", "priority", "blocked", "sort":
 		if value != "" {
 			(*params)[key] = value
 		}
@@ -129,3 +403,34 @@ func buildQueryParams(key string, value string, params *map[string]string) {
 		(*params)[key] = value
 	}
 }
+
+// applyPatch merges patch into current. The owner and the fields computed on
+// read are kept from current whatever the patch says.
+func applyPatch(current domain.Task, patch []byte) (domain.Task, error) {
+	var task domain.Task
+	document, err := json.Marshal(current)
+	if err == nil {
+		document, err = domain.MergePatch(document, patch)
+	}
+	if err == nil {
+		err = json.Unmarshal(document, &task)
+	}
+	task.SetOwnerId(current.GetOwnerId())
+	task.SetTenantId(current.GetTenantId())
+	task.SetSubtasks(current.GetSubtasks())
+	task.SetChildren(nil)
+	task.SetNextOccurrenceId(0)
+	return task, err
+}
+
+func setNormalizedTags(task *domain.Task) error {
+	tags, err := normalizeTags(task.GetTags())
+	if err == nil {
+		task.SetTags(tags)
+	}
+	return err
+}
+
+func isValidTagMatch(tagMatch string) bool {
+	return tagMatch == "any" || tagMatch == "all"
+}
@@ -51,7 +51,7 @@ func BenchmarkGetAllTasksHandler(b *testing.B) {
 
 	for _, scenario := range scenarios {
 		b.Run(scenario.Name, func(b *testing.B) {
-			taskRepositoryGetAllTasksMock = func(page int64, perPage int64) ([]domain.Task, error) {
+			taskRepositoryGetAllTasksMock = func(page int64, perPage int64, sort []domain.SortKey) ([]domain.Task, error) {
 				return scenario.ExpectedTasks, scenario.ScenarioErr
 			}
 			request := httptest.NewRequest("GET", scenario.Url, nil)
@@ -102,11 +102,15 @@ func BenchmarkUpdateTaskByIdHandler(b *testing.B) {
 
 	for _, scenario := range scenarios {
 		b.Run(scenario.Name, func(b *testing.B) {
+			taskRepositoryGetByIdMock = func(id string) ([]domain.Task, error) {
+				return []domain.Task{{Id: 1, Title: "sample", Status: "open"}}, nil
+			}
 			taskRepositoryUpdateTaskMock = func(task domain.Task, id string) error {
 				return scenario.ScenarioErr
 			}
 
 			request := httptest.NewRequest("PUT", "http://localhost.com/task/1", bytes.NewBuffer(scenario.Data))
+			request.Header.Set(fiber.HeaderIfMatch, "*")
 			b.StartTimer()
 			for i := 0; i < b.N; i++ {
 				response, _ := testApp.Test(request)
@@ -128,6 +132,10 @@ func BenchmarkDeleteTaskByIdHandler(b *testing.B) {
 	for _, scenario := range scenarios {
 		b.Run(scenario.Name, func(b *testing.B) {
 			request := httptest.NewRequest("DELETE", "http://localhost.com/task/8", nil)
+			request.Header.Set(fiber.HeaderIfMatch, "*")
+			taskRepositoryGetByIdMock = func(id string) ([]domain.Task, error) {
+				return []domain.Task{{Id: 8, Title: "sample", Status: "open", Version: 1}}, nil
+			}
 			taskRepositoryDeleteTaskMock = func(id string) (bool, error) {
 				return scenario.RowsAffected, scenario.ScenarioErr
 			}
@@ -6,6 +6,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"io"
 	"my-todo-app/domain"
+	"my-todo-app/repository"
 	"my-todo-app/testUtils"
 	"net/http"
 	"net/http/httptest"
@@ -16,40 +17,121 @@ import (
 type taskRepositoryMock struct{}
 
 var (
-	taskRepositoryGetByIdMock     func(id string) ([]domain.Task, error)
-	taskRepositoryGetAllTasksMock func(page int64, perPage int64) ([]domain.Task, error)
-	taskRepositoryCreateTaskMock  func(task domain.Task) (int64, error)
-	taskRepositoryUpdateTaskMock  func(task domain.Task, id string) error
-	taskRepositoryDeleteTaskMock  func(id string) (bool, error)
-	taskRepositorySearchTasksMock func(params map[string]string) ([]domain.Task, error)
+	taskRepositoryGetByIdMock      func(id string) ([]domain.Task, error)
+	taskRepositoryGetAllTasksMock  func(page int64, perPage int64, sort []domain.SortKey) ([]domain.Task, error)
+	taskRepositoryCreateTaskMock   func(task domain.Task) (int64, error)
+	taskRepositoryUpdateTaskMock   func(task domain.Task, id string) error
+	taskRepositoryPatchTaskMock    func(task domain.Task, id string, fields []string) error
+	taskRepositoryDeleteTaskMock   func(id string) (bool, error)
+	taskRepositoryApplyBatchMock   func(writes []domain.TaskWrite, atomic bool) ([]domain.TaskWriteResult, error)
+	taskRepositorySearchTasksMock  func(params map[string]string) ([]domain.Task, error)
+	taskRepositoryGetTagCountsMock func() ([]domain.Tag, error)
+	taskRepositoryGetSubtasksMock  = func(id string) ([]domain.Task, error) {
+		return []domain.Task{}, nil
+	}
 
 	testApp = fiber.New()
 )
 
-func (t taskRepositoryMock) getTaskById(id string) ([]domain.Task, error) {
+// historyRepositoryMock drops the revisions recorded by the handlers under
+// test, attachmentRepositoryMock finds no attachments and
+// dependencyRepositoryMock no blockers, until a test wires a repository of
+// its own.
+type historyRepositoryMock struct{}
+
+type attachmentRepositoryMock struct{}
+
+type dependencyRepositoryMock struct{}
+
+func init() {
+	historyRepository = historyRepositoryMock{}
+	attachmentRepository = attachmentRepositoryMock{}
+	dependencyRepository = dependencyRepositoryMock{}
+}
+
+func (h historyRepositoryMock) GetTaskHistory(caller domain.Identity, id string, page int64, perPage int64) ([]domain.TaskRevision, error) {
+	return []domain.TaskRevision{}, nil
+}
+
+func (h historyRepositoryMock) GetTaskRevision(caller domain.Identity, id string, version int64) ([]domain.TaskRevision, error) {
+	return []domain.TaskRevision{}, nil
+}
+
+func (a attachmentRepositoryMock) CreateAttachment(caller domain.Identity, attachment domain.Attachment) (int64, error) {
+	return 0, nil
+}
+
+func (a attachmentRepositoryMock) GetAttachments(caller domain.Identity, taskId string) ([]domain.Attachment, error) {
+	return []domain.Attachment{}, nil
+}
+
+func (a attachmentRepositoryMock) GetAttachmentById(caller domain.Identity, taskId string, id string) ([]domain.Attachment, error) {
+	return []domain.Attachment{}, nil
+}
+
+func (a attachmentRepositoryMock) DeleteAttachment(caller domain.Identity, taskId string, id string) (bool, error) {
+	return false, nil
+}
+
+func (d dependencyRepositoryMock) AddDependency(caller domain.Identity, dependency domain.Dependency) (bool, error) {
+	return false, nil
+}
+
+func (d dependencyRepositoryMock) RemoveDependency(caller domain.Identity, taskId string, blockerId string) (bool, error) {
+	return false, nil
+}
+
+func (d dependencyRepositoryMock) GetBlockers(caller domain.Identity, taskId string) ([]domain.Task, error) {
+	return []domain.Task{}, nil
+}
+
+func (d dependencyRepositoryMock) IsBlocked(caller domain.Identity, taskId string) (bool, error) {
+	return false, nil
+}
+
+func (t taskRepositoryMock) GetTaskById(caller domain.Identity, id string) ([]domain.Task, error) {
 	return taskRepositoryGetByIdMock(id)
 }
 
-func (t taskRepositoryMock) getAllTasks(page int64, perPage int64) ([]domain.Task, error) {
-	return taskRepositoryGetAllTasksMock(page, perPage)
+func (t taskRepositoryMock) GetAllTasks(caller domain.Identity, page int64, perPage int64, sort []domain.SortKey) ([]domain.Task, error) {
+	return taskRepositoryGetAllTasksMock(page, perPage, sort)
 }
 
-func (t taskRepositoryMock) createTask(task domain.Task) (int64, error) {
+func (t taskRepositoryMock) CreateTask(caller domain.Identity, task domain.Task, revision domain.TaskRevision) (int64, error) {
 	return taskRepositoryCreateTaskMock(task)
 }
 
-func (t taskRepositoryMock) updateTask(task domain.Task, id string) error {
-	return taskRepositoryUpdateTaskMock(task, id)
+func (t taskRepositoryMock) UpdateTask(caller domain.Identity, task domain.Task, id string, next *domain.Task,
+	revision domain.TaskRevision) (int64, error) {
+	return 0, taskRepositoryUpdateTaskMock(task, id)
 }
 
-func (t taskRepositoryMock) deleteTask(id string) (bool, error) {
+func (t taskRepositoryMock) PatchTask(caller domain.Identity, task domain.Task, id string, fields []string,
+	next *domain.Task, revision domain.TaskRevision) (int64, error) {
+	return 0, taskRepositoryPatchTaskMock(task, id, fields)
+}
+
+func (t taskRepositoryMock) DeleteTask(caller domain.Identity, id string, version int64, deletedOn int64, cascade bool,
+	revision domain.TaskRevision) (bool, error) {
 	return taskRepositoryDeleteTaskMock(id)
 }
 
-func (t taskRepositoryMock) searchTasks(params map[string]string) ([]domain.Task, error) {
+func (t taskRepositoryMock) SearchTasks(caller domain.Identity, params map[string]string) ([]domain.Task, error) {
 	return taskRepositorySearchTasksMock(params)
 }
 
+func (t taskRepositoryMock) ApplyBatch(caller domain.Identity, writes []domain.TaskWrite, atomic bool) ([]domain.TaskWriteResult, error) {
+	return taskRepositoryApplyBatchMock(writes, atomic)
+}
+
+func (t taskRepositoryMock) GetTagCounts(caller domain.Identity) ([]domain.Tag, error) {
+	return taskRepositoryGetTagCountsMock()
+}
+
+func (t taskRepositoryMock) GetSubtasks(caller domain.Identity, id string) ([]domain.Task, error) {
+	return taskRepositoryGetSubtasksMock(id)
+}
+
 func TestGetTaskByIdHandler(t *testing.T) {
 	t.Parallel()
 	taskRepository = taskRepositoryMock{}
@@ -90,7 +172,7 @@ func TestGetAllTasksHandler(t *testing.T) {
 		t.Run(scenario.Name, func(t *testing.T) {
 
 			request := httptest.NewRequest("GET", scenario.Url, nil)
-			taskRepositoryGetAllTasksMock = func(page int64, perPage int64) ([]domain.Task, error) {
+			taskRepositoryGetAllTasksMock = func(page int64, perPage int64, sort []domain.SortKey) ([]domain.Task, error) {
 				return scenario.ExpectedTasks, scenario.ScenarioErr
 			}
 
@@ -124,7 +206,6 @@ func TestCreateTaskHandler(t *testing.T) {
 }
 
 func TestUpdateTaskByIdHandler(t *testing.T) {
-	t.Parallel()
 	taskRepository = taskRepositoryMock{}
 	scenarios := testUtils.GetServiceTestScenarios(testUtils.UpdateTaskKey)
 
@@ -135,11 +216,15 @@ func TestUpdateTaskByIdHandler(t *testing.T) {
 	for _, scenario := range scenarios {
 		t.Run(scenario.Name, func(t *testing.T) {
 
+			taskRepositoryGetByIdMock = func(id string) ([]domain.Task, error) {
+				return []domain.Task{{Id: 1, Title: "sample", Status: "open"}}, nil
+			}
 			taskRepositoryUpdateTaskMock = func(task domain.Task, id string) error {
 				return scenario.ScenarioErr
 			}
 
 			request := httptest.NewRequest("PUT", "http://localhost.com/task/1", bytes.NewBuffer(scenario.Data))
+			request.Header.Set(fiber.HeaderIfMatch, "*")
 			response, _ := testApp.Test(request)
 			compareResponses(t, scenario.StatusCode, scenario.Task, response)
 		})
@@ -147,7 +232,6 @@ func TestUpdateTaskByIdHandler(t *testing.T) {
 }
 
 func TestDeleteTaskByIdHandler(t *testing.T) {
-	t.Parallel()
 	taskRepository = taskRepositoryMock{}
 	scenarios := testUtils.GetServiceTestScenarios(testUtils.DeleteTaskKey)
 
@@ -155,7 +239,11 @@ func TestDeleteTaskByIdHandler(t *testing.T) {
 		return DeleteTaskByIdHandler(c)
 	})
 
+	taskRepositoryGetByIdMock = func(id string) ([]domain.Task, error) {
+		return []domain.Task{{Id: 8, Title: "sample", Status: "open", Version: 1}}, nil
+	}
 	request := httptest.NewRequest("DELETE", "http://localhost.com/task/8", nil)
+	request.Header.Set(fiber.HeaderIfMatch, "*")
 	for _, scenario := range scenarios {
 		taskRepositoryDeleteTaskMock = func(id string) (bool, error) {
 			return scenario.RowsAffected, scenario.ScenarioErr
@@ -188,6 +276,111 @@ func TestSearchHandler(t *testing.T) {
 	}
 }
 
+func TestTaskHandlersWithMemoryRepository(t *testing.T) {
+	taskRepository, _ = repository.NewRepository(repository.MemoryDriver, "")
+	app := fiber.New()
+	app.Get("/task/:id", GetTaskByIdHandler)
+	app.Get("/tasks/search", SearchHandler)
+	app.Post("/task", CreateTaskHandler)
+	app.Put("/task/:id", UpdateTaskByIdHandler)
+	app.Delete("/task/:id", DeleteTaskByIdHandler)
+
+	created := domain.Task{Id: 1, AddedOn: 123, DueBy: 123, Title: "sample", Description: "sample", Status: "open", Priority: "P2"}
+	response, _ := app.Test(httptest.NewRequest("POST", "http://localhost.com/task",
+		bytes.NewBufferString(`{"added_on": 123, "due_by": 123, "title": "sample", "description": "sample", "status": "open"}`)))
+	compareResponses(t, http.StatusOK, created, response)
+
+	updated := created
+	updated.SetStatus("done")
+	request := httptest.NewRequest("PUT", "http://localhost.com/task/1", bytes.NewBufferString(getStringFromStruct(updated)))
+	request.Header.Set(fiber.HeaderIfMatch, `"1"`)
+	response, _ = app.Test(request)
+	compareResponses(t, http.StatusOK, updated, response)
+
+	response, _ = app.Test(httptest.NewRequest("GET", "http://localhost.com/task/1", nil))
+	compareResponses(t, http.StatusOK, updated, response)
+
+	response, _ = app.Test(httptest.NewRequest("GET", "http://localhost.com/tasks/search?status=done", nil))
+	compareResponses(t, http.StatusOK, []domain.Task{updated}, response)
+
+	request = httptest.NewRequest("DELETE", "http://localhost.com/task/1", nil)
+	request.Header.Set(fiber.HeaderIfMatch, `"2"`)
+	response, _ = app.Test(request)
+	compareResponses(t, http.StatusNoContent, nil, response)
+
+	response, _ = app.Test(httptest.NewRequest("GET", "http://localhost.com/task/1", nil))
+	compareResponses(t, http.StatusNotFound, nil, response)
+}
+
+func TestPatchTaskByIdHandler(t *testing.T) {
+	store, _ := repository.NewRepository(repository.MemoryDriver, "")
+	SetRepository(store)
+	app := fiber.New()
+	app.Get("/task/:id", GetTaskByIdHandler)
+	app.Post("/task", CreateTaskHandler)
+	app.Patch("/task/:id", PatchTaskByIdHandler)
+
+	patch := func(id string, body string) *http.Response {
+		request := httptest.NewRequest("PATCH", "http://localhost.com/task/"+id, bytes.NewBufferString(body))
+		request.Header.Set("Content-Type", "application/merge-patch+json")
+		request.Header.Set(fiber.HeaderIfMatch, "*")
+		response, _ := app.Test(request)
+		return response
+	}
+
+	task := domain.Task{Id: 1, AddedOn: 123, DueBy: 123, Title: "sample", Description: "keep me", Status: "open",
+		Tags: []string{"home"}, Priority: "P1"}
+	response, _ := app.Test(httptest.NewRequest("POST", "http://localhost.com/task", bytes.NewBufferString(getStringFromStruct(task))))
+	compareResponses(t, http.StatusOK, task, response)
+
+	t.Run("should change only the fields present", func(t *testing.T) {
+		task.SetStatus("in-progress")
+		compareResponses(t, http.StatusOK, task, patch("1", `{"status": "in-progress"}`))
+
+		task.SetTags([]string{"urgent", "work"})
+		task.SetPriority("P0")
+		compareResponses(t, http.StatusOK, task, patch("1", `{"tags": ["work", "Urgent"], "priority": "p0", "owner_id": 42}`))
+
+		response, _ := app.Test(httptest.NewRequest("GET", "http://localhost.com/task/1", nil))
+		compareResponses(t, http.StatusOK, task, response)
+	})
+
+	t.Run("should clear fields patched with null", func(t *testing.T) {
+		task.SetDescription("")
+		task.SetTags(nil)
+		compareResponses(t, http.StatusOK, task, patch("1", `{"description": null, "tags": null, "status": null}`))
+
+		response, _ := app.Test(httptest.NewRequest("GET", "http://localhost.com/task/1", nil))
+		compareResponses(t, http.StatusOK, task, response)
+	})
+
+	t.Run("should reject invalid patches", func(t *testing.T) {
+		compareResponses(t, http.StatusBadRequest, nil, patch("1", `["status", "done"]`))
+		compareResponses(t, http.StatusBadRequest, nil, patch("1", `{"status": "done"`))
+		compareResponses(t, http.StatusBadRequest, nil, patch("1", `{"id": 2}`))
+		compareResponses(t, http.StatusBadRequest, nil, patch("1", `{"title": 42}`))
+		compareResponses(t, http.StatusBadRequest, nil, patch("1", `{"priority": "P9"}`))
+		compareResponses(t, http.StatusBadRequest, nil, patch("1", `{"parent_id": 1}`))
+		compareResponses(t, http.StatusUnprocessableEntity, nil, patch("1", `{"status": "closed"}`))
+		compareResponses(t, http.StatusNotFound, nil, patch("2", `{"status": "done"}`))
+
+		response, _ := app.Test(httptest.NewRequest("GET", "http://localhost.com/task/1", nil))
+		compareResponses(t, http.StatusOK, task, response)
+	})
+
+	t.Run("should schedule the next occurrence of recurring tasks", func(t *testing.T) {
+		task.SetRecurrence("FREQ=DAILY")
+		compareResponses(t, http.StatusOK, task, patch("1", `{"recurrence": "FREQ=DAILY"}`))
+
+		response := patch("1", `{"status": "done"}`)
+		var completed domain.Task
+		_ = json.NewDecoder(response.Body).Decode(&completed)
+		if response.StatusCode != http.StatusOK || completed.GetRecurrence() != "" || completed.GetNextOccurrenceId() != 2 {
+			t.Errorf("Expected next occurrence 2, Got: %d with %v", response.StatusCode, completed)
+		}
+	})
+}
+
 func TestSingleParamBuildQueryParams(t *testing.T) {
 	t.Parallel()
 	scenarios := []domain.SearchParamScenario{
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"my-todo-app/config"
+	"my-todo-app/domain"
+	"net"
+	"strings"
+)
+
+const tenantKey = "tenant"
+
+var (
+	tenantHeader     string
+	tenantBaseDomain string
+)
+
+func init() {
+	tenantHeader = config.TenantHeader
+	tenantBaseDomain = config.TenantBaseDomain
+}
+
+// resolveTenant returns the tenant a request asks for through the tenant
+// header or a subdomain of the base domain, empty when it names none. Asking
+// for two different tenants at once is an error.
+func resolveTenant(c *fiber.Ctx) (string, error) {
+	var fromHeader, fromHost string
+	if tenantHeader != "" {
+		fromHeader = strings.ToLower(strings.TrimSpace(c.Get(tenantHeader)))
+	}
+	if tenantBaseDomain != "" {
+		fromHost = getSubdomain(c.Hostname())
+	}
+
+	switch {
+	case fromHeader != "" && !domain.IsTenantId(fromHeader):
+		return "", fmt.Errorf("invalid tenant %q in %s header", fromHeader, tenantHeader)
+	case fromHost != "" && !domain.IsTenantId(fromHost):
+		return "", fmt.Errorf("invalid tenant %q in host", fromHost)
+	case fromHeader != "" && fromHost != "" && fromHeader != fromHost:
+		return "", fmt.Errorf("tenant %q in %s header does not match tenant %q in host", fromHeader, tenantHeader, fromHost)
+	case fromHeader != "":
+		return fromHeader, nil
+	}
+	return fromHost, nil
+}
+
+// getSubdomain returns what host has in front of the base domain, port
+// excluded, or nothing for hosts outside of it.
+func getSubdomain(host string) string {
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if !strings.HasSuffix(host, "."+tenantBaseDomain) {
+		return ""
+	}
+	return strings.TrimSuffix(host, "."+tenantBaseDomain)
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt"
+	"my-todo-app/domain"
+	"my-todo-app/repository"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTenantIsolation(t *testing.T) {
+	defer func(domain string) { tenantBaseDomain = domain }(tenantBaseDomain)
+	tenantBaseDomain = "todo.example.com"
+	defer func(keys domain.JwtKeys) { jwtKeys = keys }(jwtKeys)
+	secret := []byte("secret")
+	jwtKeys = domain.NewJwtKeys("", "")
+	jwtKeys.Hmac[""] = secret
+	jwtKeys.TenantClaim = "tenant"
+
+	store, _ := repository.NewRepository(repository.MemoryDriver, "")
+	SetRepository(store)
+
+	app := fiber.New()
+	app.Use(AuthMiddleware)
+	app.Post("/users", CreateUserHandler)
+	app.Get("/tasks", GetAllTasksHandler)
+	app.Get("/task/:id", GetTaskByIdHandler)
+	app.Post("/task", CreateTaskHandler)
+	app.Put("/task/:id", UpdateTaskByIdHandler)
+	app.Delete("/task/:id", DeleteTaskByIdHandler)
+	app.Post("/lists", CreateListHandler)
+	app.Post("/lists/:id/members", AddListMemberHandler)
+	app.Put("/users/:id/role", UpdateUserRoleHandler)
+
+	request := func(method string, url string, body string, username string, tenant string) *http.Response {
+		req := httptest.NewRequest(method, url, bytes.NewBufferString(body))
+		if username != "" {
+			req.SetBasicAuth(username, "correct horse")
+		}
+		if tenant != "" {
+			req.Header.Set("X-Tenant-ID", tenant)
+		}
+		response, _ := app.Test(req, -1)
+		return response
+	}
+	register := func(url string, username string, tenant string, authorization string) *http.Response {
+		req := httptest.NewRequest("POST", url, bytes.NewBufferString(`{"username": "`+username+`", "password": "correct horse"}`))
+		if tenant != "" {
+			req.Header.Set("X-Tenant-ID", tenant)
+		}
+		if authorization != "" {
+			req.Header.Set(fiber.HeaderAuthorization, authorization)
+		}
+		response, _ := app.Test(req, -1)
+		return response
+	}
+	bearer := func(username string, tenant string) string {
+		claims := jwt.MapClaims{"sub": username, "tenant": tenant, "exp": time.Now().Add(time.Hour).Unix()}
+		token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+		return "Bearer " + token
+	}
+	basic := func(username string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":correct horse"))
+	}
+
+	t.Run("should register users in the default tenant or the tenant of their invite", func(t *testing.T) {
+		scenarios := []struct {
+			url           string
+			username      string
+			header        string
+			authorization string
+			tenant        string
+		}{
+			{"http://localhost.com/users", "alice", "Acme", bearer("alice", "acme"), "acme"},
+			{"http://globex.todo.example.com/users", "bob", "", bearer("bob", "globex"), "globex"},
+			{"http://localhost.com/users", "carol", "", "", domain.DefaultTenant},
+			{"http://acme.todo.example.com:8080/users", "dave", "acme", bearer("dave", "acme"), "acme"},
+			{"http://localhost.com/users", "carol", "", bearer("carol", "acme"), "acme"},
+		}
+		for _, scenario := range scenarios {
+			response := register(scenario.url, scenario.username, scenario.header, scenario.authorization)
+			var user domain.User
+			_ = json.NewDecoder(response.Body).Decode(&user)
+			if response.StatusCode != http.StatusCreated || user.GetTenantId() != scenario.tenant {
+				t.Errorf("Expected %s in tenant %s, Got: %d with %v", scenario.username, scenario.tenant, response.StatusCode, user)
+			}
+		}
+	})
+
+	t.Run("should refuse registrations in other tenants without an invite", func(t *testing.T) {
+		scenarios := []struct {
+			url           string
+			header        string
+			authorization string
+			statusCode    int
+		}{
+			{"http://localhost.com/users", "acme", "", http.StatusForbidden},
+			{"http://acme.todo.example.com/users", "", "", http.StatusForbidden},
+			{"http://localhost.com/users", "globex", bearer("mallory", "acme"), http.StatusForbidden},
+			{"http://localhost.com/users", "acme", bearer("trudy", "acme"), http.StatusUnauthorized},
+			{"http://localhost.com/users", "acme", basic("alice"), http.StatusForbidden},
+		}
+		for _, scenario := range scenarios {
+			compareResponses(t, scenario.statusCode, nil, register(scenario.url, "mallory", scenario.header, scenario.authorization))
+		}
+		compareResponses(t, http.StatusConflict, nil, register("http://localhost.com/users", "carol", "", ""))
+	})
+
+	t.Run("should let admins register users in their tenant", func(t *testing.T) {
+		_, _ = store.UpdateUserRole(1, domain.RoleAdmin)
+		response := register("http://localhost.com/users", "erin", "acme", basic("alice"))
+		var user domain.User
+		_ = json.NewDecoder(response.Body).Decode(&user)
+		if response.StatusCode != http.StatusCreated || user.GetTenantId() != "acme" {
+			t.Errorf("Expected erin in tenant acme, Got: %d with %v", response.StatusCode, user)
+		}
+		compareResponses(t, http.StatusUnauthorized, nil, register("http://localhost.com/users", "frank", "", basic("alice")))
+	})
+
+	t.Run("should reject invalid or conflicting tenants", func(t *testing.T) {
+		compareResponses(t, http.StatusBadRequest, nil, request("GET", "http://localhost.com/tasks", "", "alice", "not_a_tenant"))
+		compareResponses(t, http.StatusBadRequest, nil, request("GET", "http://a.b.todo.example.com/tasks", "", "alice", ""))
+		compareResponses(t, http.StatusBadRequest, nil, request("GET", "http://globex.todo.example.com/tasks", "", "alice", "acme"))
+	})
+
+	compareResponses(t, http.StatusOK, domain.Task{Id: 1, Title: "acme plans", Status: "open", Priority: "P2", OwnerId: 1},
+		request("POST", "http://localhost.com/task", `{"title": "acme plans"}`, "alice", "acme"))
+
+	t.Run("should deny requests for another tenant than the caller's", func(t *testing.T) {
+		compareResponses(t, http.StatusUnauthorized, nil, request("GET", "http://localhost.com/task/1", "", "alice", "globex"))
+		compareResponses(t, http.StatusUnauthorized, nil, request("GET", "http://globex.todo.example.com/task/1", "", "alice", ""))
+		req := httptest.NewRequest("GET", "http://globex.todo.example.com/task/1", nil)
+		req.Header.Set(fiber.HeaderAuthorization, bearer("alice", "acme"))
+		response, _ := app.Test(req, -1)
+		compareResponses(t, http.StatusForbidden, nil, response)
+		compareResponses(t, http.StatusOK, domain.Task{Id: 1, Title: "acme plans", Status: "open", Priority: "P2", OwnerId: 1},
+			request("GET", "http://acme.todo.example.com/task/1", "", "alice", ""))
+	})
+
+	t.Run("should not find tasks of other tenants by guessing ids", func(t *testing.T) {
+		compareResponses(t, http.StatusOK, []domain.Task{}, request("GET", "http://localhost.com/tasks", "", "bob", "globex"))
+		compareResponses(t, http.StatusNotFound, nil, request("GET", "http://localhost.com/task/1", "", "bob", "globex"))
+		compareResponses(t, http.StatusNotFound, nil, request("PUT", "http://localhost.com/task/1", `{"id": 1, "title": "stolen", "status": "open"}`, "bob", "globex"))
+		compareResponses(t, http.StatusNotFound, nil, request("DELETE", "http://localhost.com/task/1", "", "bob", "globex"))
+		compareResponses(t, http.StatusOK, domain.Task{Id: 1, Title: "acme plans", Status: "open", Priority: "P2", OwnerId: 1},
+			request("GET", "http://localhost.com/task/1", "", "alice", "acme"))
+	})
+
+	t.Run("should not reach users of other tenants", func(t *testing.T) {
+		compareResponses(t, http.StatusCreated, domain.List{Id: 1, Name: "Q3", OwnerId: 1},
+			request("POST", "http://localhost.com/lists", `{"name": "Q3"}`, "alice", "acme"))
+		compareResponses(t, http.StatusBadRequest, nil, request("POST", "http://localhost.com/lists/1/members", `{"username": "bob"}`, "alice", "acme"))
+		compareResponses(t, http.StatusNotFound, nil, request("PUT", "http://localhost.com/users/2/role", `{"role": "viewer"}`, "alice", "acme"))
+	})
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"my-todo-app/config"
+	"my-todo-app/domain"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var errParentInTrash = errors.New("parent task is in the trash, restore it first")
+
+var (
+	trashRetention     time.Duration
+	trashPurgeInterval time.Duration
+)
+
+func init() {
+	trashRetention = config.TrashRetention
+	trashPurgeInterval = config.TrashPurgeInterval
+}
+
+// GetTrashHandler lists the deleted tasks of the caller, the most recently
+// deleted first, with the time each was deleted on.
+func GetTrashHandler(c *fiber.Ctx) error {
+	page, _ := strconv.ParseInt(c.Query("page", "0"), 10, 64)
+	perPage, _ := strconv.ParseInt(c.Query("perPage", "10"), 10, 64)
+
+	tasks, err := trashRepository.GetTrash(getCaller(c), page, perPage)
+	if err == nil {
+		logger.Info(fmt.Sprintf("No. of tasks fetched from the trash: %d", len(tasks)))
+		return c.JSON(tasks)
+	}
+
+	logger.Error(fmt.Sprintf("Error fetching trash: %s", err))
+	return c.SendStatus(http.StatusInternalServerError)
+}
+
+// RestoreTaskHandler takes a task out of the trash together with the subtasks
+// deleted along with it. A subtask is only restored once its parent is.
+func RestoreTaskHandler(c *fiber.Ctx) error {
+	id := c.Params("id")
+	caller := getCaller(c)
+	trashed, err := trashRepository.GetTrashedTask(caller, id)
+	if err == nil && len(trashed) == 0 {
+		logger.Info(fmt.Sprintf("No task found with id: %s in the trash", id))
+		return c.SendStatus(http.StatusNotFound)
+	}
+	if err == nil && trashed[0].GetParentId() != 0 {
+		var parents []domain.Task
+		parents, err = taskRepository.GetTaskById(caller, strconv.FormatInt(trashed[0].GetParentId(), 10))
+		if err == nil && len(parents) == 0 {
+			logger.Info(fmt.Sprintf("Refusing to restore task with id=%s: %s", id, errParentInTrash))
+			return c.Status(http.StatusConflict).JSON(fiber.Map{"error": errParentInTrash.Error()})
+		}
+	}
+
+	restored := false
+	if err == nil {
+		restored, err = trashRepository.RestoreTask(caller, id, newRevision(caller, domain.HistoryRestore))
+	}
+	if err == nil && !restored {
+		logger.Info(fmt.Sprintf("No task found with id: %s in the trash", id))
+		return c.SendStatus(http.StatusNotFound)
+	}
+	var task []domain.Task
+	if err == nil {
+		task, err = taskRepository.GetTaskById(caller, id)
+	}
+	if err == nil && len(task) > 0 {
+		logger.Info(fmt.Sprintf("Restored task with id: %s from the trash", id))
+		setETag(c, task[0])
+		return c.JSON(task[0])
+	}
+	if err == nil {
+		err = errors.New("task is missing once restored")
+	}
+
+	logger.Error(fmt.Sprintf("Error restoring task with id=%s: %s", id, err))
+	return c.SendStatus(http.StatusInternalServerError)
+}
+
+// PurgeTrash removes for good the tasks of every tenant that have been in the
+// trash for longer than app.trash.retention, with the files attached to them.
+func PurgeTrash() {
+	if trashRetention <= 0 {
+		return
+	}
+	purged, attachments, err := trashRepository.PurgeTrash(toMillis(time.Now().Add(-trashRetention)))
+	// the attachments already removed lose their files even if a later part fails
+	for _, attachment := range attachments {
+		deleteBlob(attachment)
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error purging trash after %d task(s): %s", purged, err))
+		return
+	}
+	logger.Info(fmt.Sprintf("Purged %d task(s) and %d attachment(s) from the trash", purged, len(attachments)))
+}
+
+// ScheduleTrashPurge runs PurgeTrash every app.trash.purgeInterval until the
+// returned function is called.
+func ScheduleTrashPurge() func() {
+	if trashPurgeInterval <= 0 {
+		return func() {}
+	}
+
+	ticker := time.NewTicker(trashPurgeInterval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				PurgeTrash()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"my-todo-app/domain"
+	"my-todo-app/repository"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTrash(t *testing.T) {
+	store, _ := repository.NewRepository(repository.MemoryDriver, "")
+	SetRepository(store)
+	app := fiber.New()
+	app.Post("/task", CreateTaskHandler)
+	app.Get("/tasks", GetAllTasksHandler)
+	app.Delete("/task/:id", DeleteTaskByIdHandler)
+	app.Post("/task/:id/restore", RestoreTaskHandler)
+	app.Get("/trash", GetTrashHandler)
+
+	request := func(method string, url string, body string) *http.Response {
+		req := httptest.NewRequest(method, "http://localhost.com"+url, bytes.NewBufferString(body))
+		req.Header.Set(fiber.HeaderIfMatch, "*")
+		response, _ := app.Test(req)
+		return response
+	}
+	getIds := func(url string) []int64 {
+		var tasks []domain.Task
+		_ = json.NewDecoder(request("GET", url, "").Body).Decode(&tasks)
+		ids := []int64{}
+		for _, task := range tasks {
+			ids = append(ids, task.GetId())
+		}
+		return ids
+	}
+
+	request("POST", "/task", `{"title": "parent", "status": "open"}`)
+	request("POST", "/task", `{"title": "child", "status": "open", "parent_id": 1}`)
+	request("POST", "/task", `{"title": "other", "status": "open"}`)
+
+	t.Run("should move deleted tasks to the trash", func(t *testing.T) {
+		compareResponses(t, http.StatusNoContent, nil, request("DELETE", "/task/2", ""))
+		// the child is deleted on its own, before its parent
+		time.Sleep(2 * time.Millisecond)
+		compareResponses(t, http.StatusNoContent, nil, request("DELETE", "/task/1", ""))
+
+		if ids := getIds("/tasks"); !reflect.DeepEqual(ids, []int64{3}) {
+			t.Errorf("Expected only task 3 to be listed, Got: %v", ids)
+		}
+		if ids := getIds("/trash"); len(ids) != 2 {
+			t.Errorf("Expected tasks 1 and 2 in the trash, Got: %v", ids)
+		}
+		compareResponses(t, http.StatusNotFound, nil, request("DELETE", "/task/1", ""))
+	})
+
+	t.Run("should restore a subtask only after its parent", func(t *testing.T) {
+		compareResponses(t, http.StatusConflict, nil, request("POST", "/task/2/restore", ""))
+
+		response := request("POST", "/task/1/restore", "")
+		compareResponses(t, http.StatusOK, domain.Task{Id: 1, Title: "parent", Status: "open", Priority: "P2", Version: 3},
+			response)
+		if response.Header.Get(fiber.HeaderETag) != `"3"` {
+			t.Errorf("Expected ETag \"3\", Got: %s", response.Header.Get(fiber.HeaderETag))
+		}
+		compareResponses(t, http.StatusOK, domain.Task{Id: 2, Title: "child", Status: "open", ParentId: 1, Priority: "P2"},
+			request("POST", "/task/2/restore", ""))
+		compareResponses(t, http.StatusNotFound, nil, request("POST", "/task/2/restore", ""))
+
+		if ids := getIds("/tasks"); !reflect.DeepEqual(ids, []int64{1, 2, 3}) {
+			t.Errorf("Expected every task to be listed again, Got: %v", ids)
+		}
+	})
+
+	t.Run("should purge tasks older than the retention", func(t *testing.T) {
+		defer func(retention time.Duration) { trashRetention = retention }(trashRetention)
+
+		compareResponses(t, http.StatusNoContent, nil, request("DELETE", "/task/3", ""))
+		PurgeTrash()
+		if ids := getIds("/trash"); !reflect.DeepEqual(ids, []int64{3}) {
+			t.Errorf("Expected task 3 to be kept, Got: %v", ids)
+		}
+
+		trashRetention = time.Millisecond
+		time.Sleep(5 * time.Millisecond)
+		PurgeTrash()
+		if ids := getIds("/trash"); len(ids) != 0 {
+			t.Errorf("Expected the trash to be empty, Got: %v", ids)
+		}
+		compareResponses(t, http.StatusNotFound, nil, request("POST", "/task/3/restore", ""))
+	})
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"my-todo-app/domain"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	maxUsernameLength = 64
+	minPasswordLength = 8
+	// bcrypt ignores everything past 72 bytes
+	maxPasswordLength = 72
+)
+
+// CreateUserHandler registers a new account. It is public, everything else
+// needs the credentials chosen here; see getRegistrationTenant for the tenant
+// the account joins.
+func CreateUserHandler(c *fiber.Ctx) error {
+	var credentials domain.Credentials
+	err := json.Unmarshal(c.Body(), &credentials)
+	if err == nil {
+		err = validateCredentials(credentials)
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("Invalid registration: %s", err))
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	username := domain.NormalizeUsername(credentials.Username)
+	tenant, status := getRegistrationTenant(c, username)
+	if status == http.StatusUnauthorized {
+		return sendUnauthorized(c)
+	}
+	if status != http.StatusOK {
+		return c.SendStatus(status)
+	}
+
+	existing, err := userRepository.GetUserByName(tenant, username)
+	if err == nil && len(existing) > 0 {
+		logger.Info(fmt.Sprintf("Username %s is already taken in tenant %s", username, tenant))
+		return c.SendStatus(http.StatusConflict)
+	}
+
+	var user domain.User
+	if err == nil {
+		user, err = domain.NewUser(username, credentials.Password, toMillis(time.Now()))
+	}
+	if err == nil {
+		user.Role = defaultRole
+		user.TenantId = tenant
+		user.Id, err = userRepository.CreateUser(user)
+	}
+	if err == nil {
+		logger.Info(fmt.Sprintf("Registered user %s with id: %d", username, user.GetId()))
+		return c.Status(http.StatusCreated).JSON(user)
+	}
+
+	logger.Error(fmt.Sprintf("Error registering user %s: %s", username, err))
+	return c.SendStatus(http.StatusInternalServerError)
+}
+
+// GetCurrentUserHandler returns the account of the caller.
+func GetCurrentUserHandler(c *fiber.Ctx) error {
+	caller := getCaller(c)
+	users, err := userRepository.GetUserByName(caller.TenantId, caller.Username)
+	if err == nil && len(users) == 0 {
+		logger.Info(fmt.Sprintf("No user found with name: %s", caller.Username))
+		return c.SendStatus(http.StatusNotFound)
+	}
+	if err == nil {
+		return c.JSON(users[0])
+	}
+
+	logger.Error(fmt.Sprintf("Error fetching user %s: %s", caller.Username, err))
+	return c.SendStatus(http.StatusInternalServerError)
+}
+
+// getRegistrationTenant returns the tenant a user registering as username
+// joins, with the status to answer when they may not. Anyone may join the
+// default tenant; other tenants take an invite, a bearer token for username
+// whose tenant claim names the tenant, or the credentials of one of its admins.
+func getRegistrationTenant(c *fiber.Ctx, username string) (string, int) {
+	requested, _ := c.Locals(tenantKey).(string)
+	if c.Get(fiber.HeaderAuthorization) == "" {
+		if requested != "" && requested != domain.DefaultTenant {
+			logger.Warn(fmt.Sprintf("Refused to register %s in tenant %s without an invite", username, requested))
+			return "", http.StatusForbidden
+		}
+		return domain.DefaultTenant, http.StatusOK
+	}
+
+	tenant, invited := getInvitedTenant(c, username)
+	if !invited {
+		caller, found, err := authenticate(c, requested)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Error authenticating registration of %s: %s", username, err))
+			return "", http.StatusInternalServerError
+		}
+		if !found {
+			return "", http.StatusUnauthorized
+		}
+		if !caller.Can(domain.PermissionManageUsers) || !caller.CanWrite() {
+			logger.Warn(fmt.Sprintf("Refused registration of %s by user %s who is no admin", username, caller.Username))
+			return "", http.StatusForbidden
+		}
+		tenant = caller.TenantId
+	}
+
+	if requested != "" && requested != tenant {
+		logger.Warn(fmt.Sprintf("Refused to register %s in tenant %s with credentials of tenant %s", username, requested, tenant))
+		return "", http.StatusForbidden
+	}
+	return tenant, http.StatusOK
+}
+
+// getInvitedTenant reports whether the request carries a bearer token inviting
+// username, and the tenant named by its tenant claim, the default tenant when
+// it names none.
+func getInvitedTenant(c *fiber.Ctx, username string) (string, bool) {
+	scheme, token := splitAuthorization(c.Get(fiber.HeaderAuthorization))
+	if !strings.EqualFold(scheme, "bearer") || jwtKeys.IsEmpty() {
+		return "", false
+	}
+	subject, tenant, err := jwtKeys.Verify(token)
+	if err != nil || domain.NormalizeUsername(subject) != username {
+		return "", false
+	}
+	if tenant == "" {
+		tenant = domain.DefaultTenant
+	}
+	return tenant, true
+}
+
+func validateCredentials(credentials domain.Credentials) error {
+	username := domain.NormalizeUsername(credentials.Username)
+	if username == "" || len(username) > maxUsernameLength {
+		return fmt.Errorf("username must be 1 to %d characters", maxUsernameLength)
+	}
+	for _, r := range username {
+		if r == ':' || r < ' ' {
+			return errors.New("username must not contain colons or control characters")
+		}
+	}
+	if len(credentials.Password) < minPasswordLength || len(credentials.Password) > maxPasswordLength {
+		return fmt.Errorf("password must be %d to %d bytes", minPasswordLength, maxPasswordLength)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"my-todo-app/domain"
+	"my-todo-app/repository"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUserAccounts(t *testing.T) {
+	store, _ := repository.NewRepository(repository.MemoryDriver, "")
+	SetRepository(store)
+	app := fiber.New()
+	app.Use(AuthMiddleware)
+	app.Post("/users", CreateUserHandler)
+	app.Get("/users/me", GetCurrentUserHandler)
+	app.Get("/task/:id", GetTaskByIdHandler)
+	app.Get("/tasks", GetAllTasksHandler)
+	app.Post("/task", CreateTaskHandler)
+	app.Delete("/task/:id", DeleteTaskByIdHandler)
+
+	request := func(method string, url string, body string, username string, password string) *http.Response {
+		req := httptest.NewRequest(method, "http://localhost.com"+url, bytes.NewBufferString(body))
+		if username != "" {
+			req.SetBasicAuth(username, password)
+		}
+		response, _ := app.Test(req, -1)
+		return response
+	}
+
+	t.Run("should register users", func(t *testing.T) {
+		for _, username := range []string{" Alice", "bob"} {
+			response := request("POST", "/users", `{"username": "`+username+`", "password": "correct horse"}`, "", "")
+			var user domain.User
+			_ = json.NewDecoder(response.Body).Decode(&user)
+			if response.StatusCode != http.StatusCreated || user.GetUsername() != domain.NormalizeUsername(username) {
+				t.Errorf("Expected user %s to be created, Got: %d with %v", username, response.StatusCode, user)
+			}
+		}
+	})
+
+	t.Run("should reject invalid registrations", func(t *testing.T) {
+		scenarios := []struct {
+			body       string
+			statusCode int
+		}{
+			{`{"username": "ALICE", "password": "correct horse"}`, http.StatusConflict},
+			{`{"username": " ", "password": "correct horse"}`, http.StatusBadRequest},
+			{`{"username": "a:b", "password": "correct horse"}`, http.StatusBadRequest},
+			{`{"username": "carol", "password": "short"}`, http.StatusBadRequest},
+			{`{"username": "carol"`, http.StatusBadRequest},
+		}
+
+		for _, scenario := range scenarios {
+			response := request("POST", "/users", scenario.body, "", "")
+			if response.StatusCode != scenario.statusCode {
+				t.Errorf("Expected status code: %d for %s, Got: %d", scenario.statusCode, scenario.body, response.StatusCode)
+			}
+		}
+	})
+
+	t.Run("should reject missing or wrong credentials", func(t *testing.T) {
+		response := request("GET", "/users/me", "", "", "")
+		if response.StatusCode != http.StatusUnauthorized || !strings.HasPrefix(response.Header.Get("WWW-Authenticate"), "Basic") {
+			t.Errorf("Expected basic challenge, Got: %d with %v", response.StatusCode, response.Header)
+		}
+
+		response = request("GET", "/users/me", "", "alice", "wrong horse")
+		compareResponses(t, http.StatusUnauthorized, nil, response)
+
+		response = request("GET", "/users/me", "", "mallory", "correct horse")
+		compareResponses(t, http.StatusUnauthorized, nil, response)
+	})
+
+	t.Run("should keep tasks private to their owner", func(t *testing.T) {
+		response := request("POST", "/task", `{"title": "alice's task"}`, "alice", "correct horse")
+		compareResponses(t, http.StatusOK, domain.Task{Id: 1, Title: "alice's task", Status: "open", Priority: "P2", OwnerId: 1}, response)
+
+		response = request("GET", "/task/1", "", "bob", "correct horse")
+		compareResponses(t, http.StatusNotFound, nil, response)
+
+		response = request("GET", "/tasks", "", "bob", "correct horse")
+		compareResponses(t, http.StatusOK, []domain.Task{}, response)
+
+		response = request("DELETE", "/task/1", "", "bob", "correct horse")
+		compareResponses(t, http.StatusNotFound, nil, response)
+
+		response = request("GET", "/task/1", "", "ALICE", "correct horse")
+		compareResponses(t, http.StatusOK, domain.Task{Id: 1, Title: "alice's task", Status: "open", Priority: "P2", OwnerId: 1}, response)
+	})
+
+	t.Run("should return current user", func(t *testing.T) {
+		response := request("GET", "/users/me", "", "bob", "correct horse")
+		body := getStringFromResponseBody(response.Body)
+		if response.StatusCode != http.StatusOK || !strings.Contains(body, `"username":"bob"`) || strings.Contains(body, "password") {
+			t.Errorf("Expected bob without password hash, Got: %d with %s", response.StatusCode, body)
+		}
+	})
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"my-todo-app/domain"
+	"my-todo-app/repository"
+	"net/http"
+)
+
+// TransitionTaskHandler moves a task to the status configured for the action
+// in the URL, such as start, complete or reopen.
+func TransitionTaskHandler(c *fiber.Ctx) error {
+	id := c.Params("id")
+	action := c.Params("action")
+	status, found := workflow.Actions[action]
+	if !found {
+		logger.Error(fmt.Sprintf("Unknown transition action: %s", action))
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	caller := getCaller(c)
+	tasks, err := taskRepository.GetTaskById(caller, id)
+	if err == nil && len(tasks) == 0 {
+		logger.Info(fmt.Sprintf("No task found with id: %s", id))
+		return c.SendStatus(http.StatusNotFound)
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error fetching task with id=%s: %s", id, err))
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	task := tasks[0]
+	if err = checkTransition(task.GetStatus(), status); err != nil {
+		return sendUnprocessable(c, err)
+	}
+
+	previousStatus := task.GetStatus()
+	task.SetStatus(status)
+	err = checkBlockers(caller, id, status, previousStatus)
+	var next *domain.Task
+	if err == nil {
+		next, err = getNextOccurrence(caller, &task, tasks[0])
+	}
+	var nextId int64
+	if err == nil {
+		nextId, err = taskRepository.UpdateTask(caller, task, id, next, newRevision(caller, domain.HistoryTransition))
+	}
+	if err == nil {
+		addNextOccurrence(&task, next, nextId)
+		logger.Info(fmt.Sprintf("Task with id: %s moved to %s by %s", id, status, action))
+		task.SetVersion(task.GetVersion() + 1)
+		setETag(c, task)
+		return c.JSON(task)
+	}
+	if err == repository.ErrVersionConflict {
+		logger.Info(fmt.Sprintf("Task with id=%s changed while moving it to %s", id, status))
+		return c.SendStatus(http.StatusPreconditionFailed)
+	}
+	if err == repository.ErrTaskNotFound {
+		logger.Info(fmt.Sprintf("Task with id=%s was deleted while moving it to %s", id, status))
+		return c.SendStatus(http.StatusNotFound)
+	}
+	if err == errBlocked {
+		return sendBlocked(c, id)
+	}
+
+	logger.Error(fmt.Sprintf("Error while updating task with id=%s: %s", id, err))
+	return c.SendStatus(http.StatusInternalServerError)
+}
+
+// checkNewStatus normalizes the status of a new task, defaulting it to the initial status.
+func checkNewStatus(task *domain.Task) error {
+	status := domain.NormalizeStatus(task.GetStatus())
+	if status == "" {
+		status = workflow.InitialStatus()
+	}
+	if !workflow.IsStatus(status) {
+		return fmt.Errorf("unknown status %q, expected one of %v", task.GetStatus(), workflow.Statuses)
+	}
+	task.SetStatus(status)
+	return nil
+}
+
+// checkStatusUpdate normalizes the status of an updated task, keeping the
+// current status when it is left out.
+func checkStatusUpdate(task *domain.Task, current string) error {
+	status := domain.NormalizeStatus(task.GetStatus())
+	if status == "" {
+		status = current
+	}
+	if err := checkTransition(current, status); err != nil {
+		return err
+	}
+	task.SetStatus(status)
+	return nil
+}
+
+func checkTransition(from string, to string) error {
+	if !workflow.IsStatus(to) {
+		return fmt.Errorf("unknown status %q, expected one of %v", to, workflow.Statuses)
+	}
+	if !workflow.CanTransition(from, to) {
+		return fmt.Errorf("illegal status transition from %q to %q, allowed: %v", from, to, workflow.Transitions[from])
+	}
+	return nil
+}
+
+func sendUnprocessable(c *fiber.Ctx, err error) error {
+	logger.Error(fmt.Sprintf("Rejected task status: %s", err))
+	return c.Status(http.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+}
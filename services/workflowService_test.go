@@ -0,0 +1,131 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"my-todo-app/domain"
+	"my-todo-app/repository"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTransitionTaskHandler(t *testing.T) {
+	taskRepository = taskRepositoryMock{}
+	app := fiber.New()
+	app.Post("/task/:id/transition/:action", TransitionTaskHandler)
+
+	scenarios := []struct {
+		name       string
+		action     string
+		current    []domain.Task
+		err        error
+		status     string
+		statusCode int
+	}{
+		{"should start an open task", "start", []domain.Task{{Id: 1, Status: "open"}}, nil, "in-progress", http.StatusOK},
+		{"should reopen a done task", "reopen", []domain.Task{{Id: 1, Status: "done"}}, nil, "open", http.StatusOK},
+		{"should move legacy statuses", "complete", []domain.Task{{Id: 1, Status: "complete"}}, nil, "done", http.StatusOK},
+		{"should reject illegal transitions", "start", []domain.Task{{Id: 1, Status: "done"}}, nil, "", http.StatusUnprocessableEntity},
+		{"should reject unknown actions", "archive", []domain.Task{{Id: 1, Status: "open"}}, nil, "", http.StatusBadRequest},
+		{"should give 404 for missing task", "start", []domain.Task{}, nil, "", http.StatusNotFound},
+		{"should give 500 for database errors", "start", nil, errors.New("error while fetching Data"), "", http.StatusInternalServerError},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			var updated domain.Task
+			taskRepositoryGetByIdMock = func(id string) ([]domain.Task, error) {
+				return scenario.current, scenario.err
+			}
+			taskRepositoryUpdateTaskMock = func(task domain.Task, id string) error {
+				updated = task
+				return nil
+			}
+
+			response, _ := app.Test(httptest.NewRequest("POST", "http://localhost.com/task/1/transition/"+scenario.action, nil))
+			compareResponses(t, scenario.statusCode, domain.Task{Id: 1, Status: scenario.status}, response)
+			if scenario.statusCode == http.StatusOK && updated.GetStatus() != scenario.status {
+				t.Errorf("Expected stored status: %s, Got: %s", scenario.status, updated.GetStatus())
+			}
+		})
+	}
+}
+
+func TestStatusWorkflow(t *testing.T) {
+	taskRepository, _ = repository.NewRepository(repository.MemoryDriver, "")
+	app := fiber.New()
+	app.Post("/task", CreateTaskHandler)
+	app.Put("/task/:id", UpdateTaskByIdHandler)
+
+	response, _ := app.Test(httptest.NewRequest("POST", "http://localhost.com/task",
+		bytes.NewBufferString(`{"title": "sample"}`)))
+	compareResponses(t, http.StatusOK, domain.Task{Id: 1, Title: "sample", Status: "open", Priority: "P2"}, response)
+
+	scenarios := []struct {
+		name       string
+		method     string
+		url        string
+		body       string
+		statusCode int
+	}{
+		{"should normalize status case", "PUT", "/task/1", `{"id": 1, "title": "sample", "status": " DONE"}`, http.StatusOK},
+		{"should keep status when left out", "PUT", "/task/1", `{"id": 1, "title": "sample"}`, http.StatusOK},
+		{"should reject illegal transitions", "PUT", "/task/1", `{"id": 1, "title": "sample", "status": "in-progress"}`, http.StatusUnprocessableEntity},
+		{"should reject unknown statuses on update", "PUT", "/task/1", `{"id": 1, "title": "sample", "status": "complete"}`, http.StatusUnprocessableEntity},
+		{"should reject unknown statuses on create", "POST", "/task", `{"title": "sample", "status": "complete"}`, http.StatusUnprocessableEntity},
+		{"should give 404 when updating missing task", "PUT", "/task/2", `{"id": 2, "title": "sample", "status": "open"}`, http.StatusNotFound},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			request := httptest.NewRequest(scenario.method, "http://localhost.com"+scenario.url, bytes.NewBufferString(scenario.body))
+			request.Header.Set(fiber.HeaderIfMatch, "*")
+			response, _ := app.Test(request)
+			if response.StatusCode != scenario.statusCode {
+				t.Fatalf("Expected status code: %d, Got: %d", scenario.statusCode, response.StatusCode)
+			}
+
+			body := getStringFromResponseBody(response.Body)
+			if scenario.statusCode == http.StatusOK && !strings.Contains(body, `"status":"done"`) {
+				t.Errorf("Expected task to be done, Got: %s", body)
+			}
+			if scenario.statusCode == http.StatusUnprocessableEntity && !strings.Contains(body, `"error":`) {
+				t.Errorf("Expected an error message, Got: %s", body)
+			}
+		})
+	}
+}
+
+func TestWorkflowValidate(t *testing.T) {
+	scenarios := []struct {
+		name     string
+		workflow domain.Workflow
+		valid    bool
+	}{
+		{"should accept configured workflow", workflow, true},
+		{"should reject empty statuses", domain.Workflow{}, false},
+		{"should reject unknown completed status", domain.Workflow{
+			Statuses:  []string{"open", "closed"},
+			Completed: "done",
+		}, false},
+		{"should reject unknown transition target", domain.Workflow{
+			Statuses:    []string{"open"},
+			Transitions: map[string][]string{"open": {"done"}},
+		}, false},
+		{"should reject unknown action target", domain.Workflow{
+			Statuses: []string{"open"},
+			Actions:  map[string]string{"complete": "done"},
+		}, false},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			if err := scenario.workflow.Validate(); (err == nil) != scenario.valid {
+				t.Errorf("Expected valid: %t, Got error: %v", scenario.valid, err)
+			}
+		})
+	}
+}
@@ -1,5 +1,7 @@
 package testUtils
 
+import "my-todo-app/domain"
+
 const (
 	GetTaskByIdKey = "getTaskById"
 	GetAllTasksKey = "getAllTasks"
@@ -9,4 +11,10 @@ const (
 	SearchTaskKey  = "searchTask"
 )
 
-var columns = []string{"o_id", "o_title", "o_description", "o_addedOn", "o_dueBy", "o_status"}
+var columns = []string{"o_id", "o_title", "o_description", "o_addedOn", "o_dueBy", "o_status", "o_parentId", "o_recurrence", "o_priority", "o_ownerId", "o_listId", "o_tenantId", "o_version", "o_deletedOn"}
+
+// Caller is the identity repository tests act as; mocked rows belong to it.
+var Caller = domain.Identity{UserId: 1, Username: "tester", TenantId: "acme"}
+
+// DeletedOn is when repository tests move tasks to the trash.
+const DeletedOn int64 = 10
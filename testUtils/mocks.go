@@ -4,6 +4,7 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 	"my-todo-app/domain"
 	"strconv"
+	"strings"
 )
 
 func GetRepositoryMocks(action string, mock sqlmock.Sqlmock, expectedSQL string, id string, scenario domain.Scenario) {
@@ -13,33 +14,44 @@ func GetRepositoryMocks(action string, mock sqlmock.Sqlmock, expectedSQL string,
 	switch action {
 	case GetTaskByIdKey:
 		mock.ExpectQuery(expectedSQL).
-			WithArgs(id).
+			WithArgs(Caller.TenantId, Caller.UserId, Caller.UserId, id).
 			WillReturnRows(scenario.Rows).
 			WillReturnError(scenario.ScenarioErr)
+		expectTaskDetailsQueries(mock, scenario)
 
 	case GetAllTasksKey, SearchTaskKey:
 		mock.ExpectQuery(expectedSQL).
 			WillReturnRows(scenario.Rows).
 			WillReturnError(scenario.ScenarioErr)
+		expectTaskDetailsQueries(mock, scenario)
 
 	case CreateTaskKey:
 		mock.ExpectExec(expectedSQL).
 			WithArgs(scenario.Task.Title, scenario.Task.Description, scenario.Task.AddedOn,
-				scenario.Task.DueBy, scenario.Task.Status).
+				scenario.Task.DueBy, scenario.Task.Status, scenario.Task.ParentId, scenario.Task.Recurrence, scenario.Task.Priority, Caller.UserId, scenario.Task.ListId, Caller.TenantId).
 			WillReturnResult(sqlmock.NewResult(8, 1)).
 			WillReturnError(scenario.ScenarioErr)
 
 	case UpdateTaskKey:
 		mock.ExpectExec(expectedSQL).
 			WithArgs(scenario.Task.Title, scenario.Task.Description, scenario.Task.AddedOn,
-				scenario.Task.DueBy, scenario.Task.Status, scenario.Id).
+				scenario.Task.DueBy, scenario.Task.Status, scenario.Task.ParentId, scenario.Task.Recurrence, scenario.Task.Priority, scenario.Task.ListId, Caller.TenantId, Caller.UserId, Caller.UserId, scenario.Id).
 			WillReturnResult(sqlmock.NewResult(integerId, 1)).
 			WillReturnError(scenario.ScenarioErr)
 
 	case DeleteTaskKey:
-		mock.ExpectQuery(expectedSQL).WithArgs(id).
-			WillReturnRows(scenario.Rows).
+		var rowsAffected int64
+		if scenario.RowsAffected {
+			rowsAffected = 1
+		}
+		mock.ExpectExec(expectedSQL).WithArgs(DeletedOn, Caller.TenantId, Caller.UserId, Caller.UserId, id).
+			WillReturnResult(sqlmock.NewResult(0, rowsAffected)).
 			WillReturnError(scenario.ScenarioErr)
+		if scenario.RowsAffected && scenario.ScenarioErr == nil {
+			deletedId, _ := strconv.ParseInt(id, 10, 64)
+			mock.ExpectQuery("SELECT id FROM tasks WHERE parentId IN (?) AND deletedOn = 0").WithArgs(deletedId).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		}
 	}
 
 	if scenario.ScenarioErr == nil {
@@ -48,3 +60,19 @@ func GetRepositoryMocks(action string, mock sqlmock.Sqlmock, expectedSQL string,
 		mock.ExpectRollback()
 	}
 }
+
+// expectTaskDetailsQueries expects the tags and subtask rollups of the tasks
+// found by a successful query to be loaded.
+func expectTaskDetailsQueries(mock sqlmock.Sqlmock, scenario domain.Scenario) {
+	if scenario.ScenarioErr != nil || len(scenario.ExpectedTasks) == 0 {
+		return
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(scenario.ExpectedTasks)), ",")
+	mock.ExpectQuery("SELECT task_tags.taskId, tags.name FROM task_tags JOIN tags ON tags.id = task_tags.tagId " +
+		"WHERE task_tags.taskId IN (" + placeholders + ") ORDER BY tags.name").
+		WillReturnRows(sqlmock.NewRows([]string{"taskId", "name"}))
+	mock.ExpectQuery("SELECT parentId, COUNT(*), SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) FROM tasks " +
+		"WHERE parentId IN (" + placeholders + ") AND deletedOn = 0 GROUP BY parentId").
+		WillReturnRows(sqlmock.NewRows([]string{"parentId", "total", "done"}))
+}
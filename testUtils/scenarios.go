@@ -20,24 +20,27 @@ func GetRepositoryTestScenarios(action string) []domain.Scenario {
 					Title:       "sample",
 					Description: "sample",
 					Status:      "sample",
+					OwnerId:     1,
+					TenantId:    "acme",
+					Version:     1,
 				}},
 				Id:          "8",
-				Rows:        sqlmock.NewRows(columns).AddRow(8, "sample", "sample", 1, 1, "sample"),
-				ExpectedSQL: "SELECT * FROM tasks WHERE id = ?",
+				Rows:        sqlmock.NewRows(columns).AddRow(8, "sample", "sample", 1, 1, "sample", 0, "", "", 1, 0, "acme", 1, 0),
+				ExpectedSQL: "SELECT id, title, description, addedOn, dueBy, status, parentId, recurrence, priority, ownerId, listId, tenantId, version, deletedOn FROM tasks WHERE (tenantId = ? AND (ownerId = ? OR listId IN (SELECT listId FROM list_members WHERE userId = ?)) AND deletedOn = 0) AND id = ?",
 			},
 			{
 				Name:          "should get no tasks",
 				ExpectedTasks: []domain.Task{},
 				Id:            "8",
 				Rows:          sqlmock.NewRows(columns),
-				ExpectedSQL:   "SELECT * FROM tasks WHERE id = ?",
+				ExpectedSQL:   "SELECT id, title, description, addedOn, dueBy, status, parentId, recurrence, priority, ownerId, listId, tenantId, version, deletedOn FROM tasks WHERE (tenantId = ? AND (ownerId = ? OR listId IN (SELECT listId FROM list_members WHERE userId = ?)) AND deletedOn = 0) AND id = ?",
 			},
 			{
 				Name:          "should rollback tx for errors",
 				ExpectedTasks: []domain.Task{},
 				ScenarioErr:   errors.New("error occurred"),
 				Rows:          sqlmock.NewRows(columns),
-				ExpectedSQL:   "SELECT * FROM tasks WHERE id = ?",
+				ExpectedSQL:   "SELECT id, title, description, addedOn, dueBy, status, parentId, recurrence, priority, ownerId, listId, tenantId, version, deletedOn FROM tasks WHERE (tenantId = ? AND (ownerId = ? OR listId IN (SELECT listId FROM list_members WHERE userId = ?)) AND deletedOn = 0) AND id = ?",
 			},
 		}
 	case GetAllTasksKey:
@@ -45,33 +48,33 @@ func GetRepositoryTestScenarios(action string) []domain.Scenario {
 			{
 				Name: "should get all tasks with page number",
 				ExpectedTasks: []domain.Task{
-					{Id: 8, AddedOn: 1, DueBy: 1, Title: "sample", Description: "sample", Status: "sample"},
-					{Id: 88, AddedOn: 1, DueBy: 1, Title: "sample", Description: "sample", Status: "sample"},
+					{Id: 8, AddedOn: 1, DueBy: 1, Title: "sample", Description: "sample", Status: "sample", OwnerId: 1, TenantId: "acme", Version: 1},
+					{Id: 88, AddedOn: 1, DueBy: 1, Title: "sample", Description: "sample", Status: "sample", OwnerId: 1, TenantId: "acme", Version: 1},
 				},
 				Page:        1,
 				PerPage:     5,
-				ExpectedSQL: "SELECT * FROM tasks LIMIT 5 OFFSET 5",
+				ExpectedSQL: "SELECT id, title, description, addedOn, dueBy, status, parentId, recurrence, priority, ownerId, listId, tenantId, version, deletedOn FROM tasks WHERE (tenantId = ? AND (ownerId = ? OR listId IN (SELECT listId FROM list_members WHERE userId = ?)) AND deletedOn = 0) ORDER BY id LIMIT 5 OFFSET 5",
 				Rows: sqlmock.NewRows(columns).
-					AddRow(8, "sample", "sample", 1, 1, "sample").
-					AddRow(88, "sample", "sample", 1, 1, "sample"),
+					AddRow(8, "sample", "sample", 1, 1, "sample", 0, "", "", 1, 0, "acme", 1, 0).
+					AddRow(88, "sample", "sample", 1, 1, "sample", 0, "", "", 1, 0, "acme", 1, 0),
 			},
 			{
 				Name: "should get all tasks with -1 page",
 				ExpectedTasks: []domain.Task{
-					{Id: 8, AddedOn: 1, DueBy: 1, Title: "sample", Description: "sample", Status: "sample"},
+					{Id: 8, AddedOn: 1, DueBy: 1, Title: "sample", Description: "sample", Status: "sample", OwnerId: 1, TenantId: "acme", Version: 1},
 				},
 				Page:        -1,
 				PerPage:     1,
-				ExpectedSQL: "SELECT * FROM tasks",
+				ExpectedSQL: "SELECT id, title, description, addedOn, dueBy, status, parentId, recurrence, priority, ownerId, listId, tenantId, version, deletedOn FROM tasks WHERE (tenantId = ? AND (ownerId = ? OR listId IN (SELECT listId FROM list_members WHERE userId = ?)) AND deletedOn = 0) ORDER BY id",
 				Rows: sqlmock.NewRows(columns).
-					AddRow(8, "sample", "sample", 1, 1, "sample"),
+					AddRow(8, "sample", "sample", 1, 1, "sample", 0, "", "", 1, 0, "acme", 1, 0),
 			},
 			{
 				Name:          "should get no tasks",
 				ExpectedTasks: []domain.Task{},
 				Page:          1,
 				PerPage:       5,
-				ExpectedSQL:   "SELECT * FROM tasks LIMIT 5 OFFSET 5",
+				ExpectedSQL:   "SELECT id, title, description, addedOn, dueBy, status, parentId, recurrence, priority, ownerId, listId, tenantId, version, deletedOn FROM tasks WHERE (tenantId = ? AND (ownerId = ? OR listId IN (SELECT listId FROM list_members WHERE userId = ?)) AND deletedOn = 0) ORDER BY id LIMIT 5 OFFSET 5",
 				Rows:          sqlmock.NewRows(columns),
 			},
 			{
@@ -80,7 +83,7 @@ func GetRepositoryTestScenarios(action string) []domain.Scenario {
 				Page:          1,
 				PerPage:       5,
 				ScenarioErr:   errors.New("error occurred"),
-				ExpectedSQL:   "SELECT * FROM tasks LIMIT 5 OFFSET 5",
+				ExpectedSQL:   "SELECT id, title, description, addedOn, dueBy, status, parentId, recurrence, priority, ownerId, listId, tenantId, version, deletedOn FROM tasks WHERE (tenantId = ? AND (ownerId = ? OR listId IN (SELECT listId FROM list_members WHERE userId = ?)) AND deletedOn = 0) ORDER BY id LIMIT 5 OFFSET 5",
 				Rows:          sqlmock.NewRows(columns),
 			},
 		}
@@ -92,7 +95,7 @@ func GetRepositoryTestScenarios(action string) []domain.Scenario {
 					AddedOn: 1, DueBy: 1, Title: "sample", Description: "sample", Status: "sample",
 				},
 				InsertId:    8,
-				ExpectedSQL: "INSERT INTO tasks (title,description,addedOn,dueBy,status) VALUES (?,?,?,?,?)",
+				ExpectedSQL: "INSERT INTO tasks (title,description,addedOn,dueBy,status,parentId,recurrence,priority,ownerId,listId,tenantId) VALUES (?,?,?,?,?,?,?,?,?,?,?)",
 			},
 			{
 				Name: "should rollback tx for errors",
@@ -101,7 +104,7 @@ func GetRepositoryTestScenarios(action string) []domain.Scenario {
 				},
 				InsertId:    -1,
 				ScenarioErr: errors.New("error occurred"),
-				ExpectedSQL: "INSERT INTO tasks (title,description,addedOn,dueBy,status) VALUES (?,?,?,?,?)",
+				ExpectedSQL: "INSERT INTO tasks (title,description,addedOn,dueBy,status,parentId,recurrence,priority,ownerId,listId,tenantId) VALUES (?,?,?,?,?,?,?,?,?,?,?)",
 			},
 		}
 	case UpdateTaskKey:
@@ -112,7 +115,7 @@ func GetRepositoryTestScenarios(action string) []domain.Scenario {
 					Id: 8, AddedOn: 1, DueBy: 1, Title: "sample", Description: "sample", Status: "sample",
 				},
 				Id:          "8",
-				ExpectedSQL: "UPDATE tasks SET title = ?, description = ?, addedOn = ?, dueBy = ?, status = ? WHERE id = ?",
+				ExpectedSQL: "UPDATE tasks SET title = ?, description = ?, addedOn = ?, dueBy = ?, status = ?, parentId = ?, recurrence = ?, priority = ?, listId = ?, version = version + 1 WHERE (tenantId = ? AND (ownerId = ? OR listId IN (SELECT listId FROM list_members WHERE userId = ?)) AND deletedOn = 0) AND id = ?",
 			},
 			{
 				Name: "should rollback tx for errors",
@@ -121,7 +124,7 @@ func GetRepositoryTestScenarios(action string) []domain.Scenario {
 				},
 				Id:          "8",
 				ScenarioErr: errors.New("error occurred"),
-				ExpectedSQL: "UPDATE tasks SET title = ?, description = ?, addedOn = ?, dueBy = ?, status = ? WHERE id = ?",
+				ExpectedSQL: "UPDATE tasks SET title = ?, description = ?, addedOn = ?, dueBy = ?, status = ?, parentId = ?, recurrence = ?, priority = ?, listId = ?, version = version + 1 WHERE (tenantId = ? AND (ownerId = ? OR listId IN (SELECT listId FROM list_members WHERE userId = ?)) AND deletedOn = 0) AND id = ?",
 			},
 		}
 	case DeleteTaskKey:
@@ -129,23 +132,18 @@ func GetRepositoryTestScenarios(action string) []domain.Scenario {
 			{
 				Name:         "should delete task by id",
 				RowsAffected: true,
-				ExpectedSQL:  "DELETE FROM tasks WHERE id = ?",
-				Rows: sqlmock.NewRows(columns).
-					AddRow(8, "sample", "sample", 1, 1, "sample").
-					AddRow(88, "sample", "sample", 1, 1, "sample"),
+				ExpectedSQL:  "UPDATE tasks SET deletedOn = ?, version = version + 1 WHERE (tenantId = ? AND (ownerId = ? OR listId IN (SELECT listId FROM list_members WHERE userId = ?)) AND deletedOn = 0) AND id = ?",
 			},
 			{
 				Name:         "should not delete task if not present",
 				RowsAffected: false,
-				ExpectedSQL:  "DELETE FROM tasks WHERE id = ?",
-				Rows:         sqlmock.NewRows([]string{}),
+				ExpectedSQL:  "UPDATE tasks SET deletedOn = ?, version = version + 1 WHERE (tenantId = ? AND (ownerId = ? OR listId IN (SELECT listId FROM list_members WHERE userId = ?)) AND deletedOn = 0) AND id = ?",
 			},
 			{
 				Name:         "should rollback tx for errors",
 				ScenarioErr:  errors.New("error occurred"),
 				RowsAffected: false,
-				ExpectedSQL:  "DELETE FROM tasks WHERE id = ?",
-				Rows:         sqlmock.NewRows([]string{}),
+				ExpectedSQL:  "UPDATE tasks SET deletedOn = ?, version = version + 1 WHERE (tenantId = ? AND (ownerId = ? OR listId IN (SELECT listId FROM list_members WHERE userId = ?)) AND deletedOn = 0) AND id = ?",
 			},
 		}
 	case SearchTaskKey:
@@ -153,85 +151,85 @@ func GetRepositoryTestScenarios(action string) []domain.Scenario {
 			{
 				Name: "should get all tasks with id 8",
 				ExpectedTasks: []domain.Task{
-					{Id: 8, AddedOn: 1, DueBy: 1, Title: "sample", Description: "sample", Status: "done"},
+					{Id: 8, AddedOn: 1, DueBy: 1, Title: "sample", Description: "sample", Status: "done", OwnerId: 1, TenantId: "acme", Version: 1},
 				},
 				SearchParams: map[string]string{"id": "8"},
-				ExpectedSQL:  "SELECT * FROM tasks WHERE id = ? LIMIT 10 OFFSET 0",
+				ExpectedSQL:  "SELECT id, title, description, addedOn, dueBy, status, parentId, recurrence, priority, ownerId, listId, tenantId, version, deletedOn FROM tasks WHERE (tenantId = ? AND (ownerId = ? OR listId IN (SELECT listId FROM list_members WHERE userId = ?)) AND deletedOn = 0) AND id = ? ORDER BY id LIMIT 10 OFFSET 0",
 				Rows: sqlmock.NewRows(columns).
-					AddRow(8, "sample", "sample", 1, 1, "done"),
+					AddRow(8, "sample", "sample", 1, 1, "done", 0, "", "", 1, 0, "acme", 1, 0),
 			},
 			{
 				Name: "should get all tasks with addedOn before 10",
 				ExpectedTasks: []domain.Task{
-					{Id: 8, AddedOn: 1, DueBy: 1, Title: "sample", Description: "sample", Status: "done"},
-					{Id: 9, AddedOn: 1, DueBy: 1, Title: "sample", Description: "sample", Status: "done"},
+					{Id: 8, AddedOn: 1, DueBy: 1, Title: "sample", Description: "sample", Status: "done", OwnerId: 1, TenantId: "acme", Version: 1},
+					{Id: 9, AddedOn: 1, DueBy: 1, Title: "sample", Description: "sample", Status: "done", OwnerId: 1, TenantId: "acme", Version: 1},
 				},
 				SearchParams: map[string]string{"addedOnTo": "10"},
-				ExpectedSQL:  "SELECT * FROM tasks WHERE addedOn <= ? LIMIT 10 OFFSET 0",
+				ExpectedSQL:  "SELECT id, title, description, addedOn, dueBy, status, parentId, recurrence, priority, ownerId, listId, tenantId, version, deletedOn FROM tasks WHERE (tenantId = ? AND (ownerId = ? OR listId IN (SELECT listId FROM list_members WHERE userId = ?)) AND deletedOn = 0) AND addedOn <= ? ORDER BY id LIMIT 10 OFFSET 0",
 				Rows: sqlmock.NewRows(columns).
-					AddRow(8, "sample", "sample", 1, 1, "done").
-					AddRow(9, "sample", "sample", 1, 1, "done"),
+					AddRow(8, "sample", "sample", 1, 1, "done", 0, "", "", 1, 0, "acme", 1, 0).
+					AddRow(9, "sample", "sample", 1, 1, "done", 0, "", "", 1, 0, "acme", 1, 0),
 			},
 			{
 				Name: "should get all tasks with addedOn after 10",
 				ExpectedTasks: []domain.Task{
-					{Id: 8, AddedOn: 11, DueBy: 11, Title: "sample", Description: "sample", Status: "done"},
-					{Id: 9, AddedOn: 11, DueBy: 11, Title: "sample", Description: "sample", Status: "done"},
+					{Id: 8, AddedOn: 11, DueBy: 11, Title: "sample", Description: "sample", Status: "done", OwnerId: 1, TenantId: "acme", Version: 1},
+					{Id: 9, AddedOn: 11, DueBy: 11, Title: "sample", Description: "sample", Status: "done", OwnerId: 1, TenantId: "acme", Version: 1},
 				},
 				SearchParams: map[string]string{"addedOnFrom": "10"},
-				ExpectedSQL:  "SELECT * FROM tasks WHERE addedOn >= ? LIMIT 10 OFFSET 0",
+				ExpectedSQL:  "SELECT id, title, description, addedOn, dueBy, status, parentId, recurrence, priority, ownerId, listId, tenantId, version, deletedOn FROM tasks WHERE (tenantId = ? AND (ownerId = ? OR listId IN (SELECT listId FROM list_members WHERE userId = ?)) AND deletedOn = 0) AND addedOn >= ? ORDER BY id LIMIT 10 OFFSET 0",
 				Rows: sqlmock.NewRows(columns).
-					AddRow(8, "sample", "sample", 11, 11, "done").
-					AddRow(9, "sample", "sample", 11, 11, "done"),
+					AddRow(8, "sample", "sample", 11, 11, "done", 0, "", "", 1, 0, "acme", 1, 0).
+					AddRow(9, "sample", "sample", 11, 11, "done", 0, "", "", 1, 0, "acme", 1, 0),
 			},
 			{
 				Name: "should get all tasks with dueBy before 10",
 				ExpectedTasks: []domain.Task{
-					{Id: 8, AddedOn: 1, DueBy: 1, Title: "sample", Description: "sample", Status: "done"},
-					{Id: 9, AddedOn: 1, DueBy: 1, Title: "sample", Description: "sample", Status: "done"},
+					{Id: 8, AddedOn: 1, DueBy: 1, Title: "sample", Description: "sample", Status: "done", OwnerId: 1, TenantId: "acme", Version: 1},
+					{Id: 9, AddedOn: 1, DueBy: 1, Title: "sample", Description: "sample", Status: "done", OwnerId: 1, TenantId: "acme", Version: 1},
 				},
 				SearchParams: map[string]string{"dueByTo": "10"},
-				ExpectedSQL:  "SELECT * FROM tasks WHERE dueBy <= ? LIMIT 10 OFFSET 0",
+				ExpectedSQL:  "SELECT id, title, description, addedOn, dueBy, status, parentId, recurrence, priority, ownerId, listId, tenantId, version, deletedOn FROM tasks WHERE (tenantId = ? AND (ownerId = ? OR listId IN (SELECT listId FROM list_members WHERE userId = ?)) AND deletedOn = 0) AND dueBy <= ? ORDER BY id LIMIT 10 OFFSET 0",
 				Rows: sqlmock.NewRows(columns).
-					AddRow(8, "sample", "sample", 1, 1, "done").
-					AddRow(9, "sample", "sample", 1, 1, "done"),
+					AddRow(8, "sample", "sample", 1, 1, "done", 0, "", "", 1, 0, "acme", 1, 0).
+					AddRow(9, "sample", "sample", 1, 1, "done", 0, "", "", 1, 0, "acme", 1, 0),
 			},
 			{
 				Name: "should get all tasks with dueBy after 10",
 				ExpectedTasks: []domain.Task{
-					{Id: 8, AddedOn: 11, DueBy: 11, Title: "sample", Description: "sample", Status: "done"},
-					{Id: 9, AddedOn: 11, DueBy: 11, Title: "sample", Description: "sample", Status: "done"},
+					{Id: 8, AddedOn: 11, DueBy: 11, Title: "sample", Description: "sample", Status: "done", OwnerId: 1, TenantId: "acme", Version: 1},
+					{Id: 9, AddedOn: 11, DueBy: 11, Title: "sample", Description: "sample", Status: "done", OwnerId: 1, TenantId: "acme", Version: 1},
 				},
 				SearchParams: map[string]string{"dueByFrom": "10"},
-				ExpectedSQL:  "SELECT * FROM tasks WHERE dueBy >= ? LIMIT 10 OFFSET 0",
+				ExpectedSQL:  "SELECT id, title, description, addedOn, dueBy, status, parentId, recurrence, priority, ownerId, listId, tenantId, version, deletedOn FROM tasks WHERE (tenantId = ? AND (ownerId = ? OR listId IN (SELECT listId FROM list_members WHERE userId = ?)) AND deletedOn = 0) AND dueBy >= ? ORDER BY id LIMIT 10 OFFSET 0",
 				Rows: sqlmock.NewRows(columns).
-					AddRow(8, "sample", "sample", 11, 11, "done").
-					AddRow(9, "sample", "sample", 11, 11, "done"),
+					AddRow(8, "sample", "sample", 11, 11, "done", 0, "", "", 1, 0, "acme", 1, 0).
+					AddRow(9, "sample", "sample", 11, 11, "done", 0, "", "", 1, 0, "acme", 1, 0),
 			},
 			{
 				Name: "should get all tasks with status done",
 				ExpectedTasks: []domain.Task{
-					{Id: 8, AddedOn: 1, DueBy: 1, Title: "sample", Description: "sample", Status: "done"},
-					{Id: 9, AddedOn: 1, DueBy: 1, Title: "sample", Description: "sample", Status: "done"},
+					{Id: 8, AddedOn: 1, DueBy: 1, Title: "sample", Description: "sample", Status: "done", OwnerId: 1, TenantId: "acme", Version: 1},
+					{Id: 9, AddedOn: 1, DueBy: 1, Title: "sample", Description: "sample", Status: "done", OwnerId: 1, TenantId: "acme", Version: 1},
 				},
 				SearchParams: map[string]string{"status": "done"},
-				ExpectedSQL:  "SELECT * FROM tasks WHERE status = ? LIMIT 10 OFFSET 0",
+				ExpectedSQL:  "SELECT id, title, description, addedOn, dueBy, status, parentId, recurrence, priority, ownerId, listId, tenantId, version, deletedOn FROM tasks WHERE (tenantId = ? AND (ownerId = ? OR listId IN (SELECT listId FROM list_members WHERE userId = ?)) AND deletedOn = 0) AND status = ? ORDER BY id LIMIT 10 OFFSET 0",
 				Rows: sqlmock.NewRows(columns).
-					AddRow(8, "sample", "sample", 1, 1, "done").
-					AddRow(9, "sample", "sample", 1, 1, "done"),
+					AddRow(8, "sample", "sample", 1, 1, "done", 0, "", "", 1, 0, "acme", 1, 0).
+					AddRow(9, "sample", "sample", 1, 1, "done", 0, "", "", 1, 0, "acme", 1, 0),
 			},
 			{
 				Name:          "should get no tasks",
 				ExpectedTasks: []domain.Task{},
 				SearchParams:  map[string]string{"status": "unresolved"},
-				ExpectedSQL:   "SELECT * FROM tasks WHERE status = ? LIMIT 10 OFFSET 0",
+				ExpectedSQL:   "SELECT id, title, description, addedOn, dueBy, status, parentId, recurrence, priority, ownerId, listId, tenantId, version, deletedOn FROM tasks WHERE (tenantId = ? AND (ownerId = ? OR listId IN (SELECT listId FROM list_members WHERE userId = ?)) AND deletedOn = 0) AND status = ? ORDER BY id LIMIT 10 OFFSET 0",
 				Rows:          sqlmock.NewRows(columns),
 			},
 			{
 				Name:          "should default page to 0 and perPage to 10 when garbage value provided",
 				ExpectedTasks: []domain.Task{},
 				SearchParams:  map[string]string{"page": "-1", "perPage": "the simpsons"},
-				ExpectedSQL:   "SELECT * FROM tasks LIMIT 10 OFFSET 0",
+				ExpectedSQL:   "SELECT id, title, description, addedOn, dueBy, status, parentId, recurrence, priority, ownerId, listId, tenantId, version, deletedOn FROM tasks WHERE (tenantId = ? AND (ownerId = ? OR listId IN (SELECT listId FROM list_members WHERE userId = ?)) AND deletedOn = 0) ORDER BY id LIMIT 10 OFFSET 0",
 				Rows:          sqlmock.NewRows(columns),
 			},
 			{
@@ -239,7 +237,7 @@ func GetRepositoryTestScenarios(action string) []domain.Scenario {
 				ExpectedTasks: []domain.Task{},
 				SearchParams:  map[string]string{"page": "0"},
 				ScenarioErr:   errors.New("error occurred"),
-				ExpectedSQL:   "SELECT * FROM tasks LIMIT 10 OFFSET 0",
+				ExpectedSQL:   "SELECT id, title, description, addedOn, dueBy, status, parentId, recurrence, priority, ownerId, listId, tenantId, version, deletedOn FROM tasks WHERE (tenantId = ? AND (ownerId = ? OR listId IN (SELECT listId FROM list_members WHERE userId = ?)) AND deletedOn = 0) ORDER BY id LIMIT 10 OFFSET 0",
 				Rows:          sqlmock.NewRows(columns),
 			},
 		}
@@ -300,18 +298,18 @@ func GetServiceTestScenarios(action string) []domain.Scenario {
 			{
 				Name: "should successfully create task",
 				Task: domain.Task{
-					Id: 1, AddedOn: 123, DueBy: 123, Title: "sample", Description: "sample", Status: "sample",
+					Id: 1, AddedOn: 123, DueBy: 123, Title: "sample", Description: "sample", Status: "open", Priority: "P2",
 				},
-				Data:        []byte(`{"added_on": 123, "due_by": 123, "title": "sample", "description": "sample", "status": "sample"}`),
+				Data:        []byte(`{"added_on": 123, "due_by": 123, "title": "sample", "description": "sample", "status": "open"}`),
 				StatusCode:  http.StatusOK,
 				ScenarioErr: nil,
 			},
 			{
 				Name: "should create task overriding the id from request body",
 				Task: domain.Task{
-					Id: 1, AddedOn: 123, DueBy: 123, Title: "sample", Description: "sample", Status: "sample",
+					Id: 1, AddedOn: 123, DueBy: 123, Title: "sample", Description: "sample", Status: "open", Priority: "P2",
 				},
-				Data:        []byte(`{"id": 8, "added_on": 123, "due_by": 123, "title": "sample", "description": "sample", "status": "sample"}`),
+				Data:        []byte(`{"id": 8, "added_on": 123, "due_by": 123, "title": "sample", "description": "sample", "status": "open"}`),
 				StatusCode:  http.StatusOK,
 				ScenarioErr: nil,
 			},
@@ -323,7 +321,7 @@ func GetServiceTestScenarios(action string) []domain.Scenario {
 			},
 			{
 				Name:        "should throw 500 in create task for database errors",
-				Data:        []byte(`{"added_on": 123, "due_by": 123, "title": "sample", "description": "sample", "status": "sample"}`),
+				Data:        []byte(`{"added_on": 123, "due_by": 123, "title": "sample", "description": "sample", "status": "open"}`),
 				StatusCode:  http.StatusInternalServerError,
 				ScenarioErr: errors.New("error creating task in database"),
 			},
@@ -333,15 +331,15 @@ func GetServiceTestScenarios(action string) []domain.Scenario {
 			{
 				Name: "should successfully update a task",
 				Task: domain.Task{
-					Id: 1, AddedOn: 123, DueBy: 123, Title: "sample", Description: "sample", Status: "sample",
+					Id: 1, AddedOn: 123, DueBy: 123, Title: "sample", Description: "sample", Status: "open", Priority: "P2",
 				},
-				Data:        []byte(`{"id": 1, "added_on": 123, "due_by": 123, "title": "sample", "description": "sample", "status": "sample"}`),
+				Data:        []byte(`{"id": 1, "added_on": 123, "due_by": 123, "title": "sample", "description": "sample", "status": "open"}`),
 				StatusCode:  http.StatusOK,
 				ScenarioErr: nil,
 			},
 			{
 				Name:        "should throw 400 in update task if IDs are different in URL and request body",
-				Data:        []byte(`{"id": 8, "added_on": 123, "due_by": 123, "title": "sample", "description": "sample", "status": "sample"}`),
+				Data:        []byte(`{"id": 8, "added_on": 123, "due_by": 123, "title": "sample", "description": "sample", "status": "open"}`),
 				StatusCode:  http.StatusBadRequest,
 				ScenarioErr: nil,
 			},